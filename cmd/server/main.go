@@ -24,20 +24,28 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/convexwf/uim-go/internal/api"
 	"github.com/convexwf/uim-go/internal/config"
+	"github.com/convexwf/uim-go/internal/fanout"
 	"github.com/convexwf/uim-go/internal/pkg/jwt"
+	"github.com/convexwf/uim-go/internal/pkg/mailer"
+	"github.com/convexwf/uim-go/internal/pkg/oidc"
 	"github.com/convexwf/uim-go/internal/repository"
 	"github.com/convexwf/uim-go/internal/service"
 	"github.com/convexwf/uim-go/internal/store"
+	"github.com/convexwf/uim-go/internal/webhook"
 	"github.com/convexwf/uim-go/internal/websocket"
 	"github.com/redis/go-redis/v9"
 
@@ -91,11 +99,10 @@ func main() {
 	}
 
 	// Initialize JWT manager
-	jwtManager := jwt.NewJWTManager(
-		cfg.JWT.Secret,
-		cfg.JWT.AccessExpiry,
-		cfg.JWT.RefreshExpiry,
-	)
+	jwtManager, err := initJWTManager(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize JWT keys: %v", err)
+	}
 
 	// Initialize Redis (optional: on failure, offline queue and presence are disabled)
 	var redisClient *redis.Client
@@ -112,32 +119,154 @@ func main() {
 
 	var offlineQueue store.OfflineQueue
 	var presenceStore store.PresenceStore
+	var revocations store.RevocationStore = store.NewMemoryRevocationStore()
+	var loginAttempts store.LoginAttemptStore = store.NewMemoryLoginAttemptStore()
+	var limiter store.RateLimiter = store.NewMemoryRateLimiter()
+	var oidcStates store.OIDCStateStore = store.NewMemoryOIDCStateStore()
 	if redisClient != nil {
 		offlineQueue = store.NewRedisOfflineQueue(redisClient)
 		presenceStore = store.NewRedisPresenceStore(redisClient)
+		revocations = store.NewRedisRevocationStore(redisClient)
+		loginAttempts = store.NewRedisLoginAttemptStore(redisClient)
+		limiter = store.NewRedisRateLimiter(redisClient)
+		oidcStates = store.NewRedisOIDCStateStore(redisClient)
+	}
+	if !cfg.RateLimit.Enabled {
+		limiter = nil
 	}
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	convRepo := repository.NewConversationRepository(db)
+	if cfg.Membership.Enabled {
+		localMembership := store.NewMemoryMembershipCache(cfg.Membership.Size, cfg.Membership.MaxMembers, cfg.Membership.TTL)
+		var membership store.MembershipCache = localMembership
+		if redisClient != nil {
+			shared := store.NewRedisMembershipCache(redisClient, localMembership, cfg.Membership.TTL)
+			go shared.Listen(context.Background())
+			membership = shared
+		}
+		convRepo = repository.NewCachedConversationRepository(convRepo, membership)
+	}
 	contactRepo := repository.NewContactRepository(db)
 	msgRepo := repository.NewMessageRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	botRepo := repository.NewBotRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	incomingRepo := repository.NewIncomingWebhookRepository(db)
+	botCommandRepo := repository.NewBotCommandRepository(db)
+	pinRepo := repository.NewPinnedMessageRepository(db)
+	inviteRepo := repository.NewInviteRepository(db)
+
+	mail, closeMail, err := initMailer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	defer closeMail()
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, jwtManager)
-	convSvc := service.NewConversationService(convRepo, userRepo, msgRepo)
-	contactSvc := service.NewContactService(contactRepo, userRepo, presenceStore)
 	hub := websocket.NewHub(convRepo, offlineQueue)
-	msgSvc := service.NewMessageService(msgRepo, convSvc, hub)
-	router := api.SetupRouter(cfg, db, authService, jwtManager, convSvc, contactSvc, msgSvc, hub, redisClient, offlineQueue, presenceStore)
+	loginGuard := service.NewLoginGuard(loginAttempts, service.LoginPolicy{
+		MaxUserFailures: cfg.RateLimit.LoginUserFailures,
+		MaxIPFailures:   cfg.RateLimit.LoginIPFailures,
+		Window:          cfg.RateLimit.LoginWindow,
+		BaseLockout:     cfg.RateLimit.LoginLockoutBase,
+		MaxLockout:      cfg.RateLimit.LoginLockoutMax,
+	}, nil)
+	oidcLogin := initOIDC(cfg, identityRepo, oidcStates)
+	contactSvc := service.NewContactService(contactRepo, userRepo, presenceStore)
+	// The WebSocket hub is fed through a worker pool, so sending does not wait for the
+	// participant lookup and offline queue pushes. Outgoing webhooks receive new messages and
+	// conversation events from the same hook points.
+	fanoutPool := fanout.NewPool(hub, fanout.Options{
+		Workers:   cfg.Fanout.Workers,
+		QueueSize: cfg.Fanout.QueueSize,
+	})
+	fanoutPool.Start()
+	messageNotifiers := service.MessageNotifiers{fanoutPool}
+	eventNotifiers := service.ConversationEventNotifiers{fanoutPool}
+	var webhookSvc service.WebhookService
+	var dispatcher *webhook.Dispatcher
+	if cfg.Webhook.Enabled {
+		dispatcher = webhook.NewDispatcher(webhookRepo, nil, webhook.Options{
+			Workers:              cfg.Webhook.Workers,
+			QueueSize:            cfg.Webhook.QueueSize,
+			MaxAttempts:          cfg.Webhook.MaxAttempts,
+			RetryBaseDelay:       cfg.Webhook.RetryBaseDelay,
+			RetryMaxDelay:        cfg.Webhook.RetryMaxDelay,
+			Timeout:              cfg.Webhook.Timeout,
+			AllowPrivateNetworks: cfg.Webhook.AllowPrivateNetworks,
+		})
+		dispatcher.Start()
+		messageNotifiers = append(messageNotifiers, dispatcher)
+		eventNotifiers = append(eventNotifiers, dispatcher)
+		webhookSvc = service.NewWebhookService(webhookRepo, convRepo, service.WebhookOptions{
+			RequireHTTPS:         cfg.IsProduction(),
+			AllowPrivateNetworks: cfg.Webhook.AllowPrivateNetworks,
+		})
+	}
+	convSvc := service.NewConversationService(convRepo, userRepo, msgRepo, pinRepo, inviteRepo, limiter, eventNotifiers, messageNotifiers, service.ConversationOptions{
+		MentionAllLimit: store.RateLimit{PerHour: cfg.RateLimit.MentionAll},
+		MaxPins:         cfg.Conversation.MaxPins,
+	})
+	authService := service.NewAuthService(userRepo, sessionRepo, userTokenRepo, mfaRepo, jwtManager, service.AuthOptions{
+		RequireVerifiedEmail: cfg.Auth.RequireVerifiedEmail,
+		PublicURL:            cfg.App.PublicURL,
+		VerifyEmailTTL:       cfg.Auth.VerifyEmailTTL,
+		PasswordResetTTL:     cfg.Auth.PasswordResetTTL,
+		TOTPIssuer:           cfg.Auth.TOTPIssuer,
+		AccountListener:      convSvc,
+		SessionNotifier:      hub,
+		Revocations:          revocations,
+		Mailer:               mail,
+		LoginGuard:           loginGuard,
+		OIDC:                 oidcLogin,
+	})
+	msgSvc := service.NewMessageService(msgRepo, convSvc, messageNotifiers)
+	botSvc := service.NewBotService(botRepo, userRepo)
+	incomingSvc := service.NewIncomingWebhookService(incomingRepo, convRepo, userRepo, msgSvc)
+	cmdSvc := service.NewCommandService(botCommandRepo, convRepo, userRepo, convSvc, msgSvc, hub)
+	router := api.SetupRouter(cfg, db, authService, jwtManager, convSvc, contactSvc, msgSvc, hub, redisClient, offlineQueue, presenceStore, api.RouterDeps{
+		Revocations:      revocations,
+		Limiter:          limiter,
+		Commands:         cmdSvc,
+		Bots:             botSvc,
+		Webhooks:         webhookSvc,
+		IncomingWebhooks: incomingSvc,
+		Fanout:           fanoutPool,
+	})
 
-	// Start server
-	log.Printf("Server starting on port %s", cfg.App.Port)
-	if err := router.Run(":" + cfg.App.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Start server; on SIGINT/SIGTERM stop accepting requests, then drain the fan-out pool so
+	// queued deliveries (including offline queue pushes) are not lost, and stop the webhook
+	// dispatcher (pending retries resume on the next start).
+	srv := &http.Server{Addr: ":" + cfg.App.Port, Handler: router}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		log.Printf("Server starting on port %s", cfg.App.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+	<-ctx.Done()
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+	fanoutPool.Stop()
+	if dispatcher != nil {
+		dispatcher.Stop()
 	}
 }
 
+// shutdownTimeout bounds how long in-flight requests may run after a shutdown signal.
+const shutdownTimeout = 10 * time.Second
+
 // dbLogWriter prefixes each log line with [DB] for consistent log format.
 type dbLogWriter struct {
 	w      io.Writer
@@ -257,3 +386,80 @@ func stripSQLComments(s string) string {
 	}
 	return strings.TrimSpace(b.String())
 }
+
+// initMailer creates the configured mailer. It returns a nil Mailer for MAIL_DRIVER=none.
+func initMailer(cfg *config.Config) (mailer.Mailer, func(), error) {
+	switch cfg.Mail.Driver {
+	case config.MailDriverSMTP:
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+			From:     cfg.Mail.From,
+		}), func() {}, nil
+	case config.MailDriverLog:
+		if cfg.Mail.LogFile == "" {
+			return mailer.NewLogMailer(nil), func() {}, nil
+		}
+		f, err := os.OpenFile(cfg.Mail.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open mail log file: %w", err)
+		}
+		return mailer.NewLogMailer(f), func() { _ = f.Close() }, nil
+	default:
+		log.Print("[AUTH] MAIL_DRIVER=none: email verification and password reset are disabled")
+		return nil, func() {}, nil
+	}
+}
+
+// initOIDC creates the single sign-on providers. It returns nil when none are configured.
+// Provider discovery happens on first use, so an unreachable provider does not block startup.
+func initOIDC(cfg *config.Config, identities repository.IdentityRepository, states store.OIDCStateStore) *service.OIDCLogin {
+	if len(cfg.OIDC.Providers) == 0 {
+		return nil
+	}
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	providers := make([]service.OIDCProvider, 0, len(cfg.OIDC.Providers))
+	for _, p := range cfg.OIDC.Providers {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		}, httpClient))
+		log.Printf("[AUTH] oidc provider enabled name=%s issuer=%s", p.Name, p.Issuer)
+	}
+	return service.NewOIDCLogin(identities, states, providers...)
+}
+
+// initJWTManager creates the JWT manager for the configured algorithm. For RS256 / EdDSA the
+// keys come from JWT_KEYS_DIR, which is watched for new keys and scheduled rotation.
+func initJWTManager(cfg *config.Config) (*jwt.JWTManager, error) {
+	if cfg.JWT.Algorithm == jwt.AlgorithmHS256 {
+		return jwt.NewJWTManager(cfg.JWT.Secret, cfg.JWT.AccessExpiry, cfg.JWT.RefreshExpiry), nil
+	}
+	if cfg.JWT.KeysDir == "" {
+		return nil, fmt.Errorf("JWT_KEYS_DIR is required for JWT_ALGORITHM=%s", cfg.JWT.Algorithm)
+	}
+	if err := os.MkdirAll(cfg.JWT.KeysDir, 0700); err != nil {
+		return nil, fmt.Errorf("create key dir: %w", err)
+	}
+	keyDir := jwt.KeyDir{
+		Dir:             cfg.JWT.KeysDir,
+		Algorithm:       cfg.JWT.Algorithm,
+		RotateEvery:     cfg.JWT.KeyRotationInterval,
+		ActivationDelay: cfg.JWT.KeyActivationDelay,
+	}
+	manager, err := jwt.NewJWTManagerFromKeyDir(keyDir, cfg.JWT.LegacySecret, cfg.JWT.AccessExpiry, cfg.JWT.RefreshExpiry)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[AUTH] signing with %s key kid=%s", cfg.JWT.Algorithm, manager.ActiveKeyID())
+	if cfg.JWT.KeyReloadInterval > 0 {
+		go jwt.WatchKeyDir(context.Background(), manager, keyDir, cfg.JWT.KeyReloadInterval)
+	}
+	return manager, nil
+}
@@ -23,10 +23,14 @@
 package api
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
 	"github.com/convexwf/uim-go/internal/service"
 )
@@ -45,15 +49,17 @@ func NewAuthHandler(authService service.AuthService) *AuthHandler {
 
 // RegisterRequest represents a user registration request.
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=50"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Username   string `json:"username" binding:"required,min=3,max=50"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=6"`
+	DeviceName string `json:"device_name"`
 }
 
 // LoginRequest represents a user login request.
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"`
 }
 
 // RefreshTokenRequest represents a token refresh request.
@@ -61,11 +67,85 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
+// ChangePasswordRequest represents a password change request.
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+	DeviceName  string `json:"device_name"`
+}
+
+// DeleteAccountRequest represents an account deletion request (password confirmation).
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// SessionItem is the API shape for one login session.
+type SessionItem struct {
+	SessionID  string    `json:"session_id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+// VerifyEmailRequest represents an email verification request (token from the emailed link).
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// PasswordResetRequest asks for a password reset email.
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password using the token from the reset email.
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// OIDCCallbackRequest completes a single sign-on login with the code and state the provider
+// redirected back with.
+type OIDCCallbackRequest struct {
+	Code       string `json:"code" binding:"required"`
+	State      string `json:"state" binding:"required"`
+	DeviceName string `json:"device_name"`
+}
+
+// MFALoginRequest completes a two-step login with a TOTP or recovery code.
+type MFALoginRequest struct {
+	MFAToken   string `json:"mfa_token" binding:"required"`
+	Code       string `json:"code" binding:"required"`
+	DeviceName string `json:"device_name"`
+}
+
+// MFACodeRequest carries a TOTP (or recovery) code.
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableMFARequest turns 2FA off (password and a current code).
+type DisableMFARequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// MFAChallengeResponse is returned by login when a second factor is required.
+type MFAChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
 // AuthResponse represents an authentication response with user and tokens.
 type AuthResponse struct {
 	User         interface{} `json:"user"`
 	AccessToken  string      `json:"access_token"`
 	RefreshToken string      `json:"refresh_token"`
+	// EmailVerificationRequired is set on register when login needs a verified email (no tokens issued).
+	EmailVerificationRequired bool `json:"email_verification_required,omitempty"`
 }
 
 // Register handles user registration.
@@ -92,7 +172,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 	log.Printf("[AUTH] register attempt username=%s", req.Username)
 
-	user, accessToken, refreshToken, err := h.authService.Register(req.Username, req.Email, req.Password)
+	user, accessToken, refreshToken, err := h.authService.Register(req.Username, req.Email, req.Password, clientInfo(c, req.DeviceName))
 	if err != nil {
 		switch err {
 		case service.ErrUserExists:
@@ -110,9 +190,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	log.Printf("[AUTH] register success username=%s", req.Username)
 
 	c.JSON(http.StatusCreated, AuthResponse{
-		User:         user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		User:                      user,
+		AccessToken:               accessToken,
+		RefreshToken:              refreshToken,
+		EmailVerificationRequired: accessToken == "",
 	})
 }
 
@@ -129,6 +210,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Param request body LoginRequest true "Login request"
 // @Success 200 {object} AuthResponse
 // @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Router /api/auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
@@ -139,12 +221,25 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 	log.Printf("[AUTH] login attempt username=%s", req.Username)
 
-	user, accessToken, refreshToken, err := h.authService.Login(req.Username, req.Password)
+	user, accessToken, refreshToken, err := h.authService.Login(req.Username, req.Password, clientInfo(c, req.DeviceName))
+	var mfaErr *service.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		log.Printf("[AUTH] login mfa required username=%s", req.Username)
+		c.JSON(http.StatusOK, MFAChallengeResponse{MFARequired: true, MFAToken: mfaErr.PendingToken, ExpiresAt: mfaErr.ExpiresAt})
+		return
+	}
+	if tooMany(c, err) {
+		log.Printf("[AUTH] login failed username=%s reason=locked_out", req.Username)
+		return
+	}
 	if err != nil {
 		switch err {
 		case service.ErrInvalidCredentials:
 			log.Printf("[AUTH] login failed username=%s reason=invalid_credentials", req.Username)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		case service.ErrEmailNotVerified:
+			log.Printf("[AUTH] login failed username=%s reason=email_not_verified", req.Username)
+			c.JSON(http.StatusForbidden, gin.H{"error": "email not verified"})
 		default:
 			log.Printf("[AUTH] login failed username=%s reason=internal %v", req.Username, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
@@ -182,7 +277,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 	log.Printf("[AUTH] refresh attempt")
 
-	user, accessToken, refreshToken, err := h.authService.RefreshToken(req.RefreshToken)
+	user, accessToken, refreshToken, err := h.authService.RefreshToken(req.RefreshToken, clientInfo(c, ""))
 	if err != nil {
 		log.Printf("[AUTH] refresh failed reason=invalid_token")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
@@ -211,3 +306,481 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, user)
 }
+
+// ListSessions lists the current user's active login sessions.
+// GET /api/auth/sessions
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	currentID := getSessionIDFromContext(c)
+	sessions, err := h.authService.ListSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+	items := make([]SessionItem, len(sessions))
+	for i, s := range sessions {
+		items[i] = SessionItem{
+			SessionID:  s.SessionID.String(),
+			DeviceName: s.DeviceName,
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IPAddress,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			Current:    s.SessionID == currentID,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": items})
+}
+
+// RevokeSession revokes one of the current user's sessions and closes its WebSocket connections.
+// DELETE /api/auth/sessions/:id
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return
+	}
+	if err := h.authService.RevokeSession(userID, sessionID); err != nil {
+		if err == service.ErrSessionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		log.Printf("[AUTH] revoke session failed session_id=%s reason=internal %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+	log.Printf("[AUTH] session revoked session_id=%s", sessionID)
+	c.Status(http.StatusNoContent)
+}
+
+// Logout ends the current session and revokes the access token used for this request.
+// POST /api/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	tokenID := c.GetString("token_id")
+	if err := h.authService.Logout(userID, getSessionIDFromContext(c), tokenID); err != nil {
+		log.Printf("[AUTH] logout failed user_id=%s reason=internal %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+		return
+	}
+	log.Printf("[AUTH] logout success user_id=%s", userID)
+	c.Status(http.StatusNoContent)
+}
+
+// ChangePassword changes the current user's password. All existing sessions are revoked and
+// new tokens for a fresh session are returned.
+// POST /api/auth/password
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	accessToken, refreshToken, err := h.authService.ChangePassword(userID, req.OldPassword, req.NewPassword, clientInfo(c, req.DeviceName))
+	if err != nil {
+		switch {
+		case err == service.ErrInvalidCredentials:
+			log.Printf("[AUTH] change password failed user_id=%s reason=invalid_credentials", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		case errors.Is(err, service.ErrInvalidInput):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("[AUTH] change password failed user_id=%s reason=internal %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	log.Printf("[AUTH] change password success user_id=%s", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
+}
+
+// DeleteAccount deletes the current user's account and revokes all of its sessions and tokens.
+// DELETE /api/auth/me
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.authService.DeleteAccount(userID, req.Password); err != nil {
+		switch err {
+		case service.ErrInvalidCredentials:
+			log.Printf("[AUTH] delete account failed user_id=%s reason=invalid_credentials", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		case service.ErrUserNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			log.Printf("[AUTH] delete account failed user_id=%s reason=internal %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	log.Printf("[AUTH] account deleted user_id=%s", userID)
+	c.Status(http.StatusNoContent)
+}
+
+// clientInfo collects the device details recorded on a login session.
+func clientInfo(c *gin.Context, deviceName string) service.ClientInfo {
+	return service.ClientInfo{
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+	}
+}
+
+// RequestEmailVerification re-sends the verification email to the current user.
+// POST /api/auth/verify-email/request
+func (h *AuthHandler) RequestEmailVerification(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	switch err := h.authService.RequestEmailVerification(userID); err {
+	case nil:
+		c.Status(http.StatusAccepted)
+	case service.ErrEmailAlreadyVerified:
+		c.JSON(http.StatusConflict, gin.H{"error": "email already verified"})
+	case service.ErrMailerUnavailable:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "email delivery is not configured"})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	default:
+		log.Printf("[AUTH] verification email failed user_id=%s reason=internal %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// VerifyEmail consumes an email verification token.
+// POST /api/auth/verify-email
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := h.authService.VerifyEmail(req.Token)
+	if err != nil {
+		if err == service.ErrInvalidToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+			return
+		}
+		log.Printf("[AUTH] verify email failed reason=internal %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// RequestPasswordReset emails a password reset link. It answers 202 whether or not the
+// address is registered.
+// POST /api/auth/password-reset/request
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var req PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch err := h.authService.RequestPasswordReset(req.Email); err {
+	case nil:
+		c.Status(http.StatusAccepted)
+	case service.ErrMailerUnavailable:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "email delivery is not configured"})
+	default:
+		log.Printf("[AUTH] password reset request failed reason=internal %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// ResetPassword sets a new password using a reset token; all sessions of the user are revoked.
+// POST /api/auth/password-reset
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := h.authService.ResetPassword(req.Token, req.NewPassword)
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case err == service.ErrInvalidToken:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+	case errors.Is(err, service.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[AUTH] password reset failed reason=internal %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// tooMany writes 429 with a Retry-After header (whole seconds, rounded up) if err is a
+// login lockout. Returns true if the response was written.
+func tooMany(c *gin.Context, err error) bool {
+	var lockErr *service.TooManyAttemptsError
+	if !errors.As(err, &lockErr) {
+		return false
+	}
+	retryAfter := int((lockErr.RetryAfter + time.Second - 1) / time.Second)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, try again later", "retry_after": retryAfter})
+	return true
+}
+
+// LoginMFA completes a login that returned mfa_required, exchanging the mfa_token and a
+// TOTP or recovery code for tokens.
+// POST /api/auth/login/mfa
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, accessToken, refreshToken, err := h.authService.CompleteMFALogin(req.MFAToken, req.Code, clientInfo(c, req.DeviceName))
+	if tooMany(c, err) {
+		return
+	}
+	if err != nil {
+		switch err {
+		case service.ErrInvalidMFACode:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid two-factor code"})
+		case service.ErrInvalidCredentials:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "mfa token invalid or expired, login again"})
+		default:
+			log.Printf("[AUTH] mfa login failed reason=internal %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	log.Printf("[AUTH] login success (mfa) user_id=%s", user.UserID)
+	c.JSON(http.StatusOK, AuthResponse{
+		User:         user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	})
+}
+
+// MFAStatus returns whether 2FA is enabled for the current user.
+// GET /api/auth/mfa
+func (h *AuthHandler) MFAStatus(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	status, err := h.authService.GetMFAStatus(userID)
+	if err != nil {
+		log.Printf("[AUTH] mfa status failed user_id=%s reason=internal %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": status.Enabled, "recovery_codes_left": status.RecoveryCodesLeft})
+}
+
+// EnrollTOTP starts TOTP enrollment and returns the secret and otpauth:// URI.
+// POST /api/auth/mfa/totp
+func (h *AuthHandler) EnrollTOTP(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	enrollment, err := h.authService.EnrollTOTP(userID)
+	if err != nil {
+		h.writeMFAError(c, userID, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"secret": enrollment.Secret, "provisioning_uri": enrollment.URI})
+}
+
+// ConfirmTOTP enables 2FA with a first code and returns the recovery codes (shown once).
+// POST /api/auth/mfa/totp/confirm
+func (h *AuthHandler) ConfirmTOTP(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codes, err := h.authService.ConfirmTOTP(userID, req.Code)
+	if err != nil {
+		h.writeMFAError(c, userID, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTP turns 2FA off.
+// DELETE /api/auth/mfa/totp
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req DisableMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.authService.DisableTOTP(userID, req.Password, req.Code); err != nil {
+		h.writeMFAError(c, userID, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes (requires a current TOTP code).
+// POST /api/auth/mfa/recovery-codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codes, err := h.authService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		h.writeMFAError(c, userID, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// writeMFAError maps 2FA management errors to HTTP responses.
+func (h *AuthHandler) writeMFAError(c *gin.Context, userID uuid.UUID, err error) {
+	switch err {
+	case service.ErrInvalidMFACode:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid two-factor code"})
+	case service.ErrInvalidCredentials:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	case service.ErrMFAAlreadyEnabled:
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication already enabled"})
+	case service.ErrMFANotEnabled:
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication not enabled"})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	default:
+		log.Printf("[AUTH] mfa request failed user_id=%s reason=internal %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// OIDCProviders lists the configured single sign-on providers.
+// GET /api/auth/oidc/providers
+func (h *AuthHandler) OIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.authService.OIDCProviders()})
+}
+
+// OIDCAuthorize starts a single sign-on login and returns the provider's authorization URL.
+// With ?redirect=true the response is a 302 to that URL instead (for plain browser links).
+// GET /api/auth/oidc/:provider/authorize
+func (h *AuthHandler) OIDCAuthorize(c *gin.Context) {
+	provider := c.Param("provider")
+	authz, err := h.authService.StartOIDCLogin(provider)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown identity provider"})
+		case errors.Is(err, service.ErrOIDCAuthFailed):
+			c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		default:
+			log.Printf("[AUTH] oidc authorize failed provider=%s reason=internal %v", provider, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	if c.Query("redirect") == "true" {
+		c.Redirect(http.StatusFound, authz.AuthURL)
+		return
+	}
+	c.JSON(http.StatusOK, authz)
+}
+
+// OIDCCallback completes a single sign-on login. The provider's redirect can target this
+// endpoint directly (GET with code and state query parameters), or the client app receives
+// the redirect and posts code and state as JSON.
+// GET/POST /api/auth/oidc/:provider/callback
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	provider := c.Param("provider")
+	var req OIDCCallbackRequest
+	if c.Request.Method == http.MethodGet {
+		if errCode := c.Query("error"); errCode != "" {
+			log.Printf("[AUTH] oidc login failed provider=%s reason=provider_error %s", provider, errCode)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "login cancelled or denied by identity provider", "provider_error": errCode})
+			return
+		}
+		req = OIDCCallbackRequest{Code: c.Query("code"), State: c.Query("state")}
+		if req.Code == "" || req.State == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, accessToken, refreshToken, err := h.authService.CompleteOIDCLogin(provider, req.State, req.Code, clientInfo(c, req.DeviceName))
+	var mfaErr *service.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		c.JSON(http.StatusOK, MFAChallengeResponse{MFARequired: true, MFAToken: mfaErr.PendingToken, ExpiresAt: mfaErr.ExpiresAt})
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown identity provider"})
+		case errors.Is(err, service.ErrInvalidOIDCState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "login state invalid or expired, start again"})
+		case errors.Is(err, service.ErrOIDCAuthFailed):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "identity provider authentication failed"})
+		case errors.Is(err, service.ErrIdentityConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": "email not verified"})
+		default:
+			log.Printf("[AUTH] oidc login failed provider=%s reason=internal %v", provider, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, AuthResponse{
+		User:         user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	})
+}
@@ -0,0 +1,248 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: bot_handler.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: HTTP handlers for managing bots and their API keys
+
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/service"
+)
+
+// BotHandler handles bot management requests. All routes act on bots owned by the caller.
+type BotHandler struct {
+	botSvc service.BotService
+}
+
+// NewBotHandler creates a new bot handler.
+func NewBotHandler(botSvc service.BotService) *BotHandler {
+	return &BotHandler{botSvc: botSvc}
+}
+
+// CreateBotRequest is the body for creating a bot.
+type CreateBotRequest struct {
+	Username    string `json:"username" binding:"required,min=3,max=50"`
+	DisplayName string `json:"display_name"`
+}
+
+// CreateAPIKeyRequest is the body for issuing a bot API key. ExpiresInDays 0 means no expiry.
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// BotItem is the API shape for one bot.
+type BotItem struct {
+	BotID       string    `json:"bot_id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// APIKeyItem is the API shape for one API key; the plaintext key is never listed.
+type APIKeyItem struct {
+	KeyID      string     `json:"key_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateBot creates a bot owned by the current user.
+// POST /api/bots
+func (h *BotHandler) CreateBot(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req CreateBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	bot, err := h.botSvc.CreateBot(userID, req.Username, req.DisplayName)
+	if err != nil {
+		h.writeError(c, err, "failed to create bot")
+		return
+	}
+	c.JSON(http.StatusCreated, botToItem(bot))
+}
+
+// ListBots lists the current user's bots.
+// GET /api/bots
+func (h *BotHandler) ListBots(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	bots, err := h.botSvc.ListBots(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list bots"})
+		return
+	}
+	items := make([]BotItem, len(bots))
+	for i, b := range bots {
+		items[i] = botToItem(b)
+	}
+	c.JSON(http.StatusOK, gin.H{"bots": items})
+}
+
+// DeleteBot deletes one of the current user's bots and revokes its keys.
+// DELETE /api/bots/:id
+func (h *BotHandler) DeleteBot(c *gin.Context) {
+	userID, botID, ok := h.ownerAndBot(c)
+	if !ok {
+		return
+	}
+	if err := h.botSvc.DeleteBot(userID, botID); err != nil {
+		h.writeError(c, err, "failed to delete bot")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateAPIKey issues an API key for a bot. The plaintext key is only in this response.
+// POST /api/bots/:id/keys
+func (h *BotHandler) CreateAPIKey(c *gin.Context) {
+	userID, botID, ok := h.ownerAndBot(c)
+	if !ok {
+		return
+	}
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must not be negative"})
+		return
+	}
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	key, plaintext, err := h.botSvc.CreateAPIKey(userID, botID, req.Name, req.Scopes, ttl)
+	if err != nil {
+		h.writeError(c, err, "failed to create api key")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"key": plaintext, "api_key": apiKeyToItem(key)})
+}
+
+// ListAPIKeys lists a bot's API keys, revoked ones included.
+// GET /api/bots/:id/keys
+func (h *BotHandler) ListAPIKeys(c *gin.Context) {
+	userID, botID, ok := h.ownerAndBot(c)
+	if !ok {
+		return
+	}
+	keys, err := h.botSvc.ListAPIKeys(userID, botID)
+	if err != nil {
+		h.writeError(c, err, "failed to list api keys")
+		return
+	}
+	items := make([]APIKeyItem, len(keys))
+	for i, k := range keys {
+		items[i] = apiKeyToItem(k)
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": items})
+}
+
+// RevokeAPIKey revokes a bot API key.
+// DELETE /api/bots/:id/keys/:key_id
+func (h *BotHandler) RevokeAPIKey(c *gin.Context) {
+	userID, botID, ok := h.ownerAndBot(c)
+	if !ok {
+		return
+	}
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid key id"})
+		return
+	}
+	if err := h.botSvc.RevokeAPIKey(userID, botID, keyID); err != nil {
+		h.writeError(c, err, "failed to revoke api key")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ownerAndBot parses the caller and the :id bot parameter, writing the error response on failure.
+func (h *BotHandler) ownerAndBot(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return uuid.Nil, uuid.Nil, false
+	}
+	botID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bot id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, botID, true
+}
+
+func (h *BotHandler) writeError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrBotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "bot not found"})
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+	case errors.Is(err, service.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "username already taken"})
+	case errors.Is(err, service.ErrBotLimitReached), errors.Is(err, service.ErrAPIKeyLimit):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidInput), errors.Is(err, service.ErrInvalidScope):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[AUTH] bot request failed path=%s err=%v", c.FullPath(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+func botToItem(b *model.User) BotItem {
+	return BotItem{
+		BotID:       b.UserID.String(),
+		Username:    b.Username,
+		DisplayName: b.DisplayName,
+		AvatarURL:   b.AvatarURL,
+		CreatedAt:   b.CreatedAt,
+	}
+}
+
+func apiKeyToItem(k *model.BotAPIKey) APIKeyItem {
+	scopes := k.ScopeList()
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return APIKeyItem{
+		KeyID:      k.KeyID.String(),
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     scopes,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		ExpiresAt:  k.ExpiresAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: command_handler.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: HTTP handlers for listing slash commands and for bots registering and answering them
+
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/service"
+)
+
+// CommandHandler handles slash command requests.
+type CommandHandler struct {
+	commands service.CommandService
+}
+
+// NewCommandHandler creates a new command handler.
+func NewCommandHandler(commands service.CommandService) *CommandHandler {
+	return &CommandHandler{commands: commands}
+}
+
+// RegisterCommandRequest is the body for registering a bot command.
+type RegisterCommandRequest struct {
+	Description string `json:"description"`
+}
+
+// CommandReplyRequest is the body for a bot's ephemeral reply to a participant.
+type CommandReplyRequest struct {
+	UserID  string `json:"user_id" binding:"required"`
+	Content string `json:"content" binding:"required"`
+}
+
+// ListCommands lists the built-in and bot commands available in the conversation.
+// GET /api/conversations/:id/commands
+func (h *CommandHandler) ListCommands(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	infos, err := h.commands.ListCommands(convID, userID)
+	if err != nil {
+		h.writeError(c, err, "failed to list commands")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"commands": infos})
+}
+
+// RegisterCommand registers or updates a command of the calling bot in the conversation.
+// PUT /api/conversations/:id/commands/:name
+func (h *CommandHandler) RegisterCommand(c *gin.Context) {
+	botID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req RegisterCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	cmd, err := h.commands.RegisterBotCommand(botID, convID, c.Param("name"), req.Description)
+	if err != nil {
+		h.writeError(c, err, "failed to register command")
+		return
+	}
+	c.JSON(http.StatusOK, cmd)
+}
+
+// UnregisterCommand removes a command of the calling bot.
+// DELETE /api/conversations/:id/commands/:name
+func (h *CommandHandler) UnregisterCommand(c *gin.Context) {
+	botID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	if err := h.commands.UnregisterBotCommand(botID, convID, c.Param("name")); err != nil {
+		h.writeError(c, err, "failed to remove command")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Reply sends the calling bot's ephemeral reply to one participant.
+// POST /api/conversations/:id/commands/replies
+func (h *CommandHandler) Reply(c *gin.Context) {
+	botID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req CommandReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if err := h.commands.ReplyEphemeral(botID, convID, userID, req.Content); err != nil {
+		h.writeError(c, err, "failed to send reply")
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+func (h *CommandHandler) writeError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrNotParticipant):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant"})
+	case errors.Is(err, service.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrCommandNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "command not found"})
+	case errors.Is(err, service.ErrCommandExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[CONV] command request failed path=%s err=%v", c.FullPath(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -37,3 +37,21 @@ func getUserIDFromContext(c *gin.Context) (uuid.UUID, error) {
 	}
 	return id, nil
 }
+
+// getSessionIDFromContext returns the session UUID bound to the access token, or uuid.Nil
+// when the token predates sessions. AuthMiddleware must have run.
+func getSessionIDFromContext(c *gin.Context) uuid.UUID {
+	v, ok := c.Get("session_id")
+	if !ok {
+		return uuid.Nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return uuid.Nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
@@ -14,6 +14,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -21,6 +22,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/convexwf/uim-go/internal/model"
 	"github.com/convexwf/uim-go/internal/service"
 )
 
@@ -51,6 +53,13 @@ type ConversationListItem struct {
 	OtherUser      *UserSummary    `json:"other_user,omitempty"`
 	LastMessage    *MessageSummary `json:"last_message,omitempty"`
 	UnreadCount    int             `json:"unread_count"`
+	MentionCount   int             `json:"mention_count"`
+	Muted          bool            `json:"muted"`
+	MutedUntil     *time.Time      `json:"muted_until,omitempty"`
+	Pinned         bool            `json:"pinned"`
+	PinOrder       int             `json:"pin_order,omitempty"`
+	Archived       bool            `json:"archived"`
+	Hidden         bool            `json:"hidden"`
 }
 
 // ConversationHandler handles conversation-related HTTP requests.
@@ -68,6 +77,76 @@ type CreateOneOnOneRequest struct {
 	OtherUserID string `json:"other_user_id" binding:"required"`
 }
 
+// CreateGroupRequest is the body for creating a group conversation.
+type CreateGroupRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	MemberUserIDs []string `json:"member_user_ids"`
+}
+
+// CreateChannelRequest is the body for creating a broadcast channel.
+type CreateChannelRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddMembersRequest is the body for adding users to a group.
+type AddMembersRequest struct {
+	UserIDs []string `json:"user_ids" binding:"required"`
+}
+
+// RenameGroupRequest is the body for renaming a group.
+type RenameGroupRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// UpdateModerationRequest is the body for changing a group's send restrictions. Omitted fields
+// are left unchanged.
+type UpdateModerationRequest struct {
+	AdminOnlySend   *bool `json:"admin_only_send"`
+	SlowModeSeconds *int  `json:"slow_mode_seconds"`
+}
+
+// HistoryVisibilityRequest is the body for choosing how much history new members see.
+type HistoryVisibilityRequest struct {
+	Visibility string `json:"visibility" binding:"required"`
+	Days       int    `json:"days"`
+}
+
+// SilenceMemberRequest is the body for muting a group member.
+type SilenceMemberRequest struct {
+	DurationSeconds int64 `json:"duration_seconds" binding:"required"`
+}
+
+// SetMemberRoleRequest is the body for promoting or demoting a participant.
+type SetMemberRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// TransferOwnershipRequest is the body for handing a group over to another participant.
+type TransferOwnershipRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// SetAnnouncementRequest is the body for setting a group announcement.
+type SetAnnouncementRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// UpdateSettingsRequest is the body for changing the user's settings for a conversation.
+// Omitted fields are left unchanged.
+type UpdateSettingsRequest struct {
+	MutedUntil *time.Time `json:"muted_until"`
+	Unmute     bool       `json:"unmute"`
+	Pinned     *bool      `json:"pinned"`
+	PinOrder   *int       `json:"pin_order"`
+	Archived   *bool      `json:"archived"`
+	Hidden     *bool      `json:"hidden"`
+}
+
+// ClearHistoryRequest is the optional body for clearing history; 0 clears every message.
+type ClearHistoryRequest struct {
+	UpToMessageID int64 `json:"up_to_message_id"`
+}
+
 // MarkReadRequest is the body for marking messages as read.
 type MarkReadRequest struct {
 	LastReadMessageID *int64 `json:"last_read_message_id" binding:"required"`
@@ -106,8 +185,435 @@ func (h *ConversationHandler) CreateOneOnOne(c *gin.Context) {
 	c.JSON(http.StatusCreated, conv)
 }
 
-// List lists conversations for the current user with metadata (other_user, last_message, unread_count).
-// GET /api/conversations?limit=20&offset=0
+// CreateGroup creates a group owned by the caller.
+// POST /api/conversations/group
+func (h *ConversationHandler) CreateGroup(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	memberIDs, ok := parseUserIDs(c, req.MemberUserIDs, "member_user_ids")
+	if !ok {
+		return
+	}
+	conv, err := h.convSvc.CreateGroup(userID, req.Name, memberIDs)
+	if err != nil {
+		writeGroupError(c, err, "failed to create group")
+		return
+	}
+	c.JSON(http.StatusCreated, conv)
+}
+
+// CreateChannel creates a broadcast channel owned by the caller.
+// POST /api/conversations/channel
+func (h *ConversationHandler) CreateChannel(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req CreateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	conv, err := h.convSvc.CreateChannel(userID, req.Name)
+	if err != nil {
+		writeGroupError(c, err, "failed to create channel")
+		return
+	}
+	c.JSON(http.StatusCreated, conv)
+}
+
+// AddMembers adds users to a group or channel; owners and admins only.
+// POST /api/conversations/:id/members
+func (h *ConversationHandler) AddMembers(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req AddMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	userIDs, ok := parseUserIDs(c, req.UserIDs, "user_ids")
+	if !ok {
+		return
+	}
+	added, err := h.convSvc.AddMembers(convID, userID, userIDs)
+	if err != nil {
+		writeGroupError(c, err, "failed to add members")
+		return
+	}
+	if added == nil {
+		added = []uuid.UUID{}
+	}
+	c.JSON(http.StatusOK, gin.H{"added_user_ids": added})
+}
+
+// UpdateModeration changes admin-only send and slow mode; owners and admins only.
+// PUT /api/conversations/:id/moderation
+func (h *ConversationHandler) UpdateModeration(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req UpdateModerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	conv, err := h.convSvc.UpdateModeration(convID, userID, service.ModerationUpdate{
+		AdminOnlySend:   req.AdminOnlySend,
+		SlowModeSeconds: req.SlowModeSeconds,
+	})
+	if err != nil {
+		writeGroupError(c, err, "failed to update moderation")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"admin_only_send": conv.AdminOnlySend, "slow_mode_seconds": conv.SlowMode})
+}
+
+// SetHistoryVisibility sets how much history members see from before they joined; owners and
+// admins only.
+// PUT /api/conversations/:id/history-visibility
+func (h *ConversationHandler) SetHistoryVisibility(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req HistoryVisibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	conv, err := h.convSvc.SetHistoryVisibility(convID, userID, req.Visibility, req.Days)
+	if err != nil {
+		writeGroupError(c, err, "failed to update history visibility")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"history_visibility": conv.HistoryVisibility, "history_days": conv.HistoryDays})
+}
+
+// SilenceMember keeps a member from sending for a while; owners and admins only.
+// PUT /api/conversations/:id/members/:user_id/mute
+func (h *ConversationHandler) SilenceMember(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	var req SilenceMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	until, err := h.convSvc.SilenceMember(convID, userID, memberID, time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		writeGroupError(c, err, "failed to mute member")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": memberID, "silenced_until": until})
+}
+
+// UnsilenceMember lets a muted member send again; owners and admins only.
+// DELETE /api/conversations/:id/members/:user_id/mute
+func (h *ConversationHandler) UnsilenceMember(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	if err := h.convSvc.UnsilenceMember(convID, userID, memberID); err != nil {
+		writeGroupError(c, err, "failed to unmute member")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetMemberRole makes a participant an admin or a plain member; owner only.
+// PUT /api/conversations/:id/members/:user_id/role
+func (h *ConversationHandler) SetMemberRole(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	var req SetMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if err := h.convSvc.SetMemberRole(convID, userID, memberID, req.Role); err != nil {
+		writeGroupError(c, err, "failed to change role")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": memberID, "role": req.Role})
+}
+
+// TransferOwnership hands the group over to another participant; owner only.
+// PUT /api/conversations/:id/owner
+func (h *ConversationHandler) TransferOwnership(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	newOwnerID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	if err := h.convSvc.TransferOwnership(convID, userID, newOwnerID); err != nil {
+		writeGroupError(c, err, "failed to transfer ownership")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"owner_id": newOwnerID})
+}
+
+// Leave removes the current user from a group.
+// POST /api/conversations/:id/leave
+func (h *ConversationHandler) Leave(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	if err := h.convSvc.LeaveGroup(convID, userID); err != nil {
+		writeGroupError(c, err, "failed to leave group")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveMember removes a participant from a group; owners remove anyone, admins plain members.
+// DELETE /api/conversations/:id/members/:user_id
+func (h *ConversationHandler) RemoveMember(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	if err := h.convSvc.RemoveMember(convID, userID, memberID); err != nil {
+		writeGroupError(c, err, "failed to remove member")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Get returns the conversation with its announcement and pinned messages.
+// GET /api/conversations/:id
+func (h *ConversationHandler) Get(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	d, err := h.convSvc.GetDetail(convID, userID)
+	if err != nil {
+		writeGroupError(c, err, "failed to get conversation")
+		return
+	}
+	pins := d.Pins
+	if pins == nil {
+		pins = []*model.PinnedMessage{}
+	}
+	c.JSON(http.StatusOK, gin.H{"conversation": d.Conv, "announcement": d.Announcement, "pinned_messages": pins})
+}
+
+// PinMessage pins a message of the conversation.
+// PUT /api/conversations/:id/pins/:message_id
+func (h *ConversationHandler) PinMessage(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+	pin, err := h.convSvc.PinMessage(convID, userID, messageID)
+	if err != nil {
+		writeGroupError(c, err, "failed to pin message")
+		return
+	}
+	c.JSON(http.StatusOK, pin)
+}
+
+// UnpinMessage removes a pin.
+// DELETE /api/conversations/:id/pins/:message_id
+func (h *ConversationHandler) UnpinMessage(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+	if err := h.convSvc.UnpinMessage(convID, userID, messageID); err != nil {
+		writeGroupError(c, err, "failed to unpin message")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RenameGroup renames the group; owners and admins only.
+// PUT /api/conversations/:id/name
+func (h *ConversationHandler) RenameGroup(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req RenameGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if err := h.convSvc.RenameGroup(convID, userID, req.Name); err != nil {
+		writeGroupError(c, err, "failed to rename group")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetAnnouncement sets the group announcement; owners and admins only.
+// PUT /api/conversations/:id/announcement
+func (h *ConversationHandler) SetAnnouncement(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req SetAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	a, err := h.convSvc.SetAnnouncement(convID, userID, req.Text)
+	if err != nil {
+		writeGroupError(c, err, "failed to set announcement")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"announcement": a})
+}
+
+// ClearAnnouncement removes the group announcement; owners and admins only.
+// DELETE /api/conversations/:id/announcement
+func (h *ConversationHandler) ClearAnnouncement(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	if _, err := h.convSvc.SetAnnouncement(convID, userID, ""); err != nil {
+		writeGroupError(c, err, "failed to clear announcement")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UpdateSettings changes the current user's mute, pin, archive and hide settings.
+// PATCH /api/conversations/:id/settings
+func (h *ConversationHandler) UpdateSettings(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	p, err := h.convSvc.UpdateSettings(convID, userID, service.ConversationSettingsUpdate{
+		MutedUntil: req.MutedUntil,
+		Unmute:     req.Unmute,
+		Pinned:     req.Pinned,
+		PinOrder:   req.PinOrder,
+		Archived:   req.Archived,
+		Hidden:     req.Hidden,
+	})
+	if err != nil {
+		writeGroupError(c, err, "failed to update settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": convID,
+		"muted_until":     p.MutedUntil,
+		"pinned":          p.PinOrder > 0,
+		"pin_order":       p.PinOrder,
+		"archived":        p.Archived,
+		"hidden":          p.HiddenUntilMessageID != nil,
+	})
+}
+
+// writeGroupError maps group management errors to HTTP responses.
+func writeGroupError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrNotParticipant):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant"})
+	case errors.Is(err, service.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, service.ErrMessageNotFound), errors.Is(err, service.ErrNotPinned),
+		errors.Is(err, service.ErrInviteNotFound), errors.Is(err, service.ErrJoinRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInviteUnusable):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrGroupOnly), errors.Is(err, service.ErrPinLimit), errors.Is(err, service.ErrInviteLimit):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidGroupSize), errors.Is(err, service.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// parseUserIDs parses a list of user IDs from a request body field, writing 400 on failure.
+func parseUserIDs(c *gin.Context, raw []string, field string) ([]uuid.UUID, bool) {
+	ids := make([]uuid.UUID, 0, len(raw))
+	for _, s := range raw {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + field})
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// List lists conversations for the current user with metadata (other_user, last_message, unread_count)
+// and the unread badge over all unmuted conversations (total_unread).
+// GET /api/conversations?limit=20&offset=0&include_archived=false&include_hidden=false
 func (h *ConversationHandler) List(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -119,11 +625,19 @@ func (h *ConversationHandler) List(c *gin.Context) {
 	if limit > 100 {
 		limit = 100
 	}
-	convs, err := h.convSvc.ListByUserIDWithMeta(userID, limit, offset)
+	includeArchived, _ := strconv.ParseBool(c.Query("include_archived"))
+	includeHidden, _ := strconv.ParseBool(c.Query("include_hidden"))
+	filter := service.ConversationListFilter{IncludeArchived: includeArchived, IncludeHidden: includeHidden}
+	convs, err := h.convSvc.ListByUserIDWithMeta(userID, limit, offset, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list conversations"})
 		return
 	}
+	totalUnread, err := h.convSvc.GetUnreadTotal(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count unread messages"})
+		return
+	}
 	if convs == nil {
 		convs = []*service.ConversationWithMeta{}
 	}
@@ -131,7 +645,7 @@ func (h *ConversationHandler) List(c *gin.Context) {
 	for i, m := range convs {
 		items[i] = conversationWithMetaToListItem(m)
 	}
-	c.JSON(http.StatusOK, gin.H{"conversations": items})
+	c.JSON(http.StatusOK, gin.H{"conversations": items, "total_unread": totalUnread})
 }
 
 func conversationWithMetaToListItem(m *service.ConversationWithMeta) ConversationListItem {
@@ -143,6 +657,17 @@ func conversationWithMetaToListItem(m *service.ConversationWithMeta) Conversatio
 		CreatedAt:      m.Conv.CreatedAt,
 		UpdatedAt:      m.Conv.UpdatedAt,
 		UnreadCount:    m.UnreadCount,
+		MentionCount:   m.MentionCount,
+		Muted:          m.Muted,
+	}
+	if p := m.Participant; p != nil {
+		if m.Muted {
+			item.MutedUntil = p.MutedUntil
+		}
+		item.Pinned = p.PinOrder > 0
+		item.PinOrder = p.PinOrder
+		item.Archived = p.Archived
+		item.Hidden = p.HiddenUntilMessageID != nil && (m.LastMessage == nil || m.LastMessage.MessageID <= *p.HiddenUntilMessageID)
 	}
 	if m.OtherUser != nil {
 		item.OtherUser = &UserSummary{
@@ -198,31 +723,43 @@ func (h *ConversationHandler) MarkRead(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// DeleteConversation removes a conversation for all participants.
-// DELETE /api/conversations/:id
+// DeleteConversation deletes the conversation for the current user only; with
+// for_everyone=true a group owner or admin deletes it for all participants.
+// DELETE /api/conversations/:id?for_everyone=false
 func (h *ConversationHandler) DeleteConversation(c *gin.Context) {
-	userID, err := getUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
 		return
 	}
-	convIDStr := c.Param("id")
-	if convIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing conversation id"})
-		return
+	var err error
+	if forEveryone, _ := strconv.ParseBool(c.Query("for_everyone")); forEveryone {
+		err = h.convSvc.PurgeConversation(convID, userID)
+	} else {
+		err = h.convSvc.DeleteConversation(convID, userID)
 	}
-	convID, err := uuid.Parse(convIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid conversation id"})
+		writeGroupError(c, err, "failed to delete conversation")
 		return
 	}
-	err = h.convSvc.DeleteConversation(convID, userID)
-	if err != nil {
-		if err == service.ErrNotParticipant {
-			c.JSON(http.StatusForbidden, gin.H{"error": "not a participant"})
+	c.Status(http.StatusNoContent)
+}
+
+// ClearHistory clears the current user's history up to a message (all messages by default).
+// POST /api/conversations/:id/clear
+func (h *ConversationHandler) ClearHistory(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req ClearHistoryRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete conversation"})
+	}
+	if err := h.convSvc.ClearHistory(convID, userID, req.UpToMessageID); err != nil {
+		writeGroupError(c, err, "failed to clear history")
 		return
 	}
 	c.Status(http.StatusNoContent)
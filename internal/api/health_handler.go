@@ -30,23 +30,29 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+
+	"github.com/convexwf/uim-go/internal/fanout"
 )
 
 // HealthHandler handles health check requests.
 type HealthHandler struct {
-	db    *gorm.DB
-	redis redis.Cmdable
+	db     *gorm.DB
+	redis  redis.Cmdable
+	fanout *fanout.Pool
 }
 
 // NewHealthHandler creates a new health check handler. redis may be nil (Redis check skipped).
-func NewHealthHandler(db *gorm.DB, redis redis.Cmdable) *HealthHandler {
-	return &HealthHandler{db: db, redis: redis}
+// pool may be nil (no fan-out stats in the response).
+func NewHealthHandler(db *gorm.DB, redis redis.Cmdable, pool *fanout.Pool) *HealthHandler {
+	return &HealthHandler{db: db, redis: redis, fanout: pool}
 }
 
 // HealthResponse represents the health check response.
 type HealthResponse struct {
 	Status string            `json:"status"`
 	Checks map[string]string `json:"checks"`
+	// Fanout holds the counters of the message delivery worker pool.
+	Fanout *fanout.Stats `json:"fanout,omitempty"`
 }
 
 // Health handles health check requests.
@@ -95,6 +101,10 @@ func (h *HealthHandler) Health(c *gin.Context) {
 		Status: status,
 		Checks: checks,
 	}
+	if h.fanout != nil {
+		stats := h.fanout.Stats()
+		response.Fanout = &stats
+	}
 
 	if status == "healthy" || status == "degraded" {
 		c.JSON(http.StatusOK, response)
@@ -0,0 +1,162 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: incoming_webhook_handler.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: HTTP handlers for incoming webhook tokens and POST /hooks/:token
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/service"
+)
+
+// IncomingWebhookHandler handles incoming webhook management and posts.
+type IncomingWebhookHandler struct {
+	hookSvc service.IncomingWebhookService
+}
+
+// NewIncomingWebhookHandler creates a new incoming webhook handler.
+func NewIncomingWebhookHandler(hookSvc service.IncomingWebhookService) *IncomingWebhookHandler {
+	return &IncomingWebhookHandler{hookSvc: hookSvc}
+}
+
+// CreateIncomingWebhookRequest is the body for creating an incoming webhook token.
+type CreateIncomingWebhookRequest struct {
+	BotID string `json:"bot_id" binding:"required"`
+	Name  string `json:"name" binding:"required"`
+}
+
+// CreateIncomingWebhook issues a token that posts into the conversation as the given bot.
+// The response carries the token and its path once.
+// POST /api/conversations/:id/incoming-webhooks
+func (h *IncomingWebhookHandler) CreateIncomingWebhook(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req CreateIncomingWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	botID, err := uuid.Parse(req.BotID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bot id"})
+		return
+	}
+	hook, token, err := h.hookSvc.CreateIncomingWebhook(userID, convID, botID, req.Name)
+	if err != nil {
+		h.writeError(c, err, "failed to create incoming webhook")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"incoming_webhook": hook, "token": token, "path": "/hooks/" + token})
+}
+
+// ListIncomingWebhooks lists the conversation's tokens, revoked ones included.
+// GET /api/conversations/:id/incoming-webhooks
+func (h *IncomingWebhookHandler) ListIncomingWebhooks(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	hooks, err := h.hookSvc.ListIncomingWebhooks(userID, convID)
+	if err != nil {
+		h.writeError(c, err, "failed to list incoming webhooks")
+		return
+	}
+	if hooks == nil {
+		hooks = []*model.IncomingWebhook{}
+	}
+	c.JSON(http.StatusOK, gin.H{"incoming_webhooks": hooks})
+}
+
+// RevokeIncomingWebhook revokes a token.
+// DELETE /api/conversations/:id/incoming-webhooks/:hook_id
+func (h *IncomingWebhookHandler) RevokeIncomingWebhook(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	hookID, err := uuid.Parse(c.Param("hook_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid incoming webhook id"})
+		return
+	}
+	if err := h.hookSvc.RevokeIncomingWebhook(userID, convID, hookID); err != nil {
+		h.writeError(c, err, "failed to revoke incoming webhook")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Post creates a message from an external system. The token in the path is the only credential.
+// POST /hooks/:token
+func (h *IncomingWebhookHandler) Post(c *gin.Context) {
+	var req service.IncomingMessage
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	msg, err := h.hookSvc.Post(c.Param("token"), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidHookToken):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrNotParticipant):
+			c.JSON(http.StatusForbidden, gin.H{"error": "the webhook's bot is no longer a participant"})
+		default:
+			// Send restrictions (admin-only, silenced, slow mode) map like a member's own send.
+			writeSendError(c, err)
+			if c.Writer.Status() >= http.StatusInternalServerError {
+				log.Printf("[WEBHOOK] incoming post failed err=%v", err)
+			}
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message_id": msg.MessageID, "conversation_id": msg.ConversationID})
+}
+
+func (h *IncomingWebhookHandler) writeError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrNotParticipant):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant"})
+	case errors.Is(err, service.ErrNotConversationOwner):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+	case errors.Is(err, service.ErrBotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "bot not found"})
+	case errors.Is(err, service.ErrIncomingWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "incoming webhook not found"})
+	case errors.Is(err, service.ErrWebhookLimit):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[WEBHOOK] request failed path=%s err=%v", c.FullPath(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// hookRateLimitIdentity buckets POST /hooks/:token per token. The key holds a hash of the token
+// so the store never sees the credential.
+func hookRateLimitIdentity(c *gin.Context) string {
+	sum := sha256.Sum256([]byte(c.Param("token")))
+	return "token:" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,183 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: invite_handler.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: HTTP handlers for group invite links, join approval and join requests
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/convexwf/uim-go/internal/service"
+)
+
+// InviteHandler handles invite link and join request endpoints.
+type InviteHandler struct {
+	convSvc service.ConversationService
+}
+
+// NewInviteHandler creates a new invite handler.
+func NewInviteHandler(convSvc service.ConversationService) *InviteHandler {
+	return &InviteHandler{convSvc: convSvc}
+}
+
+// CreateInviteRequest is the body for creating an invite link. Zero values mean no expiry and
+// unlimited uses.
+type CreateInviteRequest struct {
+	ExpiresInSeconds int64 `json:"expires_in_seconds"`
+	MaxUses          int   `json:"max_uses"`
+}
+
+// SetJoinApprovalRequest is the body for turning join approval mode on or off.
+type SetJoinApprovalRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// CreateInvite creates an invite link for the group; owners and admins only.
+// POST /api/conversations/:id/invites
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req CreateInviteRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+			return
+		}
+	}
+	invite, err := h.convSvc.CreateInvite(convID, userID, service.InviteOptions{
+		ExpiresIn: time.Duration(req.ExpiresInSeconds) * time.Second,
+		MaxUses:   req.MaxUses,
+	})
+	if err != nil {
+		writeGroupError(c, err, "failed to create invite")
+		return
+	}
+	c.JSON(http.StatusCreated, invite)
+}
+
+// ListInvites lists the group's invite links; owners and admins only.
+// GET /api/conversations/:id/invites
+func (h *InviteHandler) ListInvites(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	invites, err := h.convSvc.ListInvites(convID, userID)
+	if err != nil {
+		writeGroupError(c, err, "failed to list invites")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+// RevokeInvite revokes an invite link; owners and admins only.
+// DELETE /api/conversations/:id/invites/:code
+func (h *InviteHandler) RevokeInvite(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	if err := h.convSvc.RevokeInvite(convID, userID, c.Param("code")); err != nil {
+		writeGroupError(c, err, "failed to revoke invite")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Join joins the group of an invite link. Returns 200 when the user is a member and 202 with
+// the pending request when the group requires approval.
+// POST /api/invites/:code/join
+func (h *InviteHandler) Join(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.convSvc.JoinByInvite(c.Param("code"), userID)
+	if err != nil {
+		writeGroupError(c, err, "failed to join")
+		return
+	}
+	if !res.Joined {
+		c.JSON(http.StatusAccepted, gin.H{"status": "pending", "request": res.Request})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "joined", "conversation": res.Conv})
+}
+
+// SetJoinApproval turns the group's join approval mode on or off; owners and admins only.
+// PUT /api/conversations/:id/join-approval
+func (h *InviteHandler) SetJoinApproval(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req SetJoinApprovalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if err := h.convSvc.SetJoinApproval(convID, userID, *req.Enabled); err != nil {
+		writeGroupError(c, err, "failed to update join approval")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"join_approval": *req.Enabled})
+}
+
+// ListJoinRequests lists the group's pending join requests; owners and admins only.
+// GET /api/conversations/:id/join-requests
+func (h *InviteHandler) ListJoinRequests(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	reqs, err := h.convSvc.ListJoinRequests(convID, userID)
+	if err != nil {
+		writeGroupError(c, err, "failed to list join requests")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"join_requests": reqs})
+}
+
+// ApproveJoinRequest adds the requester to the group.
+// POST /api/conversations/:id/join-requests/:request_id/approve
+func (h *InviteHandler) ApproveJoinRequest(c *gin.Context) {
+	h.decideJoinRequest(c, true)
+}
+
+// RejectJoinRequest rejects a pending join request.
+// POST /api/conversations/:id/join-requests/:request_id/reject
+func (h *InviteHandler) RejectJoinRequest(c *gin.Context) {
+	h.decideJoinRequest(c, false)
+}
+
+func (h *InviteHandler) decideJoinRequest(c *gin.Context, approve bool) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	requestID, err := strconv.ParseInt(c.Param("request_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request id"})
+		return
+	}
+	if err := h.convSvc.DecideJoinRequest(convID, userID, requestID, approve); err != nil {
+		writeGroupError(c, err, "failed to decide join request")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
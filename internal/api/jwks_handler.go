@@ -0,0 +1,46 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: jwks_handler.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: HTTP handler publishing the JWT verification keys (JWKS)
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/convexwf/uim-go/internal/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// jwksMaxAge lets verifiers cache the key set briefly while still picking up a rotated key soon.
+const jwksMaxAge = "public, max-age=300"
+
+// JWKSHandler serves the public keys that verify UIM-issued tokens.
+type JWKSHandler struct {
+	jwtManager *jwt.JWTManager
+}
+
+// NewJWKSHandler creates a new JWKS handler.
+func NewJWKSHandler(jwtManager *jwt.JWTManager) *JWKSHandler {
+	return &JWKSHandler{jwtManager: jwtManager}
+}
+
+// JWKS returns the active and still-valid retired public keys. With HS256 the set is empty.
+//
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens (RS256 / EdDSA)
+// @Tags auth
+// @Produce json
+// @Success 200 {object} jwt.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", jwksMaxAge)
+	c.JSON(http.StatusOK, h.jwtManager.JWKS())
+}
@@ -14,8 +14,10 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -26,16 +28,92 @@ import (
 
 // MessageHandler handles message-related HTTP requests.
 type MessageHandler struct {
-	msgSvc service.MessageService
+	msgSvc   service.MessageService
+	commands service.CommandService
 }
 
-// NewMessageHandler creates a new message handler.
-func NewMessageHandler(msgSvc service.MessageService) *MessageHandler {
-	return &MessageHandler{msgSvc: msgSvc}
+// NewMessageHandler creates a new message handler. commands may be nil (content starting with
+// "/" is sent as text).
+func NewMessageHandler(msgSvc service.MessageService, commands service.CommandService) *MessageHandler {
+	return &MessageHandler{msgSvc: msgSvc, commands: commands}
 }
 
-// ListByConversation returns paginated messages for a conversation.
+// SendMessageRequest is the body for sending a message over HTTP.
+type SendMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// Create sends a text message to the conversation. It goes through the same path as WebSocket
+// sends, so participants connected to /ws receive it as new_message. Content starting with "/"
+// runs a slash command: a command that posts a message answers 201 with it, any other 200 with
+// the command name and its ephemeral reply (also pushed to the caller's connections).
+// POST /api/conversations/:id/messages
+func (h *MessageHandler) Create(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	convID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid conversation id"})
+		return
+	}
+	var req SendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if h.commands != nil {
+		res, err := h.commands.Execute(convID, userID, req.Content)
+		if err != nil {
+			writeSendError(c, err)
+			return
+		}
+		if res != nil {
+			if res.Message != nil {
+				c.JSON(http.StatusCreated, res.Message)
+			} else {
+				c.JSON(http.StatusOK, gin.H{"command": res.Command, "reply": res.Reply})
+			}
+			return
+		}
+	}
+	msg, err := h.msgSvc.Create(convID, userID, req.Content, model.MessageTypeText, nil)
+	if err != nil {
+		writeSendError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, msg)
+}
+
+// writeSendError maps errors of sending a message to HTTP responses.
+func writeSendError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotParticipant):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant"})
+	case errors.Is(err, service.ErrMentionAllNotAllowed), errors.Is(err, service.ErrAdminOnlySend),
+		errors.Is(err, service.ErrMemberSilenced):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSlowMode):
+		var restricted *service.SendRestrictionError
+		if errors.As(err, &restricted) && restricted.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int((restricted.RetryAfter+time.Second-1)/time.Second)))
+		}
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrMentionRateLimited):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send message"})
+	}
+}
+
+// ListByConversation returns paginated messages for a conversation, newest first; with after_id,
+// the messages following it, oldest first.
 // GET /api/conversations/:id/messages?limit=50&offset=0&before_id=123
+// GET /api/conversations/:id/messages?after_id=123&limit=100
 func (h *MessageHandler) ListByConversation(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -60,7 +138,18 @@ func (h *MessageHandler) ListByConversation(c *gin.Context) {
 			beforeID = &id
 		}
 	}
-	msgs, err := h.msgSvc.ListByConversationID(convID, userID, limit, offset, beforeID)
+	var msgs []*model.Message
+	if a := c.Query("after_id"); a != "" {
+		// Catching up: the messages following after_id, oldest first.
+		afterID, perr := strconv.ParseInt(a, 10, 64)
+		if perr != nil || afterID < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid after_id"})
+			return
+		}
+		msgs, err = h.msgSvc.ListSince(convID, userID, afterID, limit)
+	} else {
+		msgs, err = h.msgSvc.ListByConversationID(convID, userID, limit, offset, beforeID)
+	}
 	if err != nil {
 		if err == service.ErrNotParticipant {
 			c.JSON(http.StatusForbidden, gin.H{"error": "not a participant"})
@@ -28,18 +28,39 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/convexwf/uim-go/internal/config"
+	"github.com/convexwf/uim-go/internal/fanout"
 	"github.com/convexwf/uim-go/internal/middleware"
+	"github.com/convexwf/uim-go/internal/model"
 	"github.com/convexwf/uim-go/internal/pkg/jwt"
 	"github.com/convexwf/uim-go/internal/service"
 	"github.com/convexwf/uim-go/internal/store"
 	"github.com/convexwf/uim-go/internal/websocket"
 )
 
+// RouterDeps holds the optional dependencies of SetupRouter. Every field may be nil.
+type RouterDeps struct {
+	// Revocations rejects tokens of revoked sessions (nil: they stay valid until they expire).
+	Revocations store.RevocationStore
+	// Limiter enforces cfg.RateLimit per route group (nil: no rate limiting).
+	Limiter store.RateLimiter
+	// Commands handles slash commands (nil: content starting with "/" is sent as text).
+	Commands service.CommandService
+	// Bots authenticates bot API keys and serves /api/bots (nil: bot keys are rejected).
+	Bots service.BotService
+	// Webhooks serves the outgoing webhook routes (nil: not registered).
+	Webhooks service.WebhookService
+	// IncomingWebhooks serves incoming webhook routes and /hooks (nil: not registered).
+	IncomingWebhooks service.IncomingWebhookService
+	// Fanout reports fan-out pool stats in /health (nil: omitted).
+	Fanout *fanout.Pool
+}
+
 // SetupRouter configures and returns the HTTP router with all routes.
 //
 // redisClient may be nil (offline queue and presence disabled, health check skips Redis).
 // offlineQueue and presenceStore may be nil (offline messages dropped, presence returns offline).
-func SetupRouter(cfg *config.Config, db *gorm.DB, authService service.AuthService, jwtManager *jwt.JWTManager, convSvc service.ConversationService, contactSvc service.ContactService, msgSvc service.MessageService, hub *websocket.Hub, redisClient redis.Cmdable, offlineQueue store.OfflineQueue, presenceStore store.PresenceStore) *gin.Engine {
+// Optional services and stores are passed in deps; see RouterDeps.
+func SetupRouter(cfg *config.Config, db *gorm.DB, authService service.AuthService, jwtManager *jwt.JWTManager, convSvc service.ConversationService, contactSvc service.ContactService, msgSvc service.MessageService, hub *websocket.Hub, redisClient redis.Cmdable, offlineQueue store.OfflineQueue, presenceStore store.PresenceStore, deps RouterDeps) *gin.Engine {
 	// Use New + Recovery only: gin.Default() also attaches gin.Logger() writing to
 	// gin.DefaultWriter (often stderr), which does not follow log.SetOutput(UIM_LOG_FILE).
 	// cmd/server applies LoggerMiddlewareSimple so [HTTP] lines share the same log sink as [AUTH]/[DB].
@@ -54,39 +75,117 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, authService service.AuthServic
 	)
 
 	// Health check (no auth required)
-	healthHandler := NewHealthHandler(db, redisClient)
+	healthHandler := NewHealthHandler(db, redisClient, deps.Fanout)
 	router.GET("/health", healthHandler.Health)
 
+	// Token verification keys (no auth required)
+	jwksHandler := NewJWKSHandler(jwtManager)
+	router.GET("/.well-known/jwks.json", jwksHandler.JWKS)
+
+	// Bot API keys are only accepted when bots are enabled.
+	var bots middleware.BotAuthenticator
+	if deps.Bots != nil {
+		bots = deps.Bots
+	}
+
+	// Authenticated API routes share one bucket per user.
+	apiRateLimit := middleware.RateLimitMiddleware(deps.Limiter, "api", store.RateLimit{PerMinute: cfg.RateLimit.Requests})
+
 	// API routes
 	apiGroup := router.Group("/api")
 	{
 		// Auth routes (no auth required)
 		authHandler := NewAuthHandler(authService)
 		auth := apiGroup.Group("/auth")
+		auth.Use(middleware.RateLimitMiddleware(deps.Limiter, "auth", store.RateLimit{PerMinute: cfg.RateLimit.Auth}))
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/login/mfa", authHandler.LoginMFA)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/password-reset/request", authHandler.RequestPasswordReset)
+			auth.POST("/password-reset", authHandler.ResetPassword)
+			auth.GET("/oidc/providers", authHandler.OIDCProviders)
+			auth.GET("/oidc/:provider/authorize", authHandler.OIDCAuthorize)
+			auth.GET("/oidc/:provider/callback", authHandler.OIDCCallback)
+			auth.POST("/oidc/:provider/callback", authHandler.OIDCCallback)
 		}
 
 		authProtected := apiGroup.Group("/auth")
-		authProtected.Use(middleware.AuthMiddleware(jwtManager))
+		authProtected.Use(middleware.AuthMiddleware(jwtManager, deps.Revocations), apiRateLimit)
 		{
 			authProtected.GET("/me", authHandler.Me)
+			authProtected.DELETE("/me", authHandler.DeleteAccount)
+			authProtected.POST("/password", authHandler.ChangePassword)
+			authProtected.POST("/logout", authHandler.Logout)
+			authProtected.GET("/sessions", authHandler.ListSessions)
+			authProtected.DELETE("/sessions/:id", authHandler.RevokeSession)
+			authProtected.POST("/verify-email/request", authHandler.RequestEmailVerification)
+			authProtected.GET("/mfa", authHandler.MFAStatus)
+			authProtected.POST("/mfa/totp", authHandler.EnrollTOTP)
+			authProtected.POST("/mfa/totp/confirm", authHandler.ConfirmTOTP)
+			authProtected.DELETE("/mfa/totp", authHandler.DisableTOTP)
+			authProtected.POST("/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+		}
+
+		convHandler := NewConversationHandler(convSvc)
+		msgHandler := NewMessageHandler(msgSvc, deps.Commands)
+
+		// Routes open to bots (Authorization: Bot <key>) as well as users; each checks the key's scope.
+		botAPI := apiGroup.Group("")
+		botAPI.Use(middleware.BotOrUserAuthMiddleware(jwtManager, deps.Revocations, bots), apiRateLimit)
+		{
+			botAPI.GET("/conversations", middleware.RequireBotScope(model.BotScopeConversationsRead), convHandler.List)
+			botAPI.GET("/conversations/:id/messages", middleware.RequireBotScope(model.BotScopeMessagesRead), msgHandler.ListByConversation)
+			botAPI.POST("/conversations/:id/messages", middleware.RequireBotScope(model.BotScopeMessagesWrite),
+				middleware.RateLimitMiddleware(deps.Limiter, "message", store.RateLimit{PerMinute: cfg.RateLimit.Messages}), msgHandler.Create)
+
+			if deps.Commands != nil {
+				cmdHandler := NewCommandHandler(deps.Commands)
+				botAPI.GET("/conversations/:id/commands", middleware.RequireBotScope(model.BotScopeCommands), cmdHandler.ListCommands)
+				botAPI.PUT("/conversations/:id/commands/:name", middleware.RequireBotScope(model.BotScopeCommands), cmdHandler.RegisterCommand)
+				botAPI.DELETE("/conversations/:id/commands/:name", middleware.RequireBotScope(model.BotScopeCommands), cmdHandler.UnregisterCommand)
+				botAPI.POST("/conversations/:id/commands/replies", middleware.RequireBotScope(model.BotScopeCommands), cmdHandler.Reply)
+			}
 		}
 
 		// Protected routes (messaging)
 		protected := apiGroup.Group("")
-		protected.Use(middleware.AuthMiddleware(jwtManager))
+		protected.Use(middleware.AuthMiddleware(jwtManager, deps.Revocations), apiRateLimit)
 		{
-			convHandler := NewConversationHandler(convSvc)
 			protected.POST("/conversations", convHandler.CreateOneOnOne)
-			protected.GET("/conversations", convHandler.List)
+			protected.POST("/conversations/group", convHandler.CreateGroup)
+			protected.POST("/conversations/channel", convHandler.CreateChannel)
+			protected.POST("/conversations/:id/members", convHandler.AddMembers)
+			protected.GET("/conversations/:id", convHandler.Get)
+			protected.PUT("/conversations/:id/pins/:message_id", convHandler.PinMessage)
+			protected.DELETE("/conversations/:id/pins/:message_id", convHandler.UnpinMessage)
+			protected.PUT("/conversations/:id/announcement", convHandler.SetAnnouncement)
+			protected.PUT("/conversations/:id/name", convHandler.RenameGroup)
+			protected.PUT("/conversations/:id/moderation", convHandler.UpdateModeration)
+			protected.PUT("/conversations/:id/history-visibility", convHandler.SetHistoryVisibility)
+			protected.PUT("/conversations/:id/members/:user_id/mute", convHandler.SilenceMember)
+			protected.DELETE("/conversations/:id/members/:user_id/mute", convHandler.UnsilenceMember)
+			protected.PUT("/conversations/:id/members/:user_id/role", convHandler.SetMemberRole)
+			protected.DELETE("/conversations/:id/members/:user_id", convHandler.RemoveMember)
+			protected.PUT("/conversations/:id/owner", convHandler.TransferOwnership)
+			protected.POST("/conversations/:id/leave", convHandler.Leave)
+			protected.DELETE("/conversations/:id/announcement", convHandler.ClearAnnouncement)
 			protected.POST("/conversations/:id/read", convHandler.MarkRead)
+			protected.POST("/conversations/:id/clear", convHandler.ClearHistory)
+			protected.PATCH("/conversations/:id/settings", convHandler.UpdateSettings)
 			protected.DELETE("/conversations/:id", convHandler.DeleteConversation)
 
-			msgHandler := NewMessageHandler(msgSvc)
-			protected.GET("/conversations/:id/messages", msgHandler.ListByConversation)
+			inviteHandler := NewInviteHandler(convSvc)
+			protected.POST("/conversations/:id/invites", inviteHandler.CreateInvite)
+			protected.GET("/conversations/:id/invites", inviteHandler.ListInvites)
+			protected.DELETE("/conversations/:id/invites/:code", inviteHandler.RevokeInvite)
+			protected.PUT("/conversations/:id/join-approval", inviteHandler.SetJoinApproval)
+			protected.GET("/conversations/:id/join-requests", inviteHandler.ListJoinRequests)
+			protected.POST("/conversations/:id/join-requests/:request_id/approve", inviteHandler.ApproveJoinRequest)
+			protected.POST("/conversations/:id/join-requests/:request_id/reject", inviteHandler.RejectJoinRequest)
+			protected.POST("/invites/:code/join", inviteHandler.Join)
 
 			contactHandler := NewContactHandler(contactSvc)
 			protected.GET("/contacts", contactHandler.ListContacts)
@@ -96,12 +195,43 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, authService service.AuthServic
 
 			presenceHandler := NewPresenceHandler(presenceStore)
 			protected.GET("/users/:id/presence", presenceHandler.GetPresence)
+
+			if deps.Webhooks != nil {
+				webhookHandler := NewWebhookHandler(deps.Webhooks)
+				protected.POST("/conversations/:id/webhooks", webhookHandler.CreateWebhook)
+				protected.GET("/conversations/:id/webhooks", webhookHandler.ListWebhooks)
+				protected.DELETE("/conversations/:id/webhooks/:webhook_id", webhookHandler.DeleteWebhook)
+				protected.GET("/conversations/:id/webhooks/:webhook_id/deliveries", webhookHandler.ListDeliveries)
+			}
+
+			if deps.IncomingWebhooks != nil {
+				incomingHandler := NewIncomingWebhookHandler(deps.IncomingWebhooks)
+				protected.POST("/conversations/:id/incoming-webhooks", incomingHandler.CreateIncomingWebhook)
+				protected.GET("/conversations/:id/incoming-webhooks", incomingHandler.ListIncomingWebhooks)
+				protected.DELETE("/conversations/:id/incoming-webhooks/:hook_id", incomingHandler.RevokeIncomingWebhook)
+			}
+
+			if deps.Bots != nil {
+				botHandler := NewBotHandler(deps.Bots)
+				protected.POST("/bots", botHandler.CreateBot)
+				protected.GET("/bots", botHandler.ListBots)
+				protected.DELETE("/bots/:id", botHandler.DeleteBot)
+				protected.POST("/bots/:id/keys", botHandler.CreateAPIKey)
+				protected.GET("/bots/:id/keys", botHandler.ListAPIKeys)
+				protected.DELETE("/bots/:id/keys/:key_id", botHandler.RevokeAPIKey)
+			}
 		}
 	}
 
-	// WebSocket (token in query or Authorization header)
-	wsHandler := NewWebSocketHandler(jwtManager, hub, msgSvc, offlineQueue, presenceStore)
-	router.GET("/ws", wsHandler.ServeWS)
+	// Incoming webhooks (the token in the path is the credential; limited per token)
+	if deps.IncomingWebhooks != nil {
+		incomingHandler := NewIncomingWebhookHandler(deps.IncomingWebhooks)
+		router.POST("/hooks/:token", middleware.RateLimitByMiddleware(deps.Limiter, "hook", store.RateLimit{PerMinute: cfg.RateLimit.Hooks}, hookRateLimitIdentity), incomingHandler.Post)
+	}
+
+	// WebSocket (token in query or Authorization header, or a bot key with the ws scope)
+	wsHandler := NewWebSocketHandler(jwtManager, deps.Revocations, bots, hub, msgSvc, deps.Commands, offlineQueue, presenceStore, deps.Limiter, store.RateLimit{PerMinute: cfg.RateLimit.Messages})
+	router.GET("/ws", middleware.RateLimitMiddleware(deps.Limiter, "ws", store.RateLimit{PerMinute: cfg.RateLimit.WSConnect}), wsHandler.ServeWS)
 
 	return router
 }
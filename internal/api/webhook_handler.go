@@ -0,0 +1,187 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: webhook_handler.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: HTTP handlers for outgoing conversation webhooks
+
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/service"
+)
+
+// WebhookHandler handles webhook management requests.
+type WebhookHandler struct {
+	webhookSvc service.WebhookService
+}
+
+// NewWebhookHandler creates a new webhook handler.
+func NewWebhookHandler(webhookSvc service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookSvc: webhookSvc}
+}
+
+// CreateWebhookRequest is the body for registering a webhook. No events means all events.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+}
+
+// WebhookItem is the API shape for one webhook; the secret is only returned on creation.
+type WebhookItem struct {
+	WebhookID      string    `json:"webhook_id"`
+	ConversationID string    `json:"conversation_id"`
+	URL            string    `json:"url"`
+	Events         []string  `json:"events"`
+	CreatedBy      string    `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CreateWebhook registers a webhook for the conversation.
+// POST /api/conversations/:id/webhooks
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	webhook, err := h.webhookSvc.CreateWebhook(userID, convID, req.URL, req.Events)
+	if err != nil {
+		h.writeError(c, err, "failed to create webhook")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"webhook": webhookToItem(webhook), "secret": webhook.Secret})
+}
+
+// ListWebhooks lists the conversation's webhooks.
+// GET /api/conversations/:id/webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	webhooks, err := h.webhookSvc.ListWebhooks(userID, convID)
+	if err != nil {
+		h.writeError(c, err, "failed to list webhooks")
+		return
+	}
+	items := make([]WebhookItem, len(webhooks))
+	for i, w := range webhooks {
+		items[i] = webhookToItem(w)
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": items})
+}
+
+// DeleteWebhook removes a webhook and its delivery log.
+// DELETE /api/conversations/:id/webhooks/:webhook_id
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	webhookID, err := uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+		return
+	}
+	if err := h.webhookSvc.DeleteWebhook(userID, convID, webhookID); err != nil {
+		h.writeError(c, err, "failed to delete webhook")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries returns the webhook's delivery log, newest first. ?status=dead lists dead letters.
+// GET /api/conversations/:id/webhooks/:webhook_id/deliveries?status=&limit=50
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	userID, convID, ok := parseUserAndConversation(c)
+	if !ok {
+		return
+	}
+	webhookID, err := uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	status := model.WebhookDeliveryStatus(c.Query("status"))
+	deliveries, err := h.webhookSvc.ListDeliveries(userID, convID, webhookID, status, limit)
+	if err != nil {
+		h.writeError(c, err, "failed to list deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*model.WebhookDelivery{}
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+func (h *WebhookHandler) writeError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrNotParticipant):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a participant"})
+	case errors.Is(err, service.ErrNotConversationOwner):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+	case errors.Is(err, service.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+	case errors.Is(err, service.ErrWebhookLimit):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[WEBHOOK] request failed path=%s err=%v", c.FullPath(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// parseUserAndConversation reads the caller and the :id conversation parameter, writing the
+// error response on failure.
+func parseUserAndConversation(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return uuid.Nil, uuid.Nil, false
+	}
+	convID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid conversation id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, convID, true
+}
+
+func webhookToItem(w *model.Webhook) WebhookItem {
+	events := w.EventList()
+	if events == nil {
+		events = []string{}
+	}
+	return WebhookItem{
+		WebhookID:      w.WebhookID.String(),
+		ConversationID: w.ConversationID.String(),
+		URL:            w.URL,
+		Events:         events,
+		CreatedBy:      w.CreatedBy.String(),
+		CreatedAt:      w.CreatedAt,
+	}
+}
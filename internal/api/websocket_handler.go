@@ -16,16 +16,17 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	gorillawebsocket "github.com/gorilla/websocket"
 
+	"github.com/convexwf/uim-go/internal/middleware"
 	"github.com/convexwf/uim-go/internal/model"
 	"github.com/convexwf/uim-go/internal/pkg/jwt"
 	"github.com/convexwf/uim-go/internal/service"
@@ -34,12 +35,10 @@ import (
 )
 
 const (
-	wsWriteWait       = 10 * time.Second
-	wsPongWait        = 60 * time.Second
-	wsPingPeriod      = (wsPongWait * 9) / 10
-	wsMaxMessageSize  = 64 * 1024
-	wsRateLimitCount  = 60
-	wsRateLimitWindow = time.Minute
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = (wsPongWait * 9) / 10
+	wsMaxMessageSize = 64 * 1024
 )
 
 var upgrader = gorillawebsocket.Upgrader{
@@ -52,33 +51,59 @@ var upgrader = gorillawebsocket.Upgrader{
 
 // WebSocketHandler handles WebSocket connections.
 type WebSocketHandler struct {
-	jwtManager     *jwt.JWTManager
-	hub            *websocket.Hub
-	msgSvc         service.MessageService
-	offlineQueue   store.OfflineQueue
-	presenceStore  store.PresenceStore
+	jwtManager    *jwt.JWTManager
+	revocations   store.RevocationStore
+	bots          middleware.BotAuthenticator
+	hub           *websocket.Hub
+	msgSvc        service.MessageService
+	commands      service.CommandService
+	offlineQueue  store.OfflineQueue
+	presenceStore store.PresenceStore
+	limiter       store.RateLimiter
+	messageLimit  store.RateLimit
 }
 
-// NewWebSocketHandler creates a new WebSocket handler. offlineQueue and presenceStore may be nil.
-func NewWebSocketHandler(jwtManager *jwt.JWTManager, hub *websocket.Hub, msgSvc service.MessageService, offlineQueue store.OfflineQueue, presenceStore store.PresenceStore) *WebSocketHandler {
+// NewWebSocketHandler creates a new WebSocket handler. revocations, offlineQueue and presenceStore may be nil.
+// bots may be nil (bot API keys rejected). commands may be nil (content starting with "/" is sent as text).
+// limiter may be nil (no send limit); otherwise each user may send messageLimit messages across all connections.
+func NewWebSocketHandler(jwtManager *jwt.JWTManager, revocations store.RevocationStore, bots middleware.BotAuthenticator, hub *websocket.Hub, msgSvc service.MessageService, commands service.CommandService, offlineQueue store.OfflineQueue, presenceStore store.PresenceStore, limiter store.RateLimiter, messageLimit store.RateLimit) *WebSocketHandler {
 	return &WebSocketHandler{
 		jwtManager:    jwtManager,
+		revocations:   revocations,
+		bots:          bots,
 		hub:           hub,
 		msgSvc:        msgSvc,
+		commands:      commands,
 		offlineQueue:  offlineQueue,
 		presenceStore: presenceStore,
+		limiter:       limiter,
+		messageLimit:  messageLimit,
 	}
 }
 
 // ServeWS upgrades the HTTP connection to WebSocket and runs the connection loop.
 // Token can be passed as query ?token=... or header Authorization: Bearer ...
+// Bots authenticate with header Authorization: Bot <key>; the key needs the ws scope.
 func (h *WebSocketHandler) ServeWS(c *gin.Context) {
-	token := c.Query("token")
-	if token == "" {
-		auth := c.GetHeader("Authorization")
-		if strings.HasPrefix(auth, "Bearer ") {
-			token = strings.TrimPrefix(auth, "Bearer ")
+	auth := c.GetHeader("Authorization")
+	if strings.HasPrefix(auth, "Bot ") && h.bots != nil {
+		principal, err := h.bots.AuthenticateAPIKey(strings.TrimPrefix(auth, "Bot "))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+			return
 		}
+		if !principal.HasScope(model.BotScopeWS) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "api key lacks scope " + model.BotScopeWS})
+			return
+		}
+		// Bots have no login session. Sending over the socket needs messages:write as over HTTP.
+		h.serve(c, principal.BotID, uuid.Nil, principal.HasScope(model.BotScopeMessagesWrite))
+		return
+	}
+
+	token := c.Query("token")
+	if token == "" && strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
 	}
 	if token == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
@@ -89,22 +114,35 @@ func (h *WebSocketHandler) ServeWS(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		return
 	}
+	if middleware.IsTokenRevoked(c.Request.Context(), h.revocations, claims) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+		return
+	}
 	userID, err := uuid.Parse(claims.UserID)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user"})
 		return
 	}
 
+	// Tokens issued before sessions existed carry no sid; they map to uuid.Nil.
+	sessionID, _ := uuid.Parse(claims.SessionID)
+	h.serve(c, userID, sessionID, true)
+}
+
+// serve upgrades the authenticated request and runs the connection until it closes.
+// canSend false makes the connection receive-only (send_message frames are ignored).
+func (h *WebSocketHandler) serve(c *gin.Context, userID, sessionID uuid.UUID, canSend bool) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		return
 	}
 
 	client := &websocket.Client{
-		UserID: userID,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-		Hub:    h.hub,
+		UserID:    userID,
+		SessionID: sessionID,
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		Hub:       h.hub,
 	}
 	h.hub.Register(client)
 
@@ -136,10 +174,10 @@ func (h *WebSocketHandler) ServeWS(c *gin.Context) {
 	}
 
 	go h.writePump(client)
-	h.readPump(client)
+	h.readPump(client, canSend)
 }
 
-func (h *WebSocketHandler) readPump(client *websocket.Client) {
+func (h *WebSocketHandler) readPump(client *websocket.Client, canSend bool) {
 	defer func() {
 		if h.presenceStore != nil {
 			ctx := context.Background()
@@ -162,10 +200,6 @@ func (h *WebSocketHandler) readPump(client *websocket.Client) {
 		return nil
 	})
 
-	var rateMu sync.Mutex
-	var rateCount int
-	var rateWindowStart = time.Now()
-
 	for {
 		_, raw, err := client.Conn.ReadMessage()
 		if err != nil {
@@ -173,42 +207,108 @@ func (h *WebSocketHandler) readPump(client *websocket.Client) {
 		}
 		var msg websocket.WSClientMessage
 		if err := json.Unmarshal(raw, &msg); err != nil {
+			h.sendError(client, "", "invalid_frame", "invalid JSON", 0)
 			continue
 		}
 		if msg.Type != "send_message" {
 			continue
 		}
-
-		// Rate limit
-		rateMu.Lock()
-		if time.Since(rateWindowStart) > wsRateLimitWindow {
-			rateCount = 0
-			rateWindowStart = time.Now()
+		if !canSend {
+			h.sendError(client, msg.ConversationID, "forbidden", "this key cannot send messages", 0)
+			continue
 		}
-		rateCount++
-		if rateCount > wsRateLimitCount {
-			rateMu.Unlock()
+		if ok, retryAfter := h.allowSend(client.UserID); !ok {
+			h.sendError(client, msg.ConversationID, "rate_limited", "too many messages", retryAfter)
 			continue
 		}
-		rateMu.Unlock()
 
 		convID, err := uuid.Parse(msg.ConversationID)
 		if err != nil {
+			h.sendError(client, msg.ConversationID, "invalid_input", "invalid conversation id", 0)
 			continue
 		}
 		msgType := model.MessageTypeText
 		if msg.Content == "" {
+			h.sendError(client, msg.ConversationID, "invalid_input", "message content required", 0)
 			continue
 		}
-		m, err := h.msgSvc.Create(convID, client.UserID, msg.Content, msgType)
-		if err != nil {
-			// Optionally send error back to client; for now skip
-			continue
+		// Slash commands reply through the hub (ephemeral frame) instead of being broadcast.
+		if h.commands != nil {
+			res, err := h.commands.Execute(convID, client.UserID, msg.Content)
+			if err != nil {
+				h.sendSendError(client, msg.ConversationID, err)
+				continue
+			}
+			if res != nil {
+				continue
+			}
 		}
-		// Message is persisted and NotifyNewMessage already called by service -> hub broadcasts to all
-		// So we don't need to send again from here unless we want an echo to sender
-		_ = m
+		// On success the service notifies the hub, which broadcasts the message to every
+		// participant, the sender's connections included.
+		if _, err := h.msgSvc.Create(convID, client.UserID, msg.Content, msgType, nil); err != nil {
+			h.sendSendError(client, msg.ConversationID, err)
+		}
+	}
+}
+
+// sendSendError replies to a refused send_message with an error frame.
+func (h *WebSocketHandler) sendSendError(client *websocket.Client, conversationID string, err error) {
+	var restricted *service.SendRestrictionError
+	switch {
+	case errors.As(err, &restricted):
+		code := "admin_only"
+		switch {
+		case errors.Is(err, service.ErrMemberSilenced):
+			code = "muted"
+		case errors.Is(err, service.ErrSlowMode):
+			code = "slow_mode"
+		}
+		h.sendError(client, conversationID, code, err.Error(), restricted.RetryAfter)
+	case errors.Is(err, service.ErrNotParticipant):
+		h.sendError(client, conversationID, "not_participant", "not a participant", 0)
+	case errors.Is(err, service.ErrMentionAllNotAllowed):
+		h.sendError(client, conversationID, "forbidden", err.Error(), 0)
+	case errors.Is(err, service.ErrMentionRateLimited):
+		h.sendError(client, conversationID, "rate_limited", err.Error(), 0)
+	case errors.Is(err, service.ErrInvalidInput):
+		h.sendError(client, conversationID, "invalid_input", err.Error(), 0)
+	default:
+		log.Printf("[WS] send failed user_id=%s conversation_id=%s err=%v", client.UserID, conversationID, err)
+		h.sendError(client, conversationID, "internal", "failed to send message", 0)
+	}
+}
+
+// sendError queues an error frame on the client's connection; it is dropped if the buffer is full.
+func (h *WebSocketHandler) sendError(client *websocket.Client, conversationID, code, message string, retryAfter time.Duration) {
+	frame := &websocket.WSError{Code: code, Message: message}
+	if retryAfter > 0 {
+		frame.RetryAfter = int((retryAfter + time.Second - 1) / time.Second)
+	}
+	payload, err := json.Marshal(websocket.WSMessage{Type: "error", ConversationID: conversationID, Error: frame})
+	if err != nil {
+		return
+	}
+	select {
+	case client.Send <- payload:
+	default:
+	}
+}
+
+// allowSend takes a token from the user's message bucket (shared by all their connections) and
+// returns when to retry if it is empty. Limiter errors allow the message.
+func (h *WebSocketHandler) allowSend(userID uuid.UUID) (bool, time.Duration) {
+	if h.limiter == nil || h.messageLimit.PerMinute <= 0 {
+		return true, 0
+	}
+	res, err := h.limiter.Allow(context.Background(), "message:user:"+userID.String(), h.messageLimit)
+	if err != nil {
+		log.Printf("[WS] rate limiter error user_id=%s err=%v", userID, err)
+		return true, 0
+	}
+	if !res.Allowed {
+		log.Printf("[WS] send rate limited user_id=%s retry_after=%s", userID, res.RetryAfter)
 	}
+	return res.Allowed, res.RetryAfter
 }
 
 func (h *WebSocketHandler) writePump(client *websocket.Client) {
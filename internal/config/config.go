@@ -25,6 +25,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -33,12 +34,19 @@ import (
 
 // Config holds all application configuration.
 type Config struct {
-	App       AppConfig
-	Database  DatabaseConfig
-	Redis     RedisConfig
-	JWT       JWTConfig
-	CORS      CORSConfig
-	RateLimit RateLimitConfig
+	App          AppConfig
+	Database     DatabaseConfig
+	Redis        RedisConfig
+	JWT          JWTConfig
+	CORS         CORSConfig
+	RateLimit    RateLimitConfig
+	Auth         AuthConfig
+	Mail         MailConfig
+	OIDC         OIDCConfig
+	Webhook      WebhookConfig
+	Conversation ConversationConfig
+	Fanout       FanoutConfig
+	Membership   MembershipCacheConfig
 }
 
 // AppConfig holds application-level configuration.
@@ -47,6 +55,8 @@ type AppConfig struct {
 	Port     string
 	WSPort   string
 	LogLevel string
+	// PublicURL is the client app's base URL, used for links in emails.
+	PublicURL string
 }
 
 // DatabaseConfig holds PostgreSQL database configuration.
@@ -82,6 +92,19 @@ type JWTConfig struct {
 	Secret        string
 	AccessExpiry  time.Duration
 	RefreshExpiry time.Duration
+	// Algorithm is HS256 (shared Secret) or RS256 / EdDSA (keys from KeysDir, published as JWKS).
+	Algorithm string
+	// KeysDir holds <kid>.pem private keys; required for RS256 / EdDSA.
+	KeysDir string
+	// KeyReloadInterval controls how often KeysDir is re-read (0 disables reload).
+	KeyReloadInterval time.Duration
+	// KeyRotationInterval, if > 0, generates a new key once the newest one is older than this.
+	KeyRotationInterval time.Duration
+	// KeyActivationDelay is how long a new key is only published before it signs; it should
+	// cover KeyReloadInterval and the JWKS cache lifetime (5m).
+	KeyActivationDelay time.Duration
+	// LegacySecret, if set, keeps accepting kid-less HS256 tokens after switching to asymmetric keys.
+	LegacySecret string
 }
 
 // CORSConfig holds CORS (Cross-Origin Resource Sharing) configuration.
@@ -90,49 +113,131 @@ type CORSConfig struct {
 	AllowLoopbackDev bool // When true, allow http://127.0.0.1:* and http://localhost:* (Flutter WebView plugin dev).
 }
 
-// RateLimitConfig holds rate limiting configuration.
+// RateLimitConfig holds rate limiting configuration. Limits are token buckets refilled per
+// minute, kept in Redis when available so they hold across instances.
 type RateLimitConfig struct {
-	Messages int
-	Requests int
+	Enabled   bool
+	Messages  int // WebSocket messages sent per user per minute
+	Requests  int // authenticated API requests per user per minute
+	Auth      int // anonymous /api/auth requests per client IP per minute
+	WSConnect int // WebSocket connection attempts per client IP per minute
+	Hooks     int // POST /hooks/:token messages per incoming webhook token per minute
+	// MentionAll is how many messages with @all a group accepts per hour.
+	MentionAll int
+	// Login brute-force protection: after LoginUserFailures failed logins for a username (or
+	// LoginIPFailures from one IP) within LoginWindow, further attempts are refused for
+	// LoginLockoutBase, doubling with each further failure up to LoginLockoutMax.
+	LoginUserFailures int
+	LoginIPFailures   int
+	LoginWindow       time.Duration
+	LoginLockoutBase  time.Duration
+	LoginLockoutMax   time.Duration
+}
+
+// AuthConfig holds account policy configuration.
+type AuthConfig struct {
+	RequireVerifiedEmail bool // Login is refused until the email address is verified.
+	VerifyEmailTTL       time.Duration
+	PasswordResetTTL     time.Duration
+	TOTPIssuer           string // name shown in authenticator apps
+}
+
+// Mail drivers.
+const (
+	MailDriverSMTP = "smtp"
+	MailDriverLog  = "log"  // write emails to MAIL_LOG_FILE or the server log (development)
+	MailDriverNone = "none" // email verification and password reset are unavailable
+)
+
+// MailConfig holds outgoing email configuration.
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	LogFile      string
+}
+
+// OIDCConfig holds the OpenID Connect single sign-on providers.
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+}
+
+// OIDCProviderConfig is one identity provider, read from OIDC_<NAME>_* variables.
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is registered at the provider; the page there posts code and state to the API.
+	RedirectURL string
+	Scopes      []string
+}
+
+// WebhookConfig holds outgoing webhook delivery configuration. A failed delivery is retried
+// after RetryBaseDelay, doubling up to RetryMaxDelay, until MaxAttempts attempts were made.
+type WebhookConfig struct {
+	Enabled        bool
+	Workers        int
+	QueueSize      int
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	Timeout        time.Duration // per-attempt HTTP timeout
+	// AllowPrivateNetworks lets webhooks target loopback, private and link-local addresses.
+	AllowPrivateNetworks bool
+}
+
+// ConversationConfig holds limits of conversation features.
+type ConversationConfig struct {
+	MaxPins int // pinned messages per conversation
+}
+
+// FanoutConfig holds the worker pool delivering messages and events to WebSocket clients.
+type FanoutConfig struct {
+	Workers   int
+	QueueSize int // per worker
+}
+
+// MembershipCacheConfig holds the cache of conversation participant lists.
+type MembershipCacheConfig struct {
+	Enabled    bool
+	Size       int // conversations kept in each instance's LRU
+	MaxMembers int // larger conversations are not cached
+	TTL        time.Duration
 }
 
 // Load loads configuration from environment variables.
 //
 // It attempts to load a .env file if present, then reads configuration
-// from environment variables with sensible defaults.
+// from environment variables with sensible defaults. Unparsable values and
+// insecure settings (see Validate) are collected and returned together.
 //
 // Returns:
 //   - *Config: The loaded configuration
-//   - error: An error if configuration loading fails
+//   - error: A *ValidationError listing every problem if configuration is invalid
 func Load() (*Config, error) {
 	// Load .env file if it exists (ignore error if not found)
 	_ = godotenv.Load()
 
-	accessExpiry, err := parseDuration(getEnv("JWT_ACCESS_EXPIRY", "15m"))
-	if err != nil {
-		return nil, fmt.Errorf("invalid JWT_ACCESS_EXPIRY: %w", err)
-	}
+	r := &envReader{}
+	env := getEnv("APP_ENV", "development")
 
-	refreshExpiry, err := parseDuration(getEnv("JWT_REFRESH_EXPIRY", "168h"))
-	if err != nil {
-		return nil, fmt.Errorf("invalid JWT_REFRESH_EXPIRY: %w", err)
-	}
-
-	allowedOrigins := getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:3000")
-	corsOrigins := splitString(allowedOrigins, ",")
-
-	return &Config{
+	cfg := &Config{
 		App: AppConfig{
-			Env:      getEnv("APP_ENV", "development"),
-			Port:     getEnv("APP_PORT", "8080"),
-			WSPort:   getEnv("WS_PORT", "8081"),
-			LogLevel: getEnv("LOG_LEVEL", "debug"),
+			Env:       env,
+			Port:      getEnv("APP_PORT", "8080"),
+			WSPort:    getEnv("WS_PORT", "8081"),
+			LogLevel:  getEnv("LOG_LEVEL", "debug"),
+			PublicURL: getEnv("APP_PUBLIC_URL", "http://localhost:3000"),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("POSTGRES_HOST", "localhost"),
 			Port:     getEnv("POSTGRES_PORT", "5432"),
 			User:     getEnv("POSTGRES_USER", "uim_user"),
-			Password: getEnv("POSTGRES_PASSWORD", "uim_password"),
+			Password: getEnv("POSTGRES_PASSWORD", DefaultPostgresPassword),
 			DBName:   getEnv("POSTGRES_DB", "uim_db"),
 			SSLMode:  getEnv("POSTGRES_SSLMODE", "disable"),
 		},
@@ -142,19 +247,108 @@ func Load() (*Config, error) {
 			Password: getEnv("REDIS_PASSWORD", ""),
 		},
 		JWT: JWTConfig{
-			Secret:        getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
-			AccessExpiry:  accessExpiry,
-			RefreshExpiry: refreshExpiry,
+			Secret:              getEnv("JWT_SECRET", DefaultJWTSecret),
+			AccessExpiry:        r.duration("JWT_ACCESS_EXPIRY", "15m"),
+			RefreshExpiry:       r.duration("JWT_REFRESH_EXPIRY", "168h"),
+			Algorithm:           getEnv("JWT_ALGORITHM", "HS256"),
+			KeysDir:             getEnv("JWT_KEYS_DIR", ""),
+			KeyReloadInterval:   r.duration("JWT_KEY_RELOAD_INTERVAL", "1m"),
+			KeyRotationInterval: r.duration("JWT_KEY_ROTATION_INTERVAL", "0s"),
+			KeyActivationDelay:  r.duration("JWT_KEY_ACTIVATION_DELAY", "10m"),
+			LegacySecret:        getEnv("JWT_LEGACY_SECRET", ""),
 		},
 		CORS: CORSConfig{
-			AllowedOrigins:   corsOrigins,
-			AllowLoopbackDev: getEnvBool("CORS_ALLOW_LOOPBACK", true),
+			AllowedOrigins: splitString(getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:3000"), ","),
+			// Loopback dev origins are on by default only outside production.
+			AllowLoopbackDev: r.bool("CORS_ALLOW_LOOPBACK", env != EnvProduction),
 		},
 		RateLimit: RateLimitConfig{
-			Messages: getEnvAsInt("RATE_LIMIT_MESSAGES", 50),
-			Requests: getEnvAsInt("RATE_LIMIT_REQUESTS", 100),
+			Enabled:           r.bool("RATE_LIMIT_ENABLED", true),
+			Messages:          r.int("RATE_LIMIT_MESSAGES", 50),
+			Requests:          r.int("RATE_LIMIT_REQUESTS", 100),
+			Auth:              r.int("RATE_LIMIT_AUTH", 20),
+			WSConnect:         r.int("RATE_LIMIT_WS_CONNECT", 10),
+			Hooks:             r.int("RATE_LIMIT_HOOKS", 30),
+			MentionAll:        r.int("RATE_LIMIT_MENTION_ALL", 6),
+			LoginUserFailures: r.int("RATE_LIMIT_LOGIN_USER_FAILURES", 5),
+			LoginIPFailures:   r.int("RATE_LIMIT_LOGIN_IP_FAILURES", 20),
+			LoginWindow:       r.duration("RATE_LIMIT_LOGIN_WINDOW", "15m"),
+			LoginLockoutBase:  r.duration("RATE_LIMIT_LOGIN_LOCKOUT_BASE", "30s"),
+			LoginLockoutMax:   r.duration("RATE_LIMIT_LOGIN_LOCKOUT_MAX", "1h"),
+		},
+		Auth: AuthConfig{
+			RequireVerifiedEmail: r.bool("AUTH_REQUIRE_VERIFIED_EMAIL", false),
+			VerifyEmailTTL:       r.duration("AUTH_VERIFY_EMAIL_TTL", "24h"),
+			PasswordResetTTL:     r.duration("AUTH_PASSWORD_RESET_TTL", "1h"),
+			TOTPIssuer:           getEnv("AUTH_TOTP_ISSUER", "UIM"),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", defaultMailDriver(env)),
+			From:         getEnv("MAIL_FROM", ""),
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			LogFile:      getEnv("MAIL_LOG_FILE", ""),
 		},
-	}, nil
+		Webhook: WebhookConfig{
+			Enabled:              r.bool("WEBHOOK_ENABLED", true),
+			Workers:              r.int("WEBHOOK_WORKERS", 4),
+			QueueSize:            r.int("WEBHOOK_QUEUE_SIZE", 1000),
+			MaxAttempts:          r.int("WEBHOOK_MAX_ATTEMPTS", 6),
+			RetryBaseDelay:       r.duration("WEBHOOK_RETRY_BASE_DELAY", "10s"),
+			RetryMaxDelay:        r.duration("WEBHOOK_RETRY_MAX_DELAY", "1h"),
+			Timeout:              r.duration("WEBHOOK_TIMEOUT", "10s"),
+			AllowPrivateNetworks: r.bool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+		},
+		Conversation: ConversationConfig{
+			MaxPins: r.int("CONVERSATION_MAX_PINS", 50),
+		},
+		Fanout: FanoutConfig{
+			Workers:   r.int("FANOUT_WORKERS", 8),
+			QueueSize: r.int("FANOUT_QUEUE_SIZE", 1024),
+		},
+		Membership: MembershipCacheConfig{
+			Enabled:    r.bool("MEMBERSHIP_CACHE_ENABLED", true),
+			Size:       r.int("MEMBERSHIP_CACHE_SIZE", 10000),
+			MaxMembers: r.int("MEMBERSHIP_CACHE_MAX_MEMBERS", 5000),
+			TTL:        r.duration("MEMBERSHIP_CACHE_TTL", "5m"),
+		},
+	}
+
+	cfg.OIDC = loadOIDC(cfg.App.PublicURL)
+
+	problems := append(r.problems, cfg.Validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// loadOIDC reads the providers listed in OIDC_PROVIDERS (comma-separated names). For a provider
+// "google" it reads OIDC_GOOGLE_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and _SCOPES.
+func loadOIDC(publicURL string) OIDCConfig {
+	var cfg OIDCConfig
+	for _, name := range splitString(getEnv("OIDC_PROVIDERS", ""), ",") {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		cfg.Providers = append(cfg.Providers, OIDCProviderConfig{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", strings.TrimSuffix(publicURL, "/")+"/auth/oidc/"+name+"/callback"),
+			Scopes:       splitString(getEnv(prefix+"SCOPES", "openid email profile"), " "),
+		})
+	}
+	return cfg
+}
+
+// defaultMailDriver logs emails in development; production must configure delivery explicitly.
+func defaultMailDriver(env string) string {
+	if env == EnvProduction {
+		return MailDriverNone
+	}
+	return MailDriverLog
 }
 
 // getEnv retrieves an environment variable or returns a default value.
@@ -165,8 +359,14 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-// getEnvBool parses a boolean env var (1/t/true/yes/y, case-insensitive). Empty uses default.
-func getEnvBool(key string, defaultValue bool) bool {
+// envReader reads typed environment variables, recording unparsable values as problems
+// instead of silently replacing them with zero values or defaults.
+type envReader struct {
+	problems []string
+}
+
+// bool parses a boolean env var (1/t/true/yes/y/on, 0/f/false/no/n/off, case-insensitive). Empty uses default.
+func (r *envReader) bool(key string, defaultValue bool) bool {
 	v := strings.TrimSpace(os.Getenv(key))
 	if v == "" {
 		return defaultValue
@@ -177,21 +377,34 @@ func getEnvBool(key string, defaultValue bool) bool {
 	case "0", "f", "false", "no", "n", "off":
 		return false
 	default:
+		r.problems = append(r.problems, fmt.Sprintf("%s: invalid boolean %q", key, v))
 		return defaultValue
 	}
 }
 
-// getEnvAsInt retrieves an environment variable as an integer or returns a default value.
-func getEnvAsInt(key string, defaultValue int) int {
-	value := getEnv(key, fmt.Sprintf("%d", defaultValue))
-	var result int
-	fmt.Sscanf(value, "%d", &result)
-	return result
+// int parses an integer env var. Empty uses default.
+func (r *envReader) int(key string, defaultValue int) int {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: invalid integer %q", key, v))
+		return defaultValue
+	}
+	return n
 }
 
-// parseDuration parses a duration string (e.g., "15m", "1h").
-func parseDuration(s string) (time.Duration, error) {
-	return time.ParseDuration(s)
+// duration parses a duration env var (e.g., "15m", "1h"). Empty uses default.
+func (r *envReader) duration(key, defaultValue string) time.Duration {
+	v := getEnv(key, defaultValue)
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: invalid duration %q", key, v))
+		return 0
+	}
+	return d
 }
 
 // splitString splits a string by separator and trims whitespace from each part.
@@ -0,0 +1,277 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: validate.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Configuration validation (insecure production defaults, invalid values)
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EnvProduction is the APP_ENV value that enables the strict production checks.
+const EnvProduction = "production"
+
+// Development defaults that must never reach production.
+const (
+	DefaultJWTSecret        = "your-secret-key-change-this-in-production"
+	DefaultPostgresPassword = "uim_password"
+)
+
+// minSecretLength is the minimum HS256 secret length (bytes) accepted in production.
+const minSecretLength = 32
+
+// weakSecretMarkers are substrings of placeholder secrets copied from examples and docs.
+var weakSecretMarkers = []string{"change", "secret-key", "your-secret", "example", "placeholder"}
+
+// ValidationError lists every configuration problem found, so all of them can be fixed at once.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration (%d problems):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// IsProduction reports whether the app runs with APP_ENV=production.
+func (c *Config) IsProduction() bool {
+	return c.App.Env == EnvProduction
+}
+
+// Validate checks the configuration and returns one message per problem (nil if valid).
+//
+// Checks that apply in every environment catch values the server cannot run with; the
+// production checks reject development defaults: weak or placeholder secrets, the default
+// Postgres password, loopback or "null" CORS origins, and unencrypted remote databases.
+func (c *Config) Validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	ports := []struct{ key, value string }{
+		{"APP_PORT", c.App.Port},
+		{"POSTGRES_PORT", c.Database.Port},
+		{"REDIS_PORT", c.Redis.Port},
+	}
+	for _, p := range ports {
+		if n, err := strconv.Atoi(p.value); err != nil || n < 1 || n > 65535 {
+			add("%s: invalid port %q", p.key, p.value)
+		}
+	}
+
+	if c.JWT.AccessExpiry <= 0 {
+		add("JWT_ACCESS_EXPIRY must be positive")
+	}
+	if c.JWT.RefreshExpiry <= 0 {
+		add("JWT_REFRESH_EXPIRY must be positive")
+	} else if c.JWT.RefreshExpiry < c.JWT.AccessExpiry {
+		add("JWT_REFRESH_EXPIRY (%s) must not be shorter than JWT_ACCESS_EXPIRY (%s)", c.JWT.RefreshExpiry, c.JWT.AccessExpiry)
+	}
+	if c.JWT.KeyReloadInterval < 0 || c.JWT.KeyRotationInterval < 0 || c.JWT.KeyActivationDelay < 0 {
+		add("JWT_KEY_RELOAD_INTERVAL, JWT_KEY_ROTATION_INTERVAL and JWT_KEY_ACTIVATION_DELAY must not be negative")
+	}
+	switch c.JWT.Algorithm {
+	case "HS256":
+		if c.JWT.Secret == "" {
+			add("JWT_SECRET is required for JWT_ALGORITHM=HS256")
+		}
+	case "RS256", "EdDSA":
+		if c.JWT.KeysDir == "" {
+			add("JWT_KEYS_DIR is required for JWT_ALGORITHM=%s", c.JWT.Algorithm)
+		}
+	default:
+		add("JWT_ALGORITHM: unsupported algorithm %q (use HS256, RS256 or EdDSA)", c.JWT.Algorithm)
+	}
+
+	if c.RateLimit.Messages <= 0 {
+		add("RATE_LIMIT_MESSAGES must be positive")
+	}
+	if c.RateLimit.Requests <= 0 {
+		add("RATE_LIMIT_REQUESTS must be positive")
+	}
+	if c.RateLimit.Auth <= 0 || c.RateLimit.WSConnect <= 0 || c.RateLimit.Hooks <= 0 {
+		add("RATE_LIMIT_AUTH, RATE_LIMIT_WS_CONNECT and RATE_LIMIT_HOOKS must be positive")
+	}
+	if c.RateLimit.MentionAll <= 0 {
+		add("RATE_LIMIT_MENTION_ALL must be positive")
+	}
+	if c.RateLimit.LoginUserFailures <= 0 || c.RateLimit.LoginIPFailures <= 0 {
+		add("RATE_LIMIT_LOGIN_USER_FAILURES and RATE_LIMIT_LOGIN_IP_FAILURES must be positive")
+	}
+	if c.RateLimit.LoginWindow <= 0 || c.RateLimit.LoginLockoutBase <= 0 {
+		add("RATE_LIMIT_LOGIN_WINDOW and RATE_LIMIT_LOGIN_LOCKOUT_BASE must be positive")
+	}
+	if c.RateLimit.LoginLockoutMax < c.RateLimit.LoginLockoutBase {
+		add("RATE_LIMIT_LOGIN_LOCKOUT_MAX (%s) must not be shorter than RATE_LIMIT_LOGIN_LOCKOUT_BASE (%s)", c.RateLimit.LoginLockoutMax, c.RateLimit.LoginLockoutBase)
+	}
+
+	if c.Auth.VerifyEmailTTL <= 0 || c.Auth.PasswordResetTTL <= 0 {
+		add("AUTH_VERIFY_EMAIL_TTL and AUTH_PASSWORD_RESET_TTL must be positive")
+	}
+	switch c.Mail.Driver {
+	case MailDriverSMTP:
+		if c.Mail.SMTPHost == "" || c.Mail.From == "" {
+			add("SMTP_HOST and MAIL_FROM are required for MAIL_DRIVER=smtp")
+		}
+	case MailDriverLog:
+	case MailDriverNone:
+		if c.Auth.RequireVerifiedEmail {
+			add("AUTH_REQUIRE_VERIFIED_EMAIL needs a mail driver; MAIL_DRIVER=none cannot deliver verification emails")
+		}
+	default:
+		add("MAIL_DRIVER: unsupported driver %q (use smtp, log or none)", c.Mail.Driver)
+	}
+
+	if c.Fanout.Workers <= 0 || c.Fanout.QueueSize <= 0 {
+		add("FANOUT_WORKERS and FANOUT_QUEUE_SIZE must be positive")
+	}
+
+	if c.Membership.Enabled && (c.Membership.Size <= 0 || c.Membership.MaxMembers <= 0 || c.Membership.TTL <= 0) {
+		add("MEMBERSHIP_CACHE_SIZE, MEMBERSHIP_CACHE_MAX_MEMBERS and MEMBERSHIP_CACHE_TTL must be positive")
+	}
+
+	if c.Webhook.Enabled {
+		if c.Webhook.Workers <= 0 || c.Webhook.QueueSize <= 0 || c.Webhook.MaxAttempts <= 0 {
+			add("WEBHOOK_WORKERS, WEBHOOK_QUEUE_SIZE and WEBHOOK_MAX_ATTEMPTS must be positive")
+		}
+		if c.Webhook.RetryBaseDelay <= 0 || c.Webhook.Timeout <= 0 {
+			add("WEBHOOK_RETRY_BASE_DELAY and WEBHOOK_TIMEOUT must be positive")
+		}
+		if c.Webhook.RetryMaxDelay < c.Webhook.RetryBaseDelay {
+			add("WEBHOOK_RETRY_MAX_DELAY (%s) must not be shorter than WEBHOOK_RETRY_BASE_DELAY (%s)", c.Webhook.RetryMaxDelay, c.Webhook.RetryBaseDelay)
+		}
+		if c.Webhook.AllowPrivateNetworks && c.IsProduction() {
+			add("WEBHOOK_ALLOW_PRIVATE_NETWORKS must be false in production")
+		}
+	}
+
+	if c.Conversation.MaxPins <= 0 {
+		add("CONVERSATION_MAX_PINS must be positive")
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range c.OIDC.Providers {
+		if !validProviderName(p.Name) {
+			add("OIDC_PROVIDERS: invalid provider name %q (use lowercase letters, digits, '-' and '_')", p.Name)
+			continue
+		}
+		if seen[p.Name] {
+			add("OIDC_PROVIDERS: duplicate provider %q", p.Name)
+		}
+		seen[p.Name] = true
+		if p.Issuer == "" || p.ClientID == "" {
+			add("OIDC provider %q needs an issuer and client ID", p.Name)
+		}
+		if !containsString(p.Scopes, "openid") {
+			add("OIDC provider %q scopes must include openid", p.Name)
+		}
+	}
+
+	if !c.IsProduction() {
+		return problems
+	}
+
+	for _, p := range c.OIDC.Providers {
+		if u, err := url.Parse(p.Issuer); err == nil && u.Scheme != "https" {
+			add("OIDC provider %q issuer must use https in production", p.Name)
+		}
+	}
+
+	if c.Mail.Driver == MailDriverLog {
+		add("MAIL_DRIVER=log writes reset and verification tokens in clear text; use smtp or none in production")
+	}
+
+	if c.JWT.Algorithm == "HS256" {
+		if msg := weakSecretProblem(c.JWT.Secret); msg != "" {
+			add("JWT_SECRET %s", msg)
+		}
+	}
+	if c.JWT.LegacySecret != "" {
+		if msg := weakSecretProblem(c.JWT.LegacySecret); msg != "" {
+			add("JWT_LEGACY_SECRET %s", msg)
+		}
+	}
+
+	if c.Database.Password == "" || c.Database.Password == DefaultPostgresPassword {
+		add("POSTGRES_PASSWORD must be set to a non-default value in production")
+	}
+	if c.Database.SSLMode == "disable" && !isLocalHost(c.Database.Host) {
+		add("POSTGRES_SSLMODE=disable is not allowed for non-local host %q in production", c.Database.Host)
+	}
+
+	if c.CORS.AllowLoopbackDev {
+		add("CORS_ALLOW_LOOPBACK must be false in production")
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "null" {
+			add("CORS_ALLOWED_ORIGINS: \"null\" origin is not allowed in production")
+			continue
+		}
+		if u, err := url.Parse(origin); err == nil && isLocalHost(u.Hostname()) {
+			add("CORS_ALLOWED_ORIGINS: loopback origin %q is not allowed in production", origin)
+		}
+	}
+
+	return problems
+}
+
+// validProviderName reports whether name is usable in URLs and env var names.
+func validProviderName(name string) bool {
+	if name == "" || len(name) > 50 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// weakSecretProblem describes why secret is unfit for production, or returns "".
+func weakSecretProblem(secret string) string {
+	if secret == "" {
+		return "is not set"
+	}
+	lower := strings.ToLower(secret)
+	for _, marker := range weakSecretMarkers {
+		if strings.Contains(lower, marker) {
+			return "looks like a placeholder; generate a random value (e.g. openssl rand -base64 48)"
+		}
+	}
+	if len(secret) < minSecretLength {
+		return fmt.Sprintf("must be at least %d characters in production", minSecretLength)
+	}
+	return ""
+}
+
+// isLocalHost reports whether host is localhost, a loopback IP or a Unix socket path.
+func isLocalHost(host string) bool {
+	if host == "localhost" || strings.HasPrefix(host, "/") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
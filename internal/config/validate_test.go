@@ -0,0 +1,222 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: validate_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for configuration loading and validation
+
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// productionEnv is a minimal valid production environment.
+var productionEnv = map[string]string{
+	"APP_ENV":              "production",
+	"JWT_SECRET":           "k3Qp9vX2mL7rT5wZ8bN4cF6hJ1sD0gA-uY",
+	"POSTGRES_HOST":        "db.internal",
+	"POSTGRES_PASSWORD":    "s3cure-db-pass",
+	"POSTGRES_SSLMODE":     "require",
+	"CORS_ALLOWED_ORIGINS": "https://chat.example.org",
+}
+
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+}
+
+func loadProblems(t *testing.T) []string {
+	t.Helper()
+	_, err := Load()
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load() error = %v, want *ValidationError", err)
+	}
+	return verr.Problems
+}
+
+func hasProblem(problems []string, substr string) bool {
+	for _, p := range problems {
+		if strings.Contains(p, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoad_DevelopmentDefaults(t *testing.T) {
+	t.Setenv("APP_ENV", "development")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.CORS.AllowLoopbackDev {
+		t.Error("AllowLoopbackDev = false, want true by default in development")
+	}
+}
+
+func TestLoad_ProductionValid(t *testing.T) {
+	setEnv(t, productionEnv)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.CORS.AllowLoopbackDev {
+		t.Error("AllowLoopbackDev = true, want false by default in production")
+	}
+}
+
+func TestLoad_ProductionInsecureDefaults(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("POSTGRES_HOST", "db.internal")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://chat.example.org,null,http://localhost:3000")
+	t.Setenv("CORS_ALLOW_LOOPBACK", "true")
+
+	problems := loadProblems(t)
+	for _, want := range []string{
+		"JWT_SECRET",
+		"POSTGRES_PASSWORD",
+		"POSTGRES_SSLMODE=disable",
+		"CORS_ALLOW_LOOPBACK",
+		`"null" origin`,
+		`loopback origin "http://localhost:3000"`,
+	} {
+		if !hasProblem(problems, want) {
+			t.Errorf("problems %q missing %q", problems, want)
+		}
+	}
+}
+
+func TestLoad_ProductionWeakSecret(t *testing.T) {
+	setEnv(t, productionEnv)
+	t.Setenv("JWT_SECRET", "short")
+	if problems := loadProblems(t); !hasProblem(problems, "at least 32 characters") {
+		t.Errorf("problems = %q, want short secret rejected", problems)
+	}
+}
+
+func TestLoad_InvalidValues(t *testing.T) {
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("JWT_ACCESS_EXPIRY", "15 minutes")
+	t.Setenv("RATE_LIMIT_MESSAGES", "fifty")
+	t.Setenv("CORS_ALLOW_LOOPBACK", "maybe")
+	t.Setenv("APP_PORT", "http")
+
+	problems := loadProblems(t)
+	for _, want := range []string{
+		`JWT_ACCESS_EXPIRY: invalid duration "15 minutes"`,
+		`RATE_LIMIT_MESSAGES: invalid integer "fifty"`,
+		`CORS_ALLOW_LOOPBACK: invalid boolean "maybe"`,
+		`APP_PORT: invalid port "http"`,
+	} {
+		if !hasProblem(problems, want) {
+			t.Errorf("problems %q missing %q", problems, want)
+		}
+	}
+}
+
+func TestLoad_OIDCProviders(t *testing.T) {
+	setEnv(t, map[string]string{
+		"APP_ENV":                    "development",
+		"APP_PUBLIC_URL":             "https://chat.example.org/",
+		"OIDC_PROVIDERS":             "google, corp-sso",
+		"OIDC_GOOGLE_ISSUER":         "https://accounts.google.com",
+		"OIDC_GOOGLE_CLIENT_ID":      "gid",
+		"OIDC_GOOGLE_CLIENT_SECRET":  "gsecret",
+		"OIDC_CORP_SSO_ISSUER":       "https://sso.corp.example",
+		"OIDC_CORP_SSO_CLIENT_ID":    "cid",
+		"OIDC_CORP_SSO_REDIRECT_URL": "https://chat.example.org/sso",
+		"OIDC_CORP_SSO_SCOPES":       "openid email",
+	})
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.OIDC.Providers) != 2 {
+		t.Fatalf("Providers = %+v, want 2", cfg.OIDC.Providers)
+	}
+	google, corp := cfg.OIDC.Providers[0], cfg.OIDC.Providers[1]
+	if google.ClientSecret != "gsecret" || google.RedirectURL != "https://chat.example.org/auth/oidc/google/callback" || len(google.Scopes) != 3 {
+		t.Errorf("google = %+v", google)
+	}
+	if corp.Name != "corp-sso" || corp.RedirectURL != "https://chat.example.org/sso" || len(corp.Scopes) != 2 {
+		t.Errorf("corp-sso = %+v", corp)
+	}
+}
+
+func TestLoad_OIDCProblems(t *testing.T) {
+	setEnv(t, productionEnv)
+	setEnv(t, map[string]string{
+		"OIDC_PROVIDERS":      "Bad Name,corp",
+		"OIDC_CORP_ISSUER":    "http://sso.corp.example",
+		"OIDC_CORP_CLIENT_ID": "",
+		"OIDC_CORP_SCOPES":    "email",
+	})
+	problems := loadProblems(t)
+	for _, want := range []string{"invalid provider name", "needs an issuer and client ID", "must include openid", "must use https"} {
+		if !hasProblem(problems, want) {
+			t.Errorf("problems %q missing %q", problems, want)
+		}
+	}
+}
+
+func TestLoad_FanoutProblems(t *testing.T) {
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("FANOUT_QUEUE_SIZE", "0")
+
+	if problems := loadProblems(t); !hasProblem(problems, "FANOUT_WORKERS and FANOUT_QUEUE_SIZE must be positive") {
+		t.Errorf("problems %q missing the fan-out problem", problems)
+	}
+}
+
+func TestLoad_MembershipCacheProblems(t *testing.T) {
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("MEMBERSHIP_CACHE_TTL", "0s")
+
+	want := "MEMBERSHIP_CACHE_SIZE, MEMBERSHIP_CACHE_MAX_MEMBERS and MEMBERSHIP_CACHE_TTL must be positive"
+	if problems := loadProblems(t); !hasProblem(problems, want) {
+		t.Errorf("problems %q missing %q", problems, want)
+	}
+
+	// A disabled cache is not validated.
+	t.Setenv("MEMBERSHIP_CACHE_ENABLED", "false")
+	if problems := loadProblems(t); len(problems) != 0 {
+		t.Errorf("unexpected problems with the cache disabled: %q", problems)
+	}
+}
+
+func TestLoad_WebhookProblems(t *testing.T) {
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("WEBHOOK_WORKERS", "0")
+	t.Setenv("WEBHOOK_RETRY_BASE_DELAY", "1m")
+	t.Setenv("WEBHOOK_RETRY_MAX_DELAY", "10s")
+
+	problems := loadProblems(t)
+	for _, want := range []string{
+		"WEBHOOK_WORKERS, WEBHOOK_QUEUE_SIZE and WEBHOOK_MAX_ATTEMPTS must be positive",
+		"WEBHOOK_RETRY_MAX_DELAY (10s) must not be shorter than WEBHOOK_RETRY_BASE_DELAY (1m0s)",
+	} {
+		if !hasProblem(problems, want) {
+			t.Errorf("problems %q missing %q", problems, want)
+		}
+	}
+
+	// Disabled webhooks are not validated.
+	t.Setenv("WEBHOOK_ENABLED", "false")
+	if problems := loadProblems(t); len(problems) != 0 {
+		t.Errorf("unexpected problems with webhooks disabled: %q", problems)
+	}
+}
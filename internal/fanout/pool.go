@@ -0,0 +1,264 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: pool.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Worker pool delivering new messages and conversation events off the request path
+
+package fanout
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/service"
+)
+
+// Options tunes the pool. Zero fields take the defaults below.
+type Options struct {
+	Workers   int // concurrent deliveries (default 8)
+	QueueSize int // deliveries waiting per worker (default 1024)
+	// SlowEnqueue is how long a notifier may wait for room in a full queue before it is logged
+	// (default 100ms). It keeps waiting either way.
+	SlowEnqueue time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Workers <= 0 {
+		o.Workers = 8
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1024
+	}
+	if o.SlowEnqueue <= 0 {
+		o.SlowEnqueue = 100 * time.Millisecond
+	}
+	return o
+}
+
+// Target is what the pool delivers to, e.g. the WebSocket hub.
+type Target interface {
+	service.MessageNotifier
+	service.ConversationEventNotifier
+}
+
+// Stats are counters of the pool since it was created.
+type Stats struct {
+	Workers   int    `json:"workers"`
+	Queued    int    `json:"queued"` // waiting for a worker now
+	Enqueued  uint64 `json:"enqueued"`
+	Delivered uint64 `json:"delivered"`
+	Blocked   uint64 `json:"blocked"` // notifiers that waited for room in a full queue
+	Inline    uint64 `json:"inline"`  // delivered by the notifier after Stop
+	Failed    uint64 `json:"failed"` // the target panicked
+	// AvgLatencyMs and MaxLatencyMs measure from enqueue to the end of delivery.
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+}
+
+// job is a new message (msg set) or a conversation event waiting for delivery.
+type job struct {
+	conversationID uuid.UUID
+	msg            *model.Message
+	actorID        uuid.UUID
+	event          string
+	data           map[string]interface{}
+	enqueuedAt     time.Time
+}
+
+// Pool delivers new messages and conversation events to its target from a fixed set of
+// workers, so the sender does not wait for participant lookups, encoding or offline queue
+// pushes. Each conversation is always served by the same worker: its messages and events are
+// delivered in the order they were notified. Each worker has a bounded queue; when it is full the
+// notifier waits for room, which slows senders down instead of dropping deliveries (they include
+// the offline queue pushes recipients rely on) or overtaking the queued ones. Once the pool is
+// stopped, the notifier delivers the job itself.
+//
+// Pool implements service.MessageNotifier and service.ConversationEventNotifier.
+type Pool struct {
+	target Target
+	opts   Options
+	now    func() time.Time
+
+	queues []chan job
+	wg     sync.WaitGroup
+
+	mu      sync.RWMutex // guards closing the queues against concurrent sends
+	stopped bool
+
+	enqueued     atomic.Uint64
+	delivered    atomic.Uint64
+	blocked      atomic.Uint64
+	inline       atomic.Uint64
+	failed       atomic.Uint64
+	latencyTotal atomic.Int64 // nanoseconds over delivered and failed jobs
+	latencyMax   atomic.Int64
+}
+
+// NewPool creates a pool delivering to target. Call Start before notifying.
+func NewPool(target Target, opts Options) *Pool {
+	opts = opts.withDefaults()
+	p := &Pool{
+		target: target,
+		opts:   opts,
+		now:    time.Now,
+		queues: make([]chan job, opts.Workers),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan job, opts.QueueSize)
+	}
+	return p
+}
+
+// Start launches the workers.
+func (p *Pool) Start() {
+	for _, q := range p.queues {
+		p.wg.Add(1)
+		go p.worker(q)
+	}
+}
+
+// Stop waits for the queued deliveries, and the notifiers waiting for room, to finish. Jobs
+// notified afterwards are delivered by the notifier.
+func (p *Pool) Stop() {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		for _, q := range p.queues {
+			close(q)
+		}
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// NotifyNewMessage implements service.MessageNotifier.
+func (p *Pool) NotifyNewMessage(conversationID uuid.UUID, msg *model.Message) {
+	p.enqueue(job{conversationID: conversationID, msg: msg})
+}
+
+// NotifyConversationEvent implements service.ConversationEventNotifier.
+func (p *Pool) NotifyConversationEvent(conversationID, actorID uuid.UUID, event string, data map[string]interface{}) {
+	p.enqueue(job{conversationID: conversationID, actorID: actorID, event: event, data: data})
+}
+
+// Stats returns the current counters.
+func (p *Pool) Stats() Stats {
+	s := Stats{
+		Workers:      len(p.queues),
+		Enqueued:     p.enqueued.Load(),
+		Delivered:    p.delivered.Load(),
+		Blocked:      p.blocked.Load(),
+		Inline:       p.inline.Load(),
+		Failed:       p.failed.Load(),
+		MaxLatencyMs: float64(p.latencyMax.Load()) / float64(time.Millisecond),
+	}
+	for _, q := range p.queues {
+		s.Queued += len(q)
+	}
+	if done := s.Delivered + s.Failed; done > 0 {
+		s.AvgLatencyMs = float64(p.latencyTotal.Load()) / float64(done) / float64(time.Millisecond)
+	}
+	return s
+}
+
+// enqueue queues the job on its conversation's worker, waiting for room if the queue is full.
+// Once the pool is stopped the job is delivered in the caller instead.
+func (p *Pool) enqueue(j job) {
+	j.enqueuedAt = p.now()
+	if p.queue(j) {
+		p.enqueued.Add(1)
+		return
+	}
+	p.inline.Add(1)
+	p.deliver(j)
+}
+
+// queue reports whether the job was queued; false once the pool is stopped. Stop waits for the
+// read lock held while blocked, and the workers keep draining meanwhile.
+func (p *Pool) queue(j job) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return false
+	}
+	q := p.queues[p.shard(j.conversationID)]
+	select {
+	case q <- j:
+		return true
+	default:
+	}
+	p.blocked.Add(1)
+	timer := time.NewTimer(p.opts.SlowEnqueue)
+	defer timer.Stop()
+	select {
+	case q <- j:
+		return true
+	case <-timer.C:
+		log.Printf("[FANOUT] queue full, %s waiting for a worker conversation_id=%s", j.kind(), j.conversationID)
+	}
+	q <- j
+	return true
+}
+
+// shard maps a conversation to its worker.
+func (p *Pool) shard(conversationID uuid.UUID) int {
+	h := fnv.New32a()
+	_, _ = h.Write(conversationID[:])
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+func (p *Pool) worker(q <-chan job) {
+	defer p.wg.Done()
+	for j := range q {
+		p.deliver(j)
+	}
+}
+
+// deliver hands one job to the target; a panic is logged and counted instead of killing the worker.
+func (p *Pool) deliver(j job) {
+	ok := false
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[FANOUT] delivery panicked %s conversation_id=%s err=%v", j.kind(), j.conversationID, r)
+		}
+		if ok {
+			p.delivered.Add(1)
+		} else {
+			p.failed.Add(1)
+		}
+		latency := int64(p.now().Sub(j.enqueuedAt))
+		p.latencyTotal.Add(latency)
+		for {
+			max := p.latencyMax.Load()
+			if latency <= max || p.latencyMax.CompareAndSwap(max, latency) {
+				break
+			}
+		}
+	}()
+	if j.msg != nil {
+		p.target.NotifyNewMessage(j.conversationID, j.msg)
+	} else {
+		p.target.NotifyConversationEvent(j.conversationID, j.actorID, j.event, j.data)
+	}
+	ok = true
+}
+
+// kind names the job in logs.
+func (j job) kind() string {
+	if j.msg != nil {
+		return model.EventNewMessage
+	}
+	return j.event
+}
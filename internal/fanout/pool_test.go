@@ -0,0 +1,207 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: pool_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for the fan-out worker pool
+
+package fanout
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// recordingTarget records deliveries per conversation; block, if set, holds up every delivery.
+type recordingTarget struct {
+	mu     sync.Mutex
+	byConv map[uuid.UUID][]string
+	block  chan struct{}
+	panics bool
+}
+
+func newRecordingTarget() *recordingTarget {
+	return &recordingTarget{byConv: make(map[uuid.UUID][]string)}
+}
+
+func (t *recordingTarget) record(conversationID uuid.UUID, what string) {
+	if t.block != nil {
+		<-t.block
+	}
+	if t.panics {
+		panic("boom")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.byConv[conversationID] = append(t.byConv[conversationID], what)
+}
+
+func (t *recordingTarget) NotifyNewMessage(conversationID uuid.UUID, msg *model.Message) {
+	t.record(conversationID, msg.Content)
+}
+
+func (t *recordingTarget) NotifyConversationEvent(conversationID, actorID uuid.UUID, event string, data map[string]interface{}) {
+	t.record(conversationID, event)
+}
+
+func (t *recordingTarget) deliveries(conversationID uuid.UUID) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.byConv[conversationID]...)
+}
+
+func TestPool_PerConversationOrder(t *testing.T) {
+	target := newRecordingTarget()
+	pool := NewPool(target, Options{Workers: 4, QueueSize: 1000})
+	pool.Start()
+
+	convs := make([]uuid.UUID, 8)
+	for i := range convs {
+		convs[i] = uuid.New()
+	}
+	const perConv = 100
+	for i := 0; i < perConv; i++ {
+		for _, c := range convs {
+			if i == perConv/2 {
+				pool.NotifyConversationEvent(c, uuid.Nil, model.EventMemberLeft, nil)
+			}
+			pool.NotifyNewMessage(c, &model.Message{Content: string(rune('0' + i%10))})
+		}
+	}
+	pool.Stop()
+
+	for _, c := range convs {
+		got := target.deliveries(c)
+		if len(got) != perConv+1 {
+			t.Fatalf("conversation %s: %d deliveries, want %d", c, len(got), perConv+1)
+		}
+		for i, j := 0, 0; i < perConv; i, j = i+1, j+1 {
+			if i == perConv/2 {
+				if got[j] != model.EventMemberLeft {
+					t.Fatalf("delivery %d = %q, want the event in its place", j, got[j])
+				}
+				j++
+			}
+			if want := string(rune('0' + i%10)); got[j] != want {
+				t.Fatalf("delivery %d = %q, want %q", j, got[j], want)
+			}
+		}
+	}
+	stats := pool.Stats()
+	if want := uint64(len(convs) * (perConv + 1)); stats.Enqueued != want || stats.Delivered != want {
+		t.Errorf("stats = %+v, want %d enqueued and delivered", stats, want)
+	}
+	if stats.Inline != 0 || stats.Queued != 0 {
+		t.Errorf("stats = %+v, want nothing delivered inline or queued", stats)
+	}
+}
+
+func TestPool_FullQueueBlocksNotifierInOrder(t *testing.T) {
+	target := newRecordingTarget()
+	target.block = make(chan struct{})
+	pool := NewPool(target, Options{Workers: 1, QueueSize: 2, SlowEnqueue: 10 * time.Millisecond})
+	pool.Start()
+	conv := uuid.New()
+
+	// The worker takes the first message and blocks; two more fill the queue.
+	pool.NotifyNewMessage(conv, &model.Message{Content: "a"})
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Queued != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	pool.NotifyNewMessage(conv, &model.Message{Content: "b"})
+	pool.NotifyNewMessage(conv, &model.Message{Content: "c"})
+
+	// The fourth message waits for room, past SlowEnqueue, instead of being delivered early.
+	done := make(chan struct{})
+	go func() {
+		pool.NotifyNewMessage(conv, &model.Message{Content: "d"})
+		close(done)
+	}()
+	for pool.Stats().Blocked != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(30 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("notifier returned while the queue was full")
+	default:
+	}
+	if s := pool.Stats(); s.Blocked != 1 || s.Inline != 0 || s.Queued != 2 {
+		t.Errorf("stats = %+v, want 1 blocked, none inline and 2 queued", s)
+	}
+
+	close(target.block)
+	<-done
+	pool.Stop()
+	if got := target.deliveries(conv); strings.Join(got, "") != "abcd" {
+		t.Errorf("deliveries = %q, want a, b, c and d in order", got)
+	}
+}
+
+func TestPool_StopDrainsThenDeliversInline(t *testing.T) {
+	target := newRecordingTarget()
+	pool := NewPool(target, Options{Workers: 2, QueueSize: 10})
+	conv := uuid.New()
+	// Queued before Start: delivered once the workers run, and Stop waits for them.
+	for i := 0; i < 5; i++ {
+		pool.NotifyNewMessage(conv, &model.Message{Content: "m"})
+	}
+	pool.Start()
+	pool.Stop()
+	pool.Stop()
+	if got := target.deliveries(conv); len(got) != 5 {
+		t.Fatalf("delivered %d, want 5", len(got))
+	}
+	pool.NotifyNewMessage(conv, &model.Message{Content: "late"})
+	if s := pool.Stats(); s.Inline != 1 || s.Delivered != 6 || len(target.deliveries(conv)) != 6 {
+		t.Errorf("stats = %+v, want the late message delivered inline", s)
+	}
+}
+
+func TestPool_PanicIsCounted(t *testing.T) {
+	target := newRecordingTarget()
+	target.panics = true
+	pool := NewPool(target, Options{Workers: 1})
+	pool.Start()
+	conv := uuid.New()
+	pool.NotifyNewMessage(conv, &model.Message{Content: "a"})
+	pool.NotifyNewMessage(conv, &model.Message{Content: "b"})
+	pool.Stop()
+	if s := pool.Stats(); s.Failed != 2 || s.Delivered != 0 {
+		t.Errorf("stats = %+v, want 2 failed", s)
+	}
+}
+
+// slowTarget stands in for a hub whose participant lookup and offline pushes take a while.
+type slowTarget struct{ delay time.Duration }
+
+func (t slowTarget) NotifyNewMessage(uuid.UUID, *model.Message) { time.Sleep(t.delay) }
+
+func (t slowTarget) NotifyConversationEvent(uuid.UUID, uuid.UUID, string, map[string]interface{}) {
+	time.Sleep(t.delay)
+}
+
+func TestPool_NotifyDoesNotWaitForDelivery(t *testing.T) {
+	pool := NewPool(slowTarget{delay: 50 * time.Millisecond}, Options{Workers: 1, QueueSize: 10})
+	pool.Start()
+	defer pool.Stop()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		pool.NotifyNewMessage(uuid.New(), &model.Message{})
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("notifying took %s, want it independent of delivery time", elapsed)
+	}
+}
@@ -18,30 +18,61 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 //
-// Description: JWT authentication middleware for protected routes
+// Description: JWT and bot API key authentication middleware for protected routes
 
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
 	"github.com/convexwf/uim-go/internal/pkg/jwt"
+	"github.com/convexwf/uim-go/internal/service"
+	"github.com/convexwf/uim-go/internal/store"
 )
 
+// BotAuthenticator resolves bot API keys (implemented by service.BotService).
+type BotAuthenticator interface {
+	AuthenticateAPIKey(key string) (*service.BotPrincipal, error)
+}
+
 // AuthMiddleware creates a middleware that validates JWT access tokens.
 //
 // It extracts the token from the Authorization header, validates it,
-// and sets the user ID in the request context for use in handlers.
+// and sets the user ID and session ID in the request context for use in handlers.
+// Tokens whose user, session or jti has been revoked are rejected even if the
+// signature and expiry are still valid.
+//
+// Parameters:
+//   - jwtManager: The JWT manager instance for token validation
+//   - revocations: The revocation store to consult (may be nil: no revocation check)
+//
+// Returns:
+//   - gin.HandlerFunc: The authentication middleware handler
+func AuthMiddleware(jwtManager *jwt.JWTManager, revocations store.RevocationStore) gin.HandlerFunc {
+	return BotOrUserAuthMiddleware(jwtManager, revocations, nil)
+}
+
+// BotOrUserAuthMiddleware works like AuthMiddleware but also accepts bot API keys as
+// "Authorization: Bot <key>". For bots it sets user_id to the bot's user ID, is_bot to true
+// and bot_scopes to the key's scopes; routes open to bots must guard themselves with
+// RequireBotScope.
 //
 // Parameters:
 //   - jwtManager: The JWT manager instance for token validation
+//   - revocations: The revocation store to consult (may be nil: no revocation check)
+//   - bots: The API key authenticator (may be nil: bot keys are rejected)
 //
 // Returns:
 //   - gin.HandlerFunc: The authentication middleware handler
-func AuthMiddleware(jwtManager *jwt.JWTManager) gin.HandlerFunc {
+func BotOrUserAuthMiddleware(jwtManager *jwt.JWTManager, revocations store.RevocationStore, bots BotAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -50,14 +81,31 @@ func AuthMiddleware(jwtManager *jwt.JWTManager) gin.HandlerFunc {
 			return
 		}
 
-		// Extract token from "Bearer <token>"
+		// Extract credentials from "Bearer <token>" or "Bot <key>"
 		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if len(parts) != 2 || (parts[0] != "Bearer" && (parts[0] != "Bot" || bots == nil)) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
 			c.Abort()
 			return
 		}
 
+		if parts[0] == "Bot" {
+			principal, err := bots.AuthenticateAPIKey(parts[1])
+			if err != nil {
+				if !errors.Is(err, service.ErrInvalidAPIKey) {
+					log.Printf("[AUTH] bot api key check failed err=%v", err)
+				}
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+				c.Abort()
+				return
+			}
+			c.Set("user_id", principal.BotID.String())
+			c.Set("is_bot", true)
+			c.Set("bot_scopes", principal.Scopes)
+			c.Next()
+			return
+		}
+
 		token := parts[1]
 
 		// Validate token
@@ -68,8 +116,63 @@ func AuthMiddleware(jwtManager *jwt.JWTManager) gin.HandlerFunc {
 			return
 		}
 
-		// Set user ID in context
+		if IsTokenRevoked(c.Request.Context(), revocations, claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		// Set user ID (and session ID, if the token is bound to one) in context
 		c.Set("user_id", claims.UserID)
+		c.Set("token_id", claims.ID)
+		if claims.SessionID != "" {
+			c.Set("session_id", claims.SessionID)
+		}
 		c.Next()
 	}
 }
+
+// RequireBotScope rejects bot requests whose API key lacks scope with 403. Requests from
+// human users pass through. Register it after BotOrUserAuthMiddleware.
+func RequireBotScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("is_bot") {
+			c.Next()
+			return
+		}
+		scopes, _ := c.Get("bot_scopes")
+		list, _ := scopes.([]string)
+		for _, s := range list {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "api key lacks scope " + scope})
+		c.Abort()
+	}
+}
+
+// IsTokenRevoked reports whether the token's user, session or jti has been revoked.
+// A nil store never revokes. Lookup errors are treated as revoked (fail closed).
+func IsTokenRevoked(ctx context.Context, revocations store.RevocationStore, claims *jwt.Claims) bool {
+	if revocations == nil {
+		return false
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return true
+	}
+	// Tokens issued before sessions existed carry no sid; they map to uuid.Nil.
+	sessionID, _ := uuid.Parse(claims.SessionID)
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := revocations.IsRevoked(ctx, userID, sessionID, claims.ID, issuedAt)
+	if err != nil {
+		log.Printf("[AUTH] revocation check failed user_id=%s err=%v", claims.UserID, err)
+		return true
+	}
+	return revoked
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: bot_api_key.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Long-lived, scoped API key a bot user authenticates with
+
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bot API key scopes.
+const (
+	BotScopeMessagesWrite     = "messages:write"
+	BotScopeMessagesRead      = "messages:read"
+	BotScopeConversationsRead = "conversations:read"
+	BotScopeWS                = "ws"
+	BotScopeCommands          = "commands"
+)
+
+// BotScopes lists every scope a key may be granted.
+var BotScopes = []string{BotScopeMessagesWrite, BotScopeMessagesRead, BotScopeConversationsRead, BotScopeWS, BotScopeCommands}
+
+// BotAPIKey is an API key of a bot user. Only the SHA-256 of the key is stored; Prefix is the
+// public part of the key so owners can tell keys apart. Scopes is comma-separated.
+type BotAPIKey struct {
+	KeyID      uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"key_id"`
+	BotID      uuid.UUID  `gorm:"type:uuid;not null;index:idx_bot_api_keys_bot_id" json:"bot_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);not null" json:"prefix"`
+	KeyHash    string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_bot_api_keys_key_hash" json:"-"`
+	Scopes     string     `gorm:"type:text;not null" json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName returns the database table name for the BotAPIKey model.
+func (BotAPIKey) TableName() string {
+	return "bot_api_keys"
+}
+
+// ScopeList returns the key's scopes.
+func (k *BotAPIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope reports whether the key grants scope.
+func (k *BotAPIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key can be used at now.
+func (k *BotAPIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
@@ -0,0 +1,54 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: command.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Slash commands registered by bots, command invocations and ephemeral replies
+
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BotCommand is a slash command a bot registered in one conversation. Names are unique per
+// conversation and never shadow built-in commands.
+type BotCommand struct {
+	ConversationID uuid.UUID `gorm:"type:uuid;primaryKey" json:"conversation_id"`
+	Name           string    `gorm:"type:varchar(32);primaryKey" json:"name"`
+	BotID          uuid.UUID `gorm:"type:uuid;not null;index:idx_bot_commands_bot_id" json:"bot_id"`
+	Description    string    `gorm:"type:varchar(200);not null;default:''" json:"description"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName returns the database table name for the BotCommand model.
+func (BotCommand) TableName() string {
+	return "bot_commands"
+}
+
+// CommandInvocation is sent to a bot's WebSocket connections when a user runs one of its commands.
+type CommandInvocation struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Name           string    `json:"name"`
+	Args           string    `json:"args"`
+	InvokedAt      time.Time `json:"invoked_at"`
+}
+
+// EphemeralMessage is a reply only the invoking user sees. It is delivered to the user's open
+// connections and never stored, so it is lost if the user is offline. SenderID is the replying
+// bot, or nil for built-in commands.
+type EphemeralMessage struct {
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	SenderID       *uuid.UUID `json:"sender_id,omitempty"`
+	Command        string     `json:"command,omitempty"`
+	Content        string     `json:"content"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
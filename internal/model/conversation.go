@@ -23,6 +23,7 @@
 package model
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -37,6 +38,19 @@ const (
 	ConversationTypeOneOnOne ConversationType = "one_on_one"
 	// ConversationTypeGroup represents a group conversation with multiple participants.
 	ConversationTypeGroup ConversationType = "group"
+	// ConversationTypeChannel represents a broadcast channel: owners and admins post, subscribers
+	// read.
+	ConversationTypeChannel ConversationType = "channel"
+)
+
+// History visibility of a group for participants who join later.
+const (
+	// HistoryVisibilityAll shows the whole history to new members.
+	HistoryVisibilityAll = "all"
+	// HistoryVisibilityJoined shows only messages sent after the member joined.
+	HistoryVisibilityJoined = "joined"
+	// HistoryVisibilityDays shows messages from HistoryDays days before the member joined.
+	HistoryVisibilityDays = "days"
 )
 
 // Conversation represents a conversation (chat) in the system.
@@ -44,6 +58,21 @@ type Conversation struct {
 	ConversationID uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"conversation_id"`
 	Type           ConversationType `gorm:"type:varchar(20);not null" json:"type"`
 	Name           string           `gorm:"type:varchar(255)" json:"name,omitempty"`
+	Topic          string           `gorm:"type:text" json:"topic,omitempty"`
+	// The group announcement; exposed through CurrentAnnouncement.
+	Announcement   string           `gorm:"type:text" json:"-"`
+	AnnouncementBy *uuid.UUID       `gorm:"type:uuid" json:"-"`
+	AnnouncementAt *time.Time       `json:"-"`
+	// JoinApproval makes joins through invite links pending requests for owners and admins to decide.
+	JoinApproval   bool             `gorm:"not null;default:false" json:"join_approval"`
+	// AdminOnlySend lets only owners and admins send messages to the group.
+	AdminOnlySend  bool             `gorm:"not null;default:false" json:"admin_only_send"`
+	// SlowMode is the minimum number of seconds between two messages of a member (0: off).
+	SlowMode       int              `gorm:"column:slow_mode_seconds;not null;default:0" json:"slow_mode_seconds"`
+	// HistoryVisibility is how much history participants see from before they joined (all,
+	// joined or days); HistoryDays is the number of days for days.
+	HistoryVisibility string        `gorm:"type:varchar(16);not null;default:'all'" json:"history_visibility"`
+	HistoryDays    int              `gorm:"not null;default:0" json:"history_days,omitempty"`
 	CreatedBy      uuid.UUID        `gorm:"type:uuid;not null" json:"created_by"`
 	CreatedAt      time.Time        `json:"created_at"`
 	UpdatedAt      time.Time        `json:"updated_at"`
@@ -59,6 +88,34 @@ func (Conversation) TableName() string {
 	return "conversations"
 }
 
+// IsManaged reports whether the conversation is a group or a channel, i.e. has owners and
+// admins who manage it.
+func (c *Conversation) IsManaged() bool {
+	return c.Type == ConversationTypeGroup || c.Type == ConversationTypeChannel
+}
+
+// Announcement is a group's announcement text with its author.
+type Announcement struct {
+	Text      string    `json:"text"`
+	AuthorID  uuid.UUID `json:"author_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CurrentAnnouncement returns the conversation's announcement, or nil if none is set.
+func (c *Conversation) CurrentAnnouncement() *Announcement {
+	if c.Announcement == "" || c.AnnouncementBy == nil || c.AnnouncementAt == nil {
+		return nil
+	}
+	return &Announcement{Text: c.Announcement, AuthorID: *c.AnnouncementBy, UpdatedAt: *c.AnnouncementAt}
+}
+
+// Participant roles.
+const (
+	ParticipantRoleOwner  = "owner"
+	ParticipantRoleAdmin  = "admin"
+	ParticipantRoleMember = "member"
+)
+
 // ConversationParticipant represents a user's participation in a conversation.
 type ConversationParticipant struct {
 	ConversationID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"conversation_id"`
@@ -66,9 +123,65 @@ type ConversationParticipant struct {
 	Role              string    `gorm:"type:varchar(20);default:'member'" json:"role"` // owner, admin, member
 	JoinedAt          time.Time `json:"joined_at"`
 	LastReadMessageID int64     `gorm:"type:bigint" json:"last_read_message_id"`
+	// MutedUntil silences notifications for the user until the given time (nil: not muted).
+	MutedUntil *time.Time `json:"muted_until,omitempty"`
+	// PinOrder pins the conversation to the top of the user's list (1 first); 0 is not pinned.
+	PinOrder int  `gorm:"not null;default:0" json:"pin_order"`
+	Archived bool `gorm:"not null;default:false" json:"archived"`
+	// HiddenUntilMessageID hides the conversation from the user's list until a message newer
+	// than it arrives (nil: not hidden).
+	HiddenUntilMessageID *int64 `json:"hidden_until_message_id,omitempty"`
+	// ClearedUpToMessageID hides messages up to and including this ID from the user only
+	// (nil: full history).
+	ClearedUpToMessageID *int64 `json:"cleared_up_to_message_id,omitempty"`
+	// SilencedUntil keeps the member from sending messages until the given time; set by group
+	// owners and admins (nil: not silenced). Unlike MutedUntil it concerns the whole group.
+	SilencedUntil *time.Time `json:"silenced_until,omitempty"`
+}
+
+// IsMuted reports whether the participant has muted the conversation at the given time.
+func (p *ConversationParticipant) IsMuted(now time.Time) bool {
+	return p.MutedUntil != nil && p.MutedUntil.After(now)
+}
+
+// IsSilenced reports whether the member is kept from sending at the given time.
+func (p *ConversationParticipant) IsSilenced(now time.Time) bool {
+	return p.SilencedUntil != nil && p.SilencedUntil.After(now)
+}
+
+// IsManager reports whether the participant is an owner or admin.
+func (p *ConversationParticipant) IsManager() bool {
+	return p.Role == ParticipantRoleOwner || p.Role == ParticipantRoleAdmin
 }
 
 // TableName returns the database table name for the ConversationParticipant model.
 func (ConversationParticipant) TableName() string {
 	return "conversation_participants"
 }
+
+// SendPolicy is what decides whether a participant may send, apart from membership: the
+// conversation's type and moderation settings, its owners and admins, and the members that were
+// silenced when it was loaded.
+type SendPolicy struct {
+	Type          ConversationType        `json:"type"`
+	AdminOnlySend bool                    `json:"admin_only_send"`
+	SlowMode      int                     `json:"slow_mode_seconds"`
+	Managers      []uuid.UUID             `json:"managers,omitempty"`
+	SilencedUntil map[uuid.UUID]time.Time `json:"silenced_until,omitempty"`
+}
+
+// IsManaged reports whether the conversation is a group or a channel.
+func (p *SendPolicy) IsManaged() bool {
+	return p.Type == ConversationTypeGroup || p.Type == ConversationTypeChannel
+}
+
+// IsManager reports whether the user is an owner or admin.
+func (p *SendPolicy) IsManager(userID uuid.UUID) bool {
+	return slices.Contains(p.Managers, userID)
+}
+
+// IsSilenced reports whether the user is kept from sending at the given time, and until when.
+func (p *SendPolicy) IsSilenced(userID uuid.UUID, now time.Time) (time.Time, bool) {
+	until, ok := p.SilencedUntil[userID]
+	return until, ok && until.After(now)
+}
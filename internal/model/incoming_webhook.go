@@ -0,0 +1,40 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: incoming_webhook.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Incoming webhook tokens that post messages into a conversation as a bot
+
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IncomingWebhook lets external systems post into a conversation with POST /hooks/:token. Messages
+// are sent as BotID. Only the SHA-256 of the token is stored; Prefix is its public start, shown
+// in listings so owners can tell tokens apart.
+type IncomingWebhook struct {
+	HookID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"hook_id"`
+	ConversationID uuid.UUID  `gorm:"type:uuid;not null;index:idx_incoming_webhooks_conversation_id" json:"conversation_id"`
+	BotID          uuid.UUID  `gorm:"type:uuid;not null" json:"bot_id"`
+	CreatedBy      uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	Name           string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix         string     `gorm:"type:varchar(16);not null" json:"prefix"`
+	TokenHash      string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_incoming_webhooks_token_hash" json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName returns the database table name for the IncomingWebhook model.
+func (IncomingWebhook) TableName() string {
+	return "incoming_webhooks"
+}
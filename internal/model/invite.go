@@ -0,0 +1,76 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: invite.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Group invite links and join requests
+
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConversationInvite is a shareable link code that lets users join a group.
+type ConversationInvite struct {
+	Code           string    `gorm:"type:varchar(32);primaryKey" json:"code"`
+	ConversationID uuid.UUID `gorm:"type:uuid;not null;index:idx_conversation_invites_conversation_id" json:"conversation_id"`
+	CreatedBy      uuid.UUID `gorm:"type:uuid;not null" json:"created_by"`
+	// ExpiresAt is nil for links that do not expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// MaxUses is 0 for unlimited uses.
+	MaxUses   int        `gorm:"not null;default:0" json:"max_uses"`
+	Uses      int        `gorm:"not null;default:0" json:"uses"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName returns the database table name for the ConversationInvite model.
+func (ConversationInvite) TableName() string {
+	return "conversation_invites"
+}
+
+// Usable reports whether the invite is neither revoked, expired nor used up at now.
+func (i *ConversationInvite) Usable(now time.Time) bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !i.ExpiresAt.After(now) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// JoinRequestStatus is the state of a request to join a group in approval mode.
+type JoinRequestStatus string
+
+const (
+	JoinRequestPending  JoinRequestStatus = "pending"
+	JoinRequestApproved JoinRequestStatus = "approved"
+	JoinRequestRejected JoinRequestStatus = "rejected"
+)
+
+// ConversationJoinRequest is a request to join a group made through an invite link while the
+// group requires approval.
+type ConversationJoinRequest struct {
+	RequestID      int64             `gorm:"primaryKey;autoIncrement" json:"request_id"`
+	ConversationID uuid.UUID         `gorm:"type:uuid;not null;index:idx_join_requests_conversation_status" json:"conversation_id"`
+	UserID         uuid.UUID         `gorm:"type:uuid;not null" json:"user_id"`
+	InviteCode     string            `gorm:"type:varchar(32);not null" json:"invite_code"`
+	Status         JoinRequestStatus `gorm:"type:varchar(20);not null;default:'pending';index:idx_join_requests_conversation_status" json:"status"`
+	CreatedAt      time.Time         `json:"created_at"`
+	DecidedBy      *uuid.UUID        `gorm:"type:uuid" json:"decided_by,omitempty"`
+	DecidedAt      *time.Time        `json:"decided_at,omitempty"`
+}
+
+// TableName returns the database table name for the ConversationJoinRequest model.
+func (ConversationJoinRequest) TableName() string {
+	return "conversation_join_requests"
+}
@@ -35,6 +35,12 @@ type MessageType string
 const (
 	// MessageTypeText represents a text message.
 	MessageTypeText MessageType = "text"
+	// MessageTypeEmote is an action sent with /me; clients show it as "* name content".
+	MessageTypeEmote MessageType = "emote"
+	// MessageTypeSystem is generated by the server for changes to the conversation (e.g. a member
+	// joining); Metadata describes the change. System messages cannot be sent or edited by users
+	// and do not count as unread.
+	MessageTypeSystem MessageType = "system"
 )
 
 // Message represents a message in a conversation.
@@ -51,9 +57,24 @@ type Message struct {
 	// Relationships
 	Conversation Conversation `gorm:"foreignKey:ConversationID" json:"conversation,omitempty"`
 	Sender       User         `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
+	// Mentions are created together with the message; they are not loaded on reads (the
+	// mentioned user IDs are also in Metadata).
+	Mentions []MessageMention `gorm:"foreignKey:MessageID" json:"-"`
 }
 
 // TableName returns the database table name for the Message model.
 func (Message) TableName() string {
 	return "messages"
 }
+
+// MessageMention records that a message mentions a user, by @username or through @all.
+type MessageMention struct {
+	MessageID      int64     `gorm:"primaryKey;autoIncrement:false;index:idx_message_mentions_user_conv,priority:3" json:"message_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;primaryKey;index:idx_message_mentions_user_conv,priority:1" json:"user_id"`
+	ConversationID uuid.UUID `gorm:"type:uuid;not null;index:idx_message_mentions_user_conv,priority:2" json:"conversation_id"`
+}
+
+// TableName returns the database table name for the MessageMention model.
+func (MessageMention) TableName() string {
+	return "message_mentions"
+}
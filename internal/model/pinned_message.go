@@ -0,0 +1,35 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: pinned_message.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Messages pinned in a conversation
+
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PinnedMessage is a message pinned in its conversation. Message is loaded on reads; it is nil
+// if the message was deleted since.
+type PinnedMessage struct {
+	ConversationID uuid.UUID `gorm:"type:uuid;primaryKey" json:"conversation_id"`
+	MessageID      int64     `gorm:"primaryKey;autoIncrement:false" json:"message_id"`
+	PinnedBy       uuid.UUID `gorm:"type:uuid;not null" json:"pinned_by"`
+	PinnedAt       time.Time `gorm:"not null" json:"pinned_at"`
+
+	Message *Message `gorm:"foreignKey:MessageID;references:MessageID" json:"message,omitempty"`
+}
+
+// TableName returns the database table name for the PinnedMessage model.
+func (PinnedMessage) TableName() string {
+	return "pinned_messages"
+}
@@ -0,0 +1,43 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: session.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Login session (device) data model
+
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserSession represents one login of a user on a device. Access and refresh tokens
+// carry the session ID so a session can be listed and revoked independently.
+type UserSession struct {
+	SessionID  uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"session_id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index:idx_user_sessions_user" json:"user_id"`
+	DeviceName string     `gorm:"type:varchar(100)" json:"device_name"`
+	UserAgent  string     `gorm:"type:text" json:"user_agent"`
+	IPAddress  string     `gorm:"type:varchar(64)" json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName returns the database table name for the UserSession model.
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
+
+// IsActive reports whether the session is neither revoked nor expired at t.
+func (s *UserSession) IsActive(t time.Time) bool {
+	return s.RevokedAt == nil && t.Before(s.ExpiresAt)
+}
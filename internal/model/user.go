@@ -31,15 +31,18 @@ import (
 
 // User represents a user in the system.
 type User struct {
-	UserID       uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"user_id"`
-	Username     string         `gorm:"type:varchar(50);uniqueIndex:idx_users_username;not null" json:"username"`
-	Email        string         `gorm:"type:varchar(255);uniqueIndex:idx_users_email;not null" json:"email"`
-	PasswordHash string         `gorm:"type:varchar(255);not null" json:"-"`
-	DisplayName  string         `gorm:"type:varchar(100)" json:"display_name"`
-	AvatarURL    string         `gorm:"type:text" json:"avatar_url"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index:idx_users_deleted_at" json:"-"`
+	UserID          uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"user_id"`
+	Username        string         `gorm:"type:varchar(50);uniqueIndex:idx_users_username;not null" json:"username"`
+	Email           string         `gorm:"type:varchar(255);uniqueIndex:idx_users_email;not null" json:"email"`
+	PasswordHash    string         `gorm:"type:varchar(255);not null" json:"-"`
+	DisplayName     string         `gorm:"type:varchar(100)" json:"display_name"`
+	AvatarURL       string         `gorm:"type:text" json:"avatar_url"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at,omitempty"`
+	IsBot           bool           `gorm:"not null;default:false" json:"is_bot"`
+	BotOwnerID      *uuid.UUID     `gorm:"type:uuid" json:"bot_owner_id,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index:idx_users_deleted_at" json:"-"`
 }
 
 // TableName returns the database table name for the User model.
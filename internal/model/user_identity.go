@@ -0,0 +1,35 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: user_identity.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: External identity (OpenID Connect provider account) linked to a user
+
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links an account at an external identity provider to a local user.
+type UserIdentity struct {
+	IdentityID  uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"identity_id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index:idx_user_identities_user_id" json:"user_id"`
+	Provider    string     `gorm:"type:varchar(50);not null;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_provider_subject" json:"-"`
+	Email       string     `gorm:"type:varchar(255)" json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// TableName returns the database table name for the UserIdentity model.
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
@@ -0,0 +1,53 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: user_mfa.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Two-factor authentication models (TOTP secret, recovery codes)
+
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserMFA holds a user's TOTP enrollment. Two-factor login is enforced once ConfirmedAt is set.
+type UserMFA struct {
+	UserID       uuid.UUID  `gorm:"type:uuid;primary_key" json:"user_id"`
+	TOTPSecret   string     `gorm:"column:totp_secret;type:varchar(64);not null" json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"` // last accepted TOTP step (replay protection)
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName returns the database table name for the UserMFA model.
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// Enabled reports whether two-factor login is active.
+func (m *UserMFA) Enabled() bool {
+	return m != nil && m.ConfirmedAt != nil
+}
+
+// UserRecoveryCode is a single-use fallback code for two-factor login. Only its hash is stored.
+type UserRecoveryCode struct {
+	CodeID    uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"code_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_user_recovery_codes_user_hash" json:"user_id"`
+	CodeHash  string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_user_recovery_codes_user_hash" json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// TableName returns the database table name for the UserRecoveryCode model.
+func (UserRecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
@@ -0,0 +1,44 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: user_token.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Single-use user token model (email verification, password reset)
+
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserTokenPurpose identifies what a UserToken may be used for.
+type UserTokenPurpose string
+
+const (
+	UserTokenVerifyEmail   UserTokenPurpose = "verify_email"
+	UserTokenResetPassword UserTokenPurpose = "reset_password"
+)
+
+// UserToken is a single-use, expiring token sent to the user by email. Only the SHA-256 of
+// the token is stored. Email records the address the token was sent to.
+type UserToken struct {
+	TokenHash string           `gorm:"type:varchar(64);primary_key" json:"-"`
+	UserID    uuid.UUID        `gorm:"type:uuid;not null;index:idx_user_tokens_user" json:"user_id"`
+	Purpose   UserTokenPurpose `gorm:"type:varchar(32);not null" json:"purpose"`
+	Email     string           `gorm:"type:varchar(255);not null" json:"email"`
+	CreatedAt time.Time        `json:"created_at"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	UsedAt    *time.Time       `json:"used_at,omitempty"`
+}
+
+// TableName returns the database table name for the UserToken model.
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
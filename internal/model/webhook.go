@@ -0,0 +1,107 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: webhook.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Outgoing webhooks of a conversation and their delivery log
+
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Conversation events delivered to outgoing webhooks.
+const (
+	EventNewMessage          = "new_message"
+	EventMessageEdited       = "message_edited"
+	EventMessageDeleted      = "message_deleted"
+	EventMemberJoined        = "member_joined"
+	EventMemberLeft          = "member_left"
+	EventConversationUpdated = "conversation_updated"
+	EventPinChanged          = "pin_changed"
+	EventAnnouncementChanged = "announcement_changed"
+	EventJoinRequested       = "join_requested"
+	EventMemberSilenced      = "member_silenced"
+	EventRoleChanged         = "role_changed"
+)
+
+// WebhookEvents lists every event a webhook may subscribe to.
+var WebhookEvents = []string{EventNewMessage, EventMessageEdited, EventMessageDeleted, EventMemberJoined, EventMemberLeft, EventConversationUpdated, EventPinChanged, EventAnnouncementChanged, EventJoinRequested, EventMemberSilenced, EventRoleChanged}
+
+// Webhook is an HTTP endpoint that receives signed POSTs for events of one conversation.
+// Secret is the HMAC key for the X-UIM-Signature header; it is shown to the creator once.
+// Events is comma-separated.
+type Webhook struct {
+	WebhookID      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"webhook_id"`
+	ConversationID uuid.UUID `gorm:"type:uuid;not null;index:idx_webhooks_conversation_id" json:"conversation_id"`
+	CreatedBy      uuid.UUID `gorm:"type:uuid;not null" json:"created_by"`
+	URL            string    `gorm:"type:text;not null" json:"url"`
+	Secret         string    `gorm:"type:varchar(100);not null" json:"-"`
+	Events         string    `gorm:"type:text;not null" json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName returns the database table name for the Webhook model.
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// EventList returns the events the webhook subscribes to.
+func (w *Webhook) EventList() []string {
+	if w.Events == "" {
+		return nil
+	}
+	return strings.Split(w.Events, ",")
+}
+
+// Subscribes reports whether the webhook receives event.
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.EventList() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus is the state of one delivery.
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending is waiting for its first or next attempt.
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliverySucceeded got a 2xx response.
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryDead gave up after the last attempt or a permanent failure (dead letter).
+	WebhookDeliveryDead WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery records one event sent to a webhook, including retries. Payload is the exact
+// JSON body so retries resend the same content.
+type WebhookDelivery struct {
+	DeliveryID     uuid.UUID             `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"delivery_id"`
+	WebhookID      uuid.UUID             `gorm:"type:uuid;not null;index:idx_webhook_deliveries_webhook" json:"webhook_id"`
+	Event          string                `gorm:"type:varchar(50);not null" json:"event"`
+	Payload        string                `gorm:"type:text;not null" json:"payload"`
+	Status         WebhookDeliveryStatus `gorm:"type:varchar(20);not null" json:"status"`
+	Attempts       int                   `gorm:"not null;default:0" json:"attempts"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	LastError      string                `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	CompletedAt    *time.Time            `json:"completed_at,omitempty"`
+}
+
+// TableName returns the database table name for the WebhookDelivery model.
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -24,23 +24,40 @@ package jwt
 
 import (
 	"errors"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // Claims represents the JWT claims structure.
 type Claims struct {
-	UserID string `json:"user_id"`
-	Type   string `json:"type"` // "access" or "refresh"
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid,omitempty"` // login session the token belongs to
+	Type      string `json:"type"`          // "access" or "refresh"
 	jwt.RegisteredClaims
 }
 
 // JWTManager manages JWT token operations including generation and validation.
+//
+// Tokens are signed with the active key. Keys replaced by Rotate or SyncKeys are retired but
+// keep verifying until every token they could have signed has expired (refreshExpiry).
+// Tokens without a "kid" header are verified with secret (the HS256 setup).
 type JWTManager struct {
 	secret        []byte
 	accessExpiry  time.Duration
 	refreshExpiry time.Duration
+
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*managedKey // kid -> key, includes active and retired keys
+}
+
+type managedKey struct {
+	key       *SigningKey
+	retiredAt time.Time // zero while the key is current
 }
 
 // NewJWTManager creates a new JWT manager with the specified configuration.
@@ -57,7 +74,168 @@ func NewJWTManager(secret string, accessExpiry, refreshExpiry time.Duration) *JW
 		secret:        []byte(secret),
 		accessExpiry:  accessExpiry,
 		refreshExpiry: refreshExpiry,
+		active:        NewHMACKey("", []byte(secret)),
+		keys:          make(map[string]*managedKey),
+	}
+}
+
+// NewJWTManagerWithKeys creates a JWT manager that signs with the active key and also
+// verifies tokens signed by any of verifyOnly (e.g. keys from before a restart).
+//
+// Parameters:
+//   - active: The key used to sign new tokens (its ID is sent as "kid")
+//   - verifyOnly: Additional keys accepted for verification only
+//   - legacySecret: If non-empty, kid-less HS256 tokens signed with it are still accepted
+//   - accessExpiry: The expiration time for access tokens
+//   - refreshExpiry: The expiration time for refresh tokens
+//
+// Returns:
+//   - *JWTManager: A new JWT manager instance
+func NewJWTManagerWithKeys(active *SigningKey, verifyOnly []*SigningKey, legacySecret string, accessExpiry, refreshExpiry time.Duration) *JWTManager {
+	m := &JWTManager{
+		accessExpiry:  accessExpiry,
+		refreshExpiry: refreshExpiry,
+		active:        active,
+		keys:          make(map[string]*managedKey),
+	}
+	if legacySecret != "" {
+		m.secret = []byte(legacySecret)
+	}
+	for _, k := range verifyOnly {
+		m.keys[k.ID] = &managedKey{key: k}
+	}
+	m.keys[active.ID] = &managedKey{key: active}
+	return m
+}
+
+// ActiveKeyID returns the kid of the key currently used for signing ("" for the HS256 setup).
+func (m *JWTManager) ActiveKeyID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active.ID
+}
+
+// Rotate makes key the signing key. The previous signing key is retired and keeps
+// verifying tokens until they expire.
+func (m *JWTManager) Rotate(key *SigningKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if prev, ok := m.keys[m.active.ID]; ok && prev.key != key {
+		prev.retiredAt = now
+	}
+	m.active = key
+	m.keys[key.ID] = &managedKey{key: key}
+	m.pruneLocked(now)
+}
+
+// SyncKeys replaces the current key set (e.g. after re-reading a key directory). Keys no
+// longer present are retired rather than dropped, so tokens they signed stay valid until expiry.
+// activeID must be the ID of one of keys.
+func (m *JWTManager) SyncKeys(keys []*SigningKey, activeID string) error {
+	var active *SigningKey
+	for _, k := range keys {
+		if k.ID == activeID {
+			active = k
+		}
+	}
+	if active == nil {
+		return errors.New("active key not in key set")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	present := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		present[k.ID] = true
+		m.keys[k.ID] = &managedKey{key: k}
+	}
+	for id, mk := range m.keys {
+		if !present[id] && mk.retiredAt.IsZero() {
+			mk.retiredAt = now
+		}
+	}
+	m.active = active
+	m.pruneLocked(now)
+	return nil
+}
+
+// pruneLocked drops retired keys whose tokens have all expired. Caller must hold m.mu.
+func (m *JWTManager) pruneLocked(now time.Time) {
+	for id, mk := range m.keys {
+		if !mk.retiredAt.IsZero() && now.Sub(mk.retiredAt) > m.refreshExpiry {
+			delete(m.keys, id)
+		}
+	}
+}
+
+// JWKS returns the public keys (RS256 / EdDSA) that can verify tokens, sorted by kid.
+// HMAC keys are never published.
+func (m *JWTManager) JWKS() JWKSet {
+	m.mu.Lock()
+	m.pruneLocked(time.Now())
+	set := JWKSet{Keys: []JWK{}}
+	for _, mk := range m.keys {
+		if jwk, ok := mk.key.publicJWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// sign signs claims with the active key, setting the "kid" header when the key has an ID.
+func (m *JWTManager) sign(claims *Claims) (string, error) {
+	m.mu.RLock()
+	key := m.active
+	m.mu.RUnlock()
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.private)
+}
+
+// verificationKey resolves the key for a parsed (unverified) token and checks that its
+// algorithm matches, which rules out algorithm-confusion attacks.
+func (m *JWTManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(m.secret) == 0 {
+			return nil, errors.New("invalid signing method")
+		}
+		return m.secret, nil
+	}
+	// Copy under the lock: SyncKeys and Rotate retire keys in place.
+	m.mu.RLock()
+	mk, ok := m.keys[kid]
+	var key *SigningKey
+	var retiredAt time.Time
+	if ok {
+		key, retiredAt = mk.key, mk.retiredAt
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if !retiredAt.IsZero() && time.Since(retiredAt) > m.refreshExpiry {
+		return nil, errors.New("signing key expired")
+	}
+	if token.Method.Alg() != key.signingMethod().Alg() {
+		return nil, errors.New("invalid signing method")
 	}
+	return key.public, nil
+}
+
+// AccessExpiry returns the lifetime of access tokens.
+func (m *JWTManager) AccessExpiry() time.Duration {
+	return m.accessExpiry
+}
+
+// RefreshExpiry returns the lifetime of refresh tokens (also used as the session lifetime).
+func (m *JWTManager) RefreshExpiry() time.Duration {
+	return m.refreshExpiry
 }
 
 // GenerateAccessToken generates a JWT access token for the given user ID.
@@ -67,22 +245,24 @@ func NewJWTManager(secret string, accessExpiry, refreshExpiry time.Duration) *JW
 //
 // Parameters:
 //   - userID: The unique identifier of the user
+//   - sessionID: The login session the token is bound to (may be empty)
 //
 // Returns:
 //   - string: The signed JWT access token
 //   - error: An error if token generation fails
-func (m *JWTManager) GenerateAccessToken(userID string) (string, error) {
+func (m *JWTManager) GenerateAccessToken(userID, sessionID string) (string, error) {
 	claims := &Claims{
-		UserID: userID,
-		Type:   "access",
+		UserID:    userID,
+		SessionID: sessionID,
+		Type:      "access",
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.accessExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(m.secret)
+	return m.sign(claims)
 }
 
 // GenerateRefreshToken generates a JWT refresh token for the given user ID.
@@ -92,22 +272,49 @@ func (m *JWTManager) GenerateAccessToken(userID string) (string, error) {
 //
 // Parameters:
 //   - userID: The unique identifier of the user
+//   - sessionID: The login session the token is bound to (may be empty)
 //
 // Returns:
 //   - string: The signed JWT refresh token
 //   - error: An error if token generation fails
-func (m *JWTManager) GenerateRefreshToken(userID string) (string, error) {
+func (m *JWTManager) GenerateRefreshToken(userID, sessionID string) (string, error) {
 	claims := &Claims{
-		UserID: userID,
-		Type:   "refresh",
+		UserID:    userID,
+		SessionID: sessionID,
+		Type:      "refresh",
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.refreshExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(m.secret)
+	return m.sign(claims)
+}
+
+// GenerateMFAPendingToken generates a short-lived token proving that the user passed the
+// password step of login. It cannot be used as an access token; it is only exchanged,
+// together with a second-factor code, for a real session.
+//
+// Parameters:
+//   - userID: The unique identifier of the user
+//   - ttl: The lifetime of the token
+//
+// Returns:
+//   - string: The signed JWT token
+//   - error: An error if token generation fails
+func (m *JWTManager) GenerateMFAPendingToken(userID string, ttl time.Duration) (string, error) {
+	claims := &Claims{
+		UserID: userID,
+		Type:   "mfa_pending",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return m.sign(claims)
 }
 
 // ValidateToken validates a JWT token and returns its claims.
@@ -122,12 +329,7 @@ func (m *JWTManager) GenerateRefreshToken(userID string) (string, error) {
 //   - *Claims: The token claims if valid
 //   - error: An error if validation fails
 func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return m.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, m.verificationKey)
 
 	if err != nil {
 		return nil, err
@@ -187,3 +389,24 @@ func (m *JWTManager) ValidateRefreshToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// ValidateMFAPendingToken validates a token issued by GenerateMFAPendingToken.
+//
+// Parameters:
+//   - tokenString: The JWT token string to validate
+//
+// Returns:
+//   - *Claims: The token claims if valid
+//   - error: An error if validation fails or token is not an mfa_pending token
+func (m *JWTManager) ValidateMFAPendingToken(tokenString string) (*Claims, error) {
+	claims, err := m.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != "mfa_pending" {
+		return nil, errors.New("token is not an mfa_pending token")
+	}
+
+	return claims, nil
+}
@@ -31,7 +31,7 @@ func TestJWTManager_GenerateAccessToken(t *testing.T) {
 	manager := NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
 	userID := "test-user-id"
 
-	token, err := manager.GenerateAccessToken(userID)
+	token, err := manager.GenerateAccessToken(userID, "")
 	if err != nil {
 		t.Fatalf("GenerateAccessToken() error = %v", err)
 	}
@@ -56,7 +56,7 @@ func TestJWTManager_GenerateRefreshToken(t *testing.T) {
 	manager := NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
 	userID := "test-user-id"
 
-	token, err := manager.GenerateRefreshToken(userID)
+	token, err := manager.GenerateRefreshToken(userID, "")
 	if err != nil {
 		t.Fatalf("GenerateRefreshToken() error = %v", err)
 	}
@@ -90,7 +90,7 @@ func TestJWTManager_WrongTokenType(t *testing.T) {
 	manager := NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
 	userID := "test-user-id"
 
-	refreshToken, _ := manager.GenerateRefreshToken(userID)
+	refreshToken, _ := manager.GenerateRefreshToken(userID, "")
 
 	// Try to validate refresh token as access token
 	_, err := manager.ValidateAccessToken(refreshToken)
@@ -103,7 +103,7 @@ func TestJWTManager_AccessTokenAsRefreshToken(t *testing.T) {
 	manager := NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
 	userID := "test-user-id"
 
-	accessToken, _ := manager.GenerateAccessToken(userID)
+	accessToken, _ := manager.GenerateAccessToken(userID, "")
 
 	// Try to validate access token as refresh token
 	_, err := manager.ValidateRefreshToken(accessToken)
@@ -117,7 +117,7 @@ func TestJWTManager_DifferentSecrets(t *testing.T) {
 	manager2 := NewJWTManager("secret2", 15*time.Minute, 168*time.Hour)
 	userID := "test-user-id"
 
-	token, _ := manager1.GenerateAccessToken(userID)
+	token, _ := manager1.GenerateAccessToken(userID, "")
 
 	// Token signed with secret1 should not validate with secret2
 	_, err := manager2.ValidateAccessToken(token)
@@ -129,7 +129,7 @@ func TestJWTManager_DifferentSecrets(t *testing.T) {
 func TestJWTManager_EmptyUserID(t *testing.T) {
 	manager := NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
 
-	token, err := manager.GenerateAccessToken("")
+	token, err := manager.GenerateAccessToken("", "")
 	if err != nil {
 		t.Fatalf("GenerateAccessToken() with empty userID should not error, got: %v", err)
 	}
@@ -147,7 +147,7 @@ func TestJWTManager_ExpiredToken(t *testing.T) {
 	manager := NewJWTManager("test-secret", -1*time.Hour, 168*time.Hour) // Already expired
 	userID := "test-user-id"
 
-	token, err := manager.GenerateAccessToken(userID)
+	token, err := manager.GenerateAccessToken(userID, "")
 	if err != nil {
 		t.Fatalf("GenerateAccessToken() error = %v", err)
 	}
@@ -180,3 +180,43 @@ func TestNewJWTManager(t *testing.T) {
 		t.Errorf("NewJWTManager() refreshExpiry = %v, want %v", manager.refreshExpiry, refreshExpiry)
 	}
 }
+
+func TestJWTManager_SessionIDRoundTrip(t *testing.T) {
+	manager := NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+
+	token, err := manager.GenerateRefreshToken("test-user-id", "test-session-id")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken() error = %v", err)
+	}
+	claims, err := manager.ValidateRefreshToken(token)
+	if err != nil {
+		t.Fatalf("ValidateRefreshToken() error = %v", err)
+	}
+	if claims.SessionID != "test-session-id" {
+		t.Errorf("ValidateRefreshToken() sessionID = %v, want test-session-id", claims.SessionID)
+	}
+}
+
+func TestJWTManager_MFAPendingToken(t *testing.T) {
+	manager := NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+
+	token, err := manager.GenerateMFAPendingToken("user-1", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateMFAPendingToken() error = %v", err)
+	}
+	claims, err := manager.ValidateMFAPendingToken(token)
+	if err != nil {
+		t.Fatalf("ValidateMFAPendingToken() error = %v", err)
+	}
+	if claims.UserID != "user-1" || claims.ID == "" {
+		t.Errorf("claims = %+v, want user-1 with jti", claims)
+	}
+	// A pending token must never pass as an access token, and vice versa.
+	if _, err := manager.ValidateAccessToken(token); err == nil {
+		t.Error("ValidateAccessToken() accepted an mfa_pending token")
+	}
+	access, _ := manager.GenerateAccessToken("user-1", "")
+	if _, err := manager.ValidateMFAPendingToken(access); err == nil {
+		t.Error("ValidateMFAPendingToken() accepted an access token")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: keydir.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Key directory loading, periodic reload and scheduled key rotation
+
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// keyFileExt is the extension of private key files in a key directory; the file name
+// without it is the key ID.
+const keyFileExt = ".pem"
+
+// keyIDFormat names generated keys so that lexical order equals creation order.
+const keyIDFormat = "20060102T150405Z"
+
+// KeyDir is a directory of PEM private keys named <kid>.pem. The key with the greatest kid
+// that has been in the directory for ActivationDelay signs new tokens; the others only verify.
+// Several instances can share one directory (e.g. a mounted secret) and converge on the same
+// active key after a reload.
+type KeyDir struct {
+	Dir string
+	// Algorithm is used for generated keys (RS256 or EdDSA).
+	Algorithm string
+	// RotateEvery, if > 0, generates a new key when the newest key file is older than this.
+	RotateEvery time.Duration
+	// ActivationDelay is how long a new key is only published (verifying, in the JWKS) before
+	// it signs, so that other instances reloading the directory and clients caching the JWKS
+	// know it before they see its tokens. 0 signs with a new key at once.
+	ActivationDelay time.Duration
+}
+
+// Load reads all keys in the directory, generating the first key (or the next one when
+// rotation is due). Returns the keys and the ID of the active key.
+func (d KeyDir) Load(now time.Time) ([]*SigningKey, string, error) {
+	keys, created, newest, err := d.read()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(keys) == 0 || (d.RotateEvery > 0 && now.Sub(newest) >= d.RotateEvery) {
+		key, err := d.generate(now)
+		if err != nil {
+			return nil, "", err
+		}
+		keys = append(keys, key)
+		created[key.ID] = now
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, d.activeID(keys, created, now), nil
+}
+
+// activeID picks the newest key published for at least ActivationDelay. If none is that old
+// (e.g. the first key of a new directory), the oldest key signs. keys must be sorted by ID.
+func (d KeyDir) activeID(keys []*SigningKey, created map[string]time.Time, now time.Time) string {
+	active := keys[0].ID
+	for _, k := range keys {
+		if now.Sub(keyCreatedAt(k.ID, created[k.ID])) >= d.ActivationDelay {
+			active = k.ID
+		}
+	}
+	return active
+}
+
+// keyCreatedAt returns when a key was added: the time in its ID for generated keys (the same on
+// every instance), else the file modification time.
+func keyCreatedAt(kid string, modTime time.Time) time.Time {
+	if t, err := time.Parse(keyIDFormat, kid); err == nil {
+		return t
+	}
+	return modTime
+}
+
+// read parses every <kid>.pem file and returns each file's modification time and the newest.
+func (d KeyDir) read() ([]*SigningKey, map[string]time.Time, time.Time, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if err != nil {
+		return nil, nil, time.Time{}, fmt.Errorf("read key dir: %w", err)
+	}
+	var keys []*SigningKey
+	created := make(map[string]time.Time)
+	var newest time.Time
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), keyFileExt) {
+			continue
+		}
+		path := filepath.Join(d.Dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, time.Time{}, fmt.Errorf("read key %s: %w", e.Name(), err)
+		}
+		key, err := ParsePrivateKeyPEM(strings.TrimSuffix(e.Name(), keyFileExt), data)
+		if err != nil {
+			return nil, nil, time.Time{}, fmt.Errorf("parse key %s: %w", e.Name(), err)
+		}
+		keys = append(keys, key)
+		if info, err := e.Info(); err == nil {
+			created[key.ID] = info.ModTime()
+			if info.ModTime().After(newest) {
+				newest = info.ModTime()
+			}
+		}
+	}
+	return keys, created, newest, nil
+}
+
+// generate creates a new key named after now and writes it with owner-only permissions.
+func (d KeyDir) generate(now time.Time) (*SigningKey, error) {
+	key, err := GenerateKey(now.UTC().Format(keyIDFormat), d.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	data, err := key.MarshalPrivateKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(d.Dir, key.ID+keyFileExt)
+	// O_EXCL: if another instance generated the same kid concurrently, keep theirs.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read key %s: %w", path, err)
+			}
+			return ParsePrivateKeyPEM(key.ID, data)
+		}
+		return nil, fmt.Errorf("write key: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return nil, fmt.Errorf("write key: %w", err)
+	}
+	log.Printf("[AUTH] generated new %s signing key kid=%s", key.Algorithm, key.ID)
+	return key, nil
+}
+
+// NewJWTManagerFromKeyDir loads the key directory and creates a manager signing with its
+// active key. legacySecret may be empty (kid-less HS256 tokens are then rejected).
+func NewJWTManagerFromKeyDir(d KeyDir, legacySecret string, accessExpiry, refreshExpiry time.Duration) (*JWTManager, error) {
+	keys, activeID, err := d.Load(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var active *SigningKey
+	for _, k := range keys {
+		if k.ID == activeID {
+			active = k
+		}
+	}
+	m := NewJWTManagerWithKeys(active, nil, legacySecret, accessExpiry, refreshExpiry)
+	if err := m.SyncKeys(keys, activeID); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WatchKeyDir reloads the key directory every interval until ctx is done, picking up keys
+// added by an operator or another instance, performing scheduled rotation and activating new
+// keys once their ActivationDelay has passed. Keys removed from the directory keep verifying
+// until the tokens they signed have expired.
+func WatchKeyDir(ctx context.Context, m *JWTManager, d KeyDir, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			keys, activeID, err := d.Load(now)
+			if err != nil {
+				log.Printf("[AUTH] key dir reload failed (keeping current keys): %v", err)
+				continue
+			}
+			prev := m.ActiveKeyID()
+			if err := m.SyncKeys(keys, activeID); err != nil {
+				log.Printf("[AUTH] key dir reload failed (keeping current keys): %v", err)
+				continue
+			}
+			if prev != activeID {
+				log.Printf("[AUTH] signing key rotated kid=%s (previous kid=%s)", activeID, prev)
+			}
+		}
+	}
+}
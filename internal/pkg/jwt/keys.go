@@ -0,0 +1,171 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: keys.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Signing keys (HS256 / RS256 / EdDSA), PEM encoding and JWKS export
+
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the modulus size used for generated RS256 keys.
+const rsaKeyBits = 2048
+
+// SigningKey is one key of the manager's key set, identified by ID (the JWT "kid" header).
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	private   interface{} // []byte, *rsa.PrivateKey or ed25519.PrivateKey
+	public    interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// NewHMACKey creates an HS256 key from a shared secret.
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{ID: id, Algorithm: AlgorithmHS256, private: secret, public: secret}
+}
+
+// NewRSAKey creates an RS256 key from an RSA private key.
+func NewRSAKey(id string, key *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Algorithm: AlgorithmRS256, private: key, public: &key.PublicKey}
+}
+
+// NewEdDSAKey creates an EdDSA (Ed25519) key from a private key.
+func NewEdDSAKey(id string, key ed25519.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Algorithm: AlgorithmEdDSA, private: key, public: key.Public()}
+}
+
+// GenerateKey creates a new random RS256 or EdDSA key.
+func GenerateKey(id, algorithm string) (*SigningKey, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, fmt.Errorf("generate rsa key: %w", err)
+		}
+		return NewRSAKey(id, key), nil
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate ed25519 key: %w", err)
+		}
+		return NewEdDSAKey(id, key), nil
+	default:
+		return nil, fmt.Errorf("cannot generate key for algorithm %q", algorithm)
+	}
+}
+
+// ParsePrivateKeyPEM parses a PEM-encoded RSA (PKCS#1 or PKCS#8) or Ed25519 (PKCS#8) private key.
+func ParsePrivateKeyPEM(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewRSAKey(id, key), nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, key), nil
+	case ed25519.PrivateKey:
+		return NewEdDSAKey(id, key), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+}
+
+// MarshalPrivateKeyPEM encodes an RS256 or EdDSA key as a PKCS#8 PEM block.
+func (k *SigningKey) MarshalPrivateKeyPEM() ([]byte, error) {
+	if k.Algorithm == AlgorithmHS256 {
+		return nil, errors.New("HMAC keys have no PEM form")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, fmt.Errorf("marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// signingMethod returns the jwt-go signing method for the key's algorithm.
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// JWK is a public JSON Web Key (RFC 7517) as published in the JWKS document.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKSet is the JWKS document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// publicJWK returns the public JWK for asymmetric keys; HMAC keys are never published.
+func (k *SigningKey) publicJWK() (JWK, bool) {
+	enc := base64.RawURLEncoding
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     k.ID,
+			Use:       "sig",
+			Algorithm: k.Algorithm,
+			N:         enc.EncodeToString(pub.N.Bytes()),
+			E:         enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			KeyID:     k.ID,
+			Use:       "sig",
+			Algorithm: k.Algorithm,
+			Curve:     "Ed25519",
+			X:         enc.EncodeToString(pub),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: keys_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for asymmetric signing, key rotation, JWKS and key directories
+
+package jwt
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func mustGenerateKey(t *testing.T, id, algorithm string) *SigningKey {
+	t.Helper()
+	key, err := GenerateKey(id, algorithm)
+	if err != nil {
+		t.Fatalf("GenerateKey(%s) error = %v", algorithm, err)
+	}
+	return key
+}
+
+func TestJWTManager_AsymmetricRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			key := mustGenerateKey(t, "k1", alg)
+			manager := NewJWTManagerWithKeys(key, nil, "", 15*time.Minute, time.Hour)
+
+			token, err := manager.GenerateAccessToken("user-1", "")
+			if err != nil {
+				t.Fatalf("GenerateAccessToken() error = %v", err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified() error = %v", err)
+			}
+			if parsed.Header["kid"] != "k1" || parsed.Header["alg"] != alg {
+				t.Errorf("header = %v, want kid=k1 alg=%s", parsed.Header, alg)
+			}
+			claims, err := manager.ValidateAccessToken(token)
+			if err != nil {
+				t.Fatalf("ValidateAccessToken() error = %v", err)
+			}
+			if claims.UserID != "user-1" {
+				t.Errorf("UserID = %v, want user-1", claims.UserID)
+			}
+		})
+	}
+}
+
+func TestJWTManager_RejectsAlgorithmConfusion(t *testing.T) {
+	key := mustGenerateKey(t, "k1", AlgorithmEdDSA)
+	manager := NewJWTManagerWithKeys(key, nil, "", 15*time.Minute, time.Hour)
+
+	// HS256 token claiming the EdDSA kid, "signed" with the public key bytes.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: "attacker", Type: "access"})
+	forged.Header["kid"] = "k1"
+	s, err := forged.SignedString([]byte(key.public.(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	if _, err := manager.ValidateAccessToken(s); err == nil {
+		t.Error("ValidateAccessToken() accepted HS256 token for EdDSA key")
+	}
+
+	// Kid-less HS256 tokens are rejected when no legacy secret is configured.
+	legacy := NewJWTManager("old-secret", 15*time.Minute, time.Hour)
+	s, _ = legacy.GenerateAccessToken("user-1", "")
+	if _, err := manager.ValidateAccessToken(s); err == nil {
+		t.Error("ValidateAccessToken() accepted kid-less token without legacy secret")
+	}
+}
+
+func TestJWTManager_LegacySecret(t *testing.T) {
+	legacy := NewJWTManager("old-secret", 15*time.Minute, time.Hour)
+	old, _ := legacy.GenerateAccessToken("user-1", "")
+
+	manager := NewJWTManagerWithKeys(mustGenerateKey(t, "k1", AlgorithmEdDSA), nil, "old-secret", 15*time.Minute, time.Hour)
+	if _, err := manager.ValidateAccessToken(old); err != nil {
+		t.Errorf("ValidateAccessToken(legacy) error = %v", err)
+	}
+}
+
+func TestJWTManager_Rotate(t *testing.T) {
+	k1 := mustGenerateKey(t, "k1", AlgorithmEdDSA)
+	k2 := mustGenerateKey(t, "k2", AlgorithmEdDSA)
+	manager := NewJWTManagerWithKeys(k1, nil, "", 15*time.Minute, time.Hour)
+
+	old, _ := manager.GenerateRefreshToken("user-1", "")
+	manager.Rotate(k2)
+	if got := manager.ActiveKeyID(); got != "k2" {
+		t.Fatalf("ActiveKeyID() = %v, want k2", got)
+	}
+	fresh, _ := manager.GenerateRefreshToken("user-1", "")
+	if _, err := manager.ValidateRefreshToken(old); err != nil {
+		t.Errorf("token from retired key rejected: %v", err)
+	}
+	if _, err := manager.ValidateRefreshToken(fresh); err != nil {
+		t.Errorf("token from active key rejected: %v", err)
+	}
+	if got := len(manager.JWKS().Keys); got != 2 {
+		t.Errorf("JWKS() keys = %d, want 2 while retired key is in use", got)
+	}
+
+	// Once every token from the retired key has expired, the key is dropped.
+	manager.mu.Lock()
+	manager.keys["k1"].retiredAt = time.Now().Add(-2 * time.Hour)
+	manager.mu.Unlock()
+	if _, err := manager.ValidateRefreshToken(old); err == nil {
+		t.Error("token from expired retired key accepted")
+	}
+	jwks := manager.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "k2" {
+		t.Errorf("JWKS() = %+v, want only k2", jwks.Keys)
+	}
+}
+
+func TestJWTManager_JWKS(t *testing.T) {
+	rsaKey := mustGenerateKey(t, "a-rsa", AlgorithmRS256)
+	edKey := mustGenerateKey(t, "b-ed", AlgorithmEdDSA)
+	manager := NewJWTManagerWithKeys(edKey, []*SigningKey{rsaKey}, "", 15*time.Minute, time.Hour)
+
+	jwks := manager.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS() keys = %d, want 2", len(jwks.Keys))
+	}
+	if k := jwks.Keys[0]; k.KeyID != "a-rsa" || k.KeyType != "RSA" || k.N == "" || k.E != "AQAB" {
+		t.Errorf("RSA JWK = %+v", k)
+	}
+	if k := jwks.Keys[1]; k.KeyID != "b-ed" || k.KeyType != "OKP" || k.Curve != "Ed25519" || k.X == "" {
+		t.Errorf("Ed25519 JWK = %+v", k)
+	}
+
+	if got := NewJWTManager("secret", time.Minute, time.Hour).JWKS().Keys; len(got) != 0 {
+		t.Errorf("HS256 JWKS() = %+v, want no keys", got)
+	}
+}
+
+func TestKeyDir_LoadAndRotate(t *testing.T) {
+	dir := t.TempDir()
+	d := KeyDir{Dir: dir, Algorithm: AlgorithmEdDSA, RotateEvery: 24 * time.Hour}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	keys, activeID, err := d.Load(now)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(keys) != 1 || activeID != "20250601T120000Z" {
+		t.Fatalf("Load() = %d keys, active %q; want 1 generated key", len(keys), activeID)
+	}
+	info, err := os.Stat(filepath.Join(dir, activeID+".pem"))
+	if err != nil {
+		t.Fatalf("generated key file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	manager, err := NewJWTManagerFromKeyDir(d, "", 15*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("NewJWTManagerFromKeyDir() error = %v", err)
+	}
+	old, _ := manager.GenerateAccessToken("user-1", "")
+
+	// Rotation is due once the newest key file is older than RotateEvery.
+	later := info.ModTime().Add(25 * time.Hour)
+	keys, activeID, err = d.Load(later)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(keys) != 2 || activeID != later.UTC().Format(keyIDFormat) {
+		t.Fatalf("Load() = %d keys, active %q; want rotation", len(keys), activeID)
+	}
+	if err := manager.SyncKeys(keys, activeID); err != nil {
+		t.Fatalf("SyncKeys() error = %v", err)
+	}
+	if manager.ActiveKeyID() != activeID {
+		t.Errorf("ActiveKeyID() = %v, want %v", manager.ActiveKeyID(), activeID)
+	}
+	if _, err := manager.ValidateAccessToken(old); err != nil {
+		t.Errorf("token signed before rotation rejected: %v", err)
+	}
+}
+
+func TestKeyDir_ActivationDelay(t *testing.T) {
+	dir := t.TempDir()
+	d := KeyDir{Dir: dir, Algorithm: AlgorithmEdDSA, RotateEvery: 24 * time.Hour, ActivationDelay: 10 * time.Minute}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// The first key of an empty directory signs at once.
+	_, first, err := d.Load(now)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if first != "20250601T120000Z" {
+		t.Fatalf("active = %q, want the first key", first)
+	}
+
+	keys, _, err := d.Load(now)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	manager := NewJWTManagerWithKeys(keys[0], nil, "", 15*time.Minute, time.Hour)
+
+	// A rotated key is published but the previous key keeps signing until the delay has passed.
+	info, err := os.Stat(filepath.Join(dir, first+".pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotation := info.ModTime().Add(25 * time.Hour)
+	keys, activeID, err := d.Load(rotation)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(keys) != 2 || activeID != first {
+		t.Fatalf("Load() at rotation = %d keys, active %q; want the new key published and %q signing", len(keys), activeID, first)
+	}
+	if err := manager.SyncKeys(keys, activeID); err != nil {
+		t.Fatalf("SyncKeys() error = %v", err)
+	}
+	newID := rotation.UTC().Format(keyIDFormat)
+	if jwks := manager.JWKS(); len(jwks.Keys) != 2 || jwks.Keys[1].KeyID != newID {
+		t.Errorf("JWKS() = %+v, want the new key published", jwks.Keys)
+	}
+
+	_, activeID, err = d.Load(rotation.Add(10 * time.Minute))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if activeID != newID {
+		t.Errorf("active after the delay = %q, want %q", activeID, newID)
+	}
+}
+
+func TestJWTManager_ValidateDuringSyncKeys(t *testing.T) {
+	key := mustGenerateKey(t, "a", AlgorithmEdDSA)
+	other := mustGenerateKey(t, "b", AlgorithmEdDSA)
+	manager := NewJWTManagerWithKeys(key, nil, "", 15*time.Minute, time.Hour)
+	token, err := manager.GenerateAccessToken("user-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run with -race: SyncKeys retires key "a" while tokens signed with it are verified.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			_ = manager.SyncKeys([]*SigningKey{other}, "b")
+			_ = manager.SyncKeys([]*SigningKey{key, other}, "a")
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if _, err := manager.ValidateAccessToken(token); err != nil {
+			t.Fatalf("ValidateAccessToken() error = %v", err)
+		}
+	}
+	<-done
+}
+
+func TestParsePrivateKeyPEM_RoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		key := mustGenerateKey(t, "k", alg)
+		data, err := key.MarshalPrivateKeyPEM()
+		if err != nil {
+			t.Fatalf("MarshalPrivateKeyPEM(%s) error = %v", alg, err)
+		}
+		parsed, err := ParsePrivateKeyPEM("k", data)
+		if err != nil {
+			t.Fatalf("ParsePrivateKeyPEM(%s) error = %v", alg, err)
+		}
+		if parsed.Algorithm != alg {
+			t.Errorf("Algorithm = %v, want %v", parsed.Algorithm, alg)
+		}
+	}
+	if _, err := ParsePrivateKeyPEM("k", []byte("not a key")); err == nil {
+		t.Error("ParsePrivateKeyPEM() accepted garbage")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: mailer_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for mailer implementations
+
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFormatMessage_StripsHeaderInjection(t *testing.T) {
+	raw := string(formatMessage("noreply@example.org", Message{
+		To:      "alice@example.org\r\nBcc: evil@example.org",
+		Subject: "Hi\nX-Injected: 1",
+		Body:    "line1\nline2",
+	}))
+	if strings.Contains(raw, "\r\nBcc:") || strings.Contains(raw, "\r\nX-Injected:") {
+		t.Errorf("header injection not stripped:\n%s", raw)
+	}
+	if !strings.HasSuffix(raw, "line1\r\nline2") {
+		t.Errorf("body not CRLF-normalized:\n%q", raw)
+	}
+}
+
+func TestLogMailer_WritesMessage(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf)
+	if err := m.Send(context.Background(), Message{To: "a@example.org", Subject: "S", Body: "B"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "To: a@example.org") || !strings.Contains(out, "Subject: S") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestMemoryMailer_Last(t *testing.T) {
+	m := NewMemoryMailer()
+	_ = m.Send(context.Background(), Message{To: "a@example.org", Subject: "1"})
+	_ = m.Send(context.Background(), Message{To: "b@example.org", Subject: "2"})
+	_ = m.Send(context.Background(), Message{To: "a@example.org", Subject: "3"})
+
+	if got, ok := m.Last("a@example.org"); !ok || got.Subject != "3" {
+		t.Errorf("Last() = %+v, %v; want subject 3", got, ok)
+	}
+	if _, ok := m.Last("c@example.org"); ok {
+		t.Error("Last() found message for unknown address")
+	}
+	if n := len(m.Messages()); n != 3 {
+		t.Errorf("Messages() = %d, want 3", n)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: oidc_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for the OpenID Connect relying party against a fake provider
+
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/convexwf/uim-go/internal/pkg/oidc"
+	"github.com/convexwf/uim-go/internal/pkg/oidc/oidctest"
+)
+
+func newTestProvider(t *testing.T, idp *oidctest.Server) *oidc.Provider {
+	t.Helper()
+	return oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       idp.Issuer(),
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  "https://chat.example.org/auth/oidc/test/callback",
+	}, nil)
+}
+
+// login runs the browser part of the flow and returns the code for the given nonce/verifier.
+func login(t *testing.T, idp *oidctest.Server, p *oidc.Provider, nonce, verifier string) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	if got := u.Query().Get("code_challenge"); got != oidc.CodeChallenge(verifier) {
+		t.Errorf("code_challenge = %q, want S256 of verifier", got)
+	}
+	code, state := idp.Authorize(t, authURL)
+	if state != "state-1" {
+		t.Errorf("state = %q, want state-1", state)
+	}
+	return code
+}
+
+func TestProvider_Authenticate(t *testing.T) {
+	idp := oidctest.NewServer(t, "uim", "s3cret")
+	p := newTestProvider(t, idp)
+
+	code := login(t, idp, p, "nonce-1", "verifier-1")
+	id, err := p.Authenticate(context.Background(), code, "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if id.Subject != "subject-1" || id.Email != "sso-user@example.com" || !id.EmailVerified || id.Issuer != idp.Issuer() {
+		t.Errorf("Identity = %+v", id)
+	}
+
+	// Codes are single-use.
+	if _, err := p.Authenticate(context.Background(), code, "verifier-1", "nonce-1"); !errors.Is(err, oidc.ErrExchange) {
+		t.Errorf("Authenticate(reused code) error = %v, want ErrExchange", err)
+	}
+}
+
+func TestProvider_PublicClient(t *testing.T) {
+	idp := oidctest.NewServer(t, "uim-public", "")
+	p := newTestProvider(t, idp)
+	code := login(t, idp, p, "n", "v")
+	if _, err := p.Authenticate(context.Background(), code, "v", "n"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+}
+
+func TestProvider_Rejects(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("wrong PKCE verifier", func(t *testing.T) {
+		idp := oidctest.NewServer(t, "uim", "s3cret")
+		p := newTestProvider(t, idp)
+		code := login(t, idp, p, "n", "right-verifier")
+		if _, err := p.Authenticate(ctx, code, "wrong-verifier", "n"); !errors.Is(err, oidc.ErrExchange) {
+			t.Errorf("error = %v, want ErrExchange", err)
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		idp := oidctest.NewServer(t, "uim", "s3cret")
+		p := newTestProvider(t, idp)
+		code := login(t, idp, p, "n1", "v")
+		if _, err := p.Authenticate(ctx, code, "v", "n2"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+			t.Errorf("error = %v, want ErrInvalidIDToken", err)
+		}
+	})
+
+	claims := map[string]interface{}{
+		"audience": "someone-else",
+		"issuer":   "https://evil.example.com",
+		"expired":  time.Now().Add(-time.Hour).Unix(),
+	}
+	names := map[string]string{"audience": "aud", "issuer": "iss", "expired": "exp"}
+	for name, value := range claims {
+		t.Run("wrong "+name, func(t *testing.T) {
+			idp := oidctest.NewServer(t, "uim", "s3cret")
+			idp.SetClaim(names[name], value)
+			p := newTestProvider(t, idp)
+			code := login(t, idp, p, "n", "v")
+			if _, err := p.Authenticate(ctx, code, "v", "n"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Errorf("error = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+
+	t.Run("wrong client secret", func(t *testing.T) {
+		idp := oidctest.NewServer(t, "uim", "s3cret")
+		p := oidc.NewProvider(oidc.Config{Name: "test", Issuer: idp.Issuer(), ClientID: "uim", ClientSecret: "nope", RedirectURL: "https://x/cb"}, nil)
+		authURL, _ := p.AuthCodeURL(ctx, "s", "n", "v")
+		code, _ := idp.Authorize(t, authURL)
+		if _, err := p.Authenticate(ctx, code, "v", "n"); !errors.Is(err, oidc.ErrExchange) {
+			t.Errorf("error = %v, want ErrExchange", err)
+		}
+	})
+
+	t.Run("issuer mismatch in discovery", func(t *testing.T) {
+		idp := oidctest.NewServer(t, "uim", "s3cret")
+		p := oidc.NewProvider(oidc.Config{Name: "test", Issuer: idp.Issuer() + "/tenant", ClientID: "uim"}, nil)
+		if _, err := p.AuthCodeURL(ctx, "s", "n", "v"); !errors.Is(err, oidc.ErrDiscovery) {
+			t.Errorf("error = %v, want ErrDiscovery", err)
+		}
+	})
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B.
+	got := oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("CodeChallenge() = %v, want %v", got, want)
+	}
+}
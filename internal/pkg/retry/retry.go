@@ -51,3 +51,19 @@ func Do(maxAttempts int, baseDelay time.Duration, fn func() error) error {
 	}
 	return lastErr
 }
+
+// Backoff returns the delay before retry number attempt (0-based) with the same exponential
+// schedule as Do: base, 2*base, 4*base, ... capped at max (max <= 0 means no cap).
+func Backoff(base time.Duration, attempt int, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < attempt; i++ {
+		if max > 0 && d >= max {
+			break
+		}
+		d *= 2
+	}
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
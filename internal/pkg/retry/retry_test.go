@@ -14,6 +14,7 @@ package retry
 import (
 	"errors"
 	"testing"
+	"time"
 )
 
 func TestIsRetryableError(t *testing.T) {
@@ -82,3 +83,22 @@ func TestDo_NonRetryableFailsImmediately(t *testing.T) {
 		t.Errorf("expected 1 attempt (no retry), got %d", attempts)
 	}
 }
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+		want    time.Duration
+	}{
+		{0, 0, time.Second},
+		{1, 0, 2 * time.Second},
+		{3, 0, 8 * time.Second},
+		{3, 5 * time.Second, 5 * time.Second},
+		{100, time.Minute, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := Backoff(time.Second, tt.attempt, tt.max); got != tt.want {
+			t.Errorf("Backoff(1s, %d, %s) = %s, want %s", tt.attempt, tt.max, got, tt.want)
+		}
+	}
+}
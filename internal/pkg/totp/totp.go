@@ -0,0 +1,121 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: totp.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Time-based one-time passwords (RFC 6238, HMAC-SHA1) and provisioning URIs
+
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parameters used by authenticator apps by default (Google Authenticator, 1Password, ...).
+const (
+	Digits     = 6
+	Period     = 30 * time.Second
+	secretSize = 20 // 160-bit key, as recommended by RFC 4226
+)
+
+// Skew is the number of periods before and after the current one that are accepted,
+// tolerating clock drift between server and device.
+const Skew = 1
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random base32-encoded (unpadded) secret.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate totp secret: %w", err)
+	}
+	return encoding.EncodeToString(buf), nil
+}
+
+// decodeSecret accepts secrets with or without padding, in any case and with spaces.
+func decodeSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return encoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Step returns the RFC 6238 time step (counter) for t.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// HOTP computes the RFC 4226 one-time password for key and counter with the given digits.
+func HOTP(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, bin%mod)
+}
+
+// Code returns the current code for a base32 secret at t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", fmt.Errorf("decode totp secret: %w", err)
+	}
+	return HOTP(key, uint64(Step(t)), Digits), nil
+}
+
+// Validate checks code against the secret at t, allowing Skew steps of drift. It returns the
+// matched step so callers can reject reuse of a code (steps must strictly increase).
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+	current := Step(t)
+	for delta := int64(-Skew); delta <= Skew; delta++ {
+		step := current + delta
+		if step < 0 {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(HOTP(key, uint64(step), Digits)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// ProvisioningURI returns the otpauth:// URI (Key URI Format) that authenticator apps import,
+// usually rendered as a QR code.
+func ProvisioningURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period/time.Second)))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: totp_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for TOTP (RFC 6238 / RFC 4226 test vectors)
+
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcKey is the SHA-1 test key from RFC 4226 / RFC 6238 appendices.
+var rfcKey = []byte("12345678901234567890")
+
+func TestHOTP_RFC4226Vectors(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := HOTP(rfcKey, uint64(counter), 6); got != code {
+			t.Errorf("HOTP(counter=%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestHOTP_RFC6238Vectors(t *testing.T) {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		step := Step(time.Unix(v.unix, 0))
+		if got := HOTP(rfcKey, uint64(step), 8); got != v.code {
+			t.Errorf("TOTP(t=%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestValidate_SkewAndStep(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rfcKey)
+	now := time.Unix(1111111111, 0)
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatalf("Code() error = %v", err)
+	}
+
+	step, ok := Validate(secret, code, now)
+	if !ok || step != Step(now) {
+		t.Fatalf("Validate(now) = %d, %v; want %d, true", step, ok, Step(now))
+	}
+	if _, ok := Validate(secret, code, now.Add(Period)); !ok {
+		t.Error("Validate() rejected code one period late")
+	}
+	if _, ok := Validate(secret, code, now.Add(3*Period)); ok {
+		t.Error("Validate() accepted code three periods late")
+	}
+	wrong := code[:Digits-1] + string('0'+(code[Digits-1]-'0'+1)%10)
+	if _, ok := Validate(secret, wrong, now); ok {
+		t.Error("Validate() accepted wrong code")
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Error("Validate() accepted short code")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	key, err := decodeSecret(secret)
+	if err != nil || len(key) != secretSize {
+		t.Errorf("decodeSecret(%q) = %d bytes, %v; want %d", secret, len(key), err, secretSize)
+	}
+	if _, err := decodeSecret(strings.ToLower(secret)); err != nil {
+		t.Errorf("lowercase secret rejected: %v", err)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("UIM Chat", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("uri = %s, want otpauth://totp/...", uri)
+	}
+	if u.Path != "/UIM Chat:alice@example.com" {
+		t.Errorf("label = %q", u.Path)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "UIM Chat" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("query = %v", q)
+	}
+}
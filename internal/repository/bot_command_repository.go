@@ -0,0 +1,74 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: bot_command_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Data access for slash commands registered by bots
+
+package repository
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// BotCommandRepository defines data access for bot slash commands.
+type BotCommandRepository interface {
+	// Save inserts the command or updates the existing row with the same conversation and name.
+	Save(cmd *model.BotCommand) error
+	// Get returns nil, nil if the conversation has no command with the name.
+	Get(conversationID uuid.UUID, name string) (*model.BotCommand, error)
+	ListByConversation(conversationID uuid.UUID) ([]*model.BotCommand, error)
+	// Delete removes the bot's command; false if the bot has no such command in the conversation.
+	Delete(conversationID, botID uuid.UUID, name string) (bool, error)
+}
+
+type botCommandRepository struct {
+	db *gorm.DB
+}
+
+// NewBotCommandRepository creates a new bot command repository instance.
+func NewBotCommandRepository(db *gorm.DB) BotCommandRepository {
+	return &botCommandRepository{db: db}
+}
+
+// Save upserts by primary key (conversation_id, name).
+func (r *botCommandRepository) Save(cmd *model.BotCommand) error {
+	return r.db.Save(cmd).Error
+}
+
+// Get looks up a command by name.
+func (r *botCommandRepository) Get(conversationID uuid.UUID, name string) (*model.BotCommand, error) {
+	var cmd model.BotCommand
+	err := r.db.Where("conversation_id = ? AND name = ?", conversationID, name).First(&cmd).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cmd, nil
+}
+
+// ListByConversation returns the conversation's commands ordered by name.
+func (r *botCommandRepository) ListByConversation(conversationID uuid.UUID) ([]*model.BotCommand, error) {
+	var cmds []*model.BotCommand
+	err := r.db.Where("conversation_id = ?", conversationID).Order("name ASC").Find(&cmds).Error
+	return cmds, err
+}
+
+// Delete removes one command of the bot.
+func (r *botCommandRepository) Delete(conversationID, botID uuid.UUID, name string) (bool, error) {
+	res := r.db.Where("conversation_id = ? AND bot_id = ? AND name = ?", conversationID, botID, name).
+		Delete(&model.BotCommand{})
+	return res.RowsAffected > 0, res.Error
+}
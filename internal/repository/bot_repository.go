@@ -0,0 +1,105 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: bot_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Repository for bot users and their API keys
+
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// BotRepository defines data access for bot users and bot API keys. Bots are rows of the
+// users table with is_bot set; UserRepository reads them like any other user.
+type BotRepository interface {
+	// ListByOwner returns the bots owned by the user, oldest first.
+	ListByOwner(ownerID uuid.UUID) ([]*model.User, error)
+	// Delete soft deletes the bot and revokes all of its keys in one transaction.
+	Delete(botID uuid.UUID, at time.Time) error
+	CreateKey(key *model.BotAPIKey) error
+	// GetKeyByHash returns nil, nil if no key has the hash.
+	GetKeyByHash(keyHash string) (*model.BotAPIKey, error)
+	ListKeys(botID uuid.UUID) ([]*model.BotAPIKey, error)
+	// RevokeKey revokes one key of the bot. Returns false if the bot has no such unrevoked key.
+	RevokeKey(botID, keyID uuid.UUID, at time.Time) (bool, error)
+	TouchKey(keyID uuid.UUID, at time.Time) error
+}
+
+type botRepository struct {
+	db *gorm.DB
+}
+
+// NewBotRepository creates a new bot repository instance.
+func NewBotRepository(db *gorm.DB) BotRepository {
+	return &botRepository{db: db}
+}
+
+// ListByOwner returns the owner's (not deleted) bots.
+func (r *botRepository) ListByOwner(ownerID uuid.UUID) ([]*model.User, error) {
+	var bots []*model.User
+	err := r.db.Where("is_bot = ? AND bot_owner_id = ?", true, ownerID).Order("created_at ASC").Find(&bots).Error
+	return bots, err
+}
+
+// Delete revokes the bot's keys, then soft deletes the bot user.
+func (r *botRepository) Delete(botID uuid.UUID, at time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.BotAPIKey{}).
+			Where("bot_id = ? AND revoked_at IS NULL", botID).
+			Update("revoked_at", at).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.User{}, "user_id = ? AND is_bot = ?", botID, true).Error
+	})
+}
+
+// CreateKey inserts a new key.
+func (r *botRepository) CreateKey(key *model.BotAPIKey) error {
+	return r.db.Create(key).Error
+}
+
+// GetKeyByHash looks up a key by the SHA-256 of its plaintext.
+func (r *botRepository) GetKeyByHash(keyHash string) (*model.BotAPIKey, error) {
+	var key model.BotAPIKey
+	err := r.db.Where("key_hash = ?", keyHash).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListKeys returns all keys of the bot (including revoked ones), newest first.
+func (r *botRepository) ListKeys(botID uuid.UUID) ([]*model.BotAPIKey, error) {
+	var keys []*model.BotAPIKey
+	err := r.db.Where("bot_id = ?", botID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeKey sets revoked_at on the key if it belongs to the bot and is not revoked yet.
+func (r *botRepository) RevokeKey(botID, keyID uuid.UUID, at time.Time) (bool, error) {
+	res := r.db.Model(&model.BotAPIKey{}).
+		Where("key_id = ? AND bot_id = ? AND revoked_at IS NULL", keyID, botID).
+		Update("revoked_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+// TouchKey records a successful authentication with the key.
+func (r *botRepository) TouchKey(keyID uuid.UUID, at time.Time) error {
+	return r.db.Model(&model.BotAPIKey{}).Where("key_id = ?", keyID).Update("last_used_at", at).Error
+}
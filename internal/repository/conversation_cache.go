@@ -0,0 +1,162 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_cache.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Conversation repository with a read-through membership cache
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/store"
+)
+
+// cachedConversationRepository answers GetParticipantUserIDs, GetSendPolicy, and IsParticipant
+// when the list is cached, from a store.MembershipCache. Every method that adds or removes
+// participants, or changes what the send policy holds (roles, silencing, moderation settings),
+// invalidates the conversation's entry after the database write.
+type cachedConversationRepository struct {
+	ConversationRepository
+	cache store.MembershipCache
+}
+
+// NewCachedConversationRepository wraps repo with a read-through membership cache. Only
+// GetParticipantUserIDs fills the cache: IsParticipant falls through to repo on a miss rather
+// than loading every participant of a large channel.
+func NewCachedConversationRepository(repo ConversationRepository, cache store.MembershipCache) ConversationRepository {
+	return &cachedConversationRepository{ConversationRepository: repo, cache: cache}
+}
+
+// GetParticipantUserIDs returns the cached participants, loading and caching them on a miss.
+// The version is taken before the load, so a list read before a concurrent membership change
+// is not cached after that change's invalidation.
+func (r *cachedConversationRepository) GetParticipantUserIDs(conversationID uuid.UUID) ([]uuid.UUID, error) {
+	ctx := context.Background()
+	if ids, ok := r.cache.Get(ctx, conversationID); ok {
+		return ids, nil
+	}
+	version := r.cache.Version(ctx, conversationID)
+	ids, err := r.ConversationRepository.GetParticipantUserIDs(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.Set(ctx, conversationID, version, ids)
+	return ids, nil
+}
+
+// GetSendPolicy returns the cached send policy, loading and caching it on a miss. Like the
+// participants, a policy loaded across an invalidation is not cached.
+func (r *cachedConversationRepository) GetSendPolicy(conversationID uuid.UUID) (*model.SendPolicy, error) {
+	ctx := context.Background()
+	if raw, ok := r.cache.GetSendPolicy(ctx, conversationID); ok {
+		var policy model.SendPolicy
+		if err := json.Unmarshal(raw, &policy); err == nil {
+			return &policy, nil
+		}
+	}
+	version := r.cache.Version(ctx, conversationID)
+	policy, err := r.ConversationRepository.GetSendPolicy(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err := json.Marshal(policy); err == nil {
+		r.cache.SetSendPolicy(ctx, conversationID, version, raw)
+	}
+	return policy, nil
+}
+
+// IsParticipant answers from the cached participants if present.
+func (r *cachedConversationRepository) IsParticipant(conversationID, userID uuid.UUID) (bool, error) {
+	if ids, ok := r.cache.Get(context.Background(), conversationID); ok {
+		return store.ContainsUser(ids, userID), nil
+	}
+	return r.ConversationRepository.IsParticipant(conversationID, userID)
+}
+
+// AddParticipant adds the participant and invalidates the conversation's entry.
+func (r *cachedConversationRepository) AddParticipant(p *model.ConversationParticipant) error {
+	err := r.ConversationRepository.AddParticipant(p)
+	r.invalidate(p.ConversationID)
+	return err
+}
+
+// AddParticipantByInvite adds the participant and invalidates the conversation's entry.
+func (r *cachedConversationRepository) AddParticipantByInvite(p *model.ConversationParticipant, code string) (bool, error) {
+	added, err := r.ConversationRepository.AddParticipantByInvite(p, code)
+	r.invalidate(p.ConversationID)
+	return added, err
+}
+
+// RemoveParticipant removes the participant and invalidates the conversation's entry.
+func (r *cachedConversationRepository) RemoveParticipant(conversationID, userID uuid.UUID) (bool, error) {
+	removed, err := r.ConversationRepository.RemoveParticipant(conversationID, userID)
+	r.invalidate(conversationID)
+	return removed, err
+}
+
+// SetParticipantRole changes the role and invalidates the conversation's entry.
+func (r *cachedConversationRepository) SetParticipantRole(conversationID, userID uuid.UUID, role string) (bool, error) {
+	changed, err := r.ConversationRepository.SetParticipantRole(conversationID, userID, role)
+	r.invalidate(conversationID)
+	return changed, err
+}
+
+// TransferOwnership moves the owner role and invalidates the conversation's entry.
+func (r *cachedConversationRepository) TransferOwnership(conversationID, fromUserID, toUserID uuid.UUID) (bool, error) {
+	moved, err := r.ConversationRepository.TransferOwnership(conversationID, fromUserID, toUserID)
+	r.invalidate(conversationID)
+	return moved, err
+}
+
+// UpdateModeration updates the settings and invalidates the conversation's entry.
+func (r *cachedConversationRepository) UpdateModeration(conversationID uuid.UUID, adminOnlySend bool, slowModeSeconds int) error {
+	err := r.ConversationRepository.UpdateModeration(conversationID, adminOnlySend, slowModeSeconds)
+	r.invalidate(conversationID)
+	return err
+}
+
+// UpdateParticipantSettings updates the participant and invalidates the conversation's entry
+// when silenced_until changes; the other settings are not cached.
+func (r *cachedConversationRepository) UpdateParticipantSettings(conversationID, userID uuid.UUID, updates map[string]interface{}) error {
+	err := r.ConversationRepository.UpdateParticipantSettings(conversationID, userID, updates)
+	if _, ok := updates["silenced_until"]; ok {
+		r.invalidate(conversationID)
+	}
+	return err
+}
+
+// DeleteConversation deletes the conversation and invalidates its entry.
+func (r *cachedConversationRepository) DeleteConversation(conversationID uuid.UUID) error {
+	err := r.ConversationRepository.DeleteConversation(conversationID)
+	r.invalidate(conversationID)
+	return err
+}
+
+// DeleteConversationIfAllCleared invalidates the entry if the conversation was deleted.
+func (r *cachedConversationRepository) DeleteConversationIfAllCleared(conversationID uuid.UUID) (bool, error) {
+	deleted, err := r.ConversationRepository.DeleteConversationIfAllCleared(conversationID)
+	if deleted {
+		r.invalidate(conversationID)
+	}
+	return deleted, err
+}
+
+// invalidate drops the entry even after a failed write, which may have been partly applied.
+// A failed invalidation is logged only: the entry expires with its TTL.
+func (r *cachedConversationRepository) invalidate(conversationID uuid.UUID) {
+	if err := r.cache.Invalidate(context.Background(), conversationID); err != nil {
+		log.Printf("[CACHE] membership invalidation failed conversation_id=%s err=%v", conversationID, err)
+	}
+}
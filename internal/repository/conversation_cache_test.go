@@ -0,0 +1,250 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_cache_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for the membership-cached conversation repository
+
+package repository
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/store"
+)
+
+// countingConversationRepo keeps participants in memory and counts the membership queries that
+// would reach the database. Methods not overridden panic through the nil embedded interface.
+type countingConversationRepo struct {
+	ConversationRepository
+	mu           sync.Mutex
+	participants map[uuid.UUID]map[uuid.UUID]bool
+	queries      atomic.Int64
+	// afterLoad, if set, runs once GetParticipantUserIDs has read the participants.
+	afterLoad func()
+}
+
+func newCountingConversationRepo() *countingConversationRepo {
+	return &countingConversationRepo{participants: make(map[uuid.UUID]map[uuid.UUID]bool)}
+}
+
+func (r *countingConversationRepo) AddParticipant(p *model.ConversationParticipant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.participants[p.ConversationID] == nil {
+		r.participants[p.ConversationID] = make(map[uuid.UUID]bool)
+	}
+	r.participants[p.ConversationID][p.UserID] = true
+	return nil
+}
+
+func (r *countingConversationRepo) AddParticipantByInvite(p *model.ConversationParticipant, code string) (bool, error) {
+	return true, r.AddParticipant(p)
+}
+
+func (r *countingConversationRepo) RemoveParticipant(conversationID, userID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.participants[conversationID][userID] {
+		return false, nil
+	}
+	delete(r.participants[conversationID], userID)
+	return true, nil
+}
+
+func (r *countingConversationRepo) DeleteConversation(conversationID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.participants, conversationID)
+	return nil
+}
+
+func (r *countingConversationRepo) IsParticipant(conversationID, userID uuid.UUID) (bool, error) {
+	r.queries.Add(1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.participants[conversationID][userID], nil
+}
+
+func (r *countingConversationRepo) GetParticipantUserIDs(conversationID uuid.UUID) ([]uuid.UUID, error) {
+	r.queries.Add(1)
+	r.mu.Lock()
+	out := make([]uuid.UUID, 0, len(r.participants[conversationID]))
+	for id := range r.participants[conversationID] {
+		out = append(out, id)
+	}
+	r.mu.Unlock()
+	if r.afterLoad != nil {
+		r.afterLoad()
+	}
+	return out, nil
+}
+
+// GetSendPolicy and the writes that change it count as database calls; the policy is empty.
+func (r *countingConversationRepo) GetSendPolicy(conversationID uuid.UUID) (*model.SendPolicy, error) {
+	r.queries.Add(1)
+	return &model.SendPolicy{Type: model.ConversationTypeGroup}, nil
+}
+
+func (r *countingConversationRepo) SetParticipantRole(conversationID, userID uuid.UUID, role string) (bool, error) {
+	return true, nil
+}
+
+func (r *countingConversationRepo) UpdateModeration(conversationID uuid.UUID, adminOnlySend bool, slowModeSeconds int) error {
+	return nil
+}
+
+func (r *countingConversationRepo) UpdateParticipantSettings(conversationID, userID uuid.UUID, updates map[string]interface{}) error {
+	return nil
+}
+
+// seedGroup adds n participants to a new conversation and returns it with its members.
+func seedGroup(t testing.TB, repo ConversationRepository, n int) (uuid.UUID, []uuid.UUID) {
+	t.Helper()
+	conv := uuid.New()
+	members := make([]uuid.UUID, n)
+	for i := range members {
+		members[i] = uuid.New()
+		if err := repo.AddParticipant(&model.ConversationParticipant{ConversationID: conv, UserID: members[i]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conv, members
+}
+
+func newCachedRepo() (*countingConversationRepo, ConversationRepository) {
+	inner := newCountingConversationRepo()
+	cache := store.NewMemoryMembershipCache(100, 1000, time.Minute)
+	return inner, NewCachedConversationRepository(inner, cache)
+}
+
+func TestCachedConversationRepository_ReadThrough(t *testing.T) {
+	inner, repo := newCachedRepo()
+	conv, members := seedGroup(t, repo, 3)
+
+	// IsParticipant does not fill the cache on a miss.
+	if ok, _ := repo.IsParticipant(conv, members[0]); !ok {
+		t.Fatal("member should be a participant")
+	}
+	if ok, _ := repo.IsParticipant(conv, members[0]); !ok || inner.queries.Load() != 2 {
+		t.Fatalf("queries = %d, want 2 before the list is cached", inner.queries.Load())
+	}
+
+	ids, err := repo.GetParticipantUserIDs(conv)
+	if err != nil || len(ids) != 3 {
+		t.Fatalf("GetParticipantUserIDs = %v, %v", ids, err)
+	}
+	before := inner.queries.Load()
+	if _, err := repo.GetParticipantUserIDs(conv); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := repo.IsParticipant(conv, members[1]); !ok {
+		t.Error("member should be a participant")
+	}
+	if ok, _ := repo.IsParticipant(conv, uuid.New()); ok {
+		t.Error("stranger should not be a participant")
+	}
+	if got := inner.queries.Load(); got != before {
+		t.Errorf("queries = %d, want %d: cached reads should not reach the database", got, before)
+	}
+}
+
+func TestCachedConversationRepository_Invalidation(t *testing.T) {
+	inner, repo := newCachedRepo()
+	conv, members := seedGroup(t, repo, 2)
+	newcomer, invited := uuid.New(), uuid.New()
+
+	steps := []struct {
+		name   string
+		change func()
+		user   uuid.UUID
+		want   bool
+	}{
+		{"add", func() {
+			_ = repo.AddParticipant(&model.ConversationParticipant{ConversationID: conv, UserID: newcomer})
+		}, newcomer, true},
+		{"invite", func() {
+			_, _ = repo.AddParticipantByInvite(&model.ConversationParticipant{ConversationID: conv, UserID: invited}, "code")
+		}, invited, true},
+		{"remove", func() { _, _ = repo.RemoveParticipant(conv, members[0]) }, members[0], false},
+		{"delete", func() { _ = repo.DeleteConversation(conv) }, members[1], false},
+	}
+	for _, st := range steps {
+		if _, err := repo.GetParticipantUserIDs(conv); err != nil { // cache the list
+			t.Fatal(err)
+		}
+		st.change()
+		before := inner.queries.Load()
+		if ok, _ := repo.IsParticipant(conv, st.user); ok != st.want {
+			t.Errorf("%s: IsParticipant = %v, want %v", st.name, ok, st.want)
+		}
+		if inner.queries.Load() == before {
+			t.Errorf("%s: membership change should invalidate the cached list", st.name)
+		}
+	}
+}
+
+func TestCachedConversationRepository_FillRacingChangeIsNotCached(t *testing.T) {
+	inner, repo := newCachedRepo()
+	conv, _ := seedGroup(t, repo, 2)
+	newcomer := uuid.New()
+
+	// A participant is added after the list was read but before it is cached.
+	inner.afterLoad = func() {
+		inner.afterLoad = nil
+		_ = repo.AddParticipant(&model.ConversationParticipant{ConversationID: conv, UserID: newcomer})
+	}
+	if ids, _ := repo.GetParticipantUserIDs(conv); len(ids) != 2 {
+		t.Fatalf("GetParticipantUserIDs = %v, want the list read before the change", ids)
+	}
+	if ok, _ := repo.IsParticipant(conv, newcomer); !ok {
+		t.Error("the stale list should not have been cached")
+	}
+}
+
+func TestCachedConversationRepository_SendPolicy(t *testing.T) {
+	inner, repo := newCachedRepo()
+	conv, members := seedGroup(t, repo, 2)
+
+	steps := []struct {
+		name       string
+		change     func()
+		invalidate bool
+	}{
+		{"role", func() { _, _ = repo.SetParticipantRole(conv, members[0], model.ParticipantRoleAdmin) }, true},
+		{"silence", func() {
+			_ = repo.UpdateParticipantSettings(conv, members[1], map[string]interface{}{"silenced_until": time.Now().Add(time.Hour)})
+		}, true},
+		{"moderation", func() { _ = repo.UpdateModeration(conv, true, 0) }, true},
+		{"personal setting", func() {
+			_ = repo.UpdateParticipantSettings(conv, members[1], map[string]interface{}{"archived": true})
+		}, false},
+	}
+	for _, st := range steps {
+		if _, err := repo.GetSendPolicy(conv); err != nil { // cache the policy
+			t.Fatal(err)
+		}
+		before := inner.queries.Load()
+		if _, err := repo.GetSendPolicy(conv); err != nil || inner.queries.Load() != before {
+			t.Fatalf("%s: cached policy should not reach the database (err=%v)", st.name, err)
+		}
+		st.change()
+		if _, err := repo.GetSendPolicy(conv); err != nil {
+			t.Fatal(err)
+		}
+		if reloaded := inner.queries.Load() != before; reloaded != st.invalidate {
+			t.Errorf("%s: reloaded = %v, want %v", st.name, reloaded, st.invalidate)
+		}
+	}
+}
@@ -14,25 +14,86 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
 	"github.com/convexwf/uim-go/internal/model"
 )
 
+// ConversationListFilter selects which of the user's conversations ListForUser returns.
+type ConversationListFilter struct {
+	IncludeArchived bool
+	// IncludeHidden also returns conversations hidden until a new message.
+	IncludeHidden bool
+}
+
 // ConversationRepository defines the interface for conversation data access.
 type ConversationRepository interface {
 	Create(conv *model.Conversation) error
 	GetByID(conversationID uuid.UUID) (*model.Conversation, error)
 	ListByUserID(userID uuid.UUID, limit, offset int) ([]*model.Conversation, error)
+	// ListForUser lists the user's conversations pinned first (by pin order), then by updated_at
+	// desc, leaving out archived and hidden ones unless the filter includes them.
+	ListForUser(userID uuid.UUID, filter ConversationListFilter, limit, offset int) ([]*model.Conversation, error)
 	AddParticipant(p *model.ConversationParticipant) error
+	// AddParticipantByInvite counts one use of the invite and adds the participant in one
+	// transaction; false (and nothing stored) if the invite is not usable at p.JoinedAt.
+	AddParticipantByInvite(p *model.ConversationParticipant, code string) (bool, error)
 	FindOneOnOneBetween(userID1, userID2 uuid.UUID) (*model.Conversation, error)
 	IsParticipant(conversationID, userID uuid.UUID) (bool, error)
+	// GetParticipant returns nil, nil if the user is not a participant.
+	GetParticipant(conversationID, userID uuid.UUID) (*model.ConversationParticipant, error)
 	GetParticipantUserIDs(conversationID uuid.UUID) ([]uuid.UUID, error)
+	// GetSendPolicy returns what send checks need besides membership; gorm.ErrRecordNotFound if
+	// the conversation does not exist.
+	GetSendPolicy(conversationID uuid.UUID) (*model.SendPolicy, error)
+	// ListParticipants returns the conversation's participants, longest-tenured first.
+	ListParticipants(conversationID uuid.UUID) ([]*model.ConversationParticipant, error)
+	// ListIDsForUser returns the IDs of the conversations of the given types the user is a
+	// participant of.
+	ListIDsForUser(userID uuid.UUID, types ...model.ConversationType) ([]uuid.UUID, error)
+	// CountParticipants returns the number of participants of the conversation.
+	CountParticipants(conversationID uuid.UUID) (int, error)
+	// RemoveParticipant deletes the participant row; false if the user was not a participant.
+	RemoveParticipant(conversationID, userID uuid.UUID) (bool, error)
+	// SetParticipantRole changes the role of a participant that is not the owner; false if there
+	// is no such participant.
+	SetParticipantRole(conversationID, userID uuid.UUID, role string) (bool, error)
+	// TransferOwnership makes toUserID the owner and fromUserID an admin in one transaction;
+	// false (and no change) unless fromUserID is the owner and toUserID a participant.
+	TransferOwnership(conversationID, fromUserID, toUserID uuid.UUID) (bool, error)
+	// GetParticipantsForUser returns the user's participant rows keyed by conversation.
+	GetParticipantsForUser(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]*model.ConversationParticipant, error)
 	UpdateParticipantLastRead(conversationID, userID uuid.UUID, lastReadMessageID int64) error
 	GetUnreadCounts(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]int, error)
+	// GetUnreadTotal counts the user's unread messages over all conversations not muted at now.
+	GetUnreadTotal(userID uuid.UUID, now time.Time) (int, error)
+	// GetMentionCounts counts the unread messages that mention the user, per conversation.
+	GetMentionCounts(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]int, error)
 	GetOtherParticipantUserIDsForOneOnOne(currentUserID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]uuid.UUID, error)
 	DeleteConversation(conversationID uuid.UUID) error
+	// DeleteConversationIfAllCleared deletes the conversation like DeleteConversation once every
+	// participant has cleared its history and removed it from their list. Reports whether it did.
+	DeleteConversationIfAllCleared(conversationID uuid.UUID) (bool, error)
+	UpdateTopic(conversationID uuid.UUID, topic string) error
+	UpdateName(conversationID uuid.UUID, name string) error
+	SetJoinApproval(conversationID uuid.UUID, enabled bool) error
+	// UpdateModeration sets the group's admin-only send mode and slow mode interval.
+	UpdateModeration(conversationID uuid.UUID, adminOnlySend bool, slowModeSeconds int) error
+	// UpdateHistoryVisibility sets how much history participants see from before they joined.
+	UpdateHistoryVisibility(conversationID uuid.UUID, visibility string, days int) error
+	// UpdateAnnouncement sets the announcement; an empty text clears it (by and at are then nil).
+	UpdateAnnouncement(conversationID uuid.UUID, text string, by *uuid.UUID, at *time.Time) error
+	// SetParticipantMutedUntil sets or (with nil) clears the participant's mute.
+	SetParticipantMutedUntil(conversationID, userID uuid.UUID, until *time.Time) error
+	// UpdateParticipantSettings updates the given participant columns (pin_order, archived,
+	// hidden_until_message_id, muted_until, silenced_until, ...).
+	UpdateParticipantSettings(conversationID, userID uuid.UUID, updates map[string]interface{}) error
+	// MaxPinOrder returns the highest pin_order among the user's conversations (0 if none pinned).
+	MaxPinOrder(userID uuid.UUID) (int, error)
 }
 
 type conversationRepository struct {
@@ -80,11 +141,170 @@ func (r *conversationRepository) ListByUserID(userID uuid.UUID, limit, offset in
 	return convs, nil
 }
 
+// ListForUser lists the user's conversations honoring pins, archive and hide settings.
+// A hidden conversation comes back once it has a message newer than hidden_until_message_id.
+func (r *conversationRepository) ListForUser(userID uuid.UUID, filter ConversationListFilter, limit, offset int) ([]*model.Conversation, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	q := r.db.Table("conversations").
+		Select("conversations.*").
+		Joins("INNER JOIN conversation_participants cp ON cp.conversation_id = conversations.conversation_id").
+		Where("cp.user_id = ? AND conversations.deleted_at IS NULL", userID)
+	if !filter.IncludeArchived {
+		q = q.Where("cp.archived = ?", false)
+	}
+	if !filter.IncludeHidden {
+		q = q.Where("cp.hidden_until_message_id IS NULL OR EXISTS (SELECT 1 FROM messages m WHERE m.conversation_id = conversations.conversation_id AND m.message_id > cp.hidden_until_message_id AND m.deleted_at IS NULL)")
+	}
+	var convs []*model.Conversation
+	err := q.Order("CASE WHEN cp.pin_order > 0 THEN 0 ELSE 1 END, cp.pin_order, conversations.updated_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&convs).Error
+	if err != nil {
+		return nil, err
+	}
+	return convs, nil
+}
+
+// ListParticipants returns the participants ordered by joined_at, then user_id for equal times.
+func (r *conversationRepository) ListParticipants(conversationID uuid.UUID) ([]*model.ConversationParticipant, error) {
+	var ps []*model.ConversationParticipant
+	err := r.db.Where("conversation_id = ?", conversationID).Order("joined_at ASC, user_id ASC").Find(&ps).Error
+	return ps, err
+}
+
+// GetSendPolicy loads the conversation's moderation settings with its owners, admins and
+// currently silenced members in one query.
+func (r *conversationRepository) GetSendPolicy(conversationID uuid.UUID) (*model.SendPolicy, error) {
+	var rows []struct {
+		Type            model.ConversationType
+		AdminOnlySend   bool
+		SlowModeSeconds int
+		UserID          *uuid.UUID
+		Role            *string
+		SilencedUntil   *time.Time
+	}
+	err := r.db.Table("conversations c").
+		Select("c.type, c.admin_only_send, c.slow_mode_seconds, cp.user_id, cp.role, cp.silenced_until").
+		Joins("LEFT JOIN conversation_participants cp ON cp.conversation_id = c.conversation_id AND (cp.role IN ? OR cp.silenced_until > ?)",
+			[]string{model.ParticipantRoleOwner, model.ParticipantRoleAdmin}, time.Now()).
+		Where("c.conversation_id = ? AND c.deleted_at IS NULL", conversationID).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	policy := &model.SendPolicy{Type: rows[0].Type, AdminOnlySend: rows[0].AdminOnlySend, SlowMode: rows[0].SlowModeSeconds}
+	for _, row := range rows {
+		if row.UserID == nil {
+			continue
+		}
+		if row.Role != nil && (*row.Role == model.ParticipantRoleOwner || *row.Role == model.ParticipantRoleAdmin) {
+			policy.Managers = append(policy.Managers, *row.UserID)
+		}
+		if row.SilencedUntil != nil {
+			if policy.SilencedUntil == nil {
+				policy.SilencedUntil = make(map[uuid.UUID]time.Time)
+			}
+			policy.SilencedUntil[*row.UserID] = *row.SilencedUntil
+		}
+	}
+	return policy, nil
+}
+
+// ListIDsForUser returns the conversations (not deleted) of the given types the user takes part in.
+func (r *conversationRepository) ListIDsForUser(userID uuid.UUID, types ...model.ConversationType) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Table("conversation_participants cp").
+		Joins("INNER JOIN conversations c ON c.conversation_id = cp.conversation_id AND c.deleted_at IS NULL").
+		Where("cp.user_id = ? AND c.type IN ?", userID, types).
+		Pluck("cp.conversation_id", &ids).Error
+	return ids, err
+}
+
+// CountParticipants counts the participant rows of the conversation.
+func (r *conversationRepository) CountParticipants(conversationID uuid.UUID) (int, error) {
+	var n int64
+	err := r.db.Model(&model.ConversationParticipant{}).Where("conversation_id = ?", conversationID).Count(&n).Error
+	return int(n), err
+}
+
+// RemoveParticipant deletes the user's participant row.
+func (r *conversationRepository) RemoveParticipant(conversationID, userID uuid.UUID) (bool, error) {
+	res := r.db.Where("conversation_id = ? AND user_id = ?", conversationID, userID).Delete(&model.ConversationParticipant{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// SetParticipantRole updates the role unless the participant is the owner.
+func (r *conversationRepository) SetParticipantRole(conversationID, userID uuid.UUID, role string) (bool, error) {
+	res := r.db.Model(&model.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ? AND role != ?", conversationID, userID, model.ParticipantRoleOwner).
+		Update("role", role)
+	return res.RowsAffected > 0, res.Error
+}
+
+// TransferOwnership swaps the owner role in a transaction, so the group never has zero or two
+// owners.
+func (r *conversationRepository) TransferOwnership(conversationID, fromUserID, toUserID uuid.UUID) (bool, error) {
+	ok := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ? AND role = ?", conversationID, fromUserID, model.ParticipantRoleOwner).
+			Update("role", model.ParticipantRoleAdmin)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		res = tx.Model(&model.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ?", conversationID, toUserID).
+			Update("role", model.ParticipantRoleOwner)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errNoNewOwner
+		}
+		ok = true
+		return nil
+	})
+	if errors.Is(err, errNoNewOwner) {
+		return false, nil
+	}
+	return ok, err
+}
+
+// errNoNewOwner rolls back TransferOwnership when the new owner is not a participant.
+var errNoNewOwner = errors.New("new owner is not a participant")
+
 // AddParticipant adds a participant to a conversation.
 func (r *conversationRepository) AddParticipant(p *model.ConversationParticipant) error {
 	return r.db.Create(p).Error
 }
 
+// AddParticipantByInvite rolls the use back if the insert fails, so a failed join does not use
+// up the invite.
+func (r *conversationRepository) AddParticipantByInvite(p *model.ConversationParticipant, code string) (bool, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		ok, err := (&inviteRepository{db: tx}).Use(code, p.JoinedAt)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errInviteUnusable
+		}
+		return tx.Create(p).Error
+	})
+	if errors.Is(err, errInviteUnusable) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 // FindOneOnOneBetween finds an existing one-on-one conversation between two users.
 // It looks up conversations of type one_on_one that have exactly these two participants.
 func (r *conversationRepository) FindOneOnOneBetween(userID1, userID2 uuid.UUID) (*model.Conversation, error) {
@@ -112,6 +332,19 @@ func (r *conversationRepository) IsParticipant(conversationID, userID uuid.UUID)
 	return count > 0, nil
 }
 
+// GetParticipant returns the user's participant row (role, join time, read position).
+func (r *conversationRepository) GetParticipant(conversationID, userID uuid.UUID) (*model.ConversationParticipant, error) {
+	var p model.ConversationParticipant
+	err := r.db.Where("conversation_id = ? AND user_id = ?", conversationID, userID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
 // GetParticipantUserIDs returns all user IDs that participate in the conversation.
 func (r *conversationRepository) GetParticipantUserIDs(conversationID uuid.UUID) ([]uuid.UUID, error) {
 	var ids []uuid.UUID
@@ -131,8 +364,26 @@ func (r *conversationRepository) UpdateParticipantLastRead(conversationID, userI
 		Update("last_read_message_id", lastReadMessageID).Error
 }
 
+// GetParticipantsForUser returns the user's participant rows for the given conversations.
+func (r *conversationRepository) GetParticipantsForUser(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]*model.ConversationParticipant, error) {
+	if len(conversationIDs) == 0 {
+		return map[uuid.UUID]*model.ConversationParticipant{}, nil
+	}
+	var ps []*model.ConversationParticipant
+	err := r.db.Where("user_id = ? AND conversation_id IN ?", userID, conversationIDs).Find(&ps).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID]*model.ConversationParticipant, len(ps))
+	for _, p := range ps {
+		out[p.ConversationID] = p
+	}
+	return out, nil
+}
+
 // GetUnreadCounts returns the count of messages (from others) not yet read by the user per conversation.
 // Unread = messages where message_id > participant's last_read_message_id and sender_id != userID.
+// System messages and messages from before the user joined never count.
 func (r *conversationRepository) GetUnreadCounts(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]int, error) {
 	if len(conversationIDs) == 0 {
 		return map[uuid.UUID]int{}, nil
@@ -146,6 +397,7 @@ func (r *conversationRepository) GetUnreadCounts(userID uuid.UUID, conversationI
 		Select("messages.conversation_id, COUNT(*) AS cnt").
 		Joins("INNER JOIN conversation_participants cp ON cp.conversation_id = messages.conversation_id AND cp.user_id = ?", userID).
 		Where("messages.conversation_id IN ? AND messages.sender_id != ? AND messages.message_id > COALESCE(cp.last_read_message_id, 0)", conversationIDs, userID).
+		Where("messages.message_type != ? AND messages.created_at >= cp.joined_at", model.MessageTypeSystem).
 		Group("messages.conversation_id").
 		Find(&rows).Error
 	if err != nil {
@@ -158,6 +410,52 @@ func (r *conversationRepository) GetUnreadCounts(userID uuid.UUID, conversationI
 	return out, nil
 }
 
+// GetUnreadTotal sums the unread messages (from others, system and pre-join messages excluded)
+// of the user's conversations that are not muted at now.
+func (r *conversationRepository) GetUnreadTotal(userID uuid.UUID, now time.Time) (int, error) {
+	var total int64
+	err := r.db.Table("messages").
+		Joins("INNER JOIN conversation_participants cp ON cp.conversation_id = messages.conversation_id AND cp.user_id = ?", userID).
+		Joins("INNER JOIN conversations ON conversations.conversation_id = messages.conversation_id AND conversations.deleted_at IS NULL").
+		Where("messages.deleted_at IS NULL AND messages.sender_id != ? AND messages.message_id > COALESCE(cp.last_read_message_id, 0)", userID).
+		Where("messages.message_type != ? AND messages.created_at >= cp.joined_at", model.MessageTypeSystem).
+		Where("cp.muted_until IS NULL OR cp.muted_until <= ?", now).
+		Count(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
+
+// GetMentionCounts returns the count of unread messages that mention the user per conversation.
+// A mention is unread while its message_id is above the participant's last_read_message_id;
+// mentions from before the user joined never count.
+func (r *conversationRepository) GetMentionCounts(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	if len(conversationIDs) == 0 {
+		return map[uuid.UUID]int{}, nil
+	}
+	type row struct {
+		ConversationID uuid.UUID `gorm:"column:conversation_id"`
+		Cnt            int       `gorm:"column:cnt"`
+	}
+	var rows []row
+	err := r.db.Table("message_mentions mm").
+		Select("mm.conversation_id, COUNT(*) AS cnt").
+		Joins("INNER JOIN conversation_participants cp ON cp.conversation_id = mm.conversation_id AND cp.user_id = mm.user_id").
+		Joins("INNER JOIN messages ON messages.message_id = mm.message_id AND messages.deleted_at IS NULL AND messages.created_at >= cp.joined_at").
+		Where("mm.user_id = ? AND mm.conversation_id IN ? AND mm.message_id > COALESCE(cp.last_read_message_id, 0)", userID, conversationIDs).
+		Group("mm.conversation_id").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID]int, len(rows))
+	for _, rw := range rows {
+		out[rw.ConversationID] = rw.Cnt
+	}
+	return out, nil
+}
+
 // GetOtherParticipantUserIDsForOneOnOne returns the other participant's user_id for each one_on_one conversation.
 // Only includes conversations that are type one_on_one and have exactly one other participant.
 func (r *conversationRepository) GetOtherParticipantUserIDsForOneOnOne(currentUserID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]uuid.UUID, error) {
@@ -184,18 +482,144 @@ func (r *conversationRepository) GetOtherParticipantUserIDsForOneOnOne(currentUs
 	return out, nil
 }
 
-// DeleteConversation permanently removes a conversation and its dependent rows.
+// DeleteConversation permanently removes a conversation and its dependent rows, including its
+// webhooks (with their delivery logs), incoming webhooks, bot commands, invites and join
+// requests.
 func (r *conversationRepository) DeleteConversation(conversationID uuid.UUID) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Unscoped().Where("conversation_id = ?", conversationID).Delete(&model.Message{}).Error; err != nil {
+		return deleteConversationRows(tx, conversationID)
+	})
+}
+
+// DeleteConversationIfAllCleared removes the conversation when no participant can still see a
+// message of it: each has cleared the history and hidden the conversation through the last message.
+func (r *conversationRepository) DeleteConversationIfAllCleared(conversationID uuid.UUID) (bool, error) {
+	purged := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var lastID int64
+		if err := tx.Model(&model.Message{}).
+			Select("COALESCE(MAX(message_id), 0)").
+			Where("conversation_id = ?", conversationID).
+			Scan(&lastID).Error; err != nil {
 			return err
 		}
-		if err := tx.Where("conversation_id = ?", conversationID).Delete(&model.ConversationParticipant{}).Error; err != nil {
+		var remaining int64
+		if err := tx.Model(&model.ConversationParticipant{}).
+			Where("conversation_id = ?", conversationID).
+			Where("cleared_up_to_message_id IS NULL OR cleared_up_to_message_id < ? OR hidden_until_message_id IS NULL OR hidden_until_message_id < ?", lastID, lastID).
+			Count(&remaining).Error; err != nil {
 			return err
 		}
-		if err := tx.Unscoped().Where("conversation_id = ?", conversationID).Delete(&model.Conversation{}).Error; err != nil {
+		if remaining > 0 {
+			return nil
+		}
+		purged = true
+		return deleteConversationRows(tx, conversationID)
+	})
+	if err != nil {
+		return false, err
+	}
+	return purged, nil
+}
+
+func deleteConversationRows(tx *gorm.DB, conversationID uuid.UUID) error {
+	webhookIDs := tx.Model(&model.Webhook{}).Select("webhook_id").Where("conversation_id = ?", conversationID)
+	if err := tx.Where("webhook_id IN (?)", webhookIDs).Delete(&model.WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+	for _, m := range []interface{}{&model.Webhook{}, &model.IncomingWebhook{}, &model.BotCommand{}, &model.ConversationInvite{}, &model.ConversationJoinRequest{}} {
+		if err := tx.Where("conversation_id = ?", conversationID).Delete(m).Error; err != nil {
 			return err
 		}
+	}
+	if err := tx.Where("conversation_id = ?", conversationID).Delete(&model.MessageMention{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("conversation_id = ?", conversationID).Delete(&model.PinnedMessage{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("conversation_id = ?", conversationID).Delete(&model.Message{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("conversation_id = ?", conversationID).Delete(&model.ConversationParticipant{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("conversation_id = ?", conversationID).Delete(&model.Conversation{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateTopic sets the conversation's topic and bumps updated_at.
+func (r *conversationRepository) UpdateTopic(conversationID uuid.UUID, topic string) error {
+	return r.db.Model(&model.Conversation{}).
+		Where("conversation_id = ?", conversationID).
+		Updates(map[string]interface{}{"topic": topic, "updated_at": time.Now()}).Error
+}
+
+// UpdateName sets the conversation's name and bumps updated_at.
+func (r *conversationRepository) UpdateName(conversationID uuid.UUID, name string) error {
+	return r.db.Model(&model.Conversation{}).
+		Where("conversation_id = ?", conversationID).
+		Updates(map[string]interface{}{"name": name, "updated_at": time.Now()}).Error
+}
+
+// SetJoinApproval turns the group's join approval mode on or off.
+func (r *conversationRepository) SetJoinApproval(conversationID uuid.UUID, enabled bool) error {
+	return r.db.Model(&model.Conversation{}).
+		Where("conversation_id = ?", conversationID).
+		Updates(map[string]interface{}{"join_approval": enabled, "updated_at": time.Now()}).Error
+}
+
+// UpdateModeration sets admin_only_send and slow_mode_seconds and bumps updated_at.
+func (r *conversationRepository) UpdateModeration(conversationID uuid.UUID, adminOnlySend bool, slowModeSeconds int) error {
+	return r.db.Model(&model.Conversation{}).
+		Where("conversation_id = ?", conversationID).
+		Updates(map[string]interface{}{"admin_only_send": adminOnlySend, "slow_mode_seconds": slowModeSeconds, "updated_at": time.Now()}).Error
+}
+
+// UpdateHistoryVisibility sets history_visibility and history_days and bumps updated_at.
+func (r *conversationRepository) UpdateHistoryVisibility(conversationID uuid.UUID, visibility string, days int) error {
+	return r.db.Model(&model.Conversation{}).
+		Where("conversation_id = ?", conversationID).
+		Updates(map[string]interface{}{"history_visibility": visibility, "history_days": days, "updated_at": time.Now()}).Error
+}
+
+// SetParticipantMutedUntil updates muted_until for the participant.
+func (r *conversationRepository) SetParticipantMutedUntil(conversationID, userID uuid.UUID, until *time.Time) error {
+	return r.db.Model(&model.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Update("muted_until", until).Error
+}
+
+// UpdateParticipantSettings updates the given columns of the participant row.
+func (r *conversationRepository) UpdateParticipantSettings(conversationID, userID uuid.UUID, updates map[string]interface{}) error {
+	if len(updates) == 0 {
 		return nil
-	})
+	}
+	return r.db.Model(&model.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Updates(updates).Error
+}
+
+// MaxPinOrder returns the highest pin_order of the user's participant rows.
+func (r *conversationRepository) MaxPinOrder(userID uuid.UUID) (int, error) {
+	var max int
+	err := r.db.Model(&model.ConversationParticipant{}).
+		Select("COALESCE(MAX(pin_order), 0)").
+		Where("user_id = ?", userID).
+		Scan(&max).Error
+	return max, err
+}
+
+// UpdateAnnouncement stores the conversation's announcement and bumps updated_at.
+func (r *conversationRepository) UpdateAnnouncement(conversationID uuid.UUID, text string, by *uuid.UUID, at *time.Time) error {
+	return r.db.Model(&model.Conversation{}).
+		Where("conversation_id = ?", conversationID).
+		Updates(map[string]interface{}{
+			"announcement":    text,
+			"announcement_by": by,
+			"announcement_at": at,
+			"updated_at":      time.Now(),
+		}).Error
 }
@@ -0,0 +1,87 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: identity_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Repository for external identities linked to users
+
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// IdentityRepository defines data access for external (OpenID Connect) identities.
+type IdentityRepository interface {
+	// GetByProviderSubject returns nil, nil if no user is linked to the provider account.
+	GetByProviderSubject(provider, subject string) (*model.UserIdentity, error)
+	// CreateWithUser provisions a new user and links the identity in one transaction.
+	CreateWithUser(user *model.User, identity *model.UserIdentity) error
+	// TouchLogin records a login through the identity and refreshes its email.
+	TouchLogin(identityID uuid.UUID, email string, at time.Time) error
+	ListByUserID(userID uuid.UUID) ([]*model.UserIdentity, error)
+	DeleteByUserID(userID uuid.UUID) error
+}
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+// NewIdentityRepository creates a new identity repository instance.
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+// GetByProviderSubject looks up the identity; nil if the account was never linked.
+func (r *identityRepository) GetByProviderSubject(provider, subject string) (*model.UserIdentity, error) {
+	var identity model.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// CreateWithUser inserts the user, then the identity pointing at it.
+func (r *identityRepository) CreateWithUser(user *model.User, identity *model.UserIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.UserID
+		return tx.Create(identity).Error
+	})
+}
+
+// TouchLogin updates last_login_at and email.
+func (r *identityRepository) TouchLogin(identityID uuid.UUID, email string, at time.Time) error {
+	return r.db.Model(&model.UserIdentity{}).
+		Where("identity_id = ?", identityID).
+		Updates(map[string]interface{}{"last_login_at": at, "email": email}).Error
+}
+
+// ListByUserID returns the user's linked identities, oldest first.
+func (r *identityRepository) ListByUserID(userID uuid.UUID) ([]*model.UserIdentity, error) {
+	var identities []*model.UserIdentity
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error
+	return identities, err
+}
+
+// DeleteByUserID unlinks every identity of the user.
+func (r *identityRepository) DeleteByUserID(userID uuid.UUID) error {
+	return r.db.Where("user_id = ?", userID).Delete(&model.UserIdentity{}).Error
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: incoming_webhook_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Data access for incoming webhook tokens
+
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// IncomingWebhookRepository defines data access for incoming webhook tokens.
+type IncomingWebhookRepository interface {
+	Create(hook *model.IncomingWebhook) error
+	// GetByTokenHash returns nil, nil if no token has the hash. Revoked tokens are returned too.
+	GetByTokenHash(tokenHash string) (*model.IncomingWebhook, error)
+	// ListByConversation returns the conversation's tokens, revoked ones included.
+	ListByConversation(conversationID uuid.UUID) ([]*model.IncomingWebhook, error)
+	// Revoke marks the token revoked; false if the conversation has no such active token.
+	Revoke(conversationID, hookID uuid.UUID, at time.Time) (bool, error)
+	// Touch records the last use of the token.
+	Touch(hookID uuid.UUID, at time.Time) error
+}
+
+type incomingWebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewIncomingWebhookRepository creates a new incoming webhook repository instance.
+func NewIncomingWebhookRepository(db *gorm.DB) IncomingWebhookRepository {
+	return &incomingWebhookRepository{db: db}
+}
+
+// Create inserts a token.
+func (r *incomingWebhookRepository) Create(hook *model.IncomingWebhook) error {
+	return r.db.Create(hook).Error
+}
+
+// GetByTokenHash looks a token up by the hash of its plaintext.
+func (r *incomingWebhookRepository) GetByTokenHash(tokenHash string) (*model.IncomingWebhook, error) {
+	var hook model.IncomingWebhook
+	err := r.db.Where("token_hash = ?", tokenHash).First(&hook).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// ListByConversation returns the conversation's tokens, oldest first.
+func (r *incomingWebhookRepository) ListByConversation(conversationID uuid.UUID) ([]*model.IncomingWebhook, error) {
+	var hooks []*model.IncomingWebhook
+	err := r.db.Where("conversation_id = ?", conversationID).Order("created_at ASC").Find(&hooks).Error
+	return hooks, err
+}
+
+// Revoke sets revoked_at on an active token of the conversation.
+func (r *incomingWebhookRepository) Revoke(conversationID, hookID uuid.UUID, at time.Time) (bool, error) {
+	res := r.db.Model(&model.IncomingWebhook{}).
+		Where("hook_id = ? AND conversation_id = ? AND revoked_at IS NULL", hookID, conversationID).
+		Update("revoked_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+// Touch sets last_used_at.
+func (r *incomingWebhookRepository) Touch(hookID uuid.UUID, at time.Time) error {
+	return r.db.Model(&model.IncomingWebhook{}).Where("hook_id = ?", hookID).Update("last_used_at", at).Error
+}
@@ -0,0 +1,165 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: invite_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Invite link and join request data access
+
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// InviteRepository defines data access for group invite links and join requests.
+type InviteRepository interface {
+	Create(invite *model.ConversationInvite) error
+	// GetByCode returns nil, nil if no invite has the code. Revoked invites are returned too.
+	GetByCode(code string) (*model.ConversationInvite, error)
+	// ListByConversation returns the group's invites, revoked ones included.
+	ListByConversation(conversationID uuid.UUID) ([]*model.ConversationInvite, error)
+	// Revoke marks the invite revoked; false if the group has no such active invite.
+	Revoke(conversationID uuid.UUID, code string, at time.Time) (bool, error)
+	// Use counts one use of the invite if it is still usable at now; false if it is not.
+	Use(code string, now time.Time) (bool, error)
+
+	// CreateJoinRequest stores a pending request and counts one use of its invite in the same
+	// transaction, or returns the user's pending one for the group if there is one (created is
+	// then false and no use is counted). Returns nil, false, nil if the invite is no longer
+	// usable at req.CreatedAt.
+	CreateJoinRequest(req *model.ConversationJoinRequest) (*model.ConversationJoinRequest, bool, error)
+	// GetJoinRequest returns nil, nil if the group has no such request.
+	GetJoinRequest(conversationID uuid.UUID, requestID int64) (*model.ConversationJoinRequest, error)
+	// ListPendingJoinRequests returns the group's pending requests, oldest first.
+	ListPendingJoinRequests(conversationID uuid.UUID) ([]*model.ConversationJoinRequest, error)
+	// DecideJoinRequest moves a pending request to status; false if it is no longer pending.
+	DecideJoinRequest(requestID int64, status model.JoinRequestStatus, by uuid.UUID, at time.Time) (bool, error)
+}
+
+type inviteRepository struct {
+	db *gorm.DB
+}
+
+// NewInviteRepository creates a new invite repository instance.
+func NewInviteRepository(db *gorm.DB) InviteRepository {
+	return &inviteRepository{db: db}
+}
+
+// Create inserts an invite.
+func (r *inviteRepository) Create(invite *model.ConversationInvite) error {
+	return r.db.Create(invite).Error
+}
+
+// GetByCode looks an invite up by its code.
+func (r *inviteRepository) GetByCode(code string) (*model.ConversationInvite, error) {
+	var invite model.ConversationInvite
+	err := r.db.Where("code = ?", code).First(&invite).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// ListByConversation returns the group's invites, newest first.
+func (r *inviteRepository) ListByConversation(conversationID uuid.UUID) ([]*model.ConversationInvite, error) {
+	var invites []*model.ConversationInvite
+	err := r.db.Where("conversation_id = ?", conversationID).Order("created_at DESC").Find(&invites).Error
+	return invites, err
+}
+
+// Revoke sets revoked_at on an active invite of the group.
+func (r *inviteRepository) Revoke(conversationID uuid.UUID, code string, at time.Time) (bool, error) {
+	res := r.db.Model(&model.ConversationInvite{}).
+		Where("code = ? AND conversation_id = ? AND revoked_at IS NULL", code, conversationID).
+		Update("revoked_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+// Use increments uses in a single conditional update, so concurrent joins cannot exceed max_uses.
+func (r *inviteRepository) Use(code string, now time.Time) (bool, error) {
+	res := r.db.Model(&model.ConversationInvite{}).
+		Where("code = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) AND (max_uses = 0 OR uses < max_uses)", code, now).
+		Update("uses", gorm.Expr("uses + 1"))
+	return res.RowsAffected > 0, res.Error
+}
+
+// CreateJoinRequest inserts a pending request unless the user already has one for the group,
+// rolling the insert back if the invite cannot be used.
+func (r *inviteRepository) CreateJoinRequest(req *model.ConversationJoinRequest) (*model.ConversationJoinRequest, bool, error) {
+	var existing *model.ConversationJoinRequest
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(req)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			existing = &model.ConversationJoinRequest{}
+			return tx.Where("conversation_id = ? AND user_id = ? AND status = ?", req.ConversationID, req.UserID, model.JoinRequestPending).
+				First(existing).Error
+		}
+		ok, err := (&inviteRepository{db: tx}).Use(req.InviteCode, req.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errInviteUnusable
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errInviteUnusable):
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	case existing != nil:
+		return existing, false, nil
+	}
+	return req, true, nil
+}
+
+// errInviteUnusable rolls back CreateJoinRequest when the invite is used up, expired or revoked.
+var errInviteUnusable = errors.New("invite is not usable")
+
+// GetJoinRequest returns a request of the group by ID.
+func (r *inviteRepository) GetJoinRequest(conversationID uuid.UUID, requestID int64) (*model.ConversationJoinRequest, error) {
+	var req model.ConversationJoinRequest
+	err := r.db.Where("request_id = ? AND conversation_id = ?", requestID, conversationID).First(&req).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ListPendingJoinRequests returns the group's pending requests.
+func (r *inviteRepository) ListPendingJoinRequests(conversationID uuid.UUID) ([]*model.ConversationJoinRequest, error) {
+	var reqs []*model.ConversationJoinRequest
+	err := r.db.Where("conversation_id = ? AND status = ?", conversationID, model.JoinRequestPending).
+		Order("created_at ASC").Find(&reqs).Error
+	return reqs, err
+}
+
+// DecideJoinRequest sets the status of a pending request.
+func (r *inviteRepository) DecideJoinRequest(requestID int64, status model.JoinRequestStatus, by uuid.UUID, at time.Time) (bool, error) {
+	res := r.db.Model(&model.ConversationJoinRequest{}).
+		Where("request_id = ? AND status = ?", requestID, model.JoinRequestPending).
+		Updates(map[string]interface{}{"status": status, "decided_by": by, "decided_at": at})
+	return res.RowsAffected > 0, res.Error
+}
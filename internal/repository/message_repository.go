@@ -26,9 +26,18 @@ import (
 // MessageRepository defines the interface for message data access.
 type MessageRepository interface {
 	Create(msg *model.Message) error
-	ListByConversationID(conversationID uuid.UUID, limit, offset int, beforeID *int64) ([]*model.Message, error)
+	// ListByConversationID lists messages newest first, older than beforeID and newer than
+	// afterID when those are set.
+	ListByConversationID(conversationID uuid.UUID, limit, offset int, beforeID, afterID *int64) ([]*model.Message, error)
+	// ListAfter lists up to limit messages with an ID above afterID, oldest first.
+	ListAfter(conversationID uuid.UUID, afterID int64, limit int) ([]*model.Message, error)
 	GetByID(messageID int64) (*model.Message, error)
 	GetLastMessagesByConversationIDs(conversationIDs []uuid.UUID) (map[uuid.UUID]*model.Message, error)
+	// LastSentAt returns when the user last sent a message to the conversation, or nil if never.
+	// System messages are not counted.
+	LastSentAt(conversationID, senderID uuid.UUID) (*time.Time, error)
+	// LastIDBefore returns the ID of the newest message created before t, or 0 if none.
+	LastIDBefore(conversationID uuid.UUID, t time.Time) (int64, error)
 }
 
 type messageRepository struct {
@@ -49,7 +58,8 @@ func (r *messageRepository) Create(msg *model.Message) error {
 
 // ListByConversationID lists messages in a conversation, newest first.
 // If beforeID is set, returns messages older than that ID (cursor-based pagination).
-func (r *messageRepository) ListByConversationID(conversationID uuid.UUID, limit, offset int, beforeID *int64) ([]*model.Message, error) {
+// If afterID is set, messages up to that ID are left out (history the user cleared).
+func (r *messageRepository) ListByConversationID(conversationID uuid.UUID, limit, offset int, beforeID, afterID *int64) ([]*model.Message, error) {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -60,6 +70,9 @@ func (r *messageRepository) ListByConversationID(conversationID uuid.UUID, limit
 	if beforeID != nil {
 		q = q.Where("message_id < ?", *beforeID)
 	}
+	if afterID != nil {
+		q = q.Where("message_id > ?", *afterID)
+	}
 	var msgs []*model.Message
 	err := q.Limit(limit).Offset(offset).Find(&msgs).Error
 	if err != nil {
@@ -68,6 +81,17 @@ func (r *messageRepository) ListByConversationID(conversationID uuid.UUID, limit
 	return msgs, nil
 }
 
+// ListAfter lists the messages following afterID in ID order, for clients catching up.
+func (r *messageRepository) ListAfter(conversationID uuid.UUID, afterID int64, limit int) ([]*model.Message, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var msgs []*model.Message
+	err := r.db.Where("conversation_id = ? AND message_id > ?", conversationID, afterID).
+		Order("message_id ASC").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
 // GetByID retrieves a message by ID.
 func (r *messageRepository) GetByID(messageID int64) (*model.Message, error) {
 	var msg model.Message
@@ -103,3 +127,29 @@ func (r *messageRepository) GetLastMessagesByConversationIDs(conversationIDs []u
 	}
 	return out, nil
 }
+
+// LastSentAt returns the creation time of the sender's newest message in the conversation.
+func (r *messageRepository) LastSentAt(conversationID, senderID uuid.UUID) (*time.Time, error) {
+	var msgs []*model.Message
+	err := r.db.Select("created_at").
+		Where("conversation_id = ? AND sender_id = ? AND message_type != ?", conversationID, senderID, model.MessageTypeSystem).
+		Order("message_id DESC").Limit(1).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	return &msgs[0].CreatedAt, nil
+}
+
+// LastIDBefore returns the highest message_id of the conversation created before t, deleted
+// messages included, so it bounds the history by ID.
+func (r *messageRepository) LastIDBefore(conversationID uuid.UUID, t time.Time) (int64, error) {
+	var id int64
+	err := r.db.Unscoped().Model(&model.Message{}).
+		Select("COALESCE(MAX(message_id), 0)").
+		Where("conversation_id = ? AND created_at < ?", conversationID, t).
+		Scan(&id).Error
+	return id, err
+}
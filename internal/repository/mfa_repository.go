@@ -0,0 +1,126 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: mfa_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Repository for two-factor authentication state
+
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// MFARepository defines data access for TOTP enrollment and recovery codes.
+type MFARepository interface {
+	// GetByUserID returns nil, nil if the user never enrolled, so callers can tell
+	// "no 2FA" apart from a lookup failure.
+	GetByUserID(userID uuid.UUID) (*model.UserMFA, error)
+	Save(mfa *model.UserMFA) error
+	// Delete removes the enrollment and all recovery codes of the user.
+	Delete(userID uuid.UUID) error
+	// AdvanceStep records step as last used if it is newer than the stored one. Returns false
+	// if the step was already used (replayed code).
+	AdvanceStep(userID uuid.UUID, step int64) (bool, error)
+	// ReplaceRecoveryCodes deletes the user's recovery codes and stores new hashes.
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error
+	// UseRecoveryCode marks an unused code used. Returns false if no unused code matched.
+	UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(userID uuid.UUID) (int64, error)
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new MFA repository instance.
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+// GetByUserID retrieves the user's enrollment (confirmed or not); nil if there is none.
+func (r *mfaRepository) GetByUserID(userID uuid.UUID) (*model.UserMFA, error) {
+	var mfa model.UserMFA
+	err := r.db.Where("user_id = ?", userID).First(&mfa).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// Save inserts or updates the enrollment.
+func (r *mfaRepository) Save(mfa *model.UserMFA) error {
+	return r.db.Save(mfa).Error
+}
+
+// Delete removes the enrollment and recovery codes in one transaction.
+func (r *mfaRepository) Delete(userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.UserMFA{}).Error
+	})
+}
+
+// AdvanceStep uses a conditional UPDATE so concurrent logins cannot both accept the same code.
+func (r *mfaRepository) AdvanceStep(userID uuid.UUID, step int64) (bool, error) {
+	tx := r.db.Model(&model.UserMFA{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// ReplaceRecoveryCodes deletes existing codes and inserts the new hashes.
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]*model.UserRecoveryCode, 0, len(codeHashes))
+		for _, h := range codeHashes {
+			codes = append(codes, &model.UserRecoveryCode{UserID: userID, CodeHash: h})
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode marks the matching unused code used.
+func (r *mfaRepository) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	tx := r.db.Model(&model.UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes are left.
+func (r *mfaRepository) CountUnusedRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var n int64
+	err := r.db.Model(&model.UserRecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&n).Error
+	return n, err
+}
@@ -0,0 +1,71 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: pinned_message_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Pinned message data access
+
+package repository
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// PinnedMessageRepository defines data access for pinned messages.
+type PinnedMessageRepository interface {
+	// Pin stores the pin; false if the message was already pinned.
+	Pin(pin *model.PinnedMessage) (bool, error)
+	// Unpin removes the pin; false if the message was not pinned.
+	Unpin(conversationID uuid.UUID, messageID int64) (bool, error)
+	// ListByConversation returns the pins newest first, with their messages loaded.
+	ListByConversation(conversationID uuid.UUID) ([]*model.PinnedMessage, error)
+	Count(conversationID uuid.UUID) (int64, error)
+}
+
+type pinnedMessageRepository struct {
+	db *gorm.DB
+}
+
+// NewPinnedMessageRepository creates a new pinned message repository instance.
+func NewPinnedMessageRepository(db *gorm.DB) PinnedMessageRepository {
+	return &pinnedMessageRepository{db: db}
+}
+
+// Pin inserts the row unless the message is already pinned.
+func (r *pinnedMessageRepository) Pin(pin *model.PinnedMessage) (bool, error) {
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Omit("Message").Create(pin)
+	return res.RowsAffected > 0, res.Error
+}
+
+// Unpin deletes one pin.
+func (r *pinnedMessageRepository) Unpin(conversationID uuid.UUID, messageID int64) (bool, error) {
+	res := r.db.Where("conversation_id = ? AND message_id = ?", conversationID, messageID).
+		Delete(&model.PinnedMessage{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ListByConversation loads the pins and their messages. Deleted messages leave Message nil.
+func (r *pinnedMessageRepository) ListByConversation(conversationID uuid.UUID) ([]*model.PinnedMessage, error) {
+	var pins []*model.PinnedMessage
+	err := r.db.Preload("Message").
+		Where("conversation_id = ?", conversationID).
+		Order("pinned_at DESC").
+		Find(&pins).Error
+	return pins, err
+}
+
+// Count returns the number of pins in the conversation.
+func (r *pinnedMessageRepository) Count(conversationID uuid.UUID) (int64, error) {
+	var n int64
+	err := r.db.Model(&model.PinnedMessage{}).Where("conversation_id = ?", conversationID).Count(&n).Error
+	return n, err
+}
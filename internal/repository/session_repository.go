@@ -0,0 +1,110 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: session_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Login session repository for database operations
+
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// SessionRepository defines login session data access operations.
+type SessionRepository interface {
+	Create(session *model.UserSession) error
+	GetByID(sessionID uuid.UUID) (*model.UserSession, error)
+	ListActiveByUserID(userID uuid.UUID) ([]*model.UserSession, error)
+	Touch(sessionID uuid.UUID, lastUsedAt, expiresAt time.Time) error
+	Revoke(sessionID uuid.UUID) (bool, error)
+	RevokeAllByUserID(userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new session repository instance.
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// Create inserts a new session.
+func (r *sessionRepository) Create(session *model.UserSession) error {
+	return r.db.Create(session).Error
+}
+
+// GetByID retrieves a session by ID, including revoked and expired ones.
+func (r *sessionRepository) GetByID(sessionID uuid.UUID) (*model.UserSession, error) {
+	var session model.UserSession
+	err := r.db.Where("session_id = ?", sessionID).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListActiveByUserID lists non-revoked, non-expired sessions for the user, most recently used first.
+func (r *sessionRepository) ListActiveByUserID(userID uuid.UUID) ([]*model.UserSession, error) {
+	var sessions []*model.UserSession
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Touch records session use (e.g. token refresh) and extends its expiry.
+func (r *sessionRepository) Touch(sessionID uuid.UUID, lastUsedAt, expiresAt time.Time) error {
+	return r.db.Model(&model.UserSession{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{
+			"last_used_at": lastUsedAt,
+			"expires_at":   expiresAt,
+		}).Error
+}
+
+// Revoke marks the session revoked. Returns whether any row changed.
+func (r *sessionRepository) Revoke(sessionID uuid.UUID) (bool, error) {
+	tx := r.db.Model(&model.UserSession{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now())
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// RevokeAllByUserID revokes every active session of the user and returns the revoked IDs.
+func (r *sessionRepository) RevokeAllByUserID(userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&model.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Pluck("session_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	err = r.db.Model(&model.UserSession{}).
+		Where("session_id IN ? AND revoked_at IS NULL", ids).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: user_token_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Repository for single-use user tokens
+
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// UserTokenRepository defines data access for single-use user tokens.
+type UserTokenRepository interface {
+	Create(token *model.UserToken) error
+	// Consume marks the token used if it exists, has the purpose, is unused and unexpired at now.
+	// Returns gorm.ErrRecordNotFound otherwise. Concurrent calls consume a token at most once.
+	Consume(tokenHash string, purpose model.UserTokenPurpose, now time.Time) (*model.UserToken, error)
+	// InvalidateByUserID marks all unused tokens of the user with the purpose as used.
+	InvalidateByUserID(userID uuid.UUID, purpose model.UserTokenPurpose) error
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewUserTokenRepository creates a new user token repository instance.
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+// Create inserts a new token.
+func (r *userTokenRepository) Create(token *model.UserToken) error {
+	return r.db.Create(token).Error
+}
+
+// Consume atomically marks the token used; the conditional UPDATE makes it single-use.
+func (r *userTokenRepository) Consume(tokenHash string, purpose model.UserTokenPurpose, now time.Time) (*model.UserToken, error) {
+	var token model.UserToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.UserToken{}).
+			Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("token_hash = ?", tokenHash).First(&token).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InvalidateByUserID marks all unused tokens of the user with the purpose as used.
+func (r *userTokenRepository) InvalidateByUserID(userID uuid.UUID, purpose model.UserTokenPurpose) error {
+	return r.db.Model(&model.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
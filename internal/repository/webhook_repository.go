@@ -0,0 +1,127 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: webhook_repository.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Repository for outgoing webhooks and their deliveries
+
+package repository
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// WebhookRepository defines data access for outgoing webhooks and the delivery log.
+type WebhookRepository interface {
+	Create(webhook *model.Webhook) error
+	// GetByID returns nil, nil if the webhook does not exist.
+	GetByID(webhookID uuid.UUID) (*model.Webhook, error)
+	ListByConversation(conversationID uuid.UUID) ([]*model.Webhook, error)
+	// Delete removes the webhook and its delivery log.
+	Delete(webhookID uuid.UUID) error
+
+	CreateDelivery(delivery *model.WebhookDelivery) error
+	// GetDelivery returns nil, nil if the delivery does not exist.
+	GetDelivery(deliveryID uuid.UUID) (*model.WebhookDelivery, error)
+	UpdateDelivery(delivery *model.WebhookDelivery) error
+	// ListDeliveries returns the webhook's deliveries, newest first. status "" means any status.
+	ListDeliveries(webhookID uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error)
+	// ListPendingDeliveries returns deliveries still waiting for an attempt, oldest first.
+	ListPendingDeliveries(limit int) ([]*model.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository creates a new webhook repository instance.
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+// Create inserts a webhook.
+func (r *webhookRepository) Create(webhook *model.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+// GetByID looks up a webhook.
+func (r *webhookRepository) GetByID(webhookID uuid.UUID) (*model.Webhook, error) {
+	var webhook model.Webhook
+	err := r.db.Where("webhook_id = ?", webhookID).First(&webhook).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListByConversation returns the conversation's webhooks, oldest first.
+func (r *webhookRepository) ListByConversation(conversationID uuid.UUID) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	err := r.db.Where("conversation_id = ?", conversationID).Order("created_at ASC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// Delete removes the deliveries, then the webhook, in one transaction.
+func (r *webhookRepository) Delete(webhookID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhookID).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("webhook_id = ?", webhookID).Delete(&model.Webhook{}).Error
+	})
+}
+
+// CreateDelivery inserts a delivery.
+func (r *webhookRepository) CreateDelivery(delivery *model.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+// GetDelivery looks up a delivery.
+func (r *webhookRepository) GetDelivery(deliveryID uuid.UUID) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	err := r.db.Where("delivery_id = ?", deliveryID).First(&delivery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// UpdateDelivery saves the delivery's state after an attempt.
+func (r *webhookRepository) UpdateDelivery(delivery *model.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+// ListDeliveries returns the webhook's most recent deliveries.
+func (r *webhookRepository) ListDeliveries(webhookID uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error) {
+	q := r.db.Where("webhook_id = ?", webhookID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var deliveries []*model.WebhookDelivery
+	err := q.Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ListPendingDeliveries returns pending deliveries (e.g. to resume them after a restart).
+func (r *webhookRepository) ListPendingDeliveries(limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	err := r.db.Where("status = ?", model.WebhookDeliveryPending).
+		Order("created_at ASC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
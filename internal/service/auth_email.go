@@ -0,0 +1,208 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: auth_email.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Email verification and password reset flows of the authentication service
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/pkg/mailer"
+	pwd "github.com/convexwf/uim-go/internal/pkg/password"
+)
+
+var (
+	ErrInvalidToken         = errors.New("invalid or expired token")
+	ErrEmailAlreadyVerified = errors.New("email already verified")
+	ErrMailerUnavailable    = errors.New("email delivery is not configured")
+)
+
+const (
+	defaultVerifyEmailTTL   = 24 * time.Hour
+	defaultPasswordResetTTL = time.Hour
+	userTokenBytes          = 32
+	mailSendTimeout         = 10 * time.Second
+)
+
+// RequestEmailVerification (re)sends the verification email; earlier verification links stop working.
+func (s *authService) RequestEmailVerification(userID uuid.UUID) error {
+	if s.mailer == nil {
+		return ErrMailerUnavailable
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	return s.sendVerificationEmail(user)
+}
+
+// VerifyEmail consumes a verification token and marks the user's email verified.
+// The token is only valid for the address it was sent to.
+func (s *authService) VerifyEmail(token string) (*model.User, error) {
+	t, err := s.tokenRepo.Consume(hashUserToken(token), model.UserTokenVerifyEmail, time.Now())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	user, err := s.userRepo.GetByID(t.UserID)
+	if err != nil || !strings.EqualFold(user.Email, t.Email) {
+		return nil, ErrInvalidToken
+	}
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := s.userRepo.Update(user); err != nil {
+			return nil, fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+	log.Printf("[AUTH] email verified user_id=%s", user.UserID)
+	return user, nil
+}
+
+// RequestPasswordReset emails a reset link if an account with the address exists. It returns nil
+// for unknown addresses so the endpoint cannot be used to discover registered emails, and issues
+// the token and sends the email in the background so the response time does not tell either.
+func (s *authService) RequestPasswordReset(email string) error {
+	if s.mailer == nil {
+		return ErrMailerUnavailable
+	}
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil || user.IsBot {
+		return nil
+	}
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		if err := s.sendPasswordReset(user); err != nil {
+			log.Printf("[AUTH] password reset email failed user_id=%s err=%v", user.UserID, err)
+		}
+	}()
+	return nil
+}
+
+// sendPasswordReset issues a reset token and emails the link.
+func (s *authService) sendPasswordReset(user *model.User) error {
+	token, err := s.issueUserToken(user, model.UserTokenResetPassword, s.opts.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	return s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can be used once.\n\n%s\n\nIf you did not request this, ignore this email; your password is unchanged.\n",
+			user.DisplayName, s.opts.PasswordResetTTL, s.link("/reset-password", token)),
+	})
+}
+
+// ResetPassword consumes a reset token, sets the new password and revokes every session and token
+// of the user. Receiving the email proves control of the address, so it is marked verified too.
+func (s *authService) ResetPassword(token, newPassword string) error {
+	if len(newPassword) < 6 {
+		return fmt.Errorf("%w: password must be at least 6 characters", ErrInvalidInput)
+	}
+	t, err := s.tokenRepo.Consume(hashUserToken(token), model.UserTokenResetPassword, time.Now())
+	if err != nil {
+		return ErrInvalidToken
+	}
+	user, err := s.userRepo.GetByID(t.UserID)
+	if err != nil || !strings.EqualFold(user.Email, t.Email) {
+		return ErrInvalidToken
+	}
+	passwordHash, err := pwd.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.PasswordHash = passwordHash
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.userRepo.Update(user); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	log.Printf("[AUTH] password reset user_id=%s", user.UserID)
+	return s.revokeAllSessions(user.UserID)
+}
+
+// sendVerificationEmail issues a verification token and emails the link.
+func (s *authService) sendVerificationEmail(user *model.User) error {
+	token, err := s.issueUserToken(user, model.UserTokenVerifyEmail, s.opts.VerifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s\n",
+			user.DisplayName, s.opts.VerifyEmailTTL, s.link("/verify-email", token)),
+	})
+}
+
+// issueUserToken invalidates the user's outstanding tokens for purpose and stores a new one.
+// Returns the raw token; only its hash is persisted.
+func (s *authService) issueUserToken(user *model.User, purpose model.UserTokenPurpose, ttl time.Duration) (string, error) {
+	buf := make([]byte, userTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	if err := s.tokenRepo.InvalidateByUserID(user.UserID, purpose); err != nil {
+		return "", fmt.Errorf("failed to invalidate tokens: %w", err)
+	}
+	now := time.Now()
+	err := s.tokenRepo.Create(&model.UserToken{
+		TokenHash: hashUserToken(token),
+		UserID:    user.UserID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	return token, nil
+}
+
+func (s *authService) sendMail(msg mailer.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// link builds a client URL carrying the token as query parameter.
+func (s *authService) link(path, token string) string {
+	return strings.TrimRight(s.opts.PublicURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// hashUserToken returns the hex SHA-256 of a raw token, as stored in user_tokens.
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,348 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: auth_mfa.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: TOTP two-factor authentication (enrollment, recovery codes, two-step login)
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	pwd "github.com/convexwf/uim-go/internal/pkg/password"
+	"github.com/convexwf/uim-go/internal/pkg/totp"
+)
+
+var (
+	ErrMFARequired       = errors.New("two-factor authentication required")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication not enabled")
+)
+
+const (
+	defaultTOTPIssuer = "UIM"
+	// mfaPendingTTL bounds the time between the password step and the code step of login.
+	mfaPendingTTL = 5 * time.Minute
+	// maxMFAAttempts is the number of codes accepted per user within mfaPendingTTL.
+	maxMFAAttempts    = 5
+	recoveryCodeCount = 10
+	recoveryCodeLen   = 10 // base32 characters, shown as XXXXX-XXXXX
+)
+
+// MFARequiredError is returned by Login when the password was correct but the user has
+// two-factor authentication enabled. PendingToken must be passed to CompleteMFALogin with a code.
+// errors.Is(err, ErrMFARequired) reports true.
+type MFARequiredError struct {
+	PendingToken string
+	ExpiresAt    time.Time
+}
+
+func (e *MFARequiredError) Error() string { return ErrMFARequired.Error() }
+
+// Is makes errors.Is(err, ErrMFARequired) match.
+func (e *MFARequiredError) Is(target error) bool { return target == ErrMFARequired }
+
+// TOTPEnrollment is returned when 2FA enrollment starts. The secret is shown to the user once
+// (for manual entry); URI is the otpauth:// provisioning URI for QR codes.
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// MFAStatus describes a user's two-factor setup.
+type MFAStatus struct {
+	Enabled           bool
+	RecoveryCodesLeft int64
+}
+
+// EnrollTOTP creates (or replaces an unconfirmed) TOTP secret. 2FA stays off until ConfirmTOTP.
+func (s *authService) EnrollTOTP(userID uuid.UUID) (*TOTPEnrollment, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	existing, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mfa: %w", err)
+	}
+	if existing.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.Save(&model.UserMFA{UserID: userID, TOTPSecret: secret}); err != nil {
+		return nil, fmt.Errorf("failed to save mfa: %w", err)
+	}
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+	return &TOTPEnrollment{Secret: secret, URI: totp.ProvisioningURI(s.opts.TOTPIssuer, account, secret)}, nil
+}
+
+// ConfirmTOTP enables 2FA after checking a first code from the authenticator and returns
+// the recovery codes (shown once; only hashes are stored).
+func (s *authService) ConfirmTOTP(userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mfa: %w", err)
+	}
+	if mfa == nil {
+		return nil, ErrMFANotEnabled
+	}
+	if mfa.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	step, ok := totp.Validate(mfa.TOTPSecret, code, s.now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	now := s.now()
+	mfa.ConfirmedAt = &now
+	mfa.LastUsedStep = step
+	if err := s.mfaRepo.Save(mfa); err != nil {
+		return nil, fmt.Errorf("failed to save mfa: %w", err)
+	}
+	log.Printf("[AUTH] mfa enabled user_id=%s", userID)
+	return s.newRecoveryCodes(userID)
+}
+
+// DisableTOTP turns 2FA off; it requires the password and a current code (or recovery code).
+func (s *authService) DisableTOTP(userID uuid.UUID, password, code string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if !pwd.Verify(password, user.PasswordHash) {
+		return ErrInvalidCredentials
+	}
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to load mfa: %w", err)
+	}
+	if !mfa.Enabled() {
+		return ErrMFANotEnabled
+	}
+	if err := s.verifySecondFactor(mfa, code); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.Delete(userID); err != nil {
+		return fmt.Errorf("failed to delete mfa: %w", err)
+	}
+	log.Printf("[AUTH] mfa disabled user_id=%s", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes; it requires a current TOTP code.
+func (s *authService) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mfa: %w", err)
+	}
+	if !mfa.Enabled() {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.verifyTOTP(mfa, code); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(userID)
+}
+
+// GetMFAStatus reports whether 2FA is enabled and how many recovery codes are left.
+func (s *authService) GetMFAStatus(userID uuid.UUID) (*MFAStatus, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mfa: %w", err)
+	}
+	if !mfa.Enabled() {
+		return &MFAStatus{}, nil
+	}
+	left, err := s.mfaRepo.CountUnusedRecoveryCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return &MFAStatus{Enabled: true, RecoveryCodesLeft: left}, nil
+}
+
+// CompleteMFALogin exchanges the pending token from Login plus a TOTP or recovery code for a
+// session. A user gets maxMFAAttempts codes per mfaPendingTTL across all pending tokens and
+// instances; each pending token can be used successfully once. The token no longer works once
+// 2FA is disabled or the password is changed or reset.
+func (s *authService) CompleteMFALogin(pendingToken, code string, client ClientInfo) (*model.User, string, string, error) {
+	claims, err := s.jwtManager.ValidateMFAPendingToken(pendingToken)
+	if err != nil {
+		return nil, "", "", ErrInvalidCredentials
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, "", "", ErrInvalidCredentials
+	}
+	// A password change or reset after the password step revokes the pending token.
+	if s.tokenRevoked(userID, claims) {
+		return nil, "", "", ErrInvalidCredentials
+	}
+	if !s.takeMFAAttempt(userID, claims.ID) {
+		log.Printf("[AUTH] mfa attempts exhausted user_id=%s", userID)
+		return nil, "", "", ErrInvalidCredentials
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, "", "", ErrInvalidCredentials
+	}
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to load mfa: %w", err)
+	}
+	// 2FA was disabled after the password step: the user logs in again with the password alone.
+	if !mfa.Enabled() {
+		return nil, "", "", ErrInvalidCredentials
+	}
+	if s.loginGuard != nil {
+		if err := s.loginGuard.Check(context.Background(), user.Username, client.IP); err != nil {
+			return nil, "", "", err
+		}
+	}
+	if err := s.verifySecondFactor(mfa, code); err != nil {
+		log.Printf("[AUTH] mfa login failed user_id=%s", userID)
+		if s.loginGuard != nil {
+			s.loginGuard.Failure(context.Background(), user.Username, client.IP, user.UserID)
+		}
+		return nil, "", "", err
+	}
+	s.finishMFAAttempts(userID, claims.ID, claims.ExpiresAt.Time)
+	if s.loginGuard != nil {
+		s.loginGuard.Success(context.Background(), user.Username, client.IP, user.UserID)
+	}
+
+	accessToken, refreshToken, err := s.startSession(user, client)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return user, accessToken, refreshToken, nil
+}
+
+// mfaChallenge issues the pending token returned by Login for users with 2FA enabled.
+func (s *authService) mfaChallenge(user *model.User) error {
+	token, err := s.jwtManager.GenerateMFAPendingToken(user.UserID.String(), mfaPendingTTL)
+	if err != nil {
+		return fmt.Errorf("failed to generate mfa token: %w", err)
+	}
+	return &MFARequiredError{PendingToken: token, ExpiresAt: time.Now().Add(mfaPendingTTL)}
+}
+
+// verifySecondFactor accepts a TOTP code or, failing that, an unused recovery code.
+func (s *authService) verifySecondFactor(mfa *model.UserMFA, code string) error {
+	if err := s.verifyTOTP(mfa, code); err == nil {
+		return nil
+	}
+	used, err := s.mfaRepo.UseRecoveryCode(mfa.UserID, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("failed to check recovery code: %w", err)
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	log.Printf("[AUTH] recovery code used user_id=%s", mfa.UserID)
+	return nil
+}
+
+// verifyTOTP checks a TOTP code and rejects replays of an already used time step.
+func (s *authService) verifyTOTP(mfa *model.UserMFA, code string) error {
+	step, ok := totp.Validate(mfa.TOTPSecret, code, s.now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	advanced, err := s.mfaRepo.AdvanceStep(mfa.UserID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record mfa step: %w", err)
+	}
+	if !advanced {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// newRecoveryCodes generates and stores a fresh set of recovery codes.
+func (s *authService) newRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	buf := make([]byte, recoveryCodeLen)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := base32.StdEncoding.EncodeToString(buf)[:recoveryCodeLen]
+		codes[i] = raw[:recoveryCodeLen/2] + "-" + raw[recoveryCodeLen/2:]
+		hashes[i] = hashRecoveryCode(raw)
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode normalizes (case, dashes, spaces) and hashes a recovery code. Codes carry
+// 50 bits of randomness, so a plain SHA-256 is sufficient.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// takeMFAAttempt counts a code attempt for the user; false once the user's budget is spent or
+// the pending token was already used. Store errors do not block the login.
+func (s *authService) takeMFAAttempt(userID uuid.UUID, tokenID string) bool {
+	ctx := context.Background()
+	if used, err := s.mfaAttempts.LockRemaining(ctx, mfaUsedKey(tokenID)); err == nil && used > 0 {
+		return false
+	}
+	n, err := s.mfaAttempts.RecordFailure(ctx, mfaAttemptKey(userID), mfaPendingTTL)
+	if err != nil {
+		log.Printf("[AUTH] mfa attempt record failed user_id=%s err=%v", userID, err)
+		return true
+	}
+	return n <= maxMFAAttempts
+}
+
+// finishMFAAttempts resets the user's attempt budget and makes the used pending token unusable
+// until it expires.
+func (s *authService) finishMFAAttempts(userID uuid.UUID, tokenID string, expires time.Time) {
+	ctx := context.Background()
+	if err := s.mfaAttempts.Reset(ctx, mfaAttemptKey(userID)); err != nil {
+		log.Printf("[AUTH] mfa attempt reset failed user_id=%s err=%v", userID, err)
+	}
+	if d := time.Until(expires); d > 0 {
+		if err := s.mfaAttempts.Lock(ctx, mfaUsedKey(tokenID), d); err != nil {
+			log.Printf("[AUTH] mfa token invalidation failed user_id=%s err=%v", userID, err)
+		}
+	}
+}
+
+func mfaAttemptKey(userID uuid.UUID) string {
+	return "mfa:" + userID.String()
+}
+
+func mfaUsedKey(tokenID string) string {
+	return "mfa-used:" + tokenID
+}
@@ -0,0 +1,383 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: auth_mfa_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for TOTP two-factor authentication
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/pkg/jwt"
+	"github.com/convexwf/uim-go/internal/pkg/totp"
+	"github.com/convexwf/uim-go/internal/store"
+)
+
+// mockMFARepository is an in-memory MFARepository for testing.
+type mockMFARepository struct {
+	mfa   map[uuid.UUID]*model.UserMFA
+	codes map[uuid.UUID]map[string]bool // user -> hash -> used
+}
+
+func newMockMFARepository() *mockMFARepository {
+	return &mockMFARepository{
+		mfa:   make(map[uuid.UUID]*model.UserMFA),
+		codes: make(map[uuid.UUID]map[string]bool),
+	}
+}
+
+func (m *mockMFARepository) GetByUserID(userID uuid.UUID) (*model.UserMFA, error) {
+	mfa, ok := m.mfa[userID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (m *mockMFARepository) Save(mfa *model.UserMFA) error {
+	copied := *mfa
+	m.mfa[mfa.UserID] = &copied
+	return nil
+}
+
+func (m *mockMFARepository) Delete(userID uuid.UUID) error {
+	delete(m.mfa, userID)
+	delete(m.codes, userID)
+	return nil
+}
+
+func (m *mockMFARepository) AdvanceStep(userID uuid.UUID, step int64) (bool, error) {
+	mfa, ok := m.mfa[userID]
+	if !ok || mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	mfa.LastUsedStep = step
+	return true, nil
+}
+
+func (m *mockMFARepository) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	m.codes[userID] = make(map[string]bool)
+	for _, h := range codeHashes {
+		m.codes[userID][h] = false
+	}
+	return nil
+}
+
+func (m *mockMFARepository) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	used, ok := m.codes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	m.codes[userID][codeHash] = true
+	return true, nil
+}
+
+func (m *mockMFARepository) CountUnusedRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var n int64
+	for _, used := range m.codes[userID] {
+		if !used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// testClock is a manually advanced clock for TOTP tests.
+type testClock struct{ t time.Time }
+
+func (c *testClock) Now() time.Time          { return c.t }
+func (c *testClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newMFATestService registers "testuser" and returns the service, user and clock.
+func newMFATestService(t *testing.T) (AuthService, *model.User, *testClock) {
+	t.Helper()
+	clock := &testClock{t: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(newMockUserRepository(), newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{TOTPIssuer: "UIM Test", Now: clock.Now})
+	user, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	return authService, user, clock
+}
+
+// enableMFA enrolls and confirms TOTP and returns the secret and recovery codes.
+func enableMFA(t *testing.T, authService AuthService, userID uuid.UUID, clock *testClock) (string, []string) {
+	t.Helper()
+	enrollment, err := authService.EnrollTOTP(userID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP() error = %v", err)
+	}
+	code, _ := totp.Code(enrollment.Secret, clock.Now())
+	recovery, err := authService.ConfirmTOTP(userID, code)
+	if err != nil {
+		t.Fatalf("ConfirmTOTP() error = %v", err)
+	}
+	clock.Advance(totp.Period)
+	return enrollment.Secret, recovery
+}
+
+// loginPending runs the password step and returns the pending token.
+func loginPending(t *testing.T, authService AuthService) string {
+	t.Helper()
+	_, access, _, err := authService.Login("testuser", "password123", ClientInfo{})
+	var mfaErr *MFARequiredError
+	if !errors.As(err, &mfaErr) || !errors.Is(err, ErrMFARequired) {
+		t.Fatalf("Login() error = %v, want *MFARequiredError", err)
+	}
+	if access != "" || mfaErr.PendingToken == "" {
+		t.Fatalf("Login() access = %q pending = %q; want only a pending token", access, mfaErr.PendingToken)
+	}
+	return mfaErr.PendingToken
+}
+
+func TestAuthService_EnrollTOTP(t *testing.T) {
+	authService, user, clock := newMFATestService(t)
+
+	enrollment, err := authService.EnrollTOTP(user.UserID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP() error = %v", err)
+	}
+	if want := "otpauth://totp/UIM%20Test:test@example.com?"; !strings.HasPrefix(enrollment.URI, want) {
+		t.Errorf("URI = %s, want prefix %s", enrollment.URI, want)
+	}
+
+	// Not enabled until confirmed: login still succeeds with just the password.
+	if _, _, _, err := authService.Login("testuser", "password123", ClientInfo{}); err != nil {
+		t.Errorf("Login() before confirmation error = %v", err)
+	}
+	stale, _ := totp.Code(enrollment.Secret, clock.Now().Add(-time.Hour))
+	if _, err := authService.ConfirmTOTP(user.UserID, stale); err != ErrInvalidMFACode {
+		t.Errorf("ConfirmTOTP(stale) error = %v, want ErrInvalidMFACode", err)
+	}
+
+	code, _ := totp.Code(enrollment.Secret, clock.Now())
+	recovery, err := authService.ConfirmTOTP(user.UserID, code)
+	if err != nil {
+		t.Fatalf("ConfirmTOTP() error = %v", err)
+	}
+	if len(recovery) != recoveryCodeCount {
+		t.Errorf("recovery codes = %d, want %d", len(recovery), recoveryCodeCount)
+	}
+	status, _ := authService.GetMFAStatus(user.UserID)
+	if !status.Enabled || status.RecoveryCodesLeft != recoveryCodeCount {
+		t.Errorf("GetMFAStatus() = %+v", status)
+	}
+	if _, err := authService.EnrollTOTP(user.UserID); err != ErrMFAAlreadyEnabled {
+		t.Errorf("EnrollTOTP() when enabled error = %v, want ErrMFAAlreadyEnabled", err)
+	}
+}
+
+func TestAuthService_MFALogin(t *testing.T) {
+	authService, user, clock := newMFATestService(t)
+	secret, _ := enableMFA(t, authService, user.UserID, clock)
+
+	pending := loginPending(t, authService)
+	if _, _, _, err := authService.RefreshToken(pending, ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("RefreshToken(pending) error = %v, want ErrInvalidCredentials", err)
+	}
+
+	code, _ := totp.Code(secret, clock.Now())
+	_, access, refresh, err := authService.CompleteMFALogin(pending, code, ClientInfo{})
+	if err != nil {
+		t.Fatalf("CompleteMFALogin() error = %v", err)
+	}
+	if access == "" || refresh == "" {
+		t.Error("CompleteMFALogin() returned empty tokens")
+	}
+
+	// The pending token is single-use, and the same code cannot be replayed.
+	if _, _, _, err := authService.CompleteMFALogin(pending, code, ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("CompleteMFALogin(reused token) error = %v, want ErrInvalidCredentials", err)
+	}
+	if _, _, _, err := authService.CompleteMFALogin(loginPending(t, authService), code, ClientInfo{}); err != ErrInvalidMFACode {
+		t.Errorf("CompleteMFALogin(replayed code) error = %v, want ErrInvalidMFACode", err)
+	}
+
+	// Codes from the next period work once the clock moves on.
+	clock.Advance(totp.Period)
+	code, _ = totp.Code(secret, clock.Now())
+	if _, _, _, err := authService.CompleteMFALogin(loginPending(t, authService), code, ClientInfo{}); err != nil {
+		t.Errorf("CompleteMFALogin(next period) error = %v", err)
+	}
+
+	// Codes far outside the skew window are rejected.
+	stale, _ := totp.Code(secret, clock.Now().Add(-5*totp.Period))
+	if _, _, _, err := authService.CompleteMFALogin(loginPending(t, authService), stale, ClientInfo{}); err != ErrInvalidMFACode {
+		t.Errorf("CompleteMFALogin(stale code) error = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestAuthService_MFALogin_StalePendingToken(t *testing.T) {
+	clock := &testClock{t: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(newMockUserRepository(), newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{Revocations: store.NewMemoryRevocationStore(), Now: clock.Now})
+	user, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	secret, _ := enableMFA(t, authService, user.UserID, clock)
+
+	// A password change after the password step revokes the pending token.
+	pending := loginPending(t, authService)
+	if _, _, err := authService.ChangePassword(user.UserID, "password123", "newpassword", ClientInfo{}); err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+	code, _ := totp.Code(secret, clock.Now())
+	if _, _, _, err := authService.CompleteMFALogin(pending, code, ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("CompleteMFALogin() after password change error = %v, want ErrInvalidCredentials", err)
+	}
+
+	// So does disabling 2FA: the pending token cannot skip the second factor. Leave the
+	// cutoff's second first, or the new pending token is revoked too.
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	var required *MFARequiredError
+	if _, _, _, err := authService.Login("testuser", "newpassword", ClientInfo{}); !errors.As(err, &required) {
+		t.Fatalf("Login() error = %v, want MFARequiredError", err)
+	}
+	clock.Advance(totp.Period)
+	code, _ = totp.Code(secret, clock.Now())
+	if err := authService.DisableTOTP(user.UserID, "newpassword", code); err != nil {
+		t.Fatalf("DisableTOTP() error = %v", err)
+	}
+	if _, _, _, err := authService.CompleteMFALogin(required.PendingToken, "", ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("CompleteMFALogin() after disabling 2FA error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthService_MFALogin_AttemptLimit(t *testing.T) {
+	authService, user, clock := newMFATestService(t)
+	secret, _ := enableMFA(t, authService, user.UserID, clock)
+
+	pending := loginPending(t, authService)
+	for i := 0; i < maxMFAAttempts; i++ {
+		if _, _, _, err := authService.CompleteMFALogin(pending, "bad-code", ClientInfo{}); err != ErrInvalidMFACode {
+			t.Fatalf("attempt %d error = %v, want ErrInvalidMFACode", i, err)
+		}
+	}
+	code, _ := totp.Code(secret, clock.Now())
+	if _, _, _, err := authService.CompleteMFALogin(pending, code, ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("CompleteMFALogin() after limit error = %v, want ErrInvalidCredentials", err)
+	}
+	// The budget is per user: a fresh pending token does not restore it.
+	if _, _, _, err := authService.CompleteMFALogin(loginPending(t, authService), code, ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("CompleteMFALogin() with new pending token error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthService_MFALogin_FailuresClearedOnlyAfterSecondFactor(t *testing.T) {
+	clock := &testClock{t: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	guard := NewLoginGuard(store.NewMemoryLoginAttemptStore(), LoginPolicy{MaxUserFailures: 3, MaxIPFailures: 100}, &recordingAuditor{})
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(newMockUserRepository(), newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{LoginGuard: guard, Now: clock.Now})
+	user, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	secret, _ := enableMFA(t, authService, user.UserID, clock)
+
+	// The password alone does not reset the failure counter.
+	for i := 0; i < 2; i++ {
+		_, _, _, _ = authService.Login("testuser", "wrong", ClientInfo{})
+	}
+	pending := loginPending(t, authService)
+	_, _, _, _ = authService.Login("testuser", "wrong", ClientInfo{})
+	code, _ := totp.Code(secret, clock.Now())
+	if _, _, _, err := authService.CompleteMFALogin(pending, code, ClientInfo{}); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("CompleteMFALogin() after third failure error = %v, want ErrTooManyAttempts", err)
+	}
+
+	// Completing the second factor does.
+	if err := guard.attempts.Reset(context.Background(), userKey("testuser")); err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, _ = authService.Login("testuser", "wrong", ClientInfo{})
+	pending = loginPending(t, authService)
+	if _, _, _, err := authService.CompleteMFALogin(pending, code, ClientInfo{}); err != nil {
+		t.Fatalf("CompleteMFALogin() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		_, _, _, _ = authService.Login("testuser", "wrong", ClientInfo{})
+	}
+	if err := guard.Check(context.Background(), "testuser", ""); err != nil {
+		t.Errorf("Check() after MFA success error = %v, want the counter reset", err)
+	}
+
+	// A used pending token cannot start another session.
+	clock.Advance(totp.Period)
+	next, _ := totp.Code(secret, clock.Now())
+	if _, _, _, err := authService.CompleteMFALogin(pending, next, ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("CompleteMFALogin(used token) error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthService_MFALogin_RecoveryCode(t *testing.T) {
+	authService, user, clock := newMFATestService(t)
+	_, recovery := enableMFA(t, authService, user.UserID, clock)
+
+	// Recovery codes are accepted case-insensitively and without the dash, once.
+	code := recovery[0]
+	if _, _, _, err := authService.CompleteMFALogin(loginPending(t, authService), " "+code[:5]+code[6:], ClientInfo{}); err != nil {
+		t.Fatalf("CompleteMFALogin(recovery) error = %v", err)
+	}
+	if _, _, _, err := authService.CompleteMFALogin(loginPending(t, authService), code, ClientInfo{}); err != ErrInvalidMFACode {
+		t.Errorf("CompleteMFALogin(used recovery) error = %v, want ErrInvalidMFACode", err)
+	}
+	if status, _ := authService.GetMFAStatus(user.UserID); status.RecoveryCodesLeft != recoveryCodeCount-1 {
+		t.Errorf("RecoveryCodesLeft = %d, want %d", status.RecoveryCodesLeft, recoveryCodeCount-1)
+	}
+}
+
+func TestAuthService_DisableTOTP(t *testing.T) {
+	authService, user, clock := newMFATestService(t)
+	secret, _ := enableMFA(t, authService, user.UserID, clock)
+	code, _ := totp.Code(secret, clock.Now())
+
+	if err := authService.DisableTOTP(user.UserID, "wrongpassword", code); err != ErrInvalidCredentials {
+		t.Errorf("DisableTOTP(wrong password) error = %v, want ErrInvalidCredentials", err)
+	}
+	if err := authService.DisableTOTP(user.UserID, "password123", code); err != nil {
+		t.Fatalf("DisableTOTP() error = %v", err)
+	}
+	if _, _, _, err := authService.Login("testuser", "password123", ClientInfo{}); err != nil {
+		t.Errorf("Login() after disable error = %v", err)
+	}
+	if err := authService.DisableTOTP(user.UserID, "password123", code); err != ErrMFANotEnabled {
+		t.Errorf("DisableTOTP() when disabled error = %v, want ErrMFANotEnabled", err)
+	}
+}
+
+func TestAuthService_RegenerateRecoveryCodes(t *testing.T) {
+	authService, user, clock := newMFATestService(t)
+	secret, old := enableMFA(t, authService, user.UserID, clock)
+
+	code, _ := totp.Code(secret, clock.Now())
+	fresh, err := authService.RegenerateRecoveryCodes(user.UserID, code)
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes() error = %v", err)
+	}
+	if len(fresh) != recoveryCodeCount {
+		t.Errorf("codes = %d, want %d", len(fresh), recoveryCodeCount)
+	}
+	if _, _, _, err := authService.CompleteMFALogin(loginPending(t, authService), old[0], ClientInfo{}); err != ErrInvalidMFACode {
+		t.Errorf("old recovery code error = %v, want ErrInvalidMFACode", err)
+	}
+	if _, _, _, err := authService.CompleteMFALogin(loginPending(t, authService), fresh[0], ClientInfo{}); err != nil {
+		t.Errorf("new recovery code error = %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: auth_oidc.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: OpenID Connect single sign-on (authorization code + PKCE, just-in-time provisioning)
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/pkg/oidc"
+	pwd "github.com/convexwf/uim-go/internal/pkg/password"
+	"github.com/convexwf/uim-go/internal/repository"
+	"github.com/convexwf/uim-go/internal/store"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown identity provider")
+	ErrInvalidOIDCState = errors.New("invalid or expired login state")
+	ErrOIDCAuthFailed   = errors.New("identity provider authentication failed")
+	// ErrIdentityConflict is returned when a first SSO login carries the email of an existing
+	// local account. Accounts are never linked by email alone.
+	ErrIdentityConflict = errors.New("an account with this email already exists")
+)
+
+// oidcStateTTL bounds how long a user may take at the identity provider.
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCProvider is an OpenID Connect provider (implemented by *oidc.Provider).
+type OIDCProvider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	Authenticate(ctx context.Context, code, codeVerifier, nonce string) (*oidc.Identity, error)
+}
+
+// OIDCLogin holds the single sign-on providers and the state needed to complete their logins.
+type OIDCLogin struct {
+	providers  map[string]OIDCProvider
+	states     store.OIDCStateStore
+	identities repository.IdentityRepository
+}
+
+// NewOIDCLogin creates the SSO configuration for NewAuthService. Provider names must be unique.
+func NewOIDCLogin(identities repository.IdentityRepository, states store.OIDCStateStore, providers ...OIDCProvider) *OIDCLogin {
+	m := make(map[string]OIDCProvider, len(providers))
+	for _, p := range providers {
+		m[p.Name()] = p
+	}
+	return &OIDCLogin{providers: m, states: states, identities: identities}
+}
+
+// OIDCAuthorization is where to send the user to log in at the provider.
+type OIDCAuthorization struct {
+	AuthURL   string    `json:"auth_url"`
+	State     string    `json:"state"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// OIDCProviders returns the configured provider names, sorted.
+func (s *authService) OIDCProviders() []string {
+	if s.oidc == nil {
+		return []string{}
+	}
+	names := make([]string, 0, len(s.oidc.providers))
+	for name := range s.oidc.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOIDCLogin creates state, nonce and PKCE verifier for a login and returns the provider's
+// authorization URL. The verifier and nonce stay on the server until CompleteOIDCLogin.
+func (s *authService) StartOIDCLogin(provider string) (*OIDCAuthorization, error) {
+	p, err := s.oidcProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	var values [3]string
+	for i := range values {
+		if values[i], err = oidc.RandomString(32); err != nil {
+			return nil, fmt.Errorf("failed to generate oidc state: %w", err)
+		}
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	ctx := context.Background()
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Printf("[AUTH] oidc start failed provider=%s err=%v", provider, err)
+		return nil, fmt.Errorf("%w: %v", ErrOIDCAuthFailed, err)
+	}
+	data := store.OIDCState{Provider: provider, Nonce: nonce, CodeVerifier: verifier}
+	if err := s.oidc.states.Save(ctx, state, data, oidcStateTTL); err != nil {
+		return nil, fmt.Errorf("failed to save oidc state: %w", err)
+	}
+	return &OIDCAuthorization{AuthURL: authURL, State: state, ExpiresAt: s.now().Add(oidcStateTTL)}, nil
+}
+
+// CompleteOIDCLogin redeems the authorization code of a login started with StartOIDCLogin. The
+// first login through a provider account provisions a new user. Returns tokens like Login,
+// including *MFARequiredError for users with two-factor authentication enabled.
+func (s *authService) CompleteOIDCLogin(provider, state, code string, client ClientInfo) (*model.User, string, string, error) {
+	p, err := s.oidcProvider(provider)
+	if err != nil {
+		return nil, "", "", err
+	}
+	ctx := context.Background()
+	pending, err := s.oidc.states.Take(ctx, state)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to load oidc state: %w", err)
+	}
+	if pending == nil || pending.Provider != provider {
+		return nil, "", "", ErrInvalidOIDCState
+	}
+	identity, err := p.Authenticate(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		log.Printf("[AUTH] oidc login failed provider=%s err=%v", provider, err)
+		return nil, "", "", fmt.Errorf("%w: %v", ErrOIDCAuthFailed, err)
+	}
+
+	user, err := s.userForIdentity(provider, identity)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if s.opts.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, "", "", ErrEmailNotVerified
+	}
+	mfa, err := s.mfaRepo.GetByUserID(user.UserID)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to load mfa: %w", err)
+	}
+	if mfa.Enabled() {
+		return nil, "", "", s.mfaChallenge(user)
+	}
+	accessToken, refreshToken, err := s.startSession(user, client)
+	if err != nil {
+		return nil, "", "", err
+	}
+	log.Printf("[AUTH] oidc login success provider=%s user_id=%s", provider, user.UserID)
+	return user, accessToken, refreshToken, nil
+}
+
+// userForIdentity returns the user linked to the provider account, provisioning one on first login.
+func (s *authService) userForIdentity(provider string, identity *oidc.Identity) (*model.User, error) {
+	linked, err := s.oidc.identities.GetByProviderSubject(provider, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load identity: %w", err)
+	}
+	now := s.now()
+	if linked != nil {
+		user, err := s.userRepo.GetByID(linked.UserID)
+		if err != nil {
+			return nil, ErrUserNotFound
+		}
+		if err := s.oidc.identities.TouchLogin(linked.IdentityID, identity.Email, now); err != nil {
+			log.Printf("[AUTH] oidc touch identity failed identity_id=%s err=%v", linked.IdentityID, err)
+		}
+		return user, nil
+	}
+
+	if identity.Email == "" {
+		return nil, fmt.Errorf("%w: provider returned no email (request the email scope)", ErrOIDCAuthFailed)
+	}
+	if _, err := s.userRepo.GetByEmail(identity.Email); err == nil {
+		log.Printf("[AUTH] oidc provisioning refused provider=%s reason=email_taken", provider)
+		return nil, ErrIdentityConflict
+	}
+	username, err := s.availableUsername(identity)
+	if err != nil {
+		return nil, err
+	}
+	// SSO users get an unusable random password; they can set one via password reset.
+	passwordHash, err := pwd.Hash(randomHex(32))
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	displayName := identity.Name
+	if displayName == "" {
+		displayName = username
+	}
+	user := &model.User{
+		Username:     username,
+		Email:        identity.Email,
+		PasswordHash: passwordHash,
+		DisplayName:  truncateRunes(displayName, 100),
+	}
+	if identity.EmailVerified {
+		user.EmailVerifiedAt = &now
+	}
+	link := &model.UserIdentity{Provider: provider, Subject: identity.Subject, Email: identity.Email, LastLoginAt: &now}
+	if err := s.oidc.identities.CreateWithUser(user, link); err != nil {
+		return nil, fmt.Errorf("failed to provision user: %w", err)
+	}
+	log.Printf("[AUTH] oidc provisioned user provider=%s user_id=%s username=%s", provider, user.UserID, user.Username)
+	return user, nil
+}
+
+// availableUsername derives a username from the identity, adding a random suffix if it is taken.
+func (s *authService) availableUsername(identity *oidc.Identity) (string, error) {
+	base := sanitizeUsername(identity.PreferredUsername)
+	if base == "" {
+		base = sanitizeUsername(strings.SplitN(identity.Email, "@", 2)[0])
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+	candidate := truncateRunes(base, 50)
+	for i := 0; i < 5; i++ {
+		if _, err := s.userRepo.GetByUsername(candidate); err != nil {
+			return candidate, nil
+		}
+		candidate = truncateRunes(base, 50-5) + "_" + randomHex(2)
+	}
+	return "", fmt.Errorf("failed to find a free username for %q", base)
+}
+
+func (s *authService) oidcProvider(name string) (OIDCProvider, error) {
+	if s.oidc == nil {
+		return nil, ErrUnknownProvider
+	}
+	p, ok := s.oidc.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// sanitizeUsername keeps letters, digits, '.', '_' and '-'.
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// truncateRunes shortens s to at most n runes.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// randomHex returns n random bytes hex-encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
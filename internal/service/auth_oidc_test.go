@@ -0,0 +1,248 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: auth_oidc_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for OpenID Connect login against an in-process fake provider
+
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/pkg/jwt"
+	"github.com/convexwf/uim-go/internal/pkg/oidc"
+	"github.com/convexwf/uim-go/internal/pkg/oidc/oidctest"
+	"github.com/convexwf/uim-go/internal/store"
+)
+
+// mockIdentityRepository is an in-memory IdentityRepository that provisions into a mock user repository.
+type mockIdentityRepository struct {
+	users      *mockUserRepository
+	identities map[uuid.UUID]*model.UserIdentity
+}
+
+func newMockIdentityRepository(users *mockUserRepository) *mockIdentityRepository {
+	return &mockIdentityRepository{users: users, identities: make(map[uuid.UUID]*model.UserIdentity)}
+}
+
+func (m *mockIdentityRepository) GetByProviderSubject(provider, subject string) (*model.UserIdentity, error) {
+	for _, id := range m.identities {
+		if id.Provider == provider && id.Subject == subject {
+			copied := *id
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockIdentityRepository) CreateWithUser(user *model.User, identity *model.UserIdentity) error {
+	if user.UserID == uuid.Nil {
+		user.UserID = uuid.New()
+	}
+	if err := m.users.Create(user); err != nil {
+		return err
+	}
+	identity.IdentityID = uuid.New()
+	identity.UserID = user.UserID
+	identity.CreatedAt = time.Now()
+	copied := *identity
+	m.identities[identity.IdentityID] = &copied
+	return nil
+}
+
+func (m *mockIdentityRepository) TouchLogin(identityID uuid.UUID, email string, at time.Time) error {
+	if id, ok := m.identities[identityID]; ok {
+		id.Email = email
+		id.LastLoginAt = &at
+	}
+	return nil
+}
+
+func (m *mockIdentityRepository) ListByUserID(userID uuid.UUID) ([]*model.UserIdentity, error) {
+	var out []*model.UserIdentity
+	for _, id := range m.identities {
+		if id.UserID == userID {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockIdentityRepository) DeleteByUserID(userID uuid.UUID) error {
+	for k, id := range m.identities {
+		if id.UserID == userID {
+			delete(m.identities, k)
+		}
+	}
+	return nil
+}
+
+type oidcFixture struct {
+	idp        *oidctest.Server
+	users      *mockUserRepository
+	identities *mockIdentityRepository
+	mfa        *mockMFARepository
+	jwtManager *jwt.JWTManager
+	svc        AuthService
+}
+
+func setupOIDC(t *testing.T) *oidcFixture {
+	t.Helper()
+	idp := oidctest.NewServer(t, "uim", "s3cret")
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "corp",
+		Issuer:       idp.Issuer(),
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  "https://chat.example.org/auth/oidc/corp/callback",
+	}, nil)
+	f := &oidcFixture{idp: idp, users: newMockUserRepository(), mfa: newMockMFARepository()}
+	f.identities = newMockIdentityRepository(f.users)
+	f.jwtManager = jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	login := NewOIDCLogin(f.identities, store.NewMemoryOIDCStateStore(), provider)
+	f.svc = NewAuthService(f.users, newMockSessionRepository(), newMockUserTokenRepository(), f.mfa, f.jwtManager, AuthOptions{OIDC: login})
+	return f
+}
+
+// login runs the whole flow: start, browser round trip at the fake provider, completion.
+func (f *oidcFixture) login(t *testing.T) (*model.User, string, error) {
+	t.Helper()
+	authz, err := f.svc.StartOIDCLogin("corp")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin() error = %v", err)
+	}
+	code, state := f.idp.Authorize(t, authz.AuthURL)
+	if state != authz.State {
+		t.Fatalf("state = %q, want %q", state, authz.State)
+	}
+	user, accessToken, _, err := f.svc.CompleteOIDCLogin("corp", state, code, ClientInfo{DeviceName: "browser"})
+	return user, accessToken, err
+}
+
+func TestAuthService_OIDCLogin_ProvisionsThenLinks(t *testing.T) {
+	f := setupOIDC(t)
+
+	user, accessToken, err := f.login(t)
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin() error = %v", err)
+	}
+	if user.Username != "sso-user" || user.Email != "sso-user@example.com" || user.DisplayName != "SSO User" {
+		t.Errorf("provisioned user = %+v", user)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("email verified by the provider should be marked verified")
+	}
+	claims, err := f.jwtManager.ValidateAccessToken(accessToken)
+	if err != nil || claims.UserID != user.UserID.String() {
+		t.Fatalf("access token claims = %+v err=%v", claims, err)
+	}
+
+	// The second login finds the linked identity instead of provisioning again.
+	again, _, err := f.login(t)
+	if err != nil {
+		t.Fatalf("second CompleteOIDCLogin() error = %v", err)
+	}
+	if again.UserID != user.UserID {
+		t.Errorf("second login user = %v, want %v", again.UserID, user.UserID)
+	}
+	if len(f.identities.identities) != 1 {
+		t.Errorf("identities = %d, want 1", len(f.identities.identities))
+	}
+}
+
+func TestAuthService_OIDCLogin_UsernameTaken(t *testing.T) {
+	f := setupOIDC(t)
+	if _, _, _, err := f.svc.Register("sso-user", "local@example.com", "password123", ClientInfo{}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	user, _, err := f.login(t)
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin() error = %v", err)
+	}
+	if user.Username == "sso-user" || len(user.Username) <= len("sso-user") {
+		t.Errorf("Username = %q, want sso-user with a suffix", user.Username)
+	}
+}
+
+func TestAuthService_OIDCLogin_EmailConflict(t *testing.T) {
+	f := setupOIDC(t)
+	if _, _, _, err := f.svc.Register("local", "sso-user@example.com", "password123", ClientInfo{}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if _, _, err := f.login(t); !errors.Is(err, ErrIdentityConflict) {
+		t.Errorf("CompleteOIDCLogin() error = %v, want ErrIdentityConflict", err)
+	}
+}
+
+func TestAuthService_OIDCLogin_InvalidState(t *testing.T) {
+	f := setupOIDC(t)
+	authz, err := f.svc.StartOIDCLogin("corp")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin() error = %v", err)
+	}
+	code, _ := f.idp.Authorize(t, authz.AuthURL)
+
+	if _, _, _, err := f.svc.CompleteOIDCLogin("corp", "forged-state", code, ClientInfo{}); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("forged state error = %v, want ErrInvalidOIDCState", err)
+	}
+	if _, _, _, err := f.svc.CompleteOIDCLogin("corp", authz.State, code, ClientInfo{}); err != nil {
+		t.Fatalf("CompleteOIDCLogin() error = %v", err)
+	}
+	// State is single-use.
+	if _, _, _, err := f.svc.CompleteOIDCLogin("corp", authz.State, code, ClientInfo{}); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("replayed state error = %v, want ErrInvalidOIDCState", err)
+	}
+	if _, err := f.svc.StartOIDCLogin("unknown"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("StartOIDCLogin(unknown) error = %v, want ErrUnknownProvider", err)
+	}
+}
+
+func TestAuthService_OIDCLogin_RejectedToken(t *testing.T) {
+	f := setupOIDC(t)
+	f.idp.SetClaim("aud", "another-client")
+	if _, _, err := f.login(t); !errors.Is(err, ErrOIDCAuthFailed) {
+		t.Errorf("CompleteOIDCLogin() error = %v, want ErrOIDCAuthFailed", err)
+	}
+	if len(f.users.users) != 0 {
+		t.Error("no user should be provisioned for a rejected token")
+	}
+}
+
+func TestAuthService_OIDCLogin_MFARequired(t *testing.T) {
+	f := setupOIDC(t)
+	user, _, err := f.login(t)
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin() error = %v", err)
+	}
+	now := time.Now()
+	_ = f.mfa.Save(&model.UserMFA{UserID: user.UserID, TOTPSecret: "JBSWY3DPEHPK3PXP", ConfirmedAt: &now})
+
+	_, _, err = f.login(t)
+	var mfaErr *MFARequiredError
+	if !errors.As(err, &mfaErr) || mfaErr.PendingToken == "" {
+		t.Errorf("CompleteOIDCLogin() error = %v, want MFARequiredError", err)
+	}
+}
+
+func TestAuthService_OIDCProviders(t *testing.T) {
+	f := setupOIDC(t)
+	if got := f.svc.OIDCProviders(); len(got) != 1 || got[0] != "corp" {
+		t.Errorf("OIDCProviders() = %v, want [corp]", got)
+	}
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	noSSO := NewAuthService(newMockUserRepository(), newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
+	if got := noSSO.OIDCProviders(); len(got) != 0 {
+		t.Errorf("OIDCProviders() without SSO = %v, want empty", got)
+	}
+}
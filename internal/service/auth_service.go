@@ -23,15 +23,21 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/convexwf/uim-go/internal/model"
 	"github.com/convexwf/uim-go/internal/pkg/jwt"
+	"github.com/convexwf/uim-go/internal/pkg/mailer"
 	pwd "github.com/convexwf/uim-go/internal/pkg/password"
 	"github.com/convexwf/uim-go/internal/repository"
+	"github.com/convexwf/uim-go/internal/store"
 )
 
 var (
@@ -39,28 +45,140 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserExists         = errors.New("user already exists")
 	ErrInvalidInput       = errors.New("invalid input")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrEmailNotVerified   = errors.New("email not verified")
 )
 
+const maxDeviceNameLength = 100
+
+// ClientInfo describes the device a login comes from; it is recorded on the session.
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IP         string
+}
+
+// SessionNotifier is called after a session is revoked (e.g. to close its WebSocket connections).
+// Implementations can be nil-safe; the service will only call if non-nil.
+type SessionNotifier interface {
+	NotifySessionRevoked(userID, sessionID uuid.UUID)
+}
+
+// AccountListener is called after a user deleted their account (e.g. to hand over the groups
+// they own).
+type AccountListener interface {
+	OnAccountDeleted(userID uuid.UUID)
+}
+
 type AuthService interface {
-	Register(username, email, password string) (*model.User, string, string, error)
-	Login(username, password string) (*model.User, string, string, error)
-	RefreshToken(refreshToken string) (*model.User, string, string, error)
+	Register(username, email, password string, client ClientInfo) (*model.User, string, string, error)
+	Login(username, password string, client ClientInfo) (*model.User, string, string, error)
+	RefreshToken(refreshToken string, client ClientInfo) (*model.User, string, string, error)
 	GetProfile(userID uuid.UUID) (*model.User, error)
+	ListSessions(userID uuid.UUID) ([]*model.UserSession, error)
+	RevokeSession(userID, sessionID uuid.UUID) error
+	Logout(userID, sessionID uuid.UUID, tokenID string) error
+	ChangePassword(userID uuid.UUID, oldPassword, newPassword string, client ClientInfo) (string, string, error)
+	DeleteAccount(userID uuid.UUID, password string) error
+	RequestEmailVerification(userID uuid.UUID) error
+	VerifyEmail(token string) (*model.User, error)
+	RequestPasswordReset(email string) error
+	ResetPassword(token, newPassword string) error
+	CompleteMFALogin(pendingToken, code string, client ClientInfo) (*model.User, string, string, error)
+	EnrollTOTP(userID uuid.UUID) (*TOTPEnrollment, error)
+	ConfirmTOTP(userID uuid.UUID, code string) ([]string, error)
+	DisableTOTP(userID uuid.UUID, password, code string) error
+	RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error)
+	GetMFAStatus(userID uuid.UUID) (*MFAStatus, error)
+	OIDCProviders() []string
+	StartOIDCLogin(provider string) (*OIDCAuthorization, error)
+	CompleteOIDCLogin(provider, state, code string, client ClientInfo) (*model.User, string, string, error)
+}
+
+// AuthOptions holds optional authentication policy. The zero value keeps the defaults.
+type AuthOptions struct {
+	// RequireVerifiedEmail rejects Login (ErrEmailNotVerified) until the user verified their email;
+	// Register then returns no tokens.
+	RequireVerifiedEmail bool
+	// PublicURL is the base URL of the client app used in emailed links (e.g. https://chat.example.org).
+	PublicURL string
+	// VerifyEmailTTL and PasswordResetTTL bound the lifetime of emailed tokens (defaults 24h / 1h).
+	VerifyEmailTTL   time.Duration
+	PasswordResetTTL time.Duration
+	// TOTPIssuer names the service in authenticator apps (default "UIM").
+	TOTPIssuer string
+	// Now is the clock used for TOTP validation (default time.Now); tests inject a fixed clock.
+	Now func() time.Time
+	// AccountListener is notified after DeleteAccount; may be nil.
+	AccountListener AccountListener
+	// SessionNotifier is told when sessions are revoked so live connections are closed; may be nil.
+	SessionNotifier SessionNotifier
+	// Revocations rejects access tokens of revoked sessions; may be nil (they then stay valid
+	// until they expire).
+	Revocations store.RevocationStore
+	// Mailer sends verification and password reset emails; may be nil (those flows then
+	// return ErrMailerUnavailable).
+	Mailer mailer.Mailer
+	// LoginGuard throttles failed logins; may be nil (no brute-force protection).
+	LoginGuard *LoginGuard
+	// OIDC enables single sign-on; may be nil.
+	OIDC *OIDCLogin
 }
 
 type authService struct {
-	userRepo   repository.UserRepository
-	jwtManager *jwt.JWTManager
+	userRepo        repository.UserRepository
+	sessionRepo     repository.SessionRepository
+	tokenRepo       repository.UserTokenRepository
+	mfaRepo         repository.MFARepository
+	jwtManager      *jwt.JWTManager
+	sessionNotifier SessionNotifier
+	revocations     store.RevocationStore
+	mailer          mailer.Mailer
+	loginGuard      *LoginGuard
+	oidc            *OIDCLogin
+	opts            AuthOptions
+	// mfaAttempts counts second-factor attempts per user; it is the login guard's store when
+	// one is configured, so the budget is shared by all instances.
+	mfaAttempts store.LoginAttemptStore
+	// background tracks password reset emails still being sent (waited for by tests).
+	background sync.WaitGroup
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtManager *jwt.JWTManager) AuthService {
+// NewAuthService creates a new authentication service. Optional collaborators are passed in opts.
+func NewAuthService(userRepo repository.UserRepository, sessionRepo repository.SessionRepository, tokenRepo repository.UserTokenRepository, mfaRepo repository.MFARepository, jwtManager *jwt.JWTManager, opts AuthOptions) AuthService {
+	if opts.VerifyEmailTTL <= 0 {
+		opts.VerifyEmailTTL = defaultVerifyEmailTTL
+	}
+	if opts.PasswordResetTTL <= 0 {
+		opts.PasswordResetTTL = defaultPasswordResetTTL
+	}
+	if opts.TOTPIssuer == "" {
+		opts.TOTPIssuer = defaultTOTPIssuer
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	var mfaAttempts store.LoginAttemptStore = store.NewMemoryLoginAttemptStore()
+	if opts.LoginGuard != nil {
+		mfaAttempts = opts.LoginGuard.attempts
+	}
 	return &authService{
-		userRepo:   userRepo,
-		jwtManager: jwtManager,
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		tokenRepo:       tokenRepo,
+		mfaRepo:         mfaRepo,
+		jwtManager:      jwtManager,
+		sessionNotifier: opts.SessionNotifier,
+		revocations:     opts.Revocations,
+		mailer:          opts.Mailer,
+		loginGuard:      opts.LoginGuard,
+		oidc:            opts.OIDC,
+		opts:            opts,
+		mfaAttempts:     mfaAttempts,
 	}
 }
 
-func (s *authService) Register(username, email, password string) (*model.User, string, string, error) {
+func (s *authService) Register(username, email, password string, client ClientInfo) (*model.User, string, string, error) {
 	// Validate input
 	if username == "" || email == "" || password == "" {
 		return nil, "", "", ErrInvalidInput
@@ -103,47 +221,78 @@ func (s *authService) Register(username, email, password string) (*model.User, s
 		return nil, "", "", fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtManager.GenerateAccessToken(user.UserID.String())
-	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate access token: %w", err)
+	if s.mailer != nil {
+		// Registration succeeds even if the mail cannot be sent; the user can request it again.
+		if err := s.sendVerificationEmail(user); err != nil {
+			log.Printf("[AUTH] send verification email failed user_id=%s err=%v", user.UserID, err)
+		}
+	}
+	if s.opts.RequireVerifiedEmail {
+		return user, "", "", nil
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.UserID.String())
+	accessToken, refreshToken, err := s.startSession(user, client)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, "", "", err
 	}
-
 	return user, accessToken, refreshToken, nil
 }
 
-func (s *authService) Login(username, password string) (*model.User, string, string, error) {
+func (s *authService) Login(username, password string, client ClientInfo) (*model.User, string, string, error) {
+	if s.loginGuard != nil {
+		if err := s.loginGuard.Check(context.Background(), username, client.IP); err != nil {
+			return nil, "", "", err
+		}
+	}
+
 	// Get user
 	user, err := s.userRepo.GetByUsername(username)
 	if err != nil {
+		s.loginFailed(username, client.IP, uuid.Nil)
+		return nil, "", "", ErrInvalidCredentials
+	}
+	// Bots authenticate with API keys only.
+	if user.IsBot {
+		s.loginFailed(username, client.IP, user.UserID)
 		return nil, "", "", ErrInvalidCredentials
 	}
 
 	// Verify password
 	if !pwd.Verify(password, user.PasswordHash) {
+		s.loginFailed(username, client.IP, user.UserID)
 		return nil, "", "", ErrInvalidCredentials
 	}
+	if s.opts.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, "", "", ErrEmailNotVerified
+	}
 
-	// Generate tokens
-	accessToken, err := s.jwtManager.GenerateAccessToken(user.UserID.String())
+	mfa, err := s.mfaRepo.GetByUserID(user.UserID)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate access token: %w", err)
+		return nil, "", "", fmt.Errorf("failed to load mfa: %w", err)
+	}
+	if mfa.Enabled() {
+		return nil, "", "", s.mfaChallenge(user)
+	}
+	// Failures are only cleared once the user is fully authenticated (see CompleteMFALogin).
+	if s.loginGuard != nil {
+		s.loginGuard.Success(context.Background(), username, client.IP, user.UserID)
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.UserID.String())
+	accessToken, refreshToken, err := s.startSession(user, client)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, "", "", err
 	}
-
 	return user, accessToken, refreshToken, nil
 }
 
-func (s *authService) RefreshToken(refreshToken string) (*model.User, string, string, error) {
+// loginFailed records a failed login attempt with the login guard, if configured.
+func (s *authService) loginFailed(username, ip string, userID uuid.UUID) {
+	if s.loginGuard != nil {
+		s.loginGuard.Failure(context.Background(), username, ip, userID)
+	}
+}
+
+func (s *authService) RefreshToken(refreshToken string, client ClientInfo) (*model.User, string, string, error) {
 	// Validate refresh token
 	claims, err := s.jwtManager.ValidateRefreshToken(refreshToken)
 	if err != nil {
@@ -160,18 +309,38 @@ func (s *authService) RefreshToken(refreshToken string) (*model.User, string, st
 	if err != nil {
 		return nil, "", "", ErrUserNotFound
 	}
+	// The user cutoff is the only thing that stops a token without a session (below) after a
+	// password change or reset.
+	if s.tokenRevoked(userID, claims) {
+		return nil, "", "", ErrInvalidCredentials
+	}
 
-	// Generate new tokens
-	accessToken, err := s.jwtManager.GenerateAccessToken(user.UserID.String())
-	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate access token: %w", err)
+	// Tokens issued before sessions existed carry no sid: give them a session now.
+	if claims.SessionID == "" {
+		accessToken, newRefreshToken, err := s.startSession(user, client)
+		if err != nil {
+			return nil, "", "", err
+		}
+		return user, accessToken, newRefreshToken, nil
 	}
 
-	newRefreshToken, err := s.jwtManager.GenerateRefreshToken(user.UserID.String())
+	sessionID, err := uuid.Parse(claims.SessionID)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, "", "", ErrInvalidCredentials
+	}
+	session, err := s.sessionRepo.GetByID(sessionID)
+	now := time.Now()
+	if err != nil || session.UserID != user.UserID || !session.IsActive(now) {
+		return nil, "", "", ErrInvalidCredentials
+	}
+	if err := s.sessionRepo.Touch(sessionID, now, now.Add(s.jwtManager.RefreshExpiry())); err != nil {
+		return nil, "", "", fmt.Errorf("failed to update session: %w", err)
 	}
 
+	accessToken, newRefreshToken, err := s.generateTokens(user.UserID, sessionID)
+	if err != nil {
+		return nil, "", "", err
+	}
 	return user, accessToken, newRefreshToken, nil
 }
 
@@ -182,3 +351,180 @@ func (s *authService) GetProfile(userID uuid.UUID) (*model.User, error) {
 	}
 	return user, nil
 }
+
+// ListSessions returns the user's active login sessions, most recently used first.
+func (s *authService) ListSessions(userID uuid.UUID) ([]*model.UserSession, error) {
+	return s.sessionRepo.ListActiveByUserID(userID)
+}
+
+// RevokeSession revokes one of the user's sessions and notifies listeners (e.g. WebSocket hub).
+func (s *authService) RevokeSession(userID, sessionID uuid.UUID) error {
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	revoked, err := s.sessionRepo.Revoke(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	s.afterSessionRevoked(userID, sessionID)
+	return nil
+}
+
+// Logout ends the current session and revokes the access token used for the request.
+// sessionID may be uuid.Nil for tokens issued before sessions existed.
+func (s *authService) Logout(userID, sessionID uuid.UUID, tokenID string) error {
+	if s.revocations != nil && tokenID != "" {
+		if err := s.revocations.RevokeToken(context.Background(), tokenID, s.jwtManager.AccessExpiry()); err != nil {
+			log.Printf("[AUTH] revoke token failed user_id=%s err=%v", userID, err)
+		}
+	}
+	if sessionID == uuid.Nil {
+		return nil
+	}
+	if err := s.RevokeSession(userID, sessionID); err != nil && err != ErrSessionNotFound {
+		return err
+	}
+	return nil
+}
+
+// ChangePassword verifies the old password, stores the new one, revokes every existing session
+// and token of the user, and returns tokens for a fresh session on the calling device.
+func (s *authService) ChangePassword(userID uuid.UUID, oldPassword, newPassword string, client ClientInfo) (string, string, error) {
+	if len(newPassword) < 6 {
+		return "", "", fmt.Errorf("%w: password must be at least 6 characters", ErrInvalidInput)
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return "", "", ErrUserNotFound
+	}
+	if !pwd.Verify(oldPassword, user.PasswordHash) {
+		return "", "", ErrInvalidCredentials
+	}
+	passwordHash, err := pwd.Hash(newPassword)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.PasswordHash = passwordHash
+	if err := s.userRepo.Update(user); err != nil {
+		return "", "", fmt.Errorf("failed to update user: %w", err)
+	}
+	if err := s.revokeAllSessions(userID); err != nil {
+		return "", "", err
+	}
+	return s.startSession(user, client)
+}
+
+// DeleteAccount verifies the password, soft-deletes the user and revokes all their sessions and tokens.
+func (s *authService) DeleteAccount(userID uuid.UUID, password string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if !pwd.Verify(password, user.PasswordHash) {
+		return ErrInvalidCredentials
+	}
+	if err := s.userRepo.Delete(userID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if s.opts.AccountListener != nil {
+		s.opts.AccountListener.OnAccountDeleted(userID)
+	}
+	if err := s.mfaRepo.Delete(userID); err != nil {
+		log.Printf("[AUTH] delete mfa failed user_id=%s err=%v", userID, err)
+	}
+	if s.oidc != nil {
+		if err := s.oidc.identities.DeleteByUserID(userID); err != nil {
+			log.Printf("[AUTH] delete identities failed user_id=%s err=%v", userID, err)
+		}
+	}
+	return s.revokeAllSessions(userID)
+}
+
+// tokenRevoked reports whether the token was revoked. Failed checks count as revoked.
+func (s *authService) tokenRevoked(userID uuid.UUID, claims *jwt.Claims) bool {
+	if s.revocations == nil {
+		return false
+	}
+	sessionID, _ := uuid.Parse(claims.SessionID)
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := s.revocations.IsRevoked(context.Background(), userID, sessionID, claims.ID, issuedAt)
+	if err != nil {
+		log.Printf("[AUTH] revocation check failed user_id=%s err=%v", userID, err)
+		return true
+	}
+	return revoked
+}
+
+// revokeAllSessions revokes every session of the user and records a user-wide cutoff so that
+// tokens without a session (pre-session and MFA pending tokens) issued before now are rejected.
+func (s *authService) revokeAllSessions(userID uuid.UUID) error {
+	sessionIDs, err := s.sessionRepo.RevokeAllByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if s.revocations != nil {
+		if err := s.revocations.RevokeUser(context.Background(), userID, time.Now(), s.jwtManager.RefreshExpiry()); err != nil {
+			log.Printf("[AUTH] revoke user tokens failed user_id=%s err=%v", userID, err)
+		}
+	}
+	for _, sessionID := range sessionIDs {
+		s.afterSessionRevoked(userID, sessionID)
+	}
+	return nil
+}
+
+// afterSessionRevoked blocks the session's outstanding access tokens and notifies listeners.
+func (s *authService) afterSessionRevoked(userID, sessionID uuid.UUID) {
+	if s.revocations != nil {
+		if err := s.revocations.RevokeSession(context.Background(), sessionID, s.jwtManager.RefreshExpiry()); err != nil {
+			log.Printf("[AUTH] revoke session tokens failed session_id=%s err=%v", sessionID, err)
+		}
+	}
+	if s.sessionNotifier != nil {
+		s.sessionNotifier.NotifySessionRevoked(userID, sessionID)
+	}
+}
+
+// startSession records a new login session for the user and issues tokens bound to it.
+func (s *authService) startSession(user *model.User, client ClientInfo) (string, string, error) {
+	now := time.Now()
+	session := &model.UserSession{
+		UserID:     user.UserID,
+		DeviceName: truncateRunes(client.DeviceName, maxDeviceNameLength),
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.jwtManager.RefreshExpiry()),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return "", "", fmt.Errorf("failed to create session: %w", err)
+	}
+	return s.generateTokens(user.UserID, session.SessionID)
+}
+
+// now returns the service clock (injectable for tests).
+func (s *authService) now() time.Time {
+	return s.opts.Now()
+}
+
+func (s *authService) generateTokens(userID, sessionID uuid.UUID) (string, string, error) {
+	accessToken, err := s.jwtManager.GenerateAccessToken(userID.String(), sessionID.String())
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	refreshToken, err := s.jwtManager.GenerateRefreshToken(userID.String(), sessionID.String())
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return accessToken, refreshToken, nil
+}
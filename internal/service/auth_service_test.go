@@ -23,14 +23,19 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 
 	"github.com/convexwf/uim-go/internal/model"
 	"github.com/convexwf/uim-go/internal/pkg/jwt"
+	"github.com/convexwf/uim-go/internal/pkg/mailer"
+	"github.com/convexwf/uim-go/internal/store"
 )
 
 // mockUserRepository is a mock implementation of UserRepository for testing.
@@ -119,12 +124,138 @@ func (m *mockUserRepository) Delete(userID uuid.UUID) error {
 	return nil
 }
 
+// mockSessionRepository is an in-memory SessionRepository for testing.
+type mockSessionRepository struct {
+	sessions map[uuid.UUID]*model.UserSession
+}
+
+func newMockSessionRepository() *mockSessionRepository {
+	return &mockSessionRepository{sessions: make(map[uuid.UUID]*model.UserSession)}
+}
+
+func (m *mockSessionRepository) Create(session *model.UserSession) error {
+	if session.SessionID == uuid.Nil {
+		session.SessionID = uuid.New()
+	}
+	m.sessions[session.SessionID] = session
+	return nil
+}
+
+func (m *mockSessionRepository) GetByID(sessionID uuid.UUID) (*model.UserSession, error) {
+	session, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, errors.New("session not found")
+	}
+	return session, nil
+}
+
+func (m *mockSessionRepository) ListActiveByUserID(userID uuid.UUID) ([]*model.UserSession, error) {
+	var out []*model.UserSession
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.IsActive(time.Now()) {
+			out = append(out, session)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockSessionRepository) Touch(sessionID uuid.UUID, lastUsedAt, expiresAt time.Time) error {
+	if session, ok := m.sessions[sessionID]; ok && session.RevokedAt == nil {
+		session.LastUsedAt = lastUsedAt
+		session.ExpiresAt = expiresAt
+	}
+	return nil
+}
+
+func (m *mockSessionRepository) Revoke(sessionID uuid.UUID) (bool, error) {
+	session, ok := m.sessions[sessionID]
+	if !ok || session.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return true, nil
+}
+
+func (m *mockSessionRepository) RevokeAllByUserID(userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for id, session := range m.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			now := time.Now()
+			session.RevokedAt = &now
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// mockSessionNotifier records revoked sessions.
+type mockSessionNotifier struct {
+	revoked []uuid.UUID
+}
+
+func (m *mockSessionNotifier) NotifySessionRevoked(userID, sessionID uuid.UUID) {
+	m.revoked = append(m.revoked, sessionID)
+}
+
+// mockUserTokenRepository is an in-memory UserTokenRepository for testing.
+type mockUserTokenRepository struct {
+	tokens map[string]*model.UserToken
+}
+
+func newMockUserTokenRepository() *mockUserTokenRepository {
+	return &mockUserTokenRepository{tokens: make(map[string]*model.UserToken)}
+}
+
+func (m *mockUserTokenRepository) Create(token *model.UserToken) error {
+	m.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (m *mockUserTokenRepository) Consume(tokenHash string, purpose model.UserTokenPurpose, now time.Time) (*model.UserToken, error) {
+	token, ok := m.tokens[tokenHash]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+		return nil, errors.New("token not found")
+	}
+	token.UsedAt = &now
+	return token, nil
+}
+
+func (m *mockUserTokenRepository) InvalidateByUserID(userID uuid.UUID, purpose model.UserTokenPurpose) error {
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+// tokenFromMail extracts the token query parameter from the last email sent to the address.
+// waitMail waits for the emails RequestPasswordReset sends in the background.
+func waitMail(s AuthService) {
+	s.(*authService).background.Wait()
+}
+
+func tokenFromMail(t *testing.T, m *mailer.MemoryMailer, to string) string {
+	t.Helper()
+	msg, ok := m.Last(to)
+	if !ok {
+		t.Fatalf("no email sent to %s", to)
+	}
+	i := strings.Index(msg.Body, "token=")
+	if i < 0 {
+		t.Fatalf("email has no token: %q", msg.Body)
+	}
+	return strings.Fields(msg.Body[i+len("token="):])[0]
+}
+
 func TestAuthService_Register(t *testing.T) {
 	userRepo := newMockUserRepository()
 	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
-	authService := NewAuthService(userRepo, jwtManager)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
 
-	user, accessToken, refreshToken, err := authService.Register("testuser", "test@example.com", "password123")
+	user, accessToken, refreshToken, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
 	if err != nil {
 		t.Fatalf("Register() error = %v", err)
 	}
@@ -145,14 +276,14 @@ func TestAuthService_Register(t *testing.T) {
 func TestAuthService_Register_DuplicateUsername(t *testing.T) {
 	userRepo := newMockUserRepository()
 	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
-	authService := NewAuthService(userRepo, jwtManager)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
 
-	_, _, _, err := authService.Register("testuser", "test@example.com", "password123")
+	_, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
 	if err != nil {
 		t.Fatalf("First Register() error = %v", err)
 	}
 
-	_, _, _, err = authService.Register("testuser", "test2@example.com", "password123")
+	_, _, _, err = authService.Register("testuser", "test2@example.com", "password123", ClientInfo{})
 	if err != ErrUserExists {
 		t.Errorf("Register() with duplicate username error = %v, want ErrUserExists", err)
 	}
@@ -161,14 +292,14 @@ func TestAuthService_Register_DuplicateUsername(t *testing.T) {
 func TestAuthService_Register_DuplicateEmail(t *testing.T) {
 	userRepo := newMockUserRepository()
 	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
-	authService := NewAuthService(userRepo, jwtManager)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
 
-	_, _, _, err := authService.Register("testuser", "test@example.com", "password123")
+	_, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
 	if err != nil {
 		t.Fatalf("First Register() error = %v", err)
 	}
 
-	_, _, _, err = authService.Register("testuser2", "test@example.com", "password123")
+	_, _, _, err = authService.Register("testuser2", "test@example.com", "password123", ClientInfo{})
 	if err != ErrUserExists {
 		t.Errorf("Register() with duplicate email error = %v, want ErrUserExists", err)
 	}
@@ -177,7 +308,7 @@ func TestAuthService_Register_DuplicateEmail(t *testing.T) {
 func TestAuthService_Register_InvalidInput(t *testing.T) {
 	userRepo := newMockUserRepository()
 	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
-	authService := NewAuthService(userRepo, jwtManager)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
 
 	testCases := []struct {
 		name     string
@@ -195,7 +326,7 @@ func TestAuthService_Register_InvalidInput(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, _, _, err := authService.Register(tc.username, tc.email, tc.password)
+			_, _, _, err := authService.Register(tc.username, tc.email, tc.password, ClientInfo{})
 			if err != ErrInvalidInput && !errors.Is(err, ErrInvalidInput) {
 				t.Errorf("Register() error = %v, want ErrInvalidInput or wrapped", err)
 			}
@@ -206,16 +337,16 @@ func TestAuthService_Register_InvalidInput(t *testing.T) {
 func TestAuthService_Login(t *testing.T) {
 	userRepo := newMockUserRepository()
 	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
-	authService := NewAuthService(userRepo, jwtManager)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
 
 	// Register first
-	_, _, _, err := authService.Register("testuser", "test@example.com", "password123")
+	_, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
 	if err != nil {
 		t.Fatalf("Register() error = %v", err)
 	}
 
 	// Login
-	user, accessToken, refreshToken, err := authService.Login("testuser", "password123")
+	user, accessToken, refreshToken, err := authService.Login("testuser", "password123", ClientInfo{})
 	if err != nil {
 		t.Fatalf("Login() error = %v", err)
 	}
@@ -233,10 +364,10 @@ func TestAuthService_Login(t *testing.T) {
 func TestAuthService_Login_InvalidCredentials(t *testing.T) {
 	userRepo := newMockUserRepository()
 	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
-	authService := NewAuthService(userRepo, jwtManager)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
 
 	// Register first
-	_, _, _, err := authService.Register("testuser", "test@example.com", "password123")
+	_, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
 	if err != nil {
 		t.Fatalf("Register() error = %v", err)
 	}
@@ -252,7 +383,7 @@ func TestAuthService_Login_InvalidCredentials(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, _, _, err := authService.Login(tc.username, tc.password)
+			_, _, _, err := authService.Login(tc.username, tc.password, ClientInfo{})
 			if err != ErrInvalidCredentials {
 				t.Errorf("Login() error = %v, want ErrInvalidCredentials", err)
 			}
@@ -263,16 +394,16 @@ func TestAuthService_Login_InvalidCredentials(t *testing.T) {
 func TestAuthService_RefreshToken(t *testing.T) {
 	userRepo := newMockUserRepository()
 	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
-	authService := NewAuthService(userRepo, jwtManager)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
 
 	// Register and get refresh token
-	_, _, refreshToken, err := authService.Register("testuser", "test@example.com", "password123")
+	_, _, refreshToken, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
 	if err != nil {
 		t.Fatalf("Register() error = %v", err)
 	}
 
 	// Refresh token
-	user, accessToken, newRefreshToken, err := authService.RefreshToken(refreshToken)
+	user, accessToken, newRefreshToken, err := authService.RefreshToken(refreshToken, ClientInfo{})
 	if err != nil {
 		t.Fatalf("RefreshToken() error = %v", err)
 	}
@@ -290,19 +421,323 @@ func TestAuthService_RefreshToken(t *testing.T) {
 func TestAuthService_RefreshToken_InvalidToken(t *testing.T) {
 	userRepo := newMockUserRepository()
 	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
-	authService := NewAuthService(userRepo, jwtManager)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
 
-	_, _, _, err := authService.RefreshToken("invalid-token")
+	_, _, _, err := authService.RefreshToken("invalid-token", ClientInfo{})
 	if err != ErrInvalidCredentials {
 		t.Errorf("RefreshToken() error = %v, want ErrInvalidCredentials", err)
 	}
 }
 
+func TestAuthService_Login_RecordsSession(t *testing.T) {
+	userRepo := newMockUserRepository()
+	sessionRepo := newMockSessionRepository()
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(userRepo, sessionRepo, newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
+
+	user, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	_, accessToken, _, err := authService.Login("testuser", "password123", ClientInfo{DeviceName: "Pixel", UserAgent: "uim/1.0", IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	claims, err := jwtManager.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	session, err := sessionRepo.GetByID(uuid.MustParse(claims.SessionID))
+	if err != nil {
+		t.Fatalf("access token sid %q has no session", claims.SessionID)
+	}
+	if session.DeviceName != "Pixel" || session.UserAgent != "uim/1.0" || session.IPAddress != "10.0.0.1" {
+		t.Errorf("session client info = %+v", session)
+	}
+
+	sessions, err := authService.ListSessions(user.UserID)
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Errorf("ListSessions() len = %d, want 2 (register + login)", len(sessions))
+	}
+}
+
+func TestAuthService_Login_TruncatesDeviceNameByRunes(t *testing.T) {
+	userRepo := newMockUserRepository()
+	sessionRepo := newMockSessionRepository()
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(userRepo, sessionRepo, newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
+
+	if _, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	// 99 ASCII bytes then multi-byte runes: a byte cut at 100 would split the first "é".
+	name := strings.Repeat("a", 99) + strings.Repeat("é", 10)
+	_, accessToken, _, err := authService.Login("testuser", "password123", ClientInfo{DeviceName: name})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	claims, err := jwtManager.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	session, err := sessionRepo.GetByID(uuid.MustParse(claims.SessionID))
+	if err != nil {
+		t.Fatalf("access token sid %q has no session", claims.SessionID)
+	}
+	if want := strings.Repeat("a", 99) + "é"; session.DeviceName != want || !utf8.ValidString(session.DeviceName) {
+		t.Errorf("DeviceName = %q, want %q", session.DeviceName, want)
+	}
+}
+
+func TestAuthService_RevokeSession(t *testing.T) {
+	userRepo := newMockUserRepository()
+	sessionRepo := newMockSessionRepository()
+	notifier := &mockSessionNotifier{}
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(userRepo, sessionRepo, newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{SessionNotifier: notifier})
+
+	user, _, refreshToken, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	claims, _ := jwtManager.ValidateRefreshToken(refreshToken)
+	sessionID := uuid.MustParse(claims.SessionID)
+
+	if err := authService.RevokeSession(uuid.New(), sessionID); err != ErrSessionNotFound {
+		t.Errorf("RevokeSession() by other user error = %v, want ErrSessionNotFound", err)
+	}
+	if err := authService.RevokeSession(user.UserID, sessionID); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	if len(notifier.revoked) != 1 || notifier.revoked[0] != sessionID {
+		t.Errorf("notifier revoked = %v, want [%v]", notifier.revoked, sessionID)
+	}
+	if err := authService.RevokeSession(user.UserID, sessionID); err != ErrSessionNotFound {
+		t.Errorf("RevokeSession() twice error = %v, want ErrSessionNotFound", err)
+	}
+
+	// The revoked session's refresh token must no longer work.
+	if _, _, _, err := authService.RefreshToken(refreshToken, ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("RefreshToken() after revoke error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthService_ChangePassword_RevokesTokens(t *testing.T) {
+	userRepo := newMockUserRepository()
+	sessionRepo := newMockSessionRepository()
+	revocations := store.NewMemoryRevocationStore()
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(userRepo, sessionRepo, newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{Revocations: revocations})
+
+	user, oldAccess, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	if _, _, err := authService.ChangePassword(user.UserID, "wrongpassword", "newpassword", ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("ChangePassword() with wrong password error = %v, want ErrInvalidCredentials", err)
+	}
+	newAccess, _, err := authService.ChangePassword(user.UserID, "password123", "newpassword", ClientInfo{})
+	if err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+
+	isRevoked := func(token string) bool {
+		claims, err := jwtManager.ValidateAccessToken(token)
+		if err != nil {
+			t.Fatalf("ValidateAccessToken() error = %v", err)
+		}
+		revoked, _ := revocations.IsRevoked(context.Background(), user.UserID, uuid.MustParse(claims.SessionID), claims.ID, claims.IssuedAt.Time)
+		return revoked
+	}
+	if !isRevoked(oldAccess) {
+		t.Error("access token issued before password change should be revoked")
+	}
+	if isRevoked(newAccess) {
+		t.Error("access token issued by ChangePassword should not be revoked")
+	}
+	if _, _, _, err := authService.Login("testuser", "password123", ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("Login() with old password error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthService_RefreshToken_LegacyTokenRevokedByPasswordChange(t *testing.T) {
+	userRepo := newMockUserRepository()
+	revocations := store.NewMemoryRevocationStore()
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{Revocations: revocations})
+
+	user, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	// A refresh token from before sessions existed carries no sid.
+	legacy, err := jwtManager.GenerateRefreshToken(user.UserID.String(), "")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken() error = %v", err)
+	}
+	if _, _, err := authService.ChangePassword(user.UserID, "password123", "newpassword", ClientInfo{}); err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+	if _, _, _, err := authService.RefreshToken(legacy, ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("RefreshToken() with legacy token after password change error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthService_VerifyEmail(t *testing.T) {
+	userRepo := newMockUserRepository()
+	mail := mailer.NewMemoryMailer()
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{Mailer: mail, PublicURL: "https://chat.example.org/"})
+
+	user, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	msg, _ := mail.Last("test@example.com")
+	if !strings.Contains(msg.Body, "https://chat.example.org/verify-email?token=") {
+		t.Errorf("verification email body = %q, want link to client", msg.Body)
+	}
+	first := tokenFromMail(t, mail, "test@example.com")
+
+	// Requesting a new email invalidates the earlier link.
+	if err := authService.RequestEmailVerification(user.UserID); err != nil {
+		t.Fatalf("RequestEmailVerification() error = %v", err)
+	}
+	token := tokenFromMail(t, mail, "test@example.com")
+	if _, err := authService.VerifyEmail(first); err != ErrInvalidToken {
+		t.Errorf("VerifyEmail(superseded) error = %v, want ErrInvalidToken", err)
+	}
+
+	verified, err := authService.VerifyEmail(token)
+	if err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	if verified.EmailVerifiedAt == nil {
+		t.Error("VerifyEmail() did not set EmailVerifiedAt")
+	}
+	if _, err := authService.VerifyEmail(token); err != ErrInvalidToken {
+		t.Errorf("VerifyEmail(reused) error = %v, want ErrInvalidToken", err)
+	}
+	if err := authService.RequestEmailVerification(user.UserID); err != ErrEmailAlreadyVerified {
+		t.Errorf("RequestEmailVerification() after verify error = %v, want ErrEmailAlreadyVerified", err)
+	}
+}
+
+func TestAuthService_RequireVerifiedEmail(t *testing.T) {
+	userRepo := newMockUserRepository()
+	mail := mailer.NewMemoryMailer()
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{Mailer: mail, RequireVerifiedEmail: true})
+
+	_, accessToken, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if accessToken != "" {
+		t.Error("Register() issued tokens before email verification")
+	}
+	if _, _, _, err := authService.Login("testuser", "password123", ClientInfo{}); err != ErrEmailNotVerified {
+		t.Errorf("Login() error = %v, want ErrEmailNotVerified", err)
+	}
+	if _, _, _, err := authService.Login("testuser", "wrongpassword", ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("Login() with wrong password error = %v, want ErrInvalidCredentials", err)
+	}
+
+	if _, err := authService.VerifyEmail(tokenFromMail(t, mail, "test@example.com")); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	if _, _, _, err := authService.Login("testuser", "password123", ClientInfo{}); err != nil {
+		t.Errorf("Login() after verification error = %v", err)
+	}
+}
+
+func TestAuthService_PasswordReset(t *testing.T) {
+	userRepo := newMockUserRepository()
+	sessionRepo := newMockSessionRepository()
+	mail := mailer.NewMemoryMailer()
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(userRepo, sessionRepo, newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{Mailer: mail})
+
+	user, _, refreshToken, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	// Unknown addresses are accepted silently and get no email.
+	sent := len(mail.Messages())
+	if err := authService.RequestPasswordReset("nobody@example.com"); err != nil {
+		t.Errorf("RequestPasswordReset(unknown) error = %v, want nil", err)
+	}
+	waitMail(authService)
+	if len(mail.Messages()) != sent {
+		t.Error("RequestPasswordReset(unknown) sent an email")
+	}
+
+	if err := authService.RequestPasswordReset("test@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	waitMail(authService)
+	token := tokenFromMail(t, mail, "test@example.com")
+
+	if err := authService.ResetPassword(token, "short"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("ResetPassword(short) error = %v, want ErrInvalidInput", err)
+	}
+	if err := authService.ResetPassword(token, "newpassword"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if err := authService.ResetPassword(token, "otherpassword"); err != ErrInvalidToken {
+		t.Errorf("ResetPassword(reused) error = %v, want ErrInvalidToken", err)
+	}
+	if _, _, _, err := authService.Login("testuser", "newpassword", ClientInfo{}); err != nil {
+		t.Errorf("Login() with new password error = %v", err)
+	}
+	if _, _, _, err := authService.RefreshToken(refreshToken, ClientInfo{}); err != ErrInvalidCredentials {
+		t.Errorf("RefreshToken() after reset error = %v, want ErrInvalidCredentials", err)
+	}
+	if u, _ := userRepo.GetByID(user.UserID); u.EmailVerifiedAt == nil {
+		t.Error("ResetPassword() should mark the email verified")
+	}
+}
+
+func TestAuthService_PasswordReset_Expired(t *testing.T) {
+	userRepo := newMockUserRepository()
+	mail := mailer.NewMemoryMailer()
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{Mailer: mail, PasswordResetTTL: time.Millisecond})
+
+	if _, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := authService.RequestPasswordReset("test@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	waitMail(authService)
+	token := tokenFromMail(t, mail, "test@example.com")
+	time.Sleep(5 * time.Millisecond)
+	if err := authService.ResetPassword(token, "newpassword"); err != ErrInvalidToken {
+		t.Errorf("ResetPassword(expired) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestAuthService_NoMailer(t *testing.T) {
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(newMockUserRepository(), newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
+
+	if err := authService.RequestPasswordReset("test@example.com"); err != ErrMailerUnavailable {
+		t.Errorf("RequestPasswordReset() error = %v, want ErrMailerUnavailable", err)
+	}
+}
+
 func TestNewAuthService(t *testing.T) {
 	userRepo := newMockUserRepository()
 	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
 
-	authService := NewAuthService(userRepo, jwtManager)
+	authService := NewAuthService(userRepo, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
 	if authService == nil {
 		t.Fatal("NewAuthService() returned nil")
 	}
@@ -0,0 +1,327 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: bot_service.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Bot accounts owned by users and the scoped API keys bots authenticate with
+
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	pwd "github.com/convexwf/uim-go/internal/pkg/password"
+	"github.com/convexwf/uim-go/internal/repository"
+)
+
+var (
+	ErrBotNotFound     = errors.New("bot not found")
+	ErrBotLimitReached = errors.New("bot limit reached")
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	ErrInvalidAPIKey   = errors.New("invalid api key")
+	ErrInvalidScope    = errors.New("invalid scope")
+	ErrAPIKeyLimit     = errors.New("api key limit reached")
+)
+
+const (
+	// APIKeyPrefix starts every bot API key: uimb_<8 hex prefix>_<secret>.
+	APIKeyPrefix = "uimb_"
+
+	maxBotsPerOwner      = 20
+	maxActiveKeysPerBot  = 10
+	maxAPIKeyNameLength  = 100
+	apiKeySecretBytes    = 32
+	apiKeyTouchInterval  = time.Minute
+	botEmailDomain       = "bot.invalid"
+	botDisplayNameLength = 100
+)
+
+// BotPrincipal is the identity of a request authenticated with a bot API key.
+type BotPrincipal struct {
+	BotID  uuid.UUID
+	KeyID  uuid.UUID
+	Scopes []string
+}
+
+// HasScope reports whether the key grants scope.
+func (p *BotPrincipal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// BotService manages bot users and their API keys. Every management call is made by the bot's
+// owner; bots of other users are reported as ErrBotNotFound.
+type BotService interface {
+	CreateBot(ownerID uuid.UUID, username, displayName string) (*model.User, error)
+	ListBots(ownerID uuid.UUID) ([]*model.User, error)
+	// DeleteBot deletes the bot and revokes all of its keys.
+	DeleteBot(ownerID, botID uuid.UUID) error
+	// CreateAPIKey issues a key with the given scopes. ttl 0 means the key does not expire.
+	// The plaintext key is returned only here; just its hash is stored.
+	CreateAPIKey(ownerID, botID uuid.UUID, name string, scopes []string, ttl time.Duration) (*model.BotAPIKey, string, error)
+	ListAPIKeys(ownerID, botID uuid.UUID) ([]*model.BotAPIKey, error)
+	RevokeAPIKey(ownerID, botID, keyID uuid.UUID) error
+	// AuthenticateAPIKey resolves a plaintext key. Unknown, revoked and expired keys, deleted
+	// bots and bots whose owner was deleted all yield ErrInvalidAPIKey.
+	AuthenticateAPIKey(key string) (*BotPrincipal, error)
+}
+
+type botService struct {
+	botRepo  repository.BotRepository
+	userRepo repository.UserRepository
+	now      func() time.Time
+}
+
+// NewBotService creates a new bot service.
+func NewBotService(botRepo repository.BotRepository, userRepo repository.UserRepository) BotService {
+	return &botService{botRepo: botRepo, userRepo: userRepo, now: time.Now}
+}
+
+// CreateBot creates a bot user owned by ownerID. Bots get a placeholder email and an unusable
+// password, so they can only authenticate with API keys.
+func (s *botService) CreateBot(ownerID uuid.UUID, username, displayName string) (*model.User, error) {
+	username = strings.TrimSpace(username)
+	if len(username) < 3 || len(username) > 50 || sanitizeUsername(username) != username {
+		return nil, fmt.Errorf("%w: username must be 3-50 letters, digits, '.', '_' or '-'", ErrInvalidInput)
+	}
+	owner, err := s.userRepo.GetByID(ownerID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if owner.IsBot {
+		return nil, fmt.Errorf("%w: bots cannot own bots", ErrInvalidInput)
+	}
+	bots, err := s.botRepo.ListByOwner(ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bots: %w", err)
+	}
+	if len(bots) >= maxBotsPerOwner {
+		return nil, ErrBotLimitReached
+	}
+	if _, err := s.userRepo.GetByUsername(username); err == nil {
+		return nil, ErrUserExists
+	}
+	passwordHash, err := pwd.Hash(randomHex(32))
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	displayName = strings.TrimSpace(displayName)
+	if displayName == "" {
+		displayName = username
+	}
+	bot := &model.User{
+		Username:     username,
+		Email:        "bot-" + randomHex(8) + "@" + botEmailDomain,
+		PasswordHash: passwordHash,
+		DisplayName:  truncateRunes(displayName, botDisplayNameLength),
+		IsBot:        true,
+		BotOwnerID:   &ownerID,
+	}
+	if err := s.userRepo.Create(bot); err != nil {
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+	log.Printf("[AUTH] bot created owner_id=%s bot_id=%s username=%s", ownerID, bot.UserID, bot.Username)
+	return bot, nil
+}
+
+// ListBots returns the owner's bots.
+func (s *botService) ListBots(ownerID uuid.UUID) ([]*model.User, error) {
+	return s.botRepo.ListByOwner(ownerID)
+}
+
+// DeleteBot deletes one of the owner's bots.
+func (s *botService) DeleteBot(ownerID, botID uuid.UUID) error {
+	if _, err := s.ownedBot(ownerID, botID); err != nil {
+		return err
+	}
+	if err := s.botRepo.Delete(botID, s.now()); err != nil {
+		return fmt.Errorf("failed to delete bot: %w", err)
+	}
+	log.Printf("[AUTH] bot deleted owner_id=%s bot_id=%s", ownerID, botID)
+	return nil
+}
+
+// CreateAPIKey issues a new key for one of the owner's bots.
+func (s *botService) CreateAPIKey(ownerID, botID uuid.UUID, name string, scopes []string, ttl time.Duration) (*model.BotAPIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxAPIKeyNameLength {
+		return nil, "", fmt.Errorf("%w: key name must be 1-%d characters", ErrInvalidInput, maxAPIKeyNameLength)
+	}
+	if ttl < 0 {
+		return nil, "", fmt.Errorf("%w: expiry must not be negative", ErrInvalidInput)
+	}
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := s.ownedBot(ownerID, botID); err != nil {
+		return nil, "", err
+	}
+	keys, err := s.botRepo.ListKeys(botID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list keys: %w", err)
+	}
+	now := s.now()
+	active := 0
+	for _, k := range keys {
+		if k.Active(now) {
+			active++
+		}
+	}
+	if active >= maxActiveKeysPerBot {
+		return nil, "", ErrAPIKeyLimit
+	}
+
+	plaintext, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &model.BotAPIKey{
+		BotID:     botID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKey(plaintext),
+		Scopes:    strings.Join(normalized, ","),
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+	if err := s.botRepo.CreateKey(key); err != nil {
+		return nil, "", fmt.Errorf("failed to store key: %w", err)
+	}
+	log.Printf("[AUTH] bot api key created bot_id=%s key_id=%s prefix=%s scopes=%s", botID, key.KeyID, prefix, key.Scopes)
+	return key, plaintext, nil
+}
+
+// ListAPIKeys returns every key of one of the owner's bots, revoked ones included.
+func (s *botService) ListAPIKeys(ownerID, botID uuid.UUID) ([]*model.BotAPIKey, error) {
+	if _, err := s.ownedBot(ownerID, botID); err != nil {
+		return nil, err
+	}
+	return s.botRepo.ListKeys(botID)
+}
+
+// RevokeAPIKey revokes one key; requests using it fail from then on.
+func (s *botService) RevokeAPIKey(ownerID, botID, keyID uuid.UUID) error {
+	if _, err := s.ownedBot(ownerID, botID); err != nil {
+		return err
+	}
+	ok, err := s.botRepo.RevokeKey(botID, keyID, s.now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke key: %w", err)
+	}
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	log.Printf("[AUTH] bot api key revoked bot_id=%s key_id=%s", botID, keyID)
+	return nil
+}
+
+// AuthenticateAPIKey looks the key up by hash and checks the key, bot and owner are still valid.
+func (s *botService) AuthenticateAPIKey(plaintext string) (*BotPrincipal, error) {
+	if !strings.HasPrefix(plaintext, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.botRepo.GetKeyByHash(hashAPIKey(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key: %w", err)
+	}
+	now := s.now()
+	if key == nil || !key.Active(now) {
+		return nil, ErrInvalidAPIKey
+	}
+	bot, err := s.userRepo.GetByID(key.BotID)
+	if err != nil || !bot.IsBot || bot.BotOwnerID == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if _, err := s.userRepo.GetByID(*bot.BotOwnerID); err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	// last_used_at is informational; refresh it at most once per interval to avoid a write per request.
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.botRepo.TouchKey(key.KeyID, now); err != nil {
+			log.Printf("[AUTH] bot api key touch failed key_id=%s err=%v", key.KeyID, err)
+		}
+	}
+	return &BotPrincipal{BotID: bot.UserID, KeyID: key.KeyID, Scopes: key.ScopeList()}, nil
+}
+
+// ownedBot returns the bot if it exists and belongs to ownerID.
+func (s *botService) ownedBot(ownerID, botID uuid.UUID) (*model.User, error) {
+	bot, err := s.userRepo.GetByID(botID)
+	if err != nil || !bot.IsBot || bot.BotOwnerID == nil || *bot.BotOwnerID != ownerID {
+		return nil, ErrBotNotFound
+	}
+	return bot, nil
+}
+
+// normalizeScopes validates scopes against model.BotScopes and drops duplicates.
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	seen := make(map[string]bool, len(scopes))
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !isBotScope(scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			out = append(out, scope)
+		}
+	}
+	return out, nil
+}
+
+func isBotScope(scope string) bool {
+	for _, s := range model.BotScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// generateAPIKey returns a new plaintext key and its public prefix ("uimb_" + 8 hex chars).
+func generateAPIKey() (string, string, error) {
+	id := make([]byte, 4)
+	secret := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	prefix := APIKeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// hashAPIKey returns the hex SHA-256 of a plaintext key, as stored in bot_api_keys.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,292 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: bot_service_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for bot accounts and API keys
+
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/pkg/jwt"
+	pwd "github.com/convexwf/uim-go/internal/pkg/password"
+)
+
+// botUserRepository assigns user IDs on Create, like the database does.
+type botUserRepository struct {
+	*mockUserRepository
+}
+
+func (r botUserRepository) Create(user *model.User) error {
+	if user.UserID == uuid.Nil {
+		user.UserID = uuid.New()
+	}
+	return r.mockUserRepository.Create(user)
+}
+
+// mockBotRepository is an in-memory BotRepository over a mock user repository.
+type mockBotRepository struct {
+	users *mockUserRepository
+	keys  map[uuid.UUID]*model.BotAPIKey
+}
+
+func newMockBotRepository(users *mockUserRepository) *mockBotRepository {
+	return &mockBotRepository{users: users, keys: make(map[uuid.UUID]*model.BotAPIKey)}
+}
+
+func (m *mockBotRepository) ListByOwner(ownerID uuid.UUID) ([]*model.User, error) {
+	var out []*model.User
+	for _, u := range m.users.users {
+		if u.IsBot && u.BotOwnerID != nil && *u.BotOwnerID == ownerID {
+			out = append(out, u)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockBotRepository) Delete(botID uuid.UUID, at time.Time) error {
+	for _, k := range m.keys {
+		if k.BotID == botID && k.RevokedAt == nil {
+			k.RevokedAt = &at
+		}
+	}
+	return m.users.Delete(botID)
+}
+
+func (m *mockBotRepository) CreateKey(key *model.BotAPIKey) error {
+	key.KeyID = uuid.New()
+	m.keys[key.KeyID] = key
+	return nil
+}
+
+func (m *mockBotRepository) GetKeyByHash(keyHash string) (*model.BotAPIKey, error) {
+	for _, k := range m.keys {
+		if k.KeyHash == keyHash {
+			return k, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockBotRepository) ListKeys(botID uuid.UUID) ([]*model.BotAPIKey, error) {
+	var out []*model.BotAPIKey
+	for _, k := range m.keys {
+		if k.BotID == botID {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockBotRepository) RevokeKey(botID, keyID uuid.UUID, at time.Time) (bool, error) {
+	k, ok := m.keys[keyID]
+	if !ok || k.BotID != botID || k.RevokedAt != nil {
+		return false, nil
+	}
+	k.RevokedAt = &at
+	return true, nil
+}
+
+func (m *mockBotRepository) TouchKey(keyID uuid.UUID, at time.Time) error {
+	if k, ok := m.keys[keyID]; ok {
+		k.LastUsedAt = &at
+	}
+	return nil
+}
+
+type botFixture struct {
+	svc   *botService
+	users *mockUserRepository
+	repo  *mockBotRepository
+	owner *model.User
+}
+
+func newBotFixture(t *testing.T) *botFixture {
+	t.Helper()
+	users := newMockUserRepository()
+	repo := newMockBotRepository(users)
+	owner := &model.User{UserID: uuid.New(), Username: "owner", Email: "owner@example.com"}
+	if err := users.Create(owner); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewBotService(repo, botUserRepository{users}).(*botService)
+	return &botFixture{svc: svc, users: users, repo: repo, owner: owner}
+}
+
+func TestBotService_CreateBot(t *testing.T) {
+	f := newBotFixture(t)
+	bot, err := f.svc.CreateBot(f.owner.UserID, "ci-bot", "")
+	if err != nil {
+		t.Fatalf("CreateBot: %v", err)
+	}
+	if !bot.IsBot || bot.BotOwnerID == nil || *bot.BotOwnerID != f.owner.UserID {
+		t.Fatalf("bot not owned by owner: %+v", bot)
+	}
+	if bot.DisplayName != "ci-bot" || !strings.HasSuffix(bot.Email, "@"+botEmailDomain) {
+		t.Fatalf("unexpected bot fields: display=%q email=%q", bot.DisplayName, bot.Email)
+	}
+
+	if _, err := f.svc.CreateBot(f.owner.UserID, "ci-bot", ""); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("duplicate username: want ErrUserExists, got %v", err)
+	}
+	if _, err := f.svc.CreateBot(f.owner.UserID, "bad name!", ""); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("invalid username: want ErrInvalidInput, got %v", err)
+	}
+	if _, err := f.svc.CreateBot(bot.UserID, "nested-bot", ""); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("bot owning bot: want ErrInvalidInput, got %v", err)
+	}
+	bots, _ := f.svc.ListBots(f.owner.UserID)
+	if len(bots) != 1 {
+		t.Fatalf("ListBots: want 1, got %d", len(bots))
+	}
+}
+
+func TestBotService_CreateBot_Limit(t *testing.T) {
+	f := newBotFixture(t)
+	for i := 0; i < maxBotsPerOwner; i++ {
+		if _, err := f.svc.CreateBot(f.owner.UserID, "bot-"+randomHex(4), ""); err != nil {
+			t.Fatalf("CreateBot %d: %v", i, err)
+		}
+	}
+	if _, err := f.svc.CreateBot(f.owner.UserID, "one-too-many", ""); !errors.Is(err, ErrBotLimitReached) {
+		t.Fatalf("want ErrBotLimitReached, got %v", err)
+	}
+}
+
+func TestBotService_APIKeyLifecycle(t *testing.T) {
+	f := newBotFixture(t)
+	bot, _ := f.svc.CreateBot(f.owner.UserID, "ci-bot", "CI")
+
+	key, plaintext, err := f.svc.CreateAPIKey(f.owner.UserID, bot.UserID, "ci", []string{model.BotScopeMessagesWrite, model.BotScopeWS, model.BotScopeWS}, 0)
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, key.Prefix+"_") || !strings.HasPrefix(key.Prefix, APIKeyPrefix) {
+		t.Fatalf("key %q does not start with prefix %q", plaintext, key.Prefix)
+	}
+	if key.KeyHash == plaintext || strings.Contains(key.KeyHash, plaintext) {
+		t.Fatal("plaintext key must not be stored")
+	}
+	if key.Scopes != "messages:write,ws" {
+		t.Fatalf("scopes not normalized: %q", key.Scopes)
+	}
+
+	principal, err := f.svc.AuthenticateAPIKey(plaintext)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey: %v", err)
+	}
+	if principal.BotID != bot.UserID || !principal.HasScope(model.BotScopeWS) || principal.HasScope(model.BotScopeMessagesRead) {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+	if key.LastUsedAt == nil {
+		t.Fatal("last_used_at not recorded")
+	}
+	if _, err := f.svc.AuthenticateAPIKey(plaintext + "x"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("tampered key: want ErrInvalidAPIKey, got %v", err)
+	}
+
+	if err := f.svc.RevokeAPIKey(f.owner.UserID, bot.UserID, key.KeyID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if _, err := f.svc.AuthenticateAPIKey(plaintext); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("revoked key: want ErrInvalidAPIKey, got %v", err)
+	}
+	if err := f.svc.RevokeAPIKey(f.owner.UserID, bot.UserID, key.KeyID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Fatalf("revoke twice: want ErrAPIKeyNotFound, got %v", err)
+	}
+}
+
+func TestBotService_CreateAPIKey_Validation(t *testing.T) {
+	f := newBotFixture(t)
+	bot, _ := f.svc.CreateBot(f.owner.UserID, "ci-bot", "")
+
+	if _, _, err := f.svc.CreateAPIKey(f.owner.UserID, bot.UserID, "k", nil, 0); !errors.Is(err, ErrInvalidScope) {
+		t.Fatalf("no scopes: want ErrInvalidScope, got %v", err)
+	}
+	if _, _, err := f.svc.CreateAPIKey(f.owner.UserID, bot.UserID, "k", []string{"admin"}, 0); !errors.Is(err, ErrInvalidScope) {
+		t.Fatalf("unknown scope: want ErrInvalidScope, got %v", err)
+	}
+	if _, _, err := f.svc.CreateAPIKey(f.owner.UserID, bot.UserID, "", []string{model.BotScopeWS}, 0); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("empty name: want ErrInvalidInput, got %v", err)
+	}
+	other := &model.User{UserID: uuid.New(), Username: "other", Email: "other@example.com"}
+	_ = f.users.Create(other)
+	if _, _, err := f.svc.CreateAPIKey(other.UserID, bot.UserID, "k", []string{model.BotScopeWS}, 0); !errors.Is(err, ErrBotNotFound) {
+		t.Fatalf("foreign bot: want ErrBotNotFound, got %v", err)
+	}
+	if _, err := f.svc.ListAPIKeys(other.UserID, bot.UserID); !errors.Is(err, ErrBotNotFound) {
+		t.Fatalf("foreign bot keys: want ErrBotNotFound, got %v", err)
+	}
+	for i := 0; i < maxActiveKeysPerBot; i++ {
+		if _, _, err := f.svc.CreateAPIKey(f.owner.UserID, bot.UserID, "k", []string{model.BotScopeWS}, 0); err != nil {
+			t.Fatalf("CreateAPIKey %d: %v", i, err)
+		}
+	}
+	if _, _, err := f.svc.CreateAPIKey(f.owner.UserID, bot.UserID, "k", []string{model.BotScopeWS}, 0); !errors.Is(err, ErrAPIKeyLimit) {
+		t.Fatalf("want ErrAPIKeyLimit, got %v", err)
+	}
+}
+
+func TestBotService_AuthenticateAPIKey_Expired(t *testing.T) {
+	f := newBotFixture(t)
+	bot, _ := f.svc.CreateBot(f.owner.UserID, "ci-bot", "")
+	_, plaintext, err := f.svc.CreateAPIKey(f.owner.UserID, bot.UserID, "short", []string{model.BotScopeWS}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.AuthenticateAPIKey(plaintext); err != nil {
+		t.Fatalf("fresh key: %v", err)
+	}
+	f.svc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := f.svc.AuthenticateAPIKey(plaintext); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expired key: want ErrInvalidAPIKey, got %v", err)
+	}
+}
+
+func TestBotService_DeletedBotOrOwner(t *testing.T) {
+	f := newBotFixture(t)
+	bot, _ := f.svc.CreateBot(f.owner.UserID, "ci-bot", "")
+	_, plaintext, _ := f.svc.CreateAPIKey(f.owner.UserID, bot.UserID, "k", []string{model.BotScopeWS}, 0)
+
+	// Owner account deleted: the bot's keys stop working.
+	_ = f.users.Delete(f.owner.UserID)
+	if _, err := f.svc.AuthenticateAPIKey(plaintext); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("owner deleted: want ErrInvalidAPIKey, got %v", err)
+	}
+	_ = f.users.Create(f.owner)
+
+	if err := f.svc.DeleteBot(f.owner.UserID, bot.UserID); err != nil {
+		t.Fatalf("DeleteBot: %v", err)
+	}
+	if _, err := f.svc.AuthenticateAPIKey(plaintext); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("bot deleted: want ErrInvalidAPIKey, got %v", err)
+	}
+	if err := f.svc.DeleteBot(f.owner.UserID, bot.UserID); !errors.Is(err, ErrBotNotFound) {
+		t.Fatalf("delete twice: want ErrBotNotFound, got %v", err)
+	}
+}
+
+func TestAuthService_Login_RejectsBot(t *testing.T) {
+	f := newBotFixture(t)
+	bot, _ := f.svc.CreateBot(f.owner.UserID, "ci-bot", "")
+	// Even with a known password a bot cannot log in.
+	bot.PasswordHash, _ = pwd.Hash("password123")
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	svc := NewAuthService(botUserRepository{f.users}, newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{})
+	if _, _, _, err := svc.Login("ci-bot", "password123", ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("want ErrInvalidCredentials, got %v", err)
+	}
+}
@@ -0,0 +1,494 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: command_service.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Slash command dispatch: built-in commands, bot commands and ephemeral replies
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/repository"
+)
+
+var (
+	ErrCommandNotFound = errors.New("command not found")
+	ErrCommandExists   = errors.New("command already registered by another bot")
+)
+
+const (
+	maxCommandsPerConversation  = 100
+	maxCommandDescriptionLength = 200
+	defaultMuteDuration         = 8 * time.Hour
+	maxMuteDuration             = 365 * 24 * time.Hour
+)
+
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// CommandNotifier delivers what commands produce outside the message stream (e.g. the WebSocket hub).
+type CommandNotifier interface {
+	// NotifyEphemeral sends msg to the user's open connections only; nothing is stored.
+	NotifyEphemeral(userID uuid.UUID, msg *model.EphemeralMessage)
+	// DeliverCommand sends the invocation to the bot's open connections and reports whether
+	// any received it.
+	DeliverCommand(botID uuid.UUID, inv *model.CommandInvocation) bool
+}
+
+// CommandResult is the outcome of a command. Reply is the ephemeral reply already sent to the
+// invoker (nil if none, e.g. when a bot will answer). Message is set if the command posted a
+// message (/me, or "//text" escaping a leading slash).
+type CommandResult struct {
+	Command string
+	Reply   *model.EphemeralMessage
+	Message *model.Message
+}
+
+// CommandInfo describes a command available in a conversation. BotID is nil for built-ins.
+type CommandInfo struct {
+	Name        string     `json:"name"`
+	Usage       string     `json:"usage"`
+	Description string     `json:"description"`
+	BotID       *uuid.UUID `json:"bot_id,omitempty"`
+}
+
+// CommandService routes messages starting with "/" to built-in or bot commands. Built-ins are
+// /me, /mute, /invite, /topic and /help; bots register further commands per conversation and
+// receive invocations over their WebSocket connection.
+type CommandService interface {
+	// Execute runs content as a command. It returns nil, nil if content is not a command, so the
+	// caller sends it as a normal message. Start a message with "//" to send a leading "/".
+	Execute(conversationID, userID uuid.UUID, content string) (*CommandResult, error)
+	ListCommands(conversationID, userID uuid.UUID) ([]CommandInfo, error)
+	// RegisterBotCommand adds or updates a command of the bot, which must be a participant.
+	RegisterBotCommand(botID, conversationID uuid.UUID, name, description string) (*model.BotCommand, error)
+	UnregisterBotCommand(botID, conversationID uuid.UUID, name string) error
+	// ReplyEphemeral lets a bot with a command in the conversation answer one participant privately.
+	ReplyEphemeral(botID, conversationID, userID uuid.UUID, content string) error
+}
+
+// builtinCommand runs with the conversation and invoker already checked. It returns the
+// ephemeral reply text ("" for none) and any message it posted.
+type builtinCommand struct {
+	usage       string
+	description string
+	run         func(s *commandService, conversationID, userID uuid.UUID, args string) (string, *model.Message, error)
+}
+
+// builtinCommands is filled in init because the handlers refer back to it (usage, /help).
+var builtinCommands map[string]builtinCommand
+
+func init() {
+	builtinCommands = map[string]builtinCommand{
+		"me":     {usage: "/me <action>", description: "Send an action, shown as \"* you <action>\"", run: (*commandService).runMe},
+		"mute":   {usage: "/mute [duration|off]", description: "Mute notifications of this conversation (default 8h, e.g. 30m, 2d)", run: (*commandService).runMute},
+		"invite": {usage: "/invite @user [@user...]", description: "Add users to this group (owners and admins)", run: (*commandService).runInvite},
+		"topic":  {usage: "/topic [text]", description: "Show or set the group topic (owners and admins set it)", run: (*commandService).runTopic},
+		"help":   {usage: "/help", description: "List the commands available here", run: (*commandService).runHelp},
+	}
+}
+
+type commandService struct {
+	cmdRepo  repository.BotCommandRepository
+	convRepo repository.ConversationRepository
+	userRepo repository.UserRepository
+	convSvc  ConversationService
+	msgSvc   MessageService
+	notifier CommandNotifier
+	now      func() time.Time
+}
+
+// NewCommandService creates a new command service. notifier may be nil (ephemeral replies are
+// only returned to the caller and bot commands report the bot as unavailable).
+func NewCommandService(cmdRepo repository.BotCommandRepository, convRepo repository.ConversationRepository, userRepo repository.UserRepository, convSvc ConversationService, msgSvc MessageService, notifier CommandNotifier) CommandService {
+	return &commandService{
+		cmdRepo:  cmdRepo,
+		convRepo: convRepo,
+		userRepo: userRepo,
+		convSvc:  convSvc,
+		msgSvc:   msgSvc,
+		notifier: notifier,
+		now:      time.Now,
+	}
+}
+
+// ParseCommand splits "/name args" into a lower-cased name and the trimmed arguments. ok is false
+// for content that is not a command, including "//" escapes.
+func ParseCommand(content string) (name, args string, ok bool) {
+	content = trimContent(content)
+	if !strings.HasPrefix(content, "/") || strings.HasPrefix(content, "//") {
+		return "", "", false
+	}
+	rest := content[1:]
+	if i := strings.IndexAny(rest, " \t\n\r"); i >= 0 {
+		name, args = rest[:i], trimContent(rest[i:])
+	} else {
+		name = rest
+	}
+	if name == "" {
+		return "", "", false
+	}
+	return strings.ToLower(name), args, true
+}
+
+// Execute dispatches to a built-in, then to a bot command, and otherwise replies that the
+// command is unknown.
+func (s *commandService) Execute(conversationID, userID uuid.UUID, content string) (*CommandResult, error) {
+	trimmed := trimContent(content)
+	if strings.HasPrefix(trimmed, "//") {
+		msg, err := s.msgSvc.Create(conversationID, userID, trimmed[1:], model.MessageTypeText, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &CommandResult{Message: msg}, nil
+	}
+	name, args, ok := ParseCommand(trimmed)
+	if !ok {
+		return nil, nil
+	}
+	if err := s.convSvc.EnsureUserInConversation(conversationID, userID); err != nil {
+		return nil, err
+	}
+	result := &CommandResult{Command: name}
+
+	if builtin, ok := builtinCommands[name]; ok {
+		reply, msg, err := builtin.run(s, conversationID, userID, args)
+		if err != nil {
+			reply, err = commandErrorReply(name, err)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.Message = msg
+		if reply != "" {
+			result.Reply = s.replyEphemeral(conversationID, userID, nil, name, reply)
+		}
+		return result, nil
+	}
+
+	cmd, err := s.cmdRepo.Get(conversationID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load command: %w", err)
+	}
+	if cmd == nil {
+		result.Reply = s.replyEphemeral(conversationID, userID, nil, name, fmt.Sprintf("Unknown command /%s. Type /help to list the commands available here.", name))
+		return result, nil
+	}
+	if !s.deliverToBot(cmd, userID, args) {
+		result.Reply = s.replyEphemeral(conversationID, userID, nil, name, fmt.Sprintf("The bot handling /%s is not available right now.", name))
+	}
+	return result, nil
+}
+
+// ListCommands returns the built-ins followed by the conversation's bot commands.
+func (s *commandService) ListCommands(conversationID, userID uuid.UUID) ([]CommandInfo, error) {
+	if err := s.convSvc.EnsureUserInConversation(conversationID, userID); err != nil {
+		return nil, err
+	}
+	return s.commandInfos(conversationID)
+}
+
+// RegisterBotCommand validates the name and stores the command.
+func (s *commandService) RegisterBotCommand(botID, conversationID uuid.UUID, name, description string) (*model.BotCommand, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !commandNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: command names are 1-32 lower-case letters, digits, '_' or '-'", ErrInvalidInput)
+	}
+	if _, ok := builtinCommands[name]; ok {
+		return nil, fmt.Errorf("%w: /%s is a built-in command", ErrCommandExists, name)
+	}
+	description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(description) > maxCommandDescriptionLength {
+		return nil, fmt.Errorf("%w: description must be at most %d characters", ErrInvalidInput, maxCommandDescriptionLength)
+	}
+	if err := s.requireBotParticipant(botID, conversationID); err != nil {
+		return nil, err
+	}
+	existing, err := s.cmdRepo.Get(conversationID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load command: %w", err)
+	}
+	if existing != nil && existing.BotID != botID {
+		return nil, ErrCommandExists
+	}
+	if existing == nil {
+		cmds, err := s.cmdRepo.ListByConversation(conversationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commands: %w", err)
+		}
+		if len(cmds) >= maxCommandsPerConversation {
+			return nil, fmt.Errorf("%w: at most %d bot commands per conversation", ErrInvalidInput, maxCommandsPerConversation)
+		}
+	}
+	cmd := &model.BotCommand{
+		ConversationID: conversationID,
+		Name:           name,
+		BotID:          botID,
+		Description:    description,
+		CreatedAt:      s.now(),
+	}
+	if existing != nil {
+		cmd.CreatedAt = existing.CreatedAt
+	}
+	if err := s.cmdRepo.Save(cmd); err != nil {
+		return nil, fmt.Errorf("failed to save command: %w", err)
+	}
+	log.Printf("[CONV] bot command registered bot_id=%s conversation_id=%s name=%s", botID, conversationID, name)
+	return cmd, nil
+}
+
+// UnregisterBotCommand removes one of the bot's commands.
+func (s *commandService) UnregisterBotCommand(botID, conversationID uuid.UUID, name string) error {
+	ok, err := s.cmdRepo.Delete(conversationID, botID, strings.ToLower(strings.TrimSpace(name)))
+	if err != nil {
+		return fmt.Errorf("failed to delete command: %w", err)
+	}
+	if !ok {
+		return ErrCommandNotFound
+	}
+	return nil
+}
+
+// ReplyEphemeral checks the bot serves commands in the conversation and the target is a participant.
+func (s *commandService) ReplyEphemeral(botID, conversationID, userID uuid.UUID, content string) error {
+	content = trimContent(content)
+	if content == "" || utf8.RuneCountInString(content) > MaxMessageContentLength {
+		return fmt.Errorf("%w: content must be 1-%d characters", ErrInvalidInput, MaxMessageContentLength)
+	}
+	if err := s.requireBotParticipant(botID, conversationID); err != nil {
+		return err
+	}
+	cmds, err := s.cmdRepo.ListByConversation(conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to list commands: %w", err)
+	}
+	serves := false
+	for _, c := range cmds {
+		if c.BotID == botID {
+			serves = true
+			break
+		}
+	}
+	if !serves {
+		return fmt.Errorf("%w: the bot has no commands in this conversation", ErrPermissionDenied)
+	}
+	if err := s.convSvc.EnsureUserInConversation(conversationID, userID); err != nil {
+		return err
+	}
+	s.replyEphemeral(conversationID, userID, &botID, "", content)
+	return nil
+}
+
+func (s *commandService) runMe(conversationID, userID uuid.UUID, args string) (string, *model.Message, error) {
+	if args == "" {
+		return "Usage: " + builtinCommands["me"].usage, nil, nil
+	}
+	msg, err := s.msgSvc.Create(conversationID, userID, args, model.MessageTypeEmote, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return "", msg, nil
+}
+
+func (s *commandService) runMute(conversationID, userID uuid.UUID, args string) (string, *model.Message, error) {
+	if strings.EqualFold(args, "off") {
+		if err := s.convSvc.SetMutedUntil(conversationID, userID, nil); err != nil {
+			return "", nil, err
+		}
+		return "Notifications for this conversation are back on.", nil, nil
+	}
+	d := defaultMuteDuration
+	if args != "" {
+		var err error
+		if d, err = parseMuteDuration(args); err != nil {
+			return "Usage: " + builtinCommands["mute"].usage, nil, nil
+		}
+	}
+	until := s.now().Add(d).UTC()
+	if err := s.convSvc.SetMutedUntil(conversationID, userID, &until); err != nil {
+		return "", nil, err
+	}
+	return "Notifications muted until " + until.Format("2006-01-02 15:04 MST") + ". Use /mute off to undo.", nil, nil
+}
+
+func (s *commandService) runInvite(conversationID, userID uuid.UUID, args string) (string, *model.Message, error) {
+	names := strings.Fields(args)
+	if len(names) == 0 {
+		return "Usage: " + builtinCommands["invite"].usage, nil, nil
+	}
+	ids := make([]uuid.UUID, 0, len(names))
+	usernames := make(map[uuid.UUID]string, len(names))
+	for _, n := range names {
+		n = strings.TrimPrefix(n, "@")
+		user, err := s.userRepo.GetByUsername(n)
+		if err != nil || user == nil {
+			return fmt.Sprintf("No user named @%s.", n), nil, nil
+		}
+		ids = append(ids, user.UserID)
+		usernames[user.UserID] = user.Username
+	}
+	added, err := s.convSvc.AddMembers(conversationID, userID, ids)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(added) == 0 {
+		return "Everyone you named is already in this group.", nil, nil
+	}
+	mentions := make([]string, len(added))
+	for i, id := range added {
+		mentions[i] = "@" + usernames[id]
+	}
+	return "Added " + strings.Join(mentions, ", ") + ".", nil, nil
+}
+
+func (s *commandService) runTopic(conversationID, userID uuid.UUID, args string) (string, *model.Message, error) {
+	if args == "" {
+		conv, err := s.convRepo.GetByID(conversationID)
+		if err != nil {
+			return "", nil, ErrConversationNotFound
+		}
+		if !conv.IsManaged() {
+			return "", nil, ErrGroupOnly
+		}
+		if conv.Topic == "" {
+			return "No topic is set.", nil, nil
+		}
+		return "Topic: " + conv.Topic, nil, nil
+	}
+	if strings.EqualFold(args, "clear") {
+		args = ""
+	}
+	if err := s.convSvc.SetTopic(conversationID, userID, args); err != nil {
+		return "", nil, err
+	}
+	if args == "" {
+		return "Topic cleared.", nil, nil
+	}
+	return "Topic set.", nil, nil
+}
+
+func (s *commandService) runHelp(conversationID, userID uuid.UUID, args string) (string, *model.Message, error) {
+	infos, err := s.commandInfos(conversationID)
+	if err != nil {
+		return "", nil, err
+	}
+	lines := make([]string, len(infos))
+	for i, info := range infos {
+		lines[i] = info.Usage + " - " + info.Description
+	}
+	return strings.Join(lines, "\n"), nil, nil
+}
+
+func (s *commandService) commandInfos(conversationID uuid.UUID) ([]CommandInfo, error) {
+	infos := make([]CommandInfo, 0, len(builtinCommands))
+	for name, b := range builtinCommands {
+		infos = append(infos, CommandInfo{Name: name, Usage: b.usage, Description: b.description})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	cmds, err := s.cmdRepo.ListByConversation(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commands: %w", err)
+	}
+	for _, c := range cmds {
+		botID := c.BotID
+		infos = append(infos, CommandInfo{Name: c.Name, Usage: "/" + c.Name, Description: c.Description, BotID: &botID})
+	}
+	return infos, nil
+}
+
+// deliverToBot forwards the invocation if the bot is still a participant and connected.
+func (s *commandService) deliverToBot(cmd *model.BotCommand, userID uuid.UUID, args string) bool {
+	if s.notifier == nil {
+		return false
+	}
+	p, err := s.convRepo.GetParticipant(cmd.ConversationID, cmd.BotID)
+	if err != nil || p == nil {
+		return false
+	}
+	return s.notifier.DeliverCommand(cmd.BotID, &model.CommandInvocation{
+		ConversationID: cmd.ConversationID,
+		UserID:         userID,
+		Name:           cmd.Name,
+		Args:           args,
+		InvokedAt:      s.now(),
+	})
+}
+
+func (s *commandService) replyEphemeral(conversationID, userID uuid.UUID, senderID *uuid.UUID, command, content string) *model.EphemeralMessage {
+	msg := &model.EphemeralMessage{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Command:        command,
+		Content:        content,
+		CreatedAt:      s.now(),
+	}
+	if s.notifier != nil {
+		s.notifier.NotifyEphemeral(userID, msg)
+	}
+	return msg
+}
+
+// requireBotParticipant checks botID is a bot taking part in the conversation.
+func (s *commandService) requireBotParticipant(botID, conversationID uuid.UUID) error {
+	bot, err := s.userRepo.GetByID(botID)
+	if err != nil || !bot.IsBot {
+		return fmt.Errorf("%w: only bots manage commands", ErrPermissionDenied)
+	}
+	return s.convSvc.EnsureUserInConversation(conversationID, botID)
+}
+
+// commandErrorReply turns errors the invoker can act on into reply text; other errors are
+// returned unchanged.
+func commandErrorReply(name string, err error) (string, error) {
+	switch {
+	case errors.Is(err, ErrGroupOnly):
+		return fmt.Sprintf("/%s only works in group conversations.", name), nil
+	case errors.Is(err, ErrPermissionDenied):
+		return fmt.Sprintf("Only group owners and admins can use /%s.", name), nil
+	case errors.Is(err, ErrUserNotFound):
+		return "One of the users does not exist.", nil
+	case errors.Is(err, ErrInvalidGroupSize), errors.Is(err, ErrInvalidInput),
+		errors.Is(err, ErrMentionAllNotAllowed), errors.Is(err, ErrMentionRateLimited),
+		errors.Is(err, ErrAdminOnlySend), errors.Is(err, ErrMemberSilenced), errors.Is(err, ErrSlowMode):
+		return err.Error(), nil
+	}
+	return "", err
+}
+
+// parseMuteDuration accepts Go durations (30m, 2h) and whole days (2d), up to a year.
+func parseMuteDuration(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 || d > maxMuteDuration {
+		return 0, fmt.Errorf("duration out of range")
+	}
+	return d, nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: command_service_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for slash command dispatch
+
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+type mockBotCommandRepository struct {
+	cmds map[string]*model.BotCommand
+}
+
+func newMockBotCommandRepository() *mockBotCommandRepository {
+	return &mockBotCommandRepository{cmds: make(map[string]*model.BotCommand)}
+}
+
+func (m *mockBotCommandRepository) Save(cmd *model.BotCommand) error {
+	m.cmds[cmd.ConversationID.String()+"/"+cmd.Name] = cmd
+	return nil
+}
+
+func (m *mockBotCommandRepository) Get(conversationID uuid.UUID, name string) (*model.BotCommand, error) {
+	return m.cmds[conversationID.String()+"/"+name], nil
+}
+
+func (m *mockBotCommandRepository) ListByConversation(conversationID uuid.UUID) ([]*model.BotCommand, error) {
+	var out []*model.BotCommand
+	for _, c := range m.cmds {
+		if c.ConversationID == conversationID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockBotCommandRepository) Delete(conversationID, botID uuid.UUID, name string) (bool, error) {
+	key := conversationID.String() + "/" + name
+	if c, ok := m.cmds[key]; !ok || c.BotID != botID {
+		return false, nil
+	}
+	delete(m.cmds, key)
+	return true, nil
+}
+
+type mockCommandNotifier struct {
+	botConnected bool
+	ephemerals   map[uuid.UUID][]*model.EphemeralMessage
+	invocations  []*model.CommandInvocation
+}
+
+func (m *mockCommandNotifier) NotifyEphemeral(userID uuid.UUID, msg *model.EphemeralMessage) {
+	if m.ephemerals == nil {
+		m.ephemerals = make(map[uuid.UUID][]*model.EphemeralMessage)
+	}
+	m.ephemerals[userID] = append(m.ephemerals[userID], msg)
+}
+
+func (m *mockCommandNotifier) DeliverCommand(botID uuid.UUID, inv *model.CommandInvocation) bool {
+	if !m.botConnected {
+		return false
+	}
+	m.invocations = append(m.invocations, inv)
+	return true
+}
+
+// recordingConvService records the conversation mutations commands make.
+type recordingConvService struct {
+	mockConvServiceForMessage
+	mutedUntil *time.Time
+	muteCalls  int
+	addErr     error
+}
+
+func (m *recordingConvService) SetMutedUntil(conversationID, userID uuid.UUID, until *time.Time) error {
+	m.muteCalls++
+	m.mutedUntil = until
+	return nil
+}
+
+func (m *recordingConvService) AddMembers(conversationID, operatorID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	return userIDs, m.addErr
+}
+
+type commandFixture struct {
+	svc      CommandService
+	cmdRepo  *mockBotCommandRepository
+	notifier *mockCommandNotifier
+	convSvc  *recordingConvService
+	msgs     *mockNotifier
+	conv     uuid.UUID
+	user     uuid.UUID
+	bot      uuid.UUID
+}
+
+func newCommandFixture() *commandFixture {
+	f := &commandFixture{
+		cmdRepo:  newMockBotCommandRepository(),
+		notifier: &mockCommandNotifier{},
+		convSvc:  &recordingConvService{},
+		msgs:     &mockNotifier{},
+		conv:     uuid.New(),
+		user:     uuid.New(),
+		bot:      uuid.New(),
+	}
+	convRepo := &mockConversationRepo{
+		getByIDConv: &model.Conversation{ConversationID: f.conv, Type: model.ConversationTypeGroup, Topic: "release planning"},
+		participant: &model.ConversationParticipant{ConversationID: f.conv, Role: model.ParticipantRoleMember},
+	}
+	userRepo := &mockUserRepo{getByIDUser: &model.User{UserID: f.bot, IsBot: true}}
+	msgSvc := NewMessageService(&mockMessageRepo{}, f.convSvc, f.msgs)
+	f.svc = NewCommandService(f.cmdRepo, convRepo, userRepo, f.convSvc, msgSvc, f.notifier)
+	return f
+}
+
+func (f *commandFixture) lastReply(t *testing.T) string {
+	t.Helper()
+	replies := f.notifier.ephemerals[f.user]
+	if len(replies) == 0 {
+		t.Fatal("no ephemeral reply sent")
+	}
+	return replies[len(replies)-1].Content
+}
+
+func TestParseCommand(t *testing.T) {
+	for _, tc := range []struct {
+		in, name, args string
+		ok             bool
+	}{
+		{"/me waves", "me", "waves", true},
+		{"  /MUTE   2h ", "mute", "2h", true},
+		{"/help", "help", "", true},
+		{"hello /me", "", "", false},
+		{"//not a command", "", "", false},
+		{"/", "", "", false},
+	} {
+		name, args, ok := ParseCommand(tc.in)
+		if name != tc.name || args != tc.args || ok != tc.ok {
+			t.Errorf("ParseCommand(%q) = %q, %q, %v; want %q, %q, %v", tc.in, name, args, ok, tc.name, tc.args, tc.ok)
+		}
+	}
+}
+
+func TestCommandService_NotACommand(t *testing.T) {
+	f := newCommandFixture()
+	res, err := f.svc.Execute(f.conv, f.user, "hello")
+	if res != nil || err != nil {
+		t.Fatalf("Execute(plain text) = %+v, %v; want nil, nil", res, err)
+	}
+}
+
+func TestCommandService_EscapedSlashIsSent(t *testing.T) {
+	f := newCommandFixture()
+	res, err := f.svc.Execute(f.conv, f.user, "//etc/hosts is fine")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if res.Message == nil || res.Message.Content != "/etc/hosts is fine" {
+		t.Fatalf("message = %+v, want content with one leading slash", res.Message)
+	}
+}
+
+func TestCommandService_Me(t *testing.T) {
+	f := newCommandFixture()
+	res, err := f.svc.Execute(f.conv, f.user, "/me waves")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if res.Message == nil || res.Message.MessageType != model.MessageTypeEmote || res.Message.Content != "waves" {
+		t.Fatalf("message = %+v, want emote \"waves\"", res.Message)
+	}
+	if !f.msgs.called {
+		t.Error("emote was not fanned out")
+	}
+	if res.Reply != nil {
+		t.Errorf("unexpected reply %q", res.Reply.Content)
+	}
+}
+
+func TestCommandService_Mute(t *testing.T) {
+	f := newCommandFixture()
+	before := time.Now()
+	if _, err := f.svc.Execute(f.conv, f.user, "/mute 2d"); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if f.convSvc.mutedUntil == nil || f.convSvc.mutedUntil.Before(before.Add(47*time.Hour)) {
+		t.Fatalf("mutedUntil = %v, want about two days from now", f.convSvc.mutedUntil)
+	}
+	if !strings.Contains(f.lastReply(t), "muted until") {
+		t.Errorf("reply = %q", f.lastReply(t))
+	}
+	if _, err := f.svc.Execute(f.conv, f.user, "/mute off"); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if f.convSvc.mutedUntil != nil {
+		t.Errorf("mutedUntil = %v after /mute off, want nil", f.convSvc.mutedUntil)
+	}
+	if _, err := f.svc.Execute(f.conv, f.user, "/mute forever"); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if f.convSvc.muteCalls != 2 || !strings.HasPrefix(f.lastReply(t), "Usage:") {
+		t.Errorf("invalid duration: calls = %d, reply = %q", f.convSvc.muteCalls, f.lastReply(t))
+	}
+}
+
+func TestCommandService_InvitePermissionDeniedIsReplied(t *testing.T) {
+	f := newCommandFixture()
+	f.convSvc.addErr = ErrPermissionDenied
+	// mockUserRepo resolves no usernames, so the invite stops at the lookup.
+	res, err := f.svc.Execute(f.conv, f.user, "/invite @nobody")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if res.Reply == nil || !strings.Contains(res.Reply.Content, "@nobody") {
+		t.Errorf("reply = %+v, want unknown user reply", res.Reply)
+	}
+	if reply, err := commandErrorReply("invite", ErrPermissionDenied); err != nil || !strings.Contains(reply, "owners and admins") {
+		t.Errorf("commandErrorReply = %q, %v", reply, err)
+	}
+	if _, err := commandErrorReply("invite", errors.New("db down")); err == nil {
+		t.Error("internal errors must not become replies")
+	}
+}
+
+func TestCommandService_TopicShowsCurrent(t *testing.T) {
+	f := newCommandFixture()
+	if _, err := f.svc.Execute(f.conv, f.user, "/topic"); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := f.lastReply(t); got != "Topic: release planning" {
+		t.Errorf("reply = %q", got)
+	}
+}
+
+func TestCommandService_UnknownCommand(t *testing.T) {
+	f := newCommandFixture()
+	res, err := f.svc.Execute(f.conv, f.user, "/deploy prod")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if res.Message != nil || !strings.Contains(f.lastReply(t), "Unknown command /deploy") {
+		t.Errorf("result = %+v, reply = %q", res, f.lastReply(t))
+	}
+	if f.msgs.called {
+		t.Error("command text must not be persisted")
+	}
+}
+
+func TestCommandService_BotCommand(t *testing.T) {
+	f := newCommandFixture()
+	if _, err := f.svc.RegisterBotCommand(f.bot, f.conv, "Deploy", "Deploy a service"); err != nil {
+		t.Fatalf("RegisterBotCommand: %v", err)
+	}
+	if _, err := f.svc.RegisterBotCommand(uuid.New(), f.conv, "mute", ""); !errors.Is(err, ErrCommandExists) {
+		t.Errorf("registering a built-in name: want ErrCommandExists, got %v", err)
+	}
+
+	// Bot offline: the invoker is told.
+	if _, err := f.svc.Execute(f.conv, f.user, "/deploy api"); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.Contains(f.lastReply(t), "not available") {
+		t.Errorf("reply = %q", f.lastReply(t))
+	}
+
+	// Bot online: the invocation is delivered and the bot answers privately.
+	f.notifier.botConnected = true
+	res, err := f.svc.Execute(f.conv, f.user, "/deploy api --canary")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if res.Reply != nil || len(f.notifier.invocations) != 1 {
+		t.Fatalf("reply = %+v, invocations = %d", res.Reply, len(f.notifier.invocations))
+	}
+	if inv := f.notifier.invocations[0]; inv.Name != "deploy" || inv.Args != "api --canary" || inv.UserID != f.user {
+		t.Errorf("invocation = %+v", inv)
+	}
+	if err := f.svc.ReplyEphemeral(f.bot, f.conv, f.user, "deploying api"); err != nil {
+		t.Fatalf("ReplyEphemeral: %v", err)
+	}
+	last := f.notifier.ephemerals[f.user][len(f.notifier.ephemerals[f.user])-1]
+	if last.SenderID == nil || *last.SenderID != f.bot || last.Content != "deploying api" {
+		t.Errorf("bot reply = %+v", last)
+	}
+
+	if err := f.svc.UnregisterBotCommand(f.bot, f.conv, "deploy"); err != nil {
+		t.Fatalf("UnregisterBotCommand: %v", err)
+	}
+	if err := f.svc.ReplyEphemeral(f.bot, f.conv, f.user, "late"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("reply without commands: want ErrPermissionDenied, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_channels.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Broadcast channels: few publishers, many subscribers
+
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// MaxChannelSubscribers caps the participants of a channel, owner included.
+const MaxChannelSubscribers = 100000
+
+// A channel is managed like a group (owners and admins, invite links, pins, announcement), but
+// only owners and admins post; CheckSend refuses subscribers with ErrAdminOnlySend. Subscribers
+// joining and leaving are not recorded as system messages, and the WebSocket hub delivers
+// channel messages to connected subscribers only: offline subscribers catch up by message ID
+// (MessageService.ListSince) when they reconnect.
+
+// CreateChannel validates the name and creates the channel with the creator as owner.
+func (s *conversationService) CreateChannel(creatorID uuid.UUID, name string) (*model.Conversation, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxGroupNameLength {
+		return nil, fmt.Errorf("%w: channel name must be 1-%d characters", ErrInvalidInput, maxGroupNameLength)
+	}
+	conv := &model.Conversation{
+		Type:      model.ConversationTypeChannel,
+		Name:      name,
+		CreatedBy: creatorID,
+	}
+	if err := s.convRepo.Create(conv); err != nil {
+		return nil, fmt.Errorf("create conversation: %w", err)
+	}
+	if err := s.convRepo.AddParticipant(&model.ConversationParticipant{
+		ConversationID: conv.ConversationID,
+		UserID:         creatorID,
+		Role:           model.ParticipantRoleOwner,
+		JoinedAt:       time.Now(),
+	}); err != nil {
+		return nil, fmt.Errorf("add participant: %w", err)
+	}
+	return conv, nil
+}
+
+// memberLimit returns the maximum number of participants of the group or channel.
+func memberLimit(conv *model.Conversation) int {
+	if conv.Type == model.ConversationTypeChannel {
+		return MaxChannelSubscribers
+	}
+	return MaxGroupMembers
+}
+
+// ensureRoom returns ErrInvalidGroupSize if the group or channel is full.
+func (s *conversationService) ensureRoom(conv *model.Conversation) error {
+	n, err := s.convRepo.CountParticipants(conv.ConversationID)
+	if err != nil {
+		return err
+	}
+	if limit := memberLimit(conv); n >= limit {
+		return fmt.Errorf("%w: at most %d members", ErrInvalidGroupSize, limit)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_history.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Per-participant history clearing, history visibility and conversation deletion
+
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+const maxHistoryDays = 365
+
+// ClearHistory hides the messages up to upToMessageID from the user; 0 clears every current
+// message. The other participants keep their history. Cleared history cannot be restored.
+func (s *conversationService) ClearHistory(conversationID, userID uuid.UUID, upToMessageID int64) error {
+	if upToMessageID < 0 {
+		return fmt.Errorf("%w: up_to_message_id must not be negative", ErrInvalidInput)
+	}
+	return s.clearHistory(conversationID, userID, upToMessageID, false)
+}
+
+// DeleteConversation deletes the conversation for the user only: its history is cleared and it
+// leaves the user's list until a new message arrives. Once no participant can see any message,
+// the conversation is purged.
+func (s *conversationService) DeleteConversation(conversationID, userID uuid.UUID) error {
+	if err := s.clearHistory(conversationID, userID, 0, true); err != nil {
+		return err
+	}
+	if _, err := s.convRepo.DeleteConversationIfAllCleared(conversationID); err != nil {
+		return fmt.Errorf("purge conversation: %w", err)
+	}
+	return nil
+}
+
+// PurgeConversation permanently deletes a group and its messages for every participant.
+func (s *conversationService) PurgeConversation(conversationID, operatorID uuid.UUID) error {
+	if _, err := s.requireGroupManager(conversationID, operatorID); err != nil {
+		return err
+	}
+	return s.convRepo.DeleteConversation(conversationID)
+}
+
+// HistoryStart returns the ID of the newest message hidden from the user, or 0: the later of
+// the history they cleared and the start of the history the group shows them (see
+// visibleFrom).
+func (s *conversationService) HistoryStart(conversationID, userID uuid.UUID) (int64, error) {
+	p, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if p == nil {
+		return 0, ErrNotParticipant
+	}
+	var start int64
+	if p.ClearedUpToMessageID != nil {
+		start = *p.ClearedUpToMessageID
+	}
+	conv, err := s.convRepo.GetByID(conversationID)
+	if err != nil {
+		return 0, ErrConversationNotFound
+	}
+	if from, ok := visibleFrom(conv, p); ok {
+		hidden, err := s.msgRepo.LastIDBefore(conversationID, from)
+		if err != nil {
+			return 0, fmt.Errorf("history start: %w", err)
+		}
+		if hidden > start {
+			start = hidden
+		}
+	}
+	return start, nil
+}
+
+// visibleFrom returns the time from which the group shows its history to the participant, or
+// false when it shows the whole history.
+func visibleFrom(conv *model.Conversation, p *model.ConversationParticipant) (time.Time, bool) {
+	if !conv.IsManaged() || p.JoinedAt.IsZero() {
+		return time.Time{}, false
+	}
+	switch conv.HistoryVisibility {
+	case model.HistoryVisibilityJoined:
+		return p.JoinedAt, true
+	case model.HistoryVisibilityDays:
+		return p.JoinedAt.AddDate(0, 0, -conv.HistoryDays), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// hidesMessage reports whether msg is hidden from the participant: covered by the history they
+// cleared, or older than the history the group shows them (see HistoryStart).
+func hidesMessage(conv *model.Conversation, p *model.ConversationParticipant, msg *model.Message) bool {
+	if p.ClearedUpToMessageID != nil && msg.MessageID <= *p.ClearedUpToMessageID {
+		return true
+	}
+	from, ok := visibleFrom(conv, p)
+	return ok && msg.CreatedAt.Before(from)
+}
+
+// SetHistoryVisibility applies to every participant, including those who joined before the
+// change, and broadcasts model.EventConversationUpdated.
+func (s *conversationService) SetHistoryVisibility(conversationID, operatorID uuid.UUID, visibility string, days int) (*model.Conversation, error) {
+	switch visibility {
+	case model.HistoryVisibilityAll, model.HistoryVisibilityJoined:
+		days = 0
+	case model.HistoryVisibilityDays:
+		if days < 1 || days > maxHistoryDays {
+			return nil, fmt.Errorf("%w: days must be 1-%d", ErrInvalidInput, maxHistoryDays)
+		}
+	default:
+		return nil, fmt.Errorf("%w: visibility must be all, joined or days", ErrInvalidInput)
+	}
+	conv, err := s.requireGroupManager(conversationID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.convRepo.UpdateHistoryVisibility(conversationID, visibility, days); err != nil {
+		return nil, fmt.Errorf("update history visibility: %w", err)
+	}
+	conv.HistoryVisibility, conv.HistoryDays = visibility, days
+	s.notify(conversationID, operatorID, model.EventConversationUpdated, map[string]interface{}{
+		"history_visibility": visibility,
+		"history_days":       days,
+	})
+	return conv, nil
+}
+
+// clearHistory moves the user's history start to upToMessageID (0 or past the end: the last
+// message) and marks the cleared messages read. hide also removes the conversation from the
+// user's list until a newer message arrives.
+func (s *conversationService) clearHistory(conversationID, userID uuid.UUID, upToMessageID int64, hide bool) error {
+	p, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrNotParticipant
+	}
+	lastMsgs, err := s.msgRepo.GetLastMessagesByConversationIDs([]uuid.UUID{conversationID})
+	if err != nil {
+		return fmt.Errorf("read last message: %w", err)
+	}
+	var lastID int64
+	if m := lastMsgs[conversationID]; m != nil {
+		lastID = m.MessageID
+	}
+	if upToMessageID == 0 || upToMessageID > lastID {
+		upToMessageID = lastID
+	}
+
+	updates := make(map[string]interface{})
+	if p.ClearedUpToMessageID == nil || upToMessageID > *p.ClearedUpToMessageID {
+		updates["cleared_up_to_message_id"] = upToMessageID
+	}
+	if upToMessageID > p.LastReadMessageID {
+		updates["last_read_message_id"] = upToMessageID
+	}
+	if hide {
+		updates["hidden_until_message_id"] = lastID
+	}
+	if err := s.convRepo.UpdateParticipantSettings(conversationID, userID, updates); err != nil {
+		return fmt.Errorf("clear history: %w", err)
+	}
+	return nil
+}
+
+// revive brings a conversation the user deleted back into their list; the cleared history
+// stays cleared.
+func (s *conversationService) revive(conversationID, userID uuid.UUID) error {
+	p, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if p == nil || p.HiddenUntilMessageID == nil {
+		return nil
+	}
+	return s.convRepo.UpdateParticipantSettings(conversationID, userID, map[string]interface{}{
+		"hidden_until_message_id": nil,
+	})
+}
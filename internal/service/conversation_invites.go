@@ -0,0 +1,267 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_invites.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Group invite links, join approval mode and join requests
+
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+var (
+	ErrInviteNotFound      = errors.New("invite not found")
+	ErrInviteUnusable      = errors.New("invite link has expired, been revoked or reached its use limit")
+	ErrInviteLimit         = errors.New("active invite limit reached")
+	ErrJoinRequestNotFound = errors.New("join request not found")
+)
+
+const (
+	maxActiveInvitesPerConversation = 20
+	maxInviteLifetime               = 30 * 24 * time.Hour
+	maxInviteUses                   = 10000
+	inviteCodeBytes                 = 12
+)
+
+// InviteOptions limits an invite link. Zero values mean no expiry and unlimited uses.
+type InviteOptions struct {
+	ExpiresIn time.Duration
+	MaxUses   int
+}
+
+// JoinResult is the outcome of joining through an invite: either the user is now a member
+// (Joined), or the group requires approval and Request is pending.
+type JoinResult struct {
+	Conv    *model.Conversation
+	Joined  bool
+	Request *model.ConversationJoinRequest
+}
+
+// CreateInvite creates an invite link for a group; only owners and admins may.
+func (s *conversationService) CreateInvite(conversationID, operatorID uuid.UUID, opts InviteOptions) (*model.ConversationInvite, error) {
+	if opts.ExpiresIn < 0 || opts.ExpiresIn > maxInviteLifetime {
+		return nil, fmt.Errorf("%w: expiry must be at most %d days", ErrInvalidInput, int(maxInviteLifetime/(24*time.Hour)))
+	}
+	if opts.MaxUses < 0 || opts.MaxUses > maxInviteUses {
+		return nil, fmt.Errorf("%w: max uses must be 0-%d", ErrInvalidInput, maxInviteUses)
+	}
+	if _, err := s.requireGroupManager(conversationID, operatorID); err != nil {
+		return nil, err
+	}
+	invites, err := s.inviteRepo.ListByConversation(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("list invites: %w", err)
+	}
+	now := time.Now()
+	active := 0
+	for _, inv := range invites {
+		if inv.Usable(now) {
+			active++
+		}
+	}
+	if active >= maxActiveInvitesPerConversation {
+		return nil, fmt.Errorf("%w: at most %d active invites", ErrInviteLimit, maxActiveInvitesPerConversation)
+	}
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	invite := &model.ConversationInvite{
+		Code:           code,
+		ConversationID: conversationID,
+		CreatedBy:      operatorID,
+		MaxUses:        opts.MaxUses,
+		CreatedAt:      now,
+	}
+	if opts.ExpiresIn > 0 {
+		expires := now.Add(opts.ExpiresIn)
+		invite.ExpiresAt = &expires
+	}
+	if err := s.inviteRepo.Create(invite); err != nil {
+		return nil, fmt.Errorf("create invite: %w", err)
+	}
+	return invite, nil
+}
+
+// ListInvites returns the group's invites, revoked and expired ones included.
+func (s *conversationService) ListInvites(conversationID, operatorID uuid.UUID) ([]*model.ConversationInvite, error) {
+	if _, err := s.requireGroupManager(conversationID, operatorID); err != nil {
+		return nil, err
+	}
+	return s.inviteRepo.ListByConversation(conversationID)
+}
+
+// RevokeInvite revokes an invite; joins with it fail from then on.
+func (s *conversationService) RevokeInvite(conversationID, operatorID uuid.UUID, code string) error {
+	if _, err := s.requireGroupManager(conversationID, operatorID); err != nil {
+		return err
+	}
+	ok, err := s.inviteRepo.Revoke(conversationID, code, time.Now())
+	if err != nil {
+		return fmt.Errorf("revoke invite: %w", err)
+	}
+	if !ok {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+// JoinByInvite adds the user to the invite's group, or files a join request if the group is in
+// approval mode. Joining a group the user is already in succeeds without using the invite.
+func (s *conversationService) JoinByInvite(code string, userID uuid.UUID) (*JoinResult, error) {
+	invite, err := s.inviteRepo.GetByCode(code)
+	if err != nil {
+		return nil, fmt.Errorf("get invite: %w", err)
+	}
+	if invite == nil {
+		return nil, ErrInviteNotFound
+	}
+	conv, err := s.convRepo.GetByID(invite.ConversationID)
+	if err != nil {
+		return nil, ErrInviteNotFound
+	}
+	p, err := s.convRepo.GetParticipant(conv.ConversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		return &JoinResult{Conv: conv, Joined: true}, nil
+	}
+	now := time.Now()
+	if !invite.Usable(now) {
+		return nil, ErrInviteUnusable
+	}
+	if err := s.ensureRoom(conv); err != nil {
+		return nil, err
+	}
+
+	if conv.JoinApproval {
+		req, created, err := s.inviteRepo.CreateJoinRequest(&model.ConversationJoinRequest{
+			ConversationID: conv.ConversationID,
+			UserID:         userID,
+			InviteCode:     code,
+			Status:         model.JoinRequestPending,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create join request: %w", err)
+		}
+		if req == nil {
+			return nil, ErrInviteUnusable
+		}
+		// A request counts as a use; repeating it does not.
+		if created {
+			s.notify(conv.ConversationID, userID, model.EventJoinRequested, map[string]interface{}{
+				"request_id": req.RequestID,
+				"user_id":    userID,
+			})
+		}
+		return &JoinResult{Conv: conv, Request: req}, nil
+	}
+
+	ok, err := s.convRepo.AddParticipantByInvite(&model.ConversationParticipant{
+		ConversationID: conv.ConversationID,
+		UserID:         userID,
+		Role:           model.ParticipantRoleMember,
+		JoinedAt:       now,
+	}, code)
+	if err != nil {
+		return nil, fmt.Errorf("add participant: %w", err)
+	}
+	if !ok {
+		return nil, ErrInviteUnusable
+	}
+	s.announceJoin(conv, userID, []uuid.UUID{userID}, "invite")
+	return &JoinResult{Conv: conv, Joined: true}, nil
+}
+
+// SetJoinApproval turns the group's approval mode on or off; only owners and admins may.
+// Pending requests stay pending when it is turned off.
+func (s *conversationService) SetJoinApproval(conversationID, operatorID uuid.UUID, enabled bool) error {
+	if _, err := s.requireGroupManager(conversationID, operatorID); err != nil {
+		return err
+	}
+	return s.convRepo.SetJoinApproval(conversationID, enabled)
+}
+
+// ListJoinRequests returns the group's pending join requests.
+func (s *conversationService) ListJoinRequests(conversationID, operatorID uuid.UUID) ([]*model.ConversationJoinRequest, error) {
+	if _, err := s.requireGroupManager(conversationID, operatorID); err != nil {
+		return nil, err
+	}
+	return s.inviteRepo.ListPendingJoinRequests(conversationID)
+}
+
+// DecideJoinRequest approves (adding the user) or rejects a pending request.
+func (s *conversationService) DecideJoinRequest(conversationID, operatorID uuid.UUID, requestID int64, approve bool) error {
+	conv, err := s.requireGroupManager(conversationID, operatorID)
+	if err != nil {
+		return err
+	}
+	req, err := s.inviteRepo.GetJoinRequest(conversationID, requestID)
+	if err != nil {
+		return fmt.Errorf("get join request: %w", err)
+	}
+	if req == nil || req.Status != model.JoinRequestPending {
+		return ErrJoinRequestNotFound
+	}
+	status := model.JoinRequestRejected
+	if approve {
+		status = model.JoinRequestApproved
+		if err := s.ensureRoom(conv); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	ok, err := s.inviteRepo.DecideJoinRequest(requestID, status, operatorID, now)
+	if err != nil {
+		return fmt.Errorf("decide join request: %w", err)
+	}
+	if !ok {
+		// Decided concurrently by another admin.
+		return ErrJoinRequestNotFound
+	}
+	if !approve {
+		return nil
+	}
+	p, err := s.convRepo.GetParticipant(conversationID, req.UserID)
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		return nil
+	}
+	if err := s.convRepo.AddParticipant(&model.ConversationParticipant{
+		ConversationID: conversationID,
+		UserID:         req.UserID,
+		Role:           model.ParticipantRoleMember,
+		JoinedAt:       now,
+	}); err != nil {
+		return fmt.Errorf("add participant: %w", err)
+	}
+	s.announceJoin(conv, operatorID, []uuid.UUID{req.UserID}, "approved")
+	return nil
+}
+
+func newInviteCode() (string, error) {
+	b := make([]byte, inviteCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate invite code: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_mentions.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: @username and @all mentions in group messages
+
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+var (
+	ErrMentionAllNotAllowed = errors.New("only group owners and admins can mention @all")
+	ErrMentionRateLimited   = errors.New("@all was used too often in this conversation, try again later")
+)
+
+const (
+	// MentionAll is the mention that notifies every participant of a group.
+	MentionAll = "all"
+
+	maxMentionsPerMessage = 50
+	metadataKeyMentions   = "mentions"
+	metadataKeyMentionAll = "mention_all"
+)
+
+// mentionPattern matches "@name" at the start of the content or after a character that cannot
+// be part of a name or an email address.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.\-]+)`)
+
+// Mentions are the users a message mentions.
+type Mentions struct {
+	// UserIDs are the participants mentioned by @username, in order of first mention.
+	UserIDs []uuid.UUID
+	// All is set when the message mentions @all.
+	All bool
+	// Notified are the participants whose mention count the message raises: the mentioned
+	// users, or every participant for @all. Never the sender.
+	Notified []uuid.UUID
+}
+
+// Metadata returns the message metadata entries describing the mentions.
+func (m *Mentions) Metadata() map[string]interface{} {
+	out := make(map[string]interface{}, 2)
+	if len(m.UserIDs) > 0 {
+		ids := make([]string, len(m.UserIDs))
+		for i, id := range m.UserIDs {
+			ids[i] = id.String()
+		}
+		out[metadataKeyMentions] = ids
+	}
+	if m.All {
+		out[metadataKeyMentionAll] = true
+	}
+	return out
+}
+
+// parseMentions returns the distinct names mentioned in content (case-insensitive), at most
+// maxMentionsPerMessage of them.
+func parseMentions(content string) []string {
+	if !strings.Contains(content, "@") {
+		return nil
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// A sentence may end right after the name ("thanks @bob.").
+		name := strings.TrimRight(m[1], ".-")
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, name)
+		if len(names) == maxMentionsPerMessage {
+			break
+		}
+	}
+	return names
+}
+
+// ResolveMentions maps the @mentions in a group message to participants. Names of
+// non-participants are ignored. @all is limited to owners and admins and to the configured
+// rate per group. Returns nil if the message mentions nobody or the conversation is not a group.
+func (s *conversationService) ResolveMentions(conversationID, senderID uuid.UUID, content string) (*Mentions, error) {
+	names := parseMentions(content)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	// The send policy is cached with the membership, unlike the conversation row.
+	policy, err := s.convRepo.GetSendPolicy(conversationID)
+	if err != nil {
+		return nil, ErrConversationNotFound
+	}
+	// Only groups resolve mentions; a channel would load every subscriber.
+	if policy.Type != model.ConversationTypeGroup {
+		return nil, nil
+	}
+	participantIDs, err := s.convRepo.GetParticipantUserIDs(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.userRepo.GetByIDs(participantIDs)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]uuid.UUID, len(users))
+	for _, u := range users {
+		byName[strings.ToLower(u.Username)] = u.UserID
+	}
+
+	m := &Mentions{}
+	for _, name := range names {
+		if strings.EqualFold(name, MentionAll) {
+			m.All = true
+			continue
+		}
+		if id, ok := byName[strings.ToLower(name)]; ok {
+			m.UserIDs = append(m.UserIDs, id)
+		}
+	}
+	if m.All {
+		if err := s.allowMentionAll(conversationID, senderID, policy); err != nil {
+			return nil, err
+		}
+		m.Notified = excludeUserID(participantIDs, senderID)
+	} else {
+		m.Notified = excludeUserID(m.UserIDs, senderID)
+	}
+	if len(m.UserIDs) == 0 && !m.All {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// allowMentionAll checks the sender's role and takes a token from the group's @all bucket.
+// Limiter errors allow the mention.
+func (s *conversationService) allowMentionAll(conversationID, senderID uuid.UUID, policy *model.SendPolicy) error {
+	if !policy.IsManager(senderID) {
+		return ErrMentionAllNotAllowed
+	}
+	if s.limiter == nil || s.opts.MentionAllLimit.PerHour <= 0 {
+		return nil
+	}
+	res, err := s.limiter.Allow(context.Background(), "mention_all:conversation:"+conversationID.String(), s.opts.MentionAllLimit)
+	if err != nil {
+		log.Printf("[RATE] limiter error conversation_id=%s err=%v", conversationID, err)
+		return nil
+	}
+	if !res.Allowed {
+		return ErrMentionRateLimited
+	}
+	return nil
+}
+
+func excludeUserID(ids []uuid.UUID, exclude uuid.UUID) []uuid.UUID {
+	out := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if id != exclude {
+			out = append(out, id)
+		}
+	}
+	return out
+}
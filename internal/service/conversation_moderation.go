@@ -0,0 +1,183 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_moderation.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Group moderation: admin-only send, slow mode and silencing members
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+var (
+	ErrAdminOnlySend  = errors.New("only owners and admins can send messages in this group")
+	ErrMemberSilenced = errors.New("you are muted in this group")
+	ErrSlowMode       = errors.New("slow mode is on")
+)
+
+const maxSlowModeSeconds = 3600
+
+// SendRestrictionError is returned when moderation refuses a message. Reason is
+// ErrAdminOnlySend, ErrMemberSilenced or ErrSlowMode (errors.Is matches it); RetryAfter is how
+// long until the sender may try again, 0 when it does not pass on its own.
+type SendRestrictionError struct {
+	Reason     error
+	RetryAfter time.Duration
+}
+
+func (e *SendRestrictionError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%v: try again in %ds", e.Reason, int((e.RetryAfter+time.Second-1)/time.Second))
+	}
+	return e.Reason.Error()
+}
+
+func (e *SendRestrictionError) Unwrap() error {
+	return e.Reason
+}
+
+// ModerationUpdate changes a group's send restrictions. Nil fields are left unchanged.
+type ModerationUpdate struct {
+	AdminOnlySend *bool
+	// SlowModeSeconds is the minimum interval between two messages of a member; 0 turns it off.
+	SlowModeSeconds *int
+}
+
+// CheckSend returns nil if the user may send a message to the conversation now, ErrNotParticipant,
+// or a *SendRestrictionError. Owners and admins are never restricted. Membership and the send
+// policy come from the membership cache when the repository has one.
+func (s *conversationService) CheckSend(conversationID, senderID uuid.UUID) error {
+	policy, err := s.convRepo.GetSendPolicy(conversationID)
+	if err != nil {
+		// A conversation that does not exist has no participants.
+		if ok, perr := s.convRepo.IsParticipant(conversationID, senderID); perr == nil && !ok {
+			return ErrNotParticipant
+		}
+		return ErrConversationNotFound
+	}
+	// Owners and admins are participants.
+	if policy.IsManager(senderID) {
+		return nil
+	}
+	ok, err := s.convRepo.IsParticipant(conversationID, senderID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotParticipant
+	}
+	now := time.Now()
+	if until, silenced := policy.IsSilenced(senderID, now); silenced {
+		return &SendRestrictionError{Reason: ErrMemberSilenced, RetryAfter: until.Sub(now)}
+	}
+	if !policy.IsManaged() {
+		return nil
+	}
+	// Channel subscribers only read.
+	if policy.AdminOnlySend || policy.Type == model.ConversationTypeChannel {
+		return &SendRestrictionError{Reason: ErrAdminOnlySend}
+	}
+	if policy.SlowMode > 0 {
+		last, err := s.msgRepo.LastSentAt(conversationID, senderID)
+		if err != nil {
+			return fmt.Errorf("read last message: %w", err)
+		}
+		if last != nil {
+			if wait := last.Add(time.Duration(policy.SlowMode) * time.Second).Sub(now); wait > 0 {
+				return &SendRestrictionError{Reason: ErrSlowMode, RetryAfter: wait}
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateModeration applies the update and broadcasts model.EventConversationUpdated with the
+// resulting settings.
+func (s *conversationService) UpdateModeration(conversationID, operatorID uuid.UUID, u ModerationUpdate) (*model.Conversation, error) {
+	if u.SlowModeSeconds != nil && (*u.SlowModeSeconds < 0 || *u.SlowModeSeconds > maxSlowModeSeconds) {
+		return nil, fmt.Errorf("%w: slow mode must be 0-%d seconds", ErrInvalidInput, maxSlowModeSeconds)
+	}
+	conv, err := s.requireGroupManager(conversationID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	if u.AdminOnlySend != nil {
+		conv.AdminOnlySend = *u.AdminOnlySend
+	}
+	if u.SlowModeSeconds != nil {
+		conv.SlowMode = *u.SlowModeSeconds
+	}
+	if err := s.convRepo.UpdateModeration(conversationID, conv.AdminOnlySend, conv.SlowMode); err != nil {
+		return nil, fmt.Errorf("update moderation: %w", err)
+	}
+	s.notify(conversationID, operatorID, model.EventConversationUpdated, map[string]interface{}{
+		"admin_only_send":   conv.AdminOnlySend,
+		"slow_mode_seconds": conv.SlowMode,
+	})
+	return conv, nil
+}
+
+// SilenceMember keeps a member from sending for d; owners and admins cannot be silenced.
+func (s *conversationService) SilenceMember(conversationID, operatorID, userID uuid.UUID, d time.Duration) (*time.Time, error) {
+	if d <= 0 || d > maxMuteDuration {
+		return nil, fmt.Errorf("%w: duration must be between 1 second and %d days", ErrInvalidInput, int(maxMuteDuration/(24*time.Hour)))
+	}
+	if err := s.requireSilenceable(conversationID, operatorID, userID); err != nil {
+		return nil, err
+	}
+	until := time.Now().Add(d)
+	if err := s.convRepo.UpdateParticipantSettings(conversationID, userID, map[string]interface{}{"silenced_until": until}); err != nil {
+		return nil, fmt.Errorf("silence member: %w", err)
+	}
+	s.notify(conversationID, operatorID, model.EventMemberSilenced, map[string]interface{}{
+		"user_id": userID,
+		"until":   until,
+	})
+	return &until, nil
+}
+
+// UnsilenceMember lets a silenced member send again.
+func (s *conversationService) UnsilenceMember(conversationID, operatorID, userID uuid.UUID) error {
+	if err := s.requireSilenceable(conversationID, operatorID, userID); err != nil {
+		return err
+	}
+	if err := s.convRepo.UpdateParticipantSettings(conversationID, userID, map[string]interface{}{"silenced_until": nil}); err != nil {
+		return fmt.Errorf("unsilence member: %w", err)
+	}
+	s.notify(conversationID, operatorID, model.EventMemberSilenced, map[string]interface{}{
+		"user_id": userID,
+		"until":   nil,
+	})
+	return nil
+}
+
+// requireSilenceable checks that the operator manages the group and the user is a plain member.
+func (s *conversationService) requireSilenceable(conversationID, operatorID, userID uuid.UUID) error {
+	if _, err := s.requireGroupManager(conversationID, operatorID); err != nil {
+		return err
+	}
+	target, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return ErrUserNotFound
+	}
+	if target.IsManager() {
+		return fmt.Errorf("%w: owners and admins cannot be muted", ErrPermissionDenied)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_pins.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Pinned messages, group announcements and the conversation detail view
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+var (
+	ErrPinLimit  = errors.New("pinned message limit reached")
+	ErrNotPinned = errors.New("message is not pinned")
+)
+
+const maxAnnouncementLength = 2000
+
+// ConversationDetail is a conversation with its announcement and pinned messages. Pins of
+// deleted messages are left out.
+type ConversationDetail struct {
+	Conv         *model.Conversation
+	Announcement *model.Announcement
+	Pins         []*model.PinnedMessage
+}
+
+// GetDetail reads the current announcement and pins, so members who joined later see them too.
+func (s *conversationService) GetDetail(conversationID, userID uuid.UUID) (*ConversationDetail, error) {
+	conv, err := s.GetByID(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := s.pinRepo.ListByConversation(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("list pins: %w", err)
+	}
+	visible := make([]*model.PinnedMessage, 0, len(pins))
+	for _, p := range pins {
+		if p.Message != nil {
+			visible = append(visible, p)
+		}
+	}
+	return &ConversationDetail{Conv: conv, Announcement: conv.CurrentAnnouncement(), Pins: visible}, nil
+}
+
+// PinMessage checks the operator may pin and the pin limit, then stores the pin and broadcasts
+// model.EventPinChanged.
+func (s *conversationService) PinMessage(conversationID, operatorID uuid.UUID, messageID int64) (*model.PinnedMessage, error) {
+	if err := s.requirePinManager(conversationID, operatorID); err != nil {
+		return nil, err
+	}
+	msg, err := s.msgRepo.GetByID(messageID)
+	if err != nil || msg == nil || msg.ConversationID != conversationID {
+		return nil, ErrMessageNotFound
+	}
+	if s.opts.MaxPins > 0 {
+		n, err := s.pinRepo.Count(conversationID)
+		if err != nil {
+			return nil, fmt.Errorf("count pins: %w", err)
+		}
+		if n >= int64(s.opts.MaxPins) {
+			// Re-pinning a pinned message stays a no-op at the limit.
+			existing, err := s.findPin(conversationID, messageID)
+			if err != nil {
+				return nil, err
+			}
+			if existing == nil {
+				return nil, fmt.Errorf("%w: at most %d pinned messages", ErrPinLimit, s.opts.MaxPins)
+			}
+			return existing, nil
+		}
+	}
+	pin := &model.PinnedMessage{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		PinnedBy:       operatorID,
+		PinnedAt:       time.Now(),
+	}
+	created, err := s.pinRepo.Pin(pin)
+	if err != nil {
+		return nil, fmt.Errorf("pin message: %w", err)
+	}
+	pin.Message = msg
+	if created {
+		s.notify(conversationID, operatorID, model.EventPinChanged, map[string]interface{}{
+			"action":     "pinned",
+			"message_id": messageID,
+			"pinned_by":  operatorID,
+			"pinned_at":  pin.PinnedAt,
+		})
+		s.postSystemMessage(conversationID, &SystemEvent{Actor: operatorID, Action: SystemActionMessagePinned, MessageID: messageID})
+	}
+	return pin, nil
+}
+
+// UnpinMessage removes a pin and broadcasts model.EventPinChanged.
+func (s *conversationService) UnpinMessage(conversationID, operatorID uuid.UUID, messageID int64) error {
+	if err := s.requirePinManager(conversationID, operatorID); err != nil {
+		return err
+	}
+	removed, err := s.pinRepo.Unpin(conversationID, messageID)
+	if err != nil {
+		return fmt.Errorf("unpin message: %w", err)
+	}
+	if !removed {
+		return ErrNotPinned
+	}
+	s.notify(conversationID, operatorID, model.EventPinChanged, map[string]interface{}{
+		"action":     "unpinned",
+		"message_id": messageID,
+	})
+	s.postSystemMessage(conversationID, &SystemEvent{Actor: operatorID, Action: SystemActionMessageUnpinned, MessageID: messageID})
+	return nil
+}
+
+// SetAnnouncement stores the announcement with its author and broadcasts
+// model.EventAnnouncementChanged.
+func (s *conversationService) SetAnnouncement(conversationID, operatorID uuid.UUID, text string) (*model.Announcement, error) {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) > maxAnnouncementLength {
+		return nil, fmt.Errorf("%w: announcement must be at most %d characters", ErrInvalidInput, maxAnnouncementLength)
+	}
+	if _, err := s.requireGroupManager(conversationID, operatorID); err != nil {
+		return nil, err
+	}
+	var announcement *model.Announcement
+	action := SystemActionAnnouncementChanged
+	if text == "" {
+		action = SystemActionAnnouncementCleared
+		if err := s.convRepo.UpdateAnnouncement(conversationID, "", nil, nil); err != nil {
+			return nil, fmt.Errorf("clear announcement: %w", err)
+		}
+	} else {
+		now := time.Now()
+		if err := s.convRepo.UpdateAnnouncement(conversationID, text, &operatorID, &now); err != nil {
+			return nil, fmt.Errorf("set announcement: %w", err)
+		}
+		announcement = &model.Announcement{Text: text, AuthorID: operatorID, UpdatedAt: now}
+	}
+	s.notify(conversationID, operatorID, model.EventAnnouncementChanged, map[string]interface{}{
+		"announcement": announcement,
+	})
+	s.postSystemMessage(conversationID, &SystemEvent{Actor: operatorID, Action: action})
+	return announcement, nil
+}
+
+// requirePinManager allows group owners and admins, and both participants of a 1:1 conversation.
+func (s *conversationService) requirePinManager(conversationID, userID uuid.UUID) error {
+	p, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrNotParticipant
+	}
+	conv, err := s.convRepo.GetByID(conversationID)
+	if err != nil {
+		return ErrConversationNotFound
+	}
+	if conv.IsManaged() && !p.IsManager() {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+func (s *conversationService) findPin(conversationID uuid.UUID, messageID int64) (*model.PinnedMessage, error) {
+	pins, err := s.pinRepo.ListByConversation(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("list pins: %w", err)
+	}
+	for _, p := range pins {
+		if p.MessageID == messageID {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// notify passes a conversation event to the event notifier, if any.
+func (s *conversationService) notify(conversationID, actorID uuid.UUID, event string, data map[string]interface{}) {
+	if s.events != nil {
+		s.events.NotifyConversationEvent(conversationID, actorID, event, data)
+	}
+}
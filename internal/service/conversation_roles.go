@@ -0,0 +1,293 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_roles.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Group roles: promotion, ownership transfer, leaving and removing members
+
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// Role matrix of a group:
+//
+//	action                  owner          admin          member
+//	promote/demote admins   yes            no             no
+//	transfer ownership      yes            no             no
+//	remove a member         yes            yes            no
+//	remove an admin         yes            no             no
+//	leave                   yes (hands     yes            yes
+//	                        over first)
+//
+// Nobody can remove the owner; the owner's role only changes by transferring ownership.
+
+// roleRank orders roles: a manager may remove participants ranked below them.
+func roleRank(role string) int {
+	switch role {
+	case model.ParticipantRoleOwner:
+		return 3
+	case model.ParticipantRoleAdmin:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// SetMemberRole makes a participant an admin or a plain member.
+func (s *conversationService) SetMemberRole(conversationID, operatorID, userID uuid.UUID, role string) error {
+	if role != model.ParticipantRoleAdmin && role != model.ParticipantRoleMember {
+		return fmt.Errorf("%w: role must be admin or member (transfer ownership to change the owner)", ErrInvalidInput)
+	}
+	if _, err := s.requireGroupOwner(conversationID, operatorID); err != nil {
+		return err
+	}
+	if userID == operatorID {
+		return fmt.Errorf("%w: the owner's role only changes by transferring ownership", ErrPermissionDenied)
+	}
+	target, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return ErrUserNotFound
+	}
+	if target.Role == role {
+		return nil
+	}
+	ok, err := s.convRepo.SetParticipantRole(conversationID, userID, role)
+	if err != nil {
+		return fmt.Errorf("set role: %w", err)
+	}
+	if !ok {
+		return ErrUserNotFound
+	}
+	s.postSystemMessage(conversationID, &SystemEvent{Actor: operatorID, Action: SystemActionRoleChanged, Targets: []uuid.UUID{userID}, Role: role})
+	s.notify(conversationID, operatorID, model.EventRoleChanged, map[string]interface{}{
+		"user_id": userID,
+		"role":    role,
+	})
+	return nil
+}
+
+// TransferOwnership hands the group over to another participant; the previous owner stays as an
+// admin.
+func (s *conversationService) TransferOwnership(conversationID, ownerID, newOwnerID uuid.UUID) error {
+	if newOwnerID == ownerID {
+		return fmt.Errorf("%w: already the owner", ErrInvalidInput)
+	}
+	if _, err := s.requireGroupOwner(conversationID, ownerID); err != nil {
+		return err
+	}
+	target, err := s.convRepo.GetParticipant(conversationID, newOwnerID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return ErrUserNotFound
+	}
+	return s.transferOwnership(conversationID, ownerID, newOwnerID, "transfer")
+}
+
+// LeaveGroup removes the user from the group or channel. An owner hands it over first to the
+// longest-tenured admin, or else the longest-tenured member; the last participant leaving
+// deletes it.
+func (s *conversationService) LeaveGroup(conversationID, userID uuid.UUID) error {
+	p, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrNotParticipant
+	}
+	conv, err := s.convRepo.GetByID(conversationID)
+	if err != nil {
+		return ErrConversationNotFound
+	}
+	if !conv.IsManaged() {
+		return ErrGroupOnly
+	}
+	return s.leave(conv, p, "left")
+}
+
+// RemoveMember removes a participant ranked below the operator. Removing oneself is leaving.
+func (s *conversationService) RemoveMember(conversationID, operatorID, userID uuid.UUID) error {
+	if userID == operatorID {
+		return s.LeaveGroup(conversationID, operatorID)
+	}
+	conv, err := s.requireGroupManager(conversationID, operatorID)
+	if err != nil {
+		return err
+	}
+	op, err := s.convRepo.GetParticipant(conversationID, operatorID)
+	if err != nil {
+		return err
+	}
+	target, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return ErrUserNotFound
+	}
+	if op == nil || roleRank(op.Role) <= roleRank(target.Role) {
+		return fmt.Errorf("%w: cannot remove a participant with role %s", ErrPermissionDenied, target.Role)
+	}
+	removed, err := s.convRepo.RemoveParticipant(conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("remove participant: %w", err)
+	}
+	if !removed {
+		return nil
+	}
+	if conv.Type != model.ConversationTypeChannel {
+		s.postSystemMessage(conversationID, &SystemEvent{Actor: operatorID, Action: SystemActionMemberRemoved, Targets: []uuid.UUID{userID}})
+	}
+	s.notify(conversationID, operatorID, model.EventMemberLeft, map[string]interface{}{
+		"user_id": userID,
+		"via":     "removed",
+	})
+	return nil
+}
+
+// OnAccountDeleted implements AccountListener: the user leaves every group and channel, handing
+// over the ones they own. Failures are logged; the account is already deleted.
+func (s *conversationService) OnAccountDeleted(userID uuid.UUID) {
+	ids, err := s.convRepo.ListIDsForUser(userID, model.ConversationTypeGroup, model.ConversationTypeChannel)
+	if err != nil {
+		log.Printf("[AUTH] account deletion: list groups failed user_id=%s err=%v", userID, err)
+		return
+	}
+	for _, convID := range ids {
+		p, err := s.convRepo.GetParticipant(convID, userID)
+		if err != nil || p == nil {
+			continue
+		}
+		conv, err := s.convRepo.GetByID(convID)
+		if err != nil {
+			continue
+		}
+		if err := s.leave(conv, p, "account_deleted"); err != nil {
+			log.Printf("[AUTH] account deletion: leave group failed user_id=%s conversation_id=%s err=%v", userID, convID, err)
+		}
+	}
+}
+
+// leave removes the participant from a group or channel, handing ownership over first if
+// needed.
+func (s *conversationService) leave(conv *model.Conversation, p *model.ConversationParticipant, via string) error {
+	conversationID := conv.ConversationID
+	if p.Role == model.ParticipantRoleOwner {
+		successor, err := s.successor(conversationID, p.UserID)
+		if err != nil {
+			return err
+		}
+		if successor == nil {
+			if err := s.convRepo.DeleteConversation(conversationID); err != nil {
+				return fmt.Errorf("delete group: %w", err)
+			}
+			return nil
+		}
+		if err := s.transferOwnership(conversationID, p.UserID, successor.UserID, "auto"); err != nil {
+			return err
+		}
+	}
+	removed, err := s.convRepo.RemoveParticipant(conversationID, p.UserID)
+	if err != nil {
+		return fmt.Errorf("remove participant: %w", err)
+	}
+	if !removed {
+		return nil
+	}
+	if conv.Type != model.ConversationTypeChannel {
+		s.postSystemMessage(conversationID, &SystemEvent{Actor: p.UserID, Action: SystemActionMemberLeft, Targets: []uuid.UUID{p.UserID}})
+	}
+	s.notify(conversationID, p.UserID, model.EventMemberLeft, map[string]interface{}{
+		"user_id": p.UserID,
+		"via":     via,
+	})
+	return nil
+}
+
+// successor picks the next owner: the longest-tenured admin, else the longest-tenured member.
+// Bots are only picked when no person is left. Returns nil if exclude is the only participant.
+func (s *conversationService) successor(conversationID, exclude uuid.UUID) (*model.ConversationParticipant, error) {
+	ps, err := s.convRepo.ListParticipants(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("list participants: %w", err)
+	}
+	candidates := make([]*model.ConversationParticipant, 0, len(ps))
+	ids := make([]uuid.UUID, 0, len(ps))
+	for _, p := range ps {
+		if p.UserID != exclude {
+			candidates = append(candidates, p)
+			ids = append(ids, p.UserID)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	bots := make(map[uuid.UUID]bool)
+	if users, err := s.userRepo.GetByIDs(ids); err == nil {
+		for _, u := range users {
+			if u.IsBot {
+				bots[u.UserID] = true
+			}
+		}
+	}
+	for _, allowBots := range []bool{false, true} {
+		for _, role := range []string{model.ParticipantRoleAdmin, model.ParticipantRoleMember} {
+			for _, p := range candidates {
+				if p.Role == role && (allowBots || !bots[p.UserID]) {
+					return p, nil
+				}
+			}
+		}
+	}
+	return candidates[0], nil
+}
+
+// transferOwnership swaps the owner atomically and records it; via is "transfer" or "auto".
+func (s *conversationService) transferOwnership(conversationID, fromUserID, toUserID uuid.UUID, via string) error {
+	ok, err := s.convRepo.TransferOwnership(conversationID, fromUserID, toUserID)
+	if err != nil {
+		return fmt.Errorf("transfer ownership: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: ownership changed concurrently", ErrPermissionDenied)
+	}
+	s.postSystemMessage(conversationID, &SystemEvent{Actor: fromUserID, Action: SystemActionOwnerChanged, Targets: []uuid.UUID{toUserID}, Via: via})
+	s.notify(conversationID, fromUserID, model.EventRoleChanged, map[string]interface{}{
+		"user_id":        toUserID,
+		"role":           model.ParticipantRoleOwner,
+		"previous_owner": fromUserID,
+	})
+	return nil
+}
+
+// requireGroupOwner returns the group if userID is its owner.
+func (s *conversationService) requireGroupOwner(conversationID, userID uuid.UUID) (*model.Conversation, error) {
+	conv, err := s.requireGroupManager(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil || p.Role != model.ParticipantRoleOwner {
+		return nil, fmt.Errorf("%w: only the owner may do this", ErrPermissionDenied)
+	}
+	return conv, nil
+}
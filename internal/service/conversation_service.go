@@ -16,17 +16,31 @@ package service
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 
 	"github.com/convexwf/uim-go/internal/model"
 	"github.com/convexwf/uim-go/internal/repository"
+	"github.com/convexwf/uim-go/internal/store"
 )
 
 var (
 	ErrConversationNotFound = errors.New("conversation not found")
 	ErrNotParticipant       = errors.New("user is not a participant")
 	ErrInvalidConversation  = errors.New("invalid conversation")
+	ErrGroupOnly            = errors.New("only available in group conversations")
+	ErrPermissionDenied     = errors.New("permission denied")
+	ErrInvalidGroupSize     = errors.New("invalid group size")
+)
+
+const (
+	// MaxGroupMembers caps the participants of a group, creator included.
+	MaxGroupMembers     = 200
+	maxGroupNameLength  = 100
+	maxGroupTopicLength = 500
 )
 
 // ConversationWithMeta holds a conversation and its list metadata (last message, unread count, other user for 1:1).
@@ -34,7 +48,21 @@ type ConversationWithMeta struct {
 	Conv        *model.Conversation
 	LastMessage *model.Message
 	UnreadCount int
-	OtherUser   *model.User
+	// MentionCount is the part of UnreadCount that mentions the user.
+	MentionCount int
+	OtherUser    *model.User
+	// Participant is the user's own row, carrying the per-user settings.
+	Participant *model.ConversationParticipant
+	// Muted is set while the user has the conversation muted.
+	Muted bool
+}
+
+// ConversationOptions configures the conversation service.
+type ConversationOptions struct {
+	// MentionAllLimit limits messages with @all per group (PerHour). Zero means no limit.
+	MentionAllLimit store.RateLimit
+	// MaxPins caps the pinned messages per conversation. Zero means no limit.
+	MaxPins int
 }
 
 // ConversationService defines conversation operations.
@@ -42,24 +70,119 @@ type ConversationService interface {
 	CreateOneOnOne(creatorID, otherUserID uuid.UUID) (*model.Conversation, error)
 	GetByID(conversationID, userID uuid.UUID) (*model.Conversation, error)
 	ListByUserID(userID uuid.UUID, limit, offset int) ([]*model.Conversation, error)
-	ListByUserIDWithMeta(userID uuid.UUID, limit, offset int) ([]*ConversationWithMeta, error)
+	// ListByUserIDWithMeta lists the user's conversations pinned first, leaving out archived and
+	// hidden ones unless the filter includes them.
+	ListByUserIDWithMeta(userID uuid.UUID, limit, offset int, filter ConversationListFilter) ([]*ConversationWithMeta, error)
 	EnsureUserInConversation(conversationID, userID uuid.UUID) error
+	// CheckSend returns nil if the user may send to the conversation now: ErrNotParticipant, or a
+	// *SendRestrictionError for admin-only send, a silenced member or slow mode.
+	CheckSend(conversationID, senderID uuid.UUID) error
 	MarkRead(conversationID, userID uuid.UUID, lastReadMessageID int64) error
+	// DeleteConversation deletes the conversation for the user only; the other participants
+	// keep their history.
 	DeleteConversation(conversationID, userID uuid.UUID) error
+	// ClearHistory hides the messages up to upToMessageID (0: all) from the user only.
+	ClearHistory(conversationID, userID uuid.UUID, upToMessageID int64) error
+	// PurgeConversation deletes a group for everyone; only owners and admins may.
+	PurgeConversation(conversationID, operatorID uuid.UUID) error
+	// HistoryStart returns the ID of the newest message hidden from the user (0: full history),
+	// or ErrNotParticipant. It covers the history the user cleared and, in groups, the history
+	// from before they joined that the group's visibility hides. Every path that reads messages
+	// (list, search, sync) must apply it.
+	HistoryStart(conversationID, userID uuid.UUID) (int64, error)
+	// SetHistoryVisibility sets how much history participants see from before they joined
+	// (model.HistoryVisibilityAll, Joined or Days with days); only owners and admins may.
+	SetHistoryVisibility(conversationID, operatorID uuid.UUID, visibility string, days int) (*model.Conversation, error)
+	// CreateGroup creates a group owned by creatorID with the given members (duplicates and the
+	// creator are ignored).
+	CreateGroup(creatorID uuid.UUID, name string, memberIDs []uuid.UUID) (*model.Conversation, error)
+	// CreateChannel creates a broadcast channel owned by creatorID. Subscribers join through
+	// invite links or are added by owners and admins.
+	CreateChannel(creatorID uuid.UUID, name string) (*model.Conversation, error)
+	// AddMembers adds users to a group; only owners and admins may. Users already in the group
+	// are skipped. Returns the IDs actually added, which are announced as joined.
+	AddMembers(conversationID, operatorID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error)
+	// SetTopic sets a group's topic; only owners and admins may. An empty topic clears it.
+	SetTopic(conversationID, operatorID uuid.UUID, topic string) error
+	// RenameGroup sets a group's name; only owners and admins may.
+	RenameGroup(conversationID, operatorID uuid.UUID, name string) error
+	// SetMutedUntil mutes the conversation for the user until the given time; nil unmutes.
+	SetMutedUntil(conversationID, userID uuid.UUID, until *time.Time) error
+	// UpdateSettings changes the user's mute, pin, archive and hide settings for the conversation.
+	UpdateSettings(conversationID, userID uuid.UUID, u ConversationSettingsUpdate) (*model.ConversationParticipant, error)
+	// GetUnreadTotal returns the user's unread count over all conversations not muted.
+	GetUnreadTotal(userID uuid.UUID) (int, error)
+	// ResolveMentions returns the participants a message from senderID mentions, or nil if
+	// none. Errors with ErrMentionAllNotAllowed or ErrMentionRateLimited for a refused @all.
+	ResolveMentions(conversationID, senderID uuid.UUID, content string) (*Mentions, error)
+	// GetDetail returns the conversation with its announcement and pinned messages.
+	GetDetail(conversationID, userID uuid.UUID) (*ConversationDetail, error)
+	// PinMessage pins a message of the conversation; group owners and admins, or either
+	// participant of a 1:1 conversation, may. Pinning a pinned message is a no-op.
+	PinMessage(conversationID, operatorID uuid.UUID, messageID int64) (*model.PinnedMessage, error)
+	UnpinMessage(conversationID, operatorID uuid.UUID, messageID int64) error
+	// SetAnnouncement sets a group's announcement; only owners and admins may. An empty text
+	// clears it (and returns nil).
+	SetAnnouncement(conversationID, operatorID uuid.UUID, text string) (*model.Announcement, error)
+	// CreateInvite creates an invite link for a group; only owners and admins may.
+	CreateInvite(conversationID, operatorID uuid.UUID, opts InviteOptions) (*model.ConversationInvite, error)
+	ListInvites(conversationID, operatorID uuid.UUID) ([]*model.ConversationInvite, error)
+	RevokeInvite(conversationID, operatorID uuid.UUID, code string) error
+	// JoinByInvite joins the invite's group, or files a join request in approval mode. Errors
+	// with ErrInviteNotFound or ErrInviteUnusable.
+	JoinByInvite(code string, userID uuid.UUID) (*JoinResult, error)
+	// SetJoinApproval turns approval mode on or off; only owners and admins may.
+	SetJoinApproval(conversationID, operatorID uuid.UUID, enabled bool) error
+	ListJoinRequests(conversationID, operatorID uuid.UUID) ([]*model.ConversationJoinRequest, error)
+	// DecideJoinRequest approves or rejects a pending join request; only owners and admins may.
+	DecideJoinRequest(conversationID, operatorID uuid.UUID, requestID int64, approve bool) error
+	// UpdateModeration changes a group's admin-only send and slow mode; only owners and admins may.
+	UpdateModeration(conversationID, operatorID uuid.UUID, u ModerationUpdate) (*model.Conversation, error)
+	// SilenceMember keeps a member from sending for d and returns until when; only owners and
+	// admins may, and only plain members can be silenced.
+	SilenceMember(conversationID, operatorID, userID uuid.UUID, d time.Duration) (*time.Time, error)
+	UnsilenceMember(conversationID, operatorID, userID uuid.UUID) error
+	// SetMemberRole makes a participant an admin or a plain member; only the owner may.
+	SetMemberRole(conversationID, operatorID, userID uuid.UUID, role string) error
+	// TransferOwnership makes another participant the owner; the previous owner becomes an admin.
+	TransferOwnership(conversationID, ownerID, newOwnerID uuid.UUID) error
+	// LeaveGroup removes the user from a group. An owner hands over to the longest-tenured admin,
+	// else the longest-tenured member; the last participant leaving deletes the group.
+	LeaveGroup(conversationID, userID uuid.UUID) error
+	// RemoveMember removes a participant; owners remove anyone, admins only plain members.
+	RemoveMember(conversationID, operatorID, userID uuid.UUID) error
+	// OnAccountDeleted makes a deleted user leave all their groups (see AccountListener).
+	OnAccountDeleted(userID uuid.UUID)
+	// RenderSystemMessages sets the content of system messages from their metadata, with the
+	// users' current names. Other messages are left alone.
+	RenderSystemMessages(msgs []*model.Message)
 }
 
 type conversationService struct {
-	convRepo repository.ConversationRepository
-	userRepo repository.UserRepository
-	msgRepo  repository.MessageRepository
+	convRepo   repository.ConversationRepository
+	userRepo   repository.UserRepository
+	msgRepo    repository.MessageRepository
+	pinRepo    repository.PinnedMessageRepository
+	inviteRepo repository.InviteRepository
+	limiter    store.RateLimiter
+	events     ConversationEventNotifier
+	notifier   MessageNotifier
+	opts       ConversationOptions
 }
 
-// NewConversationService creates a new conversation service.
-func NewConversationService(convRepo repository.ConversationRepository, userRepo repository.UserRepository, msgRepo repository.MessageRepository) ConversationService {
+// NewConversationService creates a new conversation service. limiter may be nil (no @all limit);
+// events and notifier (which fans out system messages) may be nil.
+func NewConversationService(convRepo repository.ConversationRepository, userRepo repository.UserRepository, msgRepo repository.MessageRepository, pinRepo repository.PinnedMessageRepository, inviteRepo repository.InviteRepository, limiter store.RateLimiter, events ConversationEventNotifier, notifier MessageNotifier, opts ConversationOptions) ConversationService {
 	return &conversationService{
-		convRepo: convRepo,
-		userRepo: userRepo,
-		msgRepo:  msgRepo,
+		convRepo:   convRepo,
+		userRepo:   userRepo,
+		msgRepo:    msgRepo,
+		pinRepo:    pinRepo,
+		inviteRepo: inviteRepo,
+		limiter:    limiter,
+		events:     events,
+		notifier:   notifier,
+		opts:       opts,
 	}
 }
 
@@ -74,6 +197,9 @@ func (s *conversationService) CreateOneOnOne(creatorID, otherUserID uuid.UUID) (
 	}
 	existing, err := s.convRepo.FindOneOnOneBetween(creatorID, otherUserID)
 	if err == nil {
+		if err := s.revive(existing.ConversationID, creatorID); err != nil {
+			return nil, fmt.Errorf("revive conversation: %w", err)
+		}
 		return existing, nil
 	}
 	conv := &model.Conversation{
@@ -88,6 +214,7 @@ func (s *conversationService) CreateOneOnOne(creatorID, otherUserID uuid.UUID) (
 			ConversationID: conv.ConversationID,
 			UserID:         uid,
 			Role:           "member",
+			JoinedAt:       time.Now(),
 		}); err != nil {
 			return nil, fmt.Errorf("add participant: %w", err)
 		}
@@ -112,9 +239,10 @@ func (s *conversationService) ListByUserID(userID uuid.UUID, limit, offset int)
 	return s.convRepo.ListByUserID(userID, limit, offset)
 }
 
-// ListByUserIDWithMeta lists conversations with last_message, unread_count, and other_user (for 1:1).
-func (s *conversationService) ListByUserIDWithMeta(userID uuid.UUID, limit, offset int) ([]*ConversationWithMeta, error) {
-	convs, err := s.convRepo.ListByUserID(userID, limit, offset)
+// ListByUserIDWithMeta lists conversations with last_message, unread_count, other_user (for 1:1)
+// and the user's settings.
+func (s *conversationService) ListByUserIDWithMeta(userID uuid.UUID, limit, offset int, filter ConversationListFilter) ([]*ConversationWithMeta, error) {
+	convs, err := s.convRepo.ListForUser(userID, filter, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -133,10 +261,18 @@ func (s *conversationService) ListByUserIDWithMeta(userID uuid.UUID, limit, offs
 	if err != nil {
 		return nil, err
 	}
+	mentionCounts, err := s.convRepo.GetMentionCounts(userID, convIDs)
+	if err != nil {
+		return nil, err
+	}
 	otherUserIDs, err := s.convRepo.GetOtherParticipantUserIDsForOneOnOne(userID, convIDs)
 	if err != nil {
 		return nil, err
 	}
+	participants, err := s.convRepo.GetParticipantsForUser(userID, convIDs)
+	if err != nil {
+		return nil, err
+	}
 	var userIDs []uuid.UUID
 	for _, uid := range otherUserIDs {
 		userIDs = append(userIDs, uid)
@@ -152,14 +288,23 @@ func (s *conversationService) ListByUserIDWithMeta(userID uuid.UUID, limit, offs
 	for _, u := range users {
 		userByID[u.UserID] = u
 	}
+	now := time.Now()
 	out := make([]*ConversationWithMeta, len(convs))
 	for i, conv := range convs {
 		meta := &ConversationWithMeta{Conv: conv}
 		meta.LastMessage = lastMsgs[conv.ConversationID]
 		meta.UnreadCount = unreadCounts[conv.ConversationID]
+		meta.MentionCount = mentionCounts[conv.ConversationID]
 		if uid, ok := otherUserIDs[conv.ConversationID]; ok {
 			meta.OtherUser = userByID[uid]
 		}
+		if p := participants[conv.ConversationID]; p != nil {
+			meta.Participant = p
+			meta.Muted = p.IsMuted(now)
+			if meta.LastMessage != nil && hidesMessage(conv, p, meta.LastMessage) {
+				meta.LastMessage = nil
+			}
+		}
 		out[i] = meta
 	}
 	return out, nil
@@ -189,14 +334,185 @@ func (s *conversationService) MarkRead(conversationID, userID uuid.UUID, lastRea
 	return s.convRepo.UpdateParticipantLastRead(conversationID, userID, lastReadMessageID)
 }
 
-// DeleteConversation deletes a conversation after verifying the requester is a participant.
-func (s *conversationService) DeleteConversation(conversationID, userID uuid.UUID) error {
-	ok, err := s.convRepo.IsParticipant(conversationID, userID)
+// CreateGroup validates the name and members and creates the group with the creator as owner.
+func (s *conversationService) CreateGroup(creatorID uuid.UUID, name string, memberIDs []uuid.UUID) (*model.Conversation, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxGroupNameLength {
+		return nil, fmt.Errorf("%w: group name must be 1-%d characters", ErrInvalidInput, maxGroupNameLength)
+	}
+	members := dedupeUserIDs(memberIDs, creatorID)
+	if len(members)+1 > MaxGroupMembers {
+		return nil, fmt.Errorf("%w: at most %d members", ErrInvalidGroupSize, MaxGroupMembers)
+	}
+	if err := s.ensureUsersExist(members); err != nil {
+		return nil, err
+	}
+	conv := &model.Conversation{
+		Type:      model.ConversationTypeGroup,
+		Name:      name,
+		CreatedBy: creatorID,
+	}
+	if err := s.convRepo.Create(conv); err != nil {
+		return nil, fmt.Errorf("create conversation: %w", err)
+	}
+	now := time.Now()
+	if err := s.convRepo.AddParticipant(&model.ConversationParticipant{
+		ConversationID: conv.ConversationID,
+		UserID:         creatorID,
+		Role:           model.ParticipantRoleOwner,
+		JoinedAt:       now,
+	}); err != nil {
+		return nil, fmt.Errorf("add participant: %w", err)
+	}
+	for _, uid := range members {
+		if err := s.convRepo.AddParticipant(&model.ConversationParticipant{
+			ConversationID: conv.ConversationID,
+			UserID:         uid,
+			Role:           model.ParticipantRoleMember,
+			JoinedAt:       now,
+		}); err != nil {
+			return nil, fmt.Errorf("add participant: %w", err)
+		}
+	}
+	return conv, nil
+}
+
+// AddMembers adds the users that are not yet participants.
+func (s *conversationService) AddMembers(conversationID, operatorID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	conv, err := s.requireGroupManager(conversationID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	candidates := dedupeUserIDs(userIDs, operatorID)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: no users to add", ErrInvalidInput)
+	}
+	if err := s.ensureUsersExist(candidates); err != nil {
+		return nil, err
+	}
+	current, err := s.convRepo.GetParticipantUserIDs(conversationID)
 	if err != nil {
+		return nil, err
+	}
+	inGroup := make(map[uuid.UUID]bool, len(current))
+	for _, uid := range current {
+		inGroup[uid] = true
+	}
+	var added []uuid.UUID
+	for _, uid := range candidates {
+		if !inGroup[uid] {
+			added = append(added, uid)
+		}
+	}
+	if limit := memberLimit(conv); len(current)+len(added) > limit {
+		return nil, fmt.Errorf("%w: at most %d members", ErrInvalidGroupSize, limit)
+	}
+	now := time.Now()
+	for _, uid := range added {
+		if err := s.convRepo.AddParticipant(&model.ConversationParticipant{
+			ConversationID: conversationID,
+			UserID:         uid,
+			Role:           model.ParticipantRoleMember,
+			JoinedAt:       now,
+		}); err != nil {
+			return nil, fmt.Errorf("add participant: %w", err)
+		}
+	}
+	s.announceJoin(conv, operatorID, added, "added")
+	return added, nil
+}
+
+// SetTopic updates the group's topic.
+func (s *conversationService) SetTopic(conversationID, operatorID uuid.UUID, topic string) error {
+	topic = strings.TrimSpace(topic)
+	if utf8.RuneCountInString(topic) > maxGroupTopicLength {
+		return fmt.Errorf("%w: topic must be at most %d characters", ErrInvalidInput, maxGroupTopicLength)
+	}
+	if _, err := s.requireGroupManager(conversationID, operatorID); err != nil {
 		return err
 	}
-	if !ok {
-		return ErrNotParticipant
+	if err := s.convRepo.UpdateTopic(conversationID, topic); err != nil {
+		return err
+	}
+	s.postSystemMessage(conversationID, &SystemEvent{Actor: operatorID, Action: SystemActionTopicChanged, Topic: topic})
+	return nil
+}
+
+// RenameGroup updates the group's name.
+func (s *conversationService) RenameGroup(conversationID, operatorID uuid.UUID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxGroupNameLength {
+		return fmt.Errorf("%w: group name must be 1-%d characters", ErrInvalidInput, maxGroupNameLength)
+	}
+	conv, err := s.requireGroupManager(conversationID, operatorID)
+	if err != nil {
+		return err
+	}
+	if conv.Name == name {
+		return nil
+	}
+	if err := s.convRepo.UpdateName(conversationID, name); err != nil {
+		return err
+	}
+	s.postSystemMessage(conversationID, &SystemEvent{Actor: operatorID, Action: SystemActionGroupRenamed, Name: name})
+	return nil
+}
+
+// SetMutedUntil stores the user's mute for the conversation.
+func (s *conversationService) SetMutedUntil(conversationID, userID uuid.UUID, until *time.Time) error {
+	if err := s.EnsureUserInConversation(conversationID, userID); err != nil {
+		return err
+	}
+	return s.convRepo.SetParticipantMutedUntil(conversationID, userID, until)
+}
+
+// requireGroupManager returns the group or channel if userID is one of its owners or admins.
+func (s *conversationService) requireGroupManager(conversationID, userID uuid.UUID) (*model.Conversation, error) {
+	p, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrNotParticipant
+	}
+	conv, err := s.convRepo.GetByID(conversationID)
+	if err != nil {
+		return nil, ErrConversationNotFound
+	}
+	if !conv.IsManaged() {
+		return nil, ErrGroupOnly
+	}
+	if !p.IsManager() {
+		return nil, ErrPermissionDenied
+	}
+	return conv, nil
+}
+
+// ensureUsersExist returns ErrUserNotFound unless every user exists.
+func (s *conversationService) ensureUsersExist(userIDs []uuid.UUID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	users, err := s.userRepo.GetByIDs(userIDs)
+	if err != nil {
+		return err
+	}
+	if len(users) != len(userIDs) {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// dedupeUserIDs drops duplicates, uuid.Nil and exclude, keeping the first occurrence order.
+func dedupeUserIDs(userIDs []uuid.UUID, exclude uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(userIDs))
+	out := make([]uuid.UUID, 0, len(userIDs))
+	for _, uid := range userIDs {
+		if uid == uuid.Nil || uid == exclude || seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		out = append(out, uid)
 	}
-	return s.convRepo.DeleteConversation(conversationID)
+	return out
 }
@@ -8,12 +8,18 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/convexwf/uim-go/internal/model"
 	"github.com/convexwf/uim-go/internal/repository"
+	"github.com/convexwf/uim-go/internal/store"
 )
 
 type mockConversationRepo struct {
@@ -29,6 +35,21 @@ type mockConversationRepo struct {
 	isParticipantErr     error
 	getParticipantIDs    []uuid.UUID
 	getParticipantIDsErr error
+	participant          *model.ConversationParticipant
+	// participants, when set, replaces participant with per-user rows; AddParticipant fills it.
+	participants        map[uuid.UUID]*model.ConversationParticipant
+	mentionCounts       map[uuid.UUID]int
+	announcement        string
+	listFilter          repository.ConversationListFilter
+	participantsForUser map[uuid.UUID]*model.ConversationParticipant
+	settingsUpdates     map[string]interface{}
+	maxPinOrder         int
+	unreadTotal         int
+	deleted             bool
+	purgeChecked        bool
+	groupIDs            []uuid.UUID
+	// invites backs AddParticipantByInvite.
+	invites *mockInviteRepo
 }
 
 func (m *mockConversationRepo) Create(conv *model.Conversation) error {
@@ -46,61 +67,445 @@ func (m *mockConversationRepo) GetByID(conversationID uuid.UUID) (*model.Convers
 func (m *mockConversationRepo) ListByUserID(userID uuid.UUID, limit, offset int) ([]*model.Conversation, error) {
 	return m.listConvs, m.listErr
 }
+func (m *mockConversationRepo) ListForUser(userID uuid.UUID, filter repository.ConversationListFilter, limit, offset int) ([]*model.Conversation, error) {
+	m.listFilter = filter
+	return m.listConvs, m.listErr
+}
 func (m *mockConversationRepo) AddParticipant(p *model.ConversationParticipant) error {
-	return m.addParticipantErr
+	if m.addParticipantErr != nil {
+		return m.addParticipantErr
+	}
+	if m.participants != nil {
+		m.participants[p.UserID] = p
+	}
+	return nil
+}
+func (m *mockConversationRepo) AddParticipantByInvite(p *model.ConversationParticipant, code string) (bool, error) {
+	if m.addParticipantErr != nil {
+		return false, m.addParticipantErr
+	}
+	if ok, _ := m.invites.Use(code, p.JoinedAt); !ok {
+		return false, nil
+	}
+	return true, m.AddParticipant(p)
 }
 func (m *mockConversationRepo) FindOneOnOneBetween(userID1, userID2 uuid.UUID) (*model.Conversation, error) {
 	return m.findOneOnOneConv, m.findOneOnOneErr
 }
 func (m *mockConversationRepo) IsParticipant(conversationID, userID uuid.UUID) (bool, error) {
+	if m.participants != nil {
+		return m.participants[userID] != nil, m.isParticipantErr
+	}
 	return m.isParticipant, m.isParticipantErr
 }
+func (m *mockConversationRepo) GetParticipant(conversationID, userID uuid.UUID) (*model.ConversationParticipant, error) {
+	if m.participants != nil {
+		return m.participants[userID], m.isParticipantErr
+	}
+	return m.participant, m.isParticipantErr
+}
+
 func (m *mockConversationRepo) GetParticipantUserIDs(conversationID uuid.UUID) ([]uuid.UUID, error) {
+	if m.participants != nil && m.getParticipantIDs == nil {
+		ids := make([]uuid.UUID, 0, len(m.participants))
+		for uid := range m.participants {
+			ids = append(ids, uid)
+		}
+		return ids, m.getParticipantIDsErr
+	}
 	return m.getParticipantIDs, m.getParticipantIDsErr
 }
+func (m *mockConversationRepo) GetSendPolicy(conversationID uuid.UUID) (*model.SendPolicy, error) {
+	if m.getByIDErr != nil {
+		return nil, m.getByIDErr
+	}
+	policy := &model.SendPolicy{}
+	if c := m.getByIDConv; c != nil {
+		policy.Type, policy.AdminOnlySend, policy.SlowMode = c.Type, c.AdminOnlySend, c.SlowMode
+	}
+	ps := m.participants
+	if ps == nil && m.participant != nil {
+		ps = map[uuid.UUID]*model.ConversationParticipant{m.participant.UserID: m.participant}
+	}
+	for uid, p := range ps {
+		if p.IsManager() {
+			policy.Managers = append(policy.Managers, uid)
+		}
+		if p.SilencedUntil != nil {
+			if policy.SilencedUntil == nil {
+				policy.SilencedUntil = make(map[uuid.UUID]time.Time)
+			}
+			policy.SilencedUntil[uid] = *p.SilencedUntil
+		}
+	}
+	return policy, nil
+}
+func (m *mockConversationRepo) ListParticipants(conversationID uuid.UUID) ([]*model.ConversationParticipant, error) {
+	ps := make([]*model.ConversationParticipant, 0, len(m.participants))
+	for _, p := range m.participants {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if !ps[i].JoinedAt.Equal(ps[j].JoinedAt) {
+			return ps[i].JoinedAt.Before(ps[j].JoinedAt)
+		}
+		return ps[i].UserID.String() < ps[j].UserID.String()
+	})
+	return ps, nil
+}
+func (m *mockConversationRepo) ListIDsForUser(userID uuid.UUID, types ...model.ConversationType) ([]uuid.UUID, error) {
+	return m.groupIDs, nil
+}
+func (m *mockConversationRepo) CountParticipants(conversationID uuid.UUID) (int, error) {
+	if m.participants != nil {
+		return len(m.participants), nil
+	}
+	return len(m.getParticipantIDs), nil
+}
+func (m *mockConversationRepo) RemoveParticipant(conversationID, userID uuid.UUID) (bool, error) {
+	if _, ok := m.participants[userID]; !ok {
+		return false, nil
+	}
+	delete(m.participants, userID)
+	return true, nil
+}
+func (m *mockConversationRepo) SetParticipantRole(conversationID, userID uuid.UUID, role string) (bool, error) {
+	p := m.participants[userID]
+	if p == nil || p.Role == model.ParticipantRoleOwner {
+		return false, nil
+	}
+	p.Role = role
+	return true, nil
+}
+func (m *mockConversationRepo) TransferOwnership(conversationID, fromUserID, toUserID uuid.UUID) (bool, error) {
+	from, to := m.participants[fromUserID], m.participants[toUserID]
+	if from == nil || to == nil || from.Role != model.ParticipantRoleOwner {
+		return false, nil
+	}
+	from.Role, to.Role = model.ParticipantRoleAdmin, model.ParticipantRoleOwner
+	return true, nil
+}
+func (m *mockConversationRepo) GetParticipantsForUser(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]*model.ConversationParticipant, error) {
+	return m.participantsForUser, nil
+}
 func (m *mockConversationRepo) UpdateParticipantLastRead(conversationID, userID uuid.UUID, lastReadMessageID int64) error {
 	return nil
 }
 func (m *mockConversationRepo) GetUnreadCounts(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]int, error) {
 	return nil, nil
 }
+func (m *mockConversationRepo) GetUnreadTotal(userID uuid.UUID, now time.Time) (int, error) {
+	return m.unreadTotal, nil
+}
+func (m *mockConversationRepo) GetMentionCounts(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	return m.mentionCounts, nil
+}
 func (m *mockConversationRepo) GetOtherParticipantUserIDsForOneOnOne(currentUserID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]uuid.UUID, error) {
 	return nil, nil
 }
-func (m *mockConversationRepo) DeleteConversation(conversationID uuid.UUID) error { return nil }
+func (m *mockConversationRepo) DeleteConversation(conversationID uuid.UUID) error {
+	m.deleted = true
+	return nil
+}
+func (m *mockConversationRepo) DeleteConversationIfAllCleared(conversationID uuid.UUID) (bool, error) {
+	m.purgeChecked = true
+	return false, nil
+}
+func (m *mockConversationRepo) UpdateTopic(conversationID uuid.UUID, topic string) error {
+	return nil
+}
+func (m *mockConversationRepo) UpdateName(conversationID uuid.UUID, name string) error {
+	if m.getByIDConv != nil {
+		m.getByIDConv.Name = name
+	}
+	return nil
+}
+func (m *mockConversationRepo) UpdateModeration(conversationID uuid.UUID, adminOnlySend bool, slowModeSeconds int) error {
+	if m.getByIDConv != nil {
+		m.getByIDConv.AdminOnlySend = adminOnlySend
+		m.getByIDConv.SlowMode = slowModeSeconds
+	}
+	return nil
+}
+func (m *mockConversationRepo) UpdateHistoryVisibility(conversationID uuid.UUID, visibility string, days int) error {
+	if m.getByIDConv != nil {
+		m.getByIDConv.HistoryVisibility, m.getByIDConv.HistoryDays = visibility, days
+	}
+	return nil
+}
+func (m *mockConversationRepo) SetJoinApproval(conversationID uuid.UUID, enabled bool) error {
+	return nil
+}
+func (m *mockConversationRepo) UpdateAnnouncement(conversationID uuid.UUID, text string, by *uuid.UUID, at *time.Time) error {
+	m.announcement = text
+	return nil
+}
+func (m *mockConversationRepo) SetParticipantMutedUntil(conversationID, userID uuid.UUID, until *time.Time) error {
+	return nil
+}
+func (m *mockConversationRepo) UpdateParticipantSettings(conversationID, userID uuid.UUID, updates map[string]interface{}) error {
+	m.settingsUpdates = updates
+	return nil
+}
+func (m *mockConversationRepo) MaxPinOrder(userID uuid.UUID) (int, error) {
+	return m.maxPinOrder, nil
+}
+
+// mockPinRepo keeps pins in memory.
+type mockPinRepo struct {
+	pins []*model.PinnedMessage
+}
+
+func (m *mockPinRepo) Pin(pin *model.PinnedMessage) (bool, error) {
+	for _, p := range m.pins {
+		if p.ConversationID == pin.ConversationID && p.MessageID == pin.MessageID {
+			return false, nil
+		}
+	}
+	m.pins = append(m.pins, pin)
+	return true, nil
+}
+func (m *mockPinRepo) Unpin(conversationID uuid.UUID, messageID int64) (bool, error) {
+	for i, p := range m.pins {
+		if p.ConversationID == conversationID && p.MessageID == messageID {
+			m.pins = append(m.pins[:i], m.pins[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockPinRepo) ListByConversation(conversationID uuid.UUID) ([]*model.PinnedMessage, error) {
+	var out []*model.PinnedMessage
+	for _, p := range m.pins {
+		if p.ConversationID == conversationID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+func (m *mockPinRepo) Count(conversationID uuid.UUID) (int64, error) {
+	pins, _ := m.ListByConversation(conversationID)
+	return int64(len(pins)), nil
+}
+
+// mockInviteRepo keeps invites and join requests in memory.
+type mockInviteRepo struct {
+	invites  map[string]*model.ConversationInvite
+	requests []*model.ConversationJoinRequest
+}
+
+func (m *mockInviteRepo) Create(invite *model.ConversationInvite) error {
+	if m.invites == nil {
+		m.invites = make(map[string]*model.ConversationInvite)
+	}
+	m.invites[invite.Code] = invite
+	return nil
+}
+func (m *mockInviteRepo) GetByCode(code string) (*model.ConversationInvite, error) {
+	return m.invites[code], nil
+}
+func (m *mockInviteRepo) ListByConversation(conversationID uuid.UUID) ([]*model.ConversationInvite, error) {
+	var out []*model.ConversationInvite
+	for _, inv := range m.invites {
+		if inv.ConversationID == conversationID {
+			out = append(out, inv)
+		}
+	}
+	return out, nil
+}
+func (m *mockInviteRepo) Revoke(conversationID uuid.UUID, code string, at time.Time) (bool, error) {
+	inv := m.invites[code]
+	if inv == nil || inv.ConversationID != conversationID || inv.RevokedAt != nil {
+		return false, nil
+	}
+	inv.RevokedAt = &at
+	return true, nil
+}
+func (m *mockInviteRepo) Use(code string, now time.Time) (bool, error) {
+	inv := m.invites[code]
+	if inv == nil || !inv.Usable(now) {
+		return false, nil
+	}
+	inv.Uses++
+	return true, nil
+}
+func (m *mockInviteRepo) CreateJoinRequest(req *model.ConversationJoinRequest) (*model.ConversationJoinRequest, bool, error) {
+	for _, r := range m.requests {
+		if r.ConversationID == req.ConversationID && r.UserID == req.UserID && r.Status == model.JoinRequestPending {
+			return r, false, nil
+		}
+	}
+	if ok, _ := m.Use(req.InviteCode, req.CreatedAt); !ok {
+		return nil, false, nil
+	}
+	req.RequestID = int64(len(m.requests) + 1)
+	m.requests = append(m.requests, req)
+	return req, true, nil
+}
+func (m *mockInviteRepo) GetJoinRequest(conversationID uuid.UUID, requestID int64) (*model.ConversationJoinRequest, error) {
+	for _, r := range m.requests {
+		if r.ConversationID == conversationID && r.RequestID == requestID {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockInviteRepo) ListPendingJoinRequests(conversationID uuid.UUID) ([]*model.ConversationJoinRequest, error) {
+	var out []*model.ConversationJoinRequest
+	for _, r := range m.requests {
+		if r.ConversationID == conversationID && r.Status == model.JoinRequestPending {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+func (m *mockInviteRepo) DecideJoinRequest(requestID int64, status model.JoinRequestStatus, by uuid.UUID, at time.Time) (bool, error) {
+	for _, r := range m.requests {
+		if r.RequestID == requestID && r.Status == model.JoinRequestPending {
+			r.Status = status
+			r.DecidedBy = &by
+			r.DecidedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-type mockMessageRepoForConv struct{}
+// mockEventNotifier records conversation events.
+type mockEventNotifier struct {
+	events []string
+	data   []map[string]interface{}
+}
+
+func (m *mockEventNotifier) NotifyConversationEvent(conversationID, actorID uuid.UUID, event string, data map[string]interface{}) {
+	m.events = append(m.events, event)
+	m.data = append(m.data, data)
+}
+
+type mockMessageRepoForConv struct {
+	byID         map[int64]*model.Message
+	lastMessages map[uuid.UUID]*model.Message
+	created      []*model.Message
+	lastSentAt   map[uuid.UUID]time.Time
+}
 
-func (m *mockMessageRepoForConv) Create(msg *model.Message) error { return nil }
-func (m *mockMessageRepoForConv) ListByConversationID(conversationID uuid.UUID, limit, offset int, beforeID *int64) ([]*model.Message, error) {
+func (m *mockMessageRepoForConv) Create(msg *model.Message) error {
+	m.created = append(m.created, msg)
+	return nil
+}
+func (m *mockMessageRepoForConv) ListByConversationID(conversationID uuid.UUID, limit, offset int, beforeID, afterID *int64) ([]*model.Message, error) {
+	return nil, nil
+}
+func (m *mockMessageRepoForConv) ListAfter(conversationID uuid.UUID, afterID int64, limit int) ([]*model.Message, error) {
 	return nil, nil
 }
-func (m *mockMessageRepoForConv) GetByID(messageID int64) (*model.Message, error) { return nil, nil }
+func (m *mockMessageRepoForConv) GetByID(messageID int64) (*model.Message, error) {
+	return m.byID[messageID], nil
+}
 func (m *mockMessageRepoForConv) GetLastMessagesByConversationIDs(conversationIDs []uuid.UUID) (map[uuid.UUID]*model.Message, error) {
+	return m.lastMessages, nil
+}
+func (m *mockMessageRepoForConv) LastIDBefore(conversationID uuid.UUID, t time.Time) (int64, error) {
+	var id int64
+	for _, msg := range m.byID {
+		if msg.CreatedAt.Before(t) && msg.MessageID > id {
+			id = msg.MessageID
+		}
+	}
+	return id, nil
+}
+func (m *mockMessageRepoForConv) LastSentAt(conversationID, senderID uuid.UUID) (*time.Time, error) {
+	if t, ok := m.lastSentAt[senderID]; ok {
+		return &t, nil
+	}
 	return nil, nil
 }
 
 type mockUserRepo struct {
-	getByIDUser *model.User
-	getByIDErr  error
+	getByIDUser   *model.User
+	getByIDErr    error
+	getByIDsUsers []*model.User
 }
 
 func (m *mockUserRepo) Create(user *model.User) error { return nil }
 func (m *mockUserRepo) GetByID(userID uuid.UUID) (*model.User, error) {
 	return m.getByIDUser, m.getByIDErr
 }
-func (m *mockUserRepo) GetByIDs(userIDs []uuid.UUID) ([]*model.User, error) { return nil, nil }
-func (m *mockUserRepo) GetByUsername(username string) (*model.User, error)  { return nil, nil }
-func (m *mockUserRepo) GetByEmail(email string) (*model.User, error)        { return nil, nil }
-func (m *mockUserRepo) Update(user *model.User) error                       { return nil }
-func (m *mockUserRepo) Delete(userID uuid.UUID) error                       { return nil }
+func (m *mockUserRepo) GetByIDs(userIDs []uuid.UUID) ([]*model.User, error) {
+	var users []*model.User
+	for _, u := range m.getByIDsUsers {
+		if slices.Contains(userIDs, u.UserID) {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+func (m *mockUserRepo) GetByUsername(username string) (*model.User, error) { return nil, nil }
+func (m *mockUserRepo) GetByEmail(email string) (*model.User, error)       { return nil, nil }
+func (m *mockUserRepo) Update(user *model.User) error                      { return nil }
+func (m *mockUserRepo) Delete(userID uuid.UUID) error                      { return nil }
+
+// groupFixture is a group with an owner, an admin and two plain members (member and other), who
+// joined in that order one minute apart, and a conversation service over in-memory mocks.
+type groupFixture struct {
+	convID                              uuid.UUID
+	ownerID, adminID, memberID, otherID uuid.UUID
+	convRepo                            *mockConversationRepo
+	userRepo                            *mockUserRepo
+	msgRepo                             *mockMessageRepoForConv
+	inviteRepo                          *mockInviteRepo
+	events                              *mockEventNotifier
+	notifier                            *mockNotifier
+	svc                                 ConversationService
+}
+
+func newGroupFixture() *groupFixture {
+	f := &groupFixture{
+		convID:     uuid.MustParse("c0000000-0000-0000-0000-000000000001"),
+		ownerID:    uuid.MustParse("b0000000-0000-0000-0000-000000000001"),
+		adminID:    uuid.MustParse("b0000000-0000-0000-0000-000000000002"),
+		memberID:   uuid.MustParse("b0000000-0000-0000-0000-000000000003"),
+		otherID:    uuid.MustParse("b0000000-0000-0000-0000-000000000004"),
+		msgRepo:    &mockMessageRepoForConv{},
+		inviteRepo: &mockInviteRepo{},
+		events:     &mockEventNotifier{},
+		notifier:   &mockNotifier{},
+	}
+	f.convRepo = &mockConversationRepo{
+		getByIDConv:  &model.Conversation{ConversationID: f.convID, Type: model.ConversationTypeGroup},
+		participants: map[uuid.UUID]*model.ConversationParticipant{},
+		invites:      f.inviteRepo,
+	}
+	f.userRepo = &mockUserRepo{}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, u := range []struct {
+		id       uuid.UUID
+		username string
+		role     string
+	}{
+		{f.ownerID, "alice", model.ParticipantRoleOwner},
+		{f.adminID, "Bob", model.ParticipantRoleAdmin},
+		{f.memberID, "carol", model.ParticipantRoleMember},
+		{f.otherID, "dave", model.ParticipantRoleMember},
+	} {
+		f.convRepo.participants[u.id] = &model.ConversationParticipant{ConversationID: f.convID, UserID: u.id, Role: u.role, JoinedAt: start.Add(time.Duration(i) * time.Minute)}
+		f.userRepo.getByIDsUsers = append(f.userRepo.getByIDsUsers, &model.User{UserID: u.id, Username: u.username})
+	}
+	f.svc = f.newService(nil, ConversationOptions{})
+	return f
+}
+
+// newService creates another conversation service over the fixture's mocks.
+func (f *groupFixture) newService(limiter store.RateLimiter, opts ConversationOptions) ConversationService {
+	return NewConversationService(f.convRepo, f.userRepo, f.msgRepo, &mockPinRepo{}, f.inviteRepo, limiter, f.events, f.notifier, opts)
+}
 
 func TestConversationService_CreateOneOnOne_SameUser(t *testing.T) {
 	uid := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
 	convRepo := &mockConversationRepo{}
 	userRepo := &mockUserRepo{}
 	msgRepo := &mockMessageRepoForConv{}
-	svc := NewConversationService(convRepo, userRepo, msgRepo)
+	svc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
 	_, err := svc.CreateOneOnOne(uid, uid)
 	if err == nil {
 		t.Fatal("expected error for same user")
@@ -116,7 +521,7 @@ func TestConversationService_CreateOneOnOne_OtherUserNotFound(t *testing.T) {
 	convRepo := &mockConversationRepo{}
 	userRepo := &mockUserRepo{getByIDErr: errors.New("not found")}
 	msgRepo := &mockMessageRepoForConv{}
-	svc := NewConversationService(convRepo, userRepo, msgRepo)
+	svc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
 	_, err := svc.CreateOneOnOne(creator, other)
 	if err == nil {
 		t.Fatal("expected error when other user not found")
@@ -136,7 +541,7 @@ func TestConversationService_CreateOneOnOne_Existing(t *testing.T) {
 	}
 	userRepo := &mockUserRepo{getByIDUser: &model.User{UserID: other}}
 	msgRepo := &mockMessageRepoForConv{}
-	svc := NewConversationService(convRepo, userRepo, msgRepo)
+	svc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
 	conv, err := svc.CreateOneOnOne(creator, other)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -146,6 +551,34 @@ func TestConversationService_CreateOneOnOne_Existing(t *testing.T) {
 	}
 }
 
+func TestConversationService_CreateOneOnOne_RevivesDeleted(t *testing.T) {
+	creator := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	other := uuid.MustParse("b0000000-0000-0000-0000-000000000002")
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	hiddenUntil, clearedUpTo := int64(9), int64(9)
+	convRepo := &mockConversationRepo{
+		findOneOnOneConv: &model.Conversation{ConversationID: convID, Type: model.ConversationTypeOneOnOne},
+		participant: &model.ConversationParticipant{
+			ConversationID: convID, UserID: creator, HiddenUntilMessageID: &hiddenUntil, ClearedUpToMessageID: &clearedUpTo,
+		},
+	}
+	userRepo := &mockUserRepo{getByIDUser: &model.User{UserID: other}}
+	svc := NewConversationService(convRepo, userRepo, &mockMessageRepoForConv{}, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	conv, err := svc.CreateOneOnOne(creator, other)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conv.ConversationID != convID {
+		t.Errorf("expected the existing conversation, got %v", conv.ConversationID)
+	}
+	if v, ok := convRepo.settingsUpdates["hidden_until_message_id"]; !ok || v != nil {
+		t.Errorf("expected the conversation to be unhidden, got %+v", convRepo.settingsUpdates)
+	}
+	if _, ok := convRepo.settingsUpdates["cleared_up_to_message_id"]; ok {
+		t.Error("reviving must not restore cleared history")
+	}
+}
+
 func TestConversationService_CreateOneOnOne_New(t *testing.T) {
 	creator := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
 	other := uuid.MustParse("b0000000-0000-0000-0000-000000000002")
@@ -154,7 +587,7 @@ func TestConversationService_CreateOneOnOne_New(t *testing.T) {
 	}
 	userRepo := &mockUserRepo{getByIDUser: &model.User{UserID: other}}
 	msgRepo := &mockMessageRepoForConv{}
-	svc := NewConversationService(convRepo, userRepo, msgRepo)
+	svc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
 	conv, err := svc.CreateOneOnOne(creator, other)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -173,7 +606,7 @@ func TestConversationService_GetByID_NotParticipant(t *testing.T) {
 	convRepo := &mockConversationRepo{isParticipant: false}
 	userRepo := &mockUserRepo{}
 	msgRepo := &mockMessageRepoForConv{}
-	svc := NewConversationService(convRepo, userRepo, msgRepo)
+	svc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
 	_, err := svc.GetByID(convID, userID)
 	if err == nil {
 		t.Fatal("expected error when not participant")
@@ -190,7 +623,7 @@ func TestConversationService_GetByID_Success(t *testing.T) {
 	convRepo := &mockConversationRepo{isParticipant: true, getByIDConv: expected}
 	userRepo := &mockUserRepo{}
 	msgRepo := &mockMessageRepoForConv{}
-	svc := NewConversationService(convRepo, userRepo, msgRepo)
+	svc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
 	conv, err := svc.GetByID(convID, userID)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -208,7 +641,7 @@ func TestConversationService_ListByUserID(t *testing.T) {
 	convRepo := &mockConversationRepo{listConvs: list}
 	userRepo := &mockUserRepo{}
 	msgRepo := &mockMessageRepoForConv{}
-	svc := NewConversationService(convRepo, userRepo, msgRepo)
+	svc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
 	convs, err := svc.ListByUserID(userID, 20, 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -224,7 +657,7 @@ func TestConversationService_MarkRead_NotParticipant(t *testing.T) {
 	convRepo := &mockConversationRepo{isParticipant: false}
 	userRepo := &mockUserRepo{}
 	msgRepo := &mockMessageRepoForConv{}
-	svc := NewConversationService(convRepo, userRepo, msgRepo)
+	svc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
 	err := svc.MarkRead(convID, userID, 10)
 	if err == nil {
 		t.Fatal("expected error when not participant")
@@ -240,7 +673,7 @@ func TestConversationService_MarkRead_Success(t *testing.T) {
 	convRepo := &mockConversationRepo{isParticipant: true}
 	userRepo := &mockUserRepo{}
 	msgRepo := &mockMessageRepoForConv{}
-	svc := NewConversationService(convRepo, userRepo, msgRepo)
+	svc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
 	err := svc.MarkRead(convID, userID, 10)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -251,3 +684,1138 @@ func TestConversationService_MarkRead_Success(t *testing.T) {
 var _ repository.ConversationRepository = (*mockConversationRepo)(nil)
 var _ repository.UserRepository = (*mockUserRepo)(nil)
 var _ repository.MessageRepository = (*mockMessageRepoForConv)(nil)
+
+func TestConversationService_DeleteConversation_PerUser(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	convRepo := &mockConversationRepo{
+		participant: &model.ConversationParticipant{ConversationID: convID, UserID: userID, LastReadMessageID: 3},
+	}
+	msgRepo := &mockMessageRepoForConv{lastMessages: map[uuid.UUID]*model.Message{convID: {MessageID: 10, ConversationID: convID}}}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	if err := svc.DeleteConversation(convID, userID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if convRepo.deleted {
+		t.Error("per-user delete must not purge the conversation")
+	}
+	if !convRepo.purgeChecked {
+		t.Error("expected a purge check after the delete")
+	}
+	for _, col := range []string{"cleared_up_to_message_id", "hidden_until_message_id", "last_read_message_id"} {
+		if convRepo.settingsUpdates[col] != int64(10) {
+			t.Errorf("%s = %v, want 10", col, convRepo.settingsUpdates[col])
+		}
+	}
+
+	convRepo.participant = nil
+	if err := svc.DeleteConversation(convID, userID); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("expected ErrNotParticipant, got %v", err)
+	}
+}
+
+func TestConversationService_ClearHistory_UpTo(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	cleared := int64(8)
+	convRepo := &mockConversationRepo{
+		participant: &model.ConversationParticipant{ConversationID: convID, UserID: userID, LastReadMessageID: 6, ClearedUpToMessageID: &cleared},
+	}
+	msgRepo := &mockMessageRepoForConv{lastMessages: map[uuid.UUID]*model.Message{convID: {MessageID: 10, ConversationID: convID}}}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+
+	// Clearing less than already cleared changes nothing.
+	if err := svc.ClearHistory(convID, userID, 5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(convRepo.settingsUpdates) != 0 {
+		t.Errorf("expected no updates, got %+v", convRepo.settingsUpdates)
+	}
+	if err := svc.ClearHistory(convID, userID, 9); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if convRepo.settingsUpdates["cleared_up_to_message_id"] != int64(9) || convRepo.settingsUpdates["last_read_message_id"] != int64(9) {
+		t.Errorf("expected history cleared and read up to 9, got %+v", convRepo.settingsUpdates)
+	}
+	if _, ok := convRepo.settingsUpdates["hidden_until_message_id"]; ok {
+		t.Error("clearing history must not hide the conversation")
+	}
+	if err := svc.ClearHistory(convID, userID, -1); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestConversationService_PurgeConversation_Permissions(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	convRepo := &mockConversationRepo{
+		getByIDConv: &model.Conversation{ConversationID: convID, Type: model.ConversationTypeGroup},
+		participant: &model.ConversationParticipant{ConversationID: convID, UserID: userID, Role: model.ParticipantRoleMember},
+	}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, &mockMessageRepoForConv{}, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	if err := svc.PurgeConversation(convID, userID); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("member: expected ErrPermissionDenied, got %v", err)
+	}
+	if convRepo.deleted {
+		t.Fatal("member must not purge the group")
+	}
+	convRepo.participant.Role = model.ParticipantRoleAdmin
+	if err := svc.PurgeConversation(convID, userID); err != nil {
+		t.Fatalf("admin: unexpected error: %v", err)
+	}
+	if !convRepo.deleted {
+		t.Error("expected the group to be purged")
+	}
+}
+
+func TestConversationService_CreateGroup_Validation(t *testing.T) {
+	creatorID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	svc := NewConversationService(&mockConversationRepo{}, &mockUserRepo{}, &mockMessageRepoForConv{}, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	if _, err := svc.CreateGroup(creatorID, "   ", nil); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("empty name: expected ErrInvalidInput, got %v", err)
+	}
+	tooMany := make([]uuid.UUID, MaxGroupMembers)
+	for i := range tooMany {
+		tooMany[i] = uuid.New()
+	}
+	if _, err := svc.CreateGroup(creatorID, "team", tooMany); !errors.Is(err, ErrInvalidGroupSize) {
+		t.Errorf("too many members: expected ErrInvalidGroupSize, got %v", err)
+	}
+	// mockUserRepo.GetByIDs finds nobody.
+	if _, err := svc.CreateGroup(creatorID, "team", []uuid.UUID{uuid.New()}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown member: expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestConversationService_AddMembers_Permissions(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	convRepo := &mockConversationRepo{
+		getByIDConv: &model.Conversation{ConversationID: convID, Type: model.ConversationTypeGroup},
+		participant: &model.ConversationParticipant{ConversationID: convID, UserID: userID, Role: model.ParticipantRoleMember},
+	}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, &mockMessageRepoForConv{}, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	if _, err := svc.AddMembers(convID, userID, []uuid.UUID{uuid.New()}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("member adding: expected ErrPermissionDenied, got %v", err)
+	}
+
+	convRepo.participant.Role = model.ParticipantRoleOwner
+	convRepo.getByIDConv.Type = model.ConversationTypeOneOnOne
+	if _, err := svc.AddMembers(convID, userID, []uuid.UUID{uuid.New()}); !errors.Is(err, ErrGroupOnly) {
+		t.Errorf("one-on-one: expected ErrGroupOnly, got %v", err)
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	got := parseMentions("@alice thanks, cc @Bob and @bob. mail me at carol@example.com (@all)")
+	want := []string{"alice", "Bob", "all"}
+	if len(got) != len(want) {
+		t.Fatalf("parseMentions = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseMentions[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if parseMentions("no mentions here") != nil {
+		t.Error("expected nil without mentions")
+	}
+}
+
+func TestConversationService_ResolveMentions_Users(t *testing.T) {
+	f := newGroupFixture()
+	m, err := f.svc.ResolveMentions(f.convID, f.memberID, "@bob @carol @nobody please review")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m == nil || len(m.UserIDs) != 2 || m.UserIDs[0] != f.adminID || m.UserIDs[1] != f.memberID {
+		t.Fatalf("UserIDs = %+v, want bob and the sender", m)
+	}
+	if len(m.Notified) != 1 || m.Notified[0] != f.adminID {
+		t.Errorf("Notified = %v, want only bob", m.Notified)
+	}
+	if m.All {
+		t.Error("All should be false")
+	}
+
+	f.convRepo.getByIDConv.Type = model.ConversationTypeOneOnOne
+	if m, err := f.svc.ResolveMentions(f.convID, f.memberID, "@bob"); m != nil || err != nil {
+		t.Errorf("one-on-one: got %+v, %v; want nil, nil", m, err)
+	}
+}
+
+func TestConversationService_ResolveMentions_AllRequiresAdmin(t *testing.T) {
+	f := newGroupFixture()
+	if _, err := f.svc.ResolveMentions(f.convID, f.memberID, "@all standup"); !errors.Is(err, ErrMentionAllNotAllowed) {
+		t.Fatalf("expected ErrMentionAllNotAllowed, got %v", err)
+	}
+
+	m, err := f.svc.ResolveMentions(f.convID, f.adminID, "@all standup")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !m.All || len(m.Notified) != 3 {
+		t.Errorf("got %+v, want @all notifying the three other participants", m)
+	}
+}
+
+func TestConversationService_ResolveMentions_AllRateLimited(t *testing.T) {
+	f := newGroupFixture()
+	svc := f.newService(store.NewMemoryRateLimiter(), ConversationOptions{
+		MentionAllLimit: store.RateLimit{PerHour: 1},
+	})
+	if _, err := svc.ResolveMentions(f.convID, f.ownerID, "@all release is out"); err != nil {
+		t.Fatalf("first @all: unexpected error: %v", err)
+	}
+	if _, err := svc.ResolveMentions(f.convID, f.ownerID, "@all and again"); !errors.Is(err, ErrMentionRateLimited) {
+		t.Errorf("second @all: expected ErrMentionRateLimited, got %v", err)
+	}
+	// Plain mentions are not limited.
+	if _, err := svc.ResolveMentions(f.convID, f.ownerID, "@bob thanks"); err != nil {
+		t.Errorf("@bob after the limit: unexpected error: %v", err)
+	}
+}
+
+func TestConversationService_ListByUserIDWithMeta_MentionCount(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	convRepo := &mockConversationRepo{
+		listConvs:     []*model.Conversation{{ConversationID: convID, Type: model.ConversationTypeGroup}},
+		mentionCounts: map[uuid.UUID]int{convID: 2},
+	}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, &mockMessageRepoForConv{}, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	metas, err := svc.ListByUserIDWithMeta(userID, 20, 0, ConversationListFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metas) != 1 || metas[0].MentionCount != 2 {
+		t.Errorf("got %+v, want mention count 2", metas)
+	}
+}
+
+func TestConversationService_ListByUserIDWithMeta_Settings(t *testing.T) {
+	mutedID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	expiredID := uuid.MustParse("c0000000-0000-0000-0000-000000000002")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	convRepo := &mockConversationRepo{
+		listConvs: []*model.Conversation{{ConversationID: mutedID}, {ConversationID: expiredID}},
+		participantsForUser: map[uuid.UUID]*model.ConversationParticipant{
+			mutedID:   {ConversationID: mutedID, UserID: userID, MutedUntil: &future, PinOrder: 1},
+			expiredID: {ConversationID: expiredID, UserID: userID, MutedUntil: &past},
+		},
+	}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, &mockMessageRepoForConv{}, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	filter := ConversationListFilter{IncludeArchived: true}
+	metas, err := svc.ListByUserIDWithMeta(userID, 20, 0, filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if convRepo.listFilter != filter {
+		t.Errorf("filter = %+v, want %+v", convRepo.listFilter, filter)
+	}
+	if len(metas) != 2 || !metas[0].Muted || metas[1].Muted {
+		t.Fatalf("muted flags wrong: %+v", metas)
+	}
+	if metas[0].Participant == nil || metas[0].Participant.PinOrder != 1 {
+		t.Errorf("participant settings missing: %+v", metas[0].Participant)
+	}
+}
+
+func TestConversationService_ListByUserIDWithMeta_HiddenHistory(t *testing.T) {
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	cleared := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	joined := uuid.MustParse("c0000000-0000-0000-0000-000000000002")
+	days := uuid.MustParse("c0000000-0000-0000-0000-000000000003")
+	joinedAt := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	clearedUpTo := int64(5)
+	convRepo := &mockConversationRepo{
+		listConvs: []*model.Conversation{
+			{ConversationID: cleared, Type: model.ConversationTypeGroup},
+			{ConversationID: joined, Type: model.ConversationTypeGroup, HistoryVisibility: model.HistoryVisibilityJoined},
+			{ConversationID: days, Type: model.ConversationTypeGroup, HistoryVisibility: model.HistoryVisibilityDays, HistoryDays: 7},
+		},
+		participantsForUser: map[uuid.UUID]*model.ConversationParticipant{
+			cleared: {ConversationID: cleared, UserID: userID, JoinedAt: joinedAt, ClearedUpToMessageID: &clearedUpTo},
+			joined:  {ConversationID: joined, UserID: userID, JoinedAt: joinedAt},
+			days:    {ConversationID: days, UserID: userID, JoinedAt: joinedAt},
+		},
+	}
+	// Every last message was sent three days before the user joined.
+	sent := joinedAt.AddDate(0, 0, -3)
+	msgRepo := &mockMessageRepoForConv{lastMessages: map[uuid.UUID]*model.Message{
+		cleared: {MessageID: 5, ConversationID: cleared, CreatedAt: sent},
+		joined:  {MessageID: 6, ConversationID: joined, CreatedAt: sent},
+		days:    {MessageID: 7, ConversationID: days, CreatedAt: sent},
+	}}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	metas, err := svc.ListByUserIDWithMeta(userID, 20, 0, ConversationListFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metas) != 3 {
+		t.Fatalf("got %d conversations, want 3", len(metas))
+	}
+	if metas[0].LastMessage != nil {
+		t.Errorf("cleared: last message %d should be hidden", metas[0].LastMessage.MessageID)
+	}
+	if metas[1].LastMessage != nil {
+		t.Errorf("joined visibility: last message %d from before joining should be hidden", metas[1].LastMessage.MessageID)
+	}
+	if metas[2].LastMessage == nil {
+		t.Errorf("7 days visibility: last message from 3 days before joining should be shown")
+	}
+}
+
+func TestConversationService_UpdateSettings(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	yes := true
+	newFixture := func() (*mockConversationRepo, ConversationService) {
+		convRepo := &mockConversationRepo{
+			participant: &model.ConversationParticipant{ConversationID: convID, UserID: userID},
+			maxPinOrder: 3,
+		}
+		msgRepo := &mockMessageRepoForConv{lastMessages: map[uuid.UUID]*model.Message{convID: {MessageID: 42, ConversationID: convID}}}
+		return convRepo, NewConversationService(convRepo, &mockUserRepo{}, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	}
+
+	convRepo, svc := newFixture()
+	p, err := svc.UpdateSettings(convID, userID, ConversationSettingsUpdate{Pinned: &yes, Archived: &yes, Hidden: &yes})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.PinOrder != 4 || convRepo.settingsUpdates["pin_order"] != 4 {
+		t.Errorf("pin order = %d, want 4 (below the other pins)", p.PinOrder)
+	}
+	if !p.Archived || convRepo.settingsUpdates["archived"] != true {
+		t.Errorf("archived not stored: %+v", convRepo.settingsUpdates)
+	}
+	if p.HiddenUntilMessageID == nil || *p.HiddenUntilMessageID != 42 {
+		t.Errorf("hidden until = %v, want the last message 42", p.HiddenUntilMessageID)
+	}
+
+	_, svc = newFixture()
+	past := time.Now().Add(-time.Minute)
+	if _, err := svc.UpdateSettings(convID, userID, ConversationSettingsUpdate{MutedUntil: &past}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("mute in the past: expected ErrInvalidInput, got %v", err)
+	}
+	convRepo, svc = newFixture()
+	future := time.Now().Add(time.Hour)
+	if _, err := svc.UpdateSettings(convID, userID, ConversationSettingsUpdate{MutedUntil: &future, Unmute: true}); err != nil {
+		t.Fatalf("unmute: unexpected error: %v", err)
+	}
+	if v, ok := convRepo.settingsUpdates["muted_until"]; !ok || v != nil {
+		t.Errorf("unmute should clear muted_until, got %+v", convRepo.settingsUpdates)
+	}
+
+	convRepo, svc = newFixture()
+	convRepo.participant = nil
+	if _, err := svc.UpdateSettings(convID, userID, ConversationSettingsUpdate{Archived: &yes}); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("non-participant: expected ErrNotParticipant, got %v", err)
+	}
+}
+
+func TestConversationService_PinMessage(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	convRepo := &mockConversationRepo{
+		getByIDConv: &model.Conversation{ConversationID: convID, Type: model.ConversationTypeGroup},
+		participant: &model.ConversationParticipant{ConversationID: convID, UserID: userID, Role: model.ParticipantRoleMember},
+	}
+	msgRepo := &mockMessageRepoForConv{byID: map[int64]*model.Message{
+		1: {MessageID: 1, ConversationID: convID},
+		2: {MessageID: 2, ConversationID: convID},
+		3: {MessageID: 3, ConversationID: uuid.New()},
+	}}
+	pinRepo := &mockPinRepo{}
+	events := &mockEventNotifier{}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, msgRepo, pinRepo, &mockInviteRepo{}, nil, events, nil, ConversationOptions{MaxPins: 1})
+
+	if _, err := svc.PinMessage(convID, userID, 1); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("member pinning: expected ErrPermissionDenied, got %v", err)
+	}
+	convRepo.participant.Role = model.ParticipantRoleAdmin
+	if _, err := svc.PinMessage(convID, userID, 3); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("message of another conversation: expected ErrMessageNotFound, got %v", err)
+	}
+	pin, err := svc.PinMessage(convID, userID, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pin.PinnedBy != userID || pin.Message == nil {
+		t.Errorf("pin = %+v", pin)
+	}
+	if _, err := svc.PinMessage(convID, userID, 1); err != nil {
+		t.Errorf("re-pinning at the limit: unexpected error: %v", err)
+	}
+	if _, err := svc.PinMessage(convID, userID, 2); !errors.Is(err, ErrPinLimit) {
+		t.Errorf("over the limit: expected ErrPinLimit, got %v", err)
+	}
+	if len(events.events) != 1 || events.events[0] != model.EventPinChanged {
+		t.Errorf("events = %v, want one pin_changed", events.events)
+	}
+
+	if err := svc.UnpinMessage(convID, userID, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.UnpinMessage(convID, userID, 1); !errors.Is(err, ErrNotPinned) {
+		t.Errorf("unpinning twice: expected ErrNotPinned, got %v", err)
+	}
+	if len(events.events) != 2 || events.data[1]["action"] != "unpinned" {
+		t.Errorf("events = %v, want pinned then unpinned", events.data)
+	}
+}
+
+func TestConversationService_SetAnnouncementAndDetail(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	conv := &model.Conversation{ConversationID: convID, Type: model.ConversationTypeGroup}
+	convRepo := &mockConversationRepo{
+		getByIDConv:   conv,
+		isParticipant: true,
+		participant:   &model.ConversationParticipant{ConversationID: convID, UserID: userID, Role: model.ParticipantRoleOwner},
+	}
+	pinRepo := &mockPinRepo{pins: []*model.PinnedMessage{
+		{ConversationID: convID, MessageID: 1, Message: &model.Message{MessageID: 1}},
+		{ConversationID: convID, MessageID: 2}, // message deleted since
+	}}
+	events := &mockEventNotifier{}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, &mockMessageRepoForConv{}, pinRepo, &mockInviteRepo{}, nil, events, nil, ConversationOptions{})
+
+	a, err := svc.SetAnnouncement(convID, userID, "  Release on Friday  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Text != "Release on Friday" || a.AuthorID != userID || convRepo.announcement != a.Text {
+		t.Errorf("announcement = %+v, stored %q", a, convRepo.announcement)
+	}
+	if len(events.events) != 1 || events.events[0] != model.EventAnnouncementChanged {
+		t.Errorf("events = %v, want announcement_changed", events.events)
+	}
+	if _, err := svc.SetAnnouncement(convID, userID, strings.Repeat("x", maxAnnouncementLength+1)); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("too long: expected ErrInvalidInput, got %v", err)
+	}
+
+	// The repository row now carries the announcement; a member who joined later reads it.
+	now := time.Now()
+	conv.Announcement, conv.AnnouncementBy, conv.AnnouncementAt = a.Text, &userID, &now
+	d, err := svc.GetDetail(convID, uuid.New())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Announcement == nil || d.Announcement.Text != "Release on Friday" {
+		t.Errorf("detail announcement = %+v", d.Announcement)
+	}
+	if len(d.Pins) != 1 || d.Pins[0].MessageID != 1 {
+		t.Errorf("detail pins = %+v, want only the pin with a message", d.Pins)
+	}
+}
+
+func TestConversationService_CreateInvite(t *testing.T) {
+	f := newGroupFixture()
+	member := f.memberID
+	if _, err := f.svc.CreateInvite(f.convID, member, InviteOptions{}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("member: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := f.svc.CreateInvite(f.convID, f.adminID, InviteOptions{ExpiresIn: 60 * 24 * time.Hour}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("too long expiry: expected ErrInvalidInput, got %v", err)
+	}
+	inv, err := f.svc.CreateInvite(f.convID, f.adminID, InviteOptions{ExpiresIn: time.Hour, MaxUses: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(inv.Code) < 16 || inv.ExpiresAt == nil || inv.MaxUses != 5 {
+		t.Errorf("unexpected invite %+v", inv)
+	}
+	if err := f.svc.RevokeInvite(f.convID, f.ownerID, inv.Code); err != nil {
+		t.Fatalf("revoke: unexpected error: %v", err)
+	}
+	if err := f.svc.RevokeInvite(f.convID, f.ownerID, inv.Code); !errors.Is(err, ErrInviteNotFound) {
+		t.Errorf("second revoke: expected ErrInviteNotFound, got %v", err)
+	}
+	if _, err := f.svc.JoinByInvite(inv.Code, uuid.New()); !errors.Is(err, ErrInviteUnusable) {
+		t.Errorf("revoked invite: expected ErrInviteUnusable, got %v", err)
+	}
+}
+
+func TestConversationService_JoinByInvite(t *testing.T) {
+	f := newGroupFixture()
+	inv, err := f.svc.CreateInvite(f.convID, f.ownerID, InviteOptions{MaxUses: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	joiner := uuid.New()
+	res, err := f.svc.JoinByInvite(inv.Code, joiner)
+	if err != nil {
+		t.Fatalf("join: unexpected error: %v", err)
+	}
+	if !res.Joined || f.convRepo.participants[joiner] == nil {
+		t.Fatalf("expected the user to join, got %+v", res)
+	}
+	if f.convRepo.participants[joiner].Role != model.ParticipantRoleMember {
+		t.Errorf("role = %q, want member", f.convRepo.participants[joiner].Role)
+	}
+	if len(f.msgRepo.created) != 1 || f.msgRepo.created[0].MessageType != model.MessageTypeSystem {
+		t.Fatalf("expected one system message, got %+v", f.msgRepo.created)
+	}
+	if meta := *f.msgRepo.created[0].Metadata; !strings.Contains(meta, `"action":"member_joined"`) || !strings.Contains(meta, joiner.String()) {
+		t.Errorf("unexpected system message metadata %s", meta)
+	}
+	if !f.notifier.called {
+		t.Error("expected the system message to be fanned out")
+	}
+	if len(f.events.events) != 1 || f.events.events[0] != model.EventMemberJoined {
+		t.Errorf("events = %v, want [member_joined]", f.events.events)
+	}
+
+	// Joining again is a no-op; the used-up invite fails for others.
+	if res, err := f.svc.JoinByInvite(inv.Code, joiner); err != nil || !res.Joined {
+		t.Errorf("rejoin: got %+v, %v", res, err)
+	}
+	if _, err := f.svc.JoinByInvite(inv.Code, uuid.New()); !errors.Is(err, ErrInviteUnusable) {
+		t.Errorf("used-up invite: expected ErrInviteUnusable, got %v", err)
+	}
+	if _, err := f.svc.JoinByInvite("nope", uuid.New()); !errors.Is(err, ErrInviteNotFound) {
+		t.Errorf("unknown code: expected ErrInviteNotFound, got %v", err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	f.inviteRepo.invites["old"] = &model.ConversationInvite{Code: "old", ConversationID: f.convID, ExpiresAt: &expired}
+	if _, err := f.svc.JoinByInvite("old", uuid.New()); !errors.Is(err, ErrInviteUnusable) {
+		t.Errorf("expired invite: expected ErrInviteUnusable, got %v", err)
+	}
+}
+
+func TestConversationService_JoinByInvite_FailedJoinKeepsUse(t *testing.T) {
+	f := newGroupFixture()
+	inv, err := f.svc.CreateInvite(f.convID, f.ownerID, InviteOptions{MaxUses: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.convRepo.addParticipantErr = errors.New("insert failed")
+	if _, err := f.svc.JoinByInvite(inv.Code, uuid.New()); err == nil {
+		t.Fatal("expected the failed insert to fail the join")
+	}
+	if inv.Uses != 0 {
+		t.Fatalf("failed join counted a use: uses = %d", inv.Uses)
+	}
+	f.convRepo.addParticipantErr = nil
+	if res, err := f.svc.JoinByInvite(inv.Code, uuid.New()); err != nil || !res.Joined {
+		t.Errorf("retry: got %+v, %v", res, err)
+	}
+}
+
+func TestConversationService_JoinByInvite_ApprovalMode(t *testing.T) {
+	f := newGroupFixture()
+	f.convRepo.getByIDConv.JoinApproval = true
+	inv, err := f.svc.CreateInvite(f.convID, f.ownerID, InviteOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	joiner := uuid.New()
+	res, err := f.svc.JoinByInvite(inv.Code, joiner)
+	if err != nil {
+		t.Fatalf("join: unexpected error: %v", err)
+	}
+	if res.Joined || res.Request == nil || res.Request.Status != model.JoinRequestPending {
+		t.Fatalf("expected a pending request, got %+v", res)
+	}
+	if f.convRepo.participants[joiner] != nil {
+		t.Fatal("user must not join before approval")
+	}
+	again, err := f.svc.JoinByInvite(inv.Code, joiner)
+	if err != nil || again.Request.RequestID != res.Request.RequestID {
+		t.Errorf("repeated join should return the pending request, got %+v, %v", again, err)
+	}
+	if inv.Uses != 1 {
+		t.Errorf("uses = %d, want 1", inv.Uses)
+	}
+
+	if err := f.svc.DecideJoinRequest(f.convID, joiner, res.Request.RequestID, true); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("requester deciding: expected ErrNotParticipant, got %v", err)
+	}
+	if err := f.svc.DecideJoinRequest(f.convID, f.adminID, res.Request.RequestID, true); err != nil {
+		t.Fatalf("approve: unexpected error: %v", err)
+	}
+	if f.convRepo.participants[joiner] == nil {
+		t.Fatal("expected the user to join on approval")
+	}
+	if len(f.msgRepo.created) != 1 || f.events.events[len(f.events.events)-1] != model.EventMemberJoined {
+		t.Errorf("expected a system message and member_joined, got %d messages, events %v", len(f.msgRepo.created), f.events.events)
+	}
+	if err := f.svc.DecideJoinRequest(f.convID, f.adminID, res.Request.RequestID, false); !errors.Is(err, ErrJoinRequestNotFound) {
+		t.Errorf("deciding twice: expected ErrJoinRequestNotFound, got %v", err)
+	}
+}
+
+// lastUseTakenInviteRepo returns a snapshot of the invite, then lets another joiner take its
+// last use before the join proceeds.
+type lastUseTakenInviteRepo struct {
+	*mockInviteRepo
+}
+
+func (r lastUseTakenInviteRepo) GetByCode(code string) (*model.ConversationInvite, error) {
+	inv := r.invites[code]
+	snapshot := *inv
+	inv.Uses = inv.MaxUses
+	return &snapshot, nil
+}
+
+func TestConversationService_JoinByInvite_ApprovalModeInviteUsedUp(t *testing.T) {
+	f := newGroupFixture()
+	f.convRepo.getByIDConv.JoinApproval = true
+	f.svc = NewConversationService(f.convRepo, f.userRepo, f.msgRepo, &mockPinRepo{}, lastUseTakenInviteRepo{f.inviteRepo}, nil, f.events, f.notifier, ConversationOptions{})
+	inv, err := f.svc.CreateInvite(f.convID, f.ownerID, InviteOptions{MaxUses: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := f.svc.JoinByInvite(inv.Code, uuid.New()); !errors.Is(err, ErrInviteUnusable) {
+		t.Errorf("expected ErrInviteUnusable, got %v", err)
+	}
+	if len(f.inviteRepo.requests) != 0 {
+		t.Errorf("expected no join request, got %d", len(f.inviteRepo.requests))
+	}
+}
+
+func TestConversationService_AddMembers_AnnouncesJoin(t *testing.T) {
+	f := newGroupFixture()
+	newUser := uuid.New()
+	f.userRepo.getByIDsUsers = append(f.userRepo.getByIDsUsers, &model.User{UserID: newUser, Username: "erin"})
+	added, err := f.svc.AddMembers(f.convID, f.ownerID, []uuid.UUID{newUser})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(added) != 1 {
+		t.Fatalf("added = %v", added)
+	}
+	if len(f.events.events) != 1 || f.events.events[0] != model.EventMemberJoined || f.events.data[0]["via"] != "added" {
+		t.Errorf("expected member_joined via added, got %v %v", f.events.events, f.events.data)
+	}
+	if len(f.msgRepo.created) != 1 || !strings.Contains(f.msgRepo.created[0].Content, "added erin") {
+		t.Errorf("unexpected system message %+v", f.msgRepo.created)
+	}
+}
+
+func TestConversationService_RenameGroup_PostsSystemMessage(t *testing.T) {
+	f := newGroupFixture()
+	if err := f.svc.RenameGroup(f.convID, f.adminID, "  "); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("blank name: expected ErrInvalidInput, got %v", err)
+	}
+	if err := f.svc.RenameGroup(f.convID, f.adminID, "Release crew"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.convRepo.getByIDConv.Name != "Release crew" {
+		t.Errorf("name = %q", f.convRepo.getByIDConv.Name)
+	}
+	if len(f.msgRepo.created) != 1 {
+		t.Fatalf("expected one system message, got %d", len(f.msgRepo.created))
+	}
+	msg := f.msgRepo.created[0]
+	e, ok := parseSystemEvent(msg)
+	if !ok || e.Action != SystemActionGroupRenamed || e.Actor != f.adminID || e.Name != "Release crew" {
+		t.Errorf("unexpected metadata %v", msg.Metadata)
+	}
+	if !f.notifier.called || f.notifier.lastMsg != msg {
+		t.Error("system message should be fanned out")
+	}
+	// Same name again: nothing to record.
+	if err := f.svc.RenameGroup(f.convID, f.adminID, "Release crew"); err != nil || len(f.msgRepo.created) != 1 {
+		t.Errorf("unchanged name should be a no-op, err=%v messages=%d", err, len(f.msgRepo.created))
+	}
+}
+
+func TestConversationService_RenderSystemMessages(t *testing.T) {
+	actor, target := uuid.New(), uuid.New()
+	userRepo := &mockUserRepo{getByIDsUsers: []*model.User{
+		{UserID: actor, Username: "alice", DisplayName: "Alice"},
+		{UserID: target, Username: "bob"},
+	}}
+	svc := NewConversationService(&mockConversationRepo{}, userRepo, &mockMessageRepoForConv{}, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	meta := fmt.Sprintf(`{"actor":%q,"action":%q,"targets":[%q],"via":"added"}`, actor, SystemActionMemberJoined, target)
+	msgs := []*model.Message{
+		{MessageID: 1, Content: "stale", MessageType: model.MessageTypeSystem, Metadata: &meta},
+		{MessageID: 2, Content: "hello", MessageType: model.MessageTypeText},
+	}
+	svc.RenderSystemMessages(msgs)
+	if msgs[0].Content != "Alice added bob" {
+		t.Errorf("system content = %q", msgs[0].Content)
+	}
+	if msgs[1].Content != "hello" {
+		t.Errorf("text message changed to %q", msgs[1].Content)
+	}
+}
+
+func TestConversationService_CheckSend_Moderation(t *testing.T) {
+	f := newGroupFixture()
+	member := f.memberID
+	if err := f.svc.CheckSend(f.convID, member); err != nil {
+		t.Fatalf("unrestricted group: unexpected error %v", err)
+	}
+	if err := f.svc.CheckSend(f.convID, uuid.New()); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("outsider: expected ErrNotParticipant, got %v", err)
+	}
+
+	on := true
+	if _, err := f.svc.UpdateModeration(f.convID, member, ModerationUpdate{AdminOnlySend: &on}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("member changing moderation: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := f.svc.UpdateModeration(f.convID, f.adminID, ModerationUpdate{AdminOnlySend: &on}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.events.events) != 1 || f.events.events[0] != model.EventConversationUpdated {
+		t.Errorf("expected conversation_updated, got %v", f.events.events)
+	}
+	err := f.svc.CheckSend(f.convID, member)
+	var restricted *SendRestrictionError
+	if !errors.As(err, &restricted) || !errors.Is(err, ErrAdminOnlySend) {
+		t.Errorf("admin-only: expected ErrAdminOnlySend, got %v", err)
+	}
+	if err := f.svc.CheckSend(f.convID, f.adminID); err != nil {
+		t.Errorf("admins may send in admin-only mode, got %v", err)
+	}
+
+	off, slow := false, 30
+	if _, err := f.svc.UpdateModeration(f.convID, f.ownerID, ModerationUpdate{AdminOnlySend: &off, SlowModeSeconds: &slow}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.msgRepo.lastSentAt = map[uuid.UUID]time.Time{
+		member:    time.Now().Add(-10 * time.Second),
+		f.adminID: time.Now(),
+	}
+	err = f.svc.CheckSend(f.convID, member)
+	if !errors.As(err, &restricted) || !errors.Is(err, ErrSlowMode) {
+		t.Fatalf("slow mode: expected ErrSlowMode, got %v", err)
+	}
+	if restricted.RetryAfter <= 15*time.Second || restricted.RetryAfter > 20*time.Second {
+		t.Errorf("RetryAfter = %s, want about 20s", restricted.RetryAfter)
+	}
+	if err := f.svc.CheckSend(f.convID, f.adminID); err != nil {
+		t.Errorf("admins are exempt from slow mode, got %v", err)
+	}
+	f.msgRepo.lastSentAt[member] = time.Now().Add(-31 * time.Second)
+	if err := f.svc.CheckSend(f.convID, member); err != nil {
+		t.Errorf("slow mode interval passed: unexpected error %v", err)
+	}
+
+	tooSlow := maxSlowModeSeconds + 1
+	if _, err := f.svc.UpdateModeration(f.convID, f.ownerID, ModerationUpdate{SlowModeSeconds: &tooSlow}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("slow mode too long: expected ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestConversationService_SilenceMember(t *testing.T) {
+	f := newGroupFixture()
+	member := f.memberID
+
+	if _, err := f.svc.SilenceMember(f.convID, member, f.adminID, time.Hour); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("member silencing: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := f.svc.SilenceMember(f.convID, f.ownerID, f.adminID, time.Hour); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("silencing an admin: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := f.svc.SilenceMember(f.convID, f.ownerID, uuid.New(), time.Hour); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("silencing an outsider: expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := f.svc.SilenceMember(f.convID, f.ownerID, member, 0); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("zero duration: expected ErrInvalidInput, got %v", err)
+	}
+	until, err := f.svc.SilenceMember(f.convID, f.adminID, member, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, ok := f.convRepo.settingsUpdates["silenced_until"].(time.Time); !ok || !got.Equal(*until) {
+		t.Errorf("silenced_until update = %v", f.convRepo.settingsUpdates)
+	}
+	if len(f.events.events) != 1 || f.events.events[0] != model.EventMemberSilenced {
+		t.Errorf("expected member_silenced, got %v", f.events.events)
+	}
+
+	f.convRepo.participants[member].SilencedUntil = until
+	err = f.svc.CheckSend(f.convID, member)
+	var restricted *SendRestrictionError
+	if !errors.As(err, &restricted) || !errors.Is(err, ErrMemberSilenced) || restricted.RetryAfter <= 59*time.Minute {
+		t.Errorf("silenced: expected ErrMemberSilenced with about 1h to wait, got %v", err)
+	}
+
+	if err := f.svc.UnsilenceMember(f.convID, f.adminID, member); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, ok := f.convRepo.settingsUpdates["silenced_until"]; !ok || v != nil {
+		t.Errorf("unsilence should clear silenced_until, got %v", f.convRepo.settingsUpdates)
+	}
+}
+
+func TestConversationService_RoleMatrix(t *testing.T) {
+	const (
+		owner  = "owner"
+		admin  = "admin"
+		member = "member"
+		other  = "other"
+	)
+	tests := []struct {
+		name    string
+		actor   string
+		target  string
+		action  string // promote, demote, transfer, remove
+		wantErr error
+	}{
+		{"owner promotes member", owner, member, "promote", nil},
+		{"owner demotes admin", owner, admin, "demote", nil},
+		{"owner transfers to member", owner, member, "transfer", nil},
+		{"owner removes admin", owner, admin, "remove", nil},
+		{"owner removes member", owner, member, "remove", nil},
+		{"owner cannot demote self", owner, owner, "demote", ErrPermissionDenied},
+		{"owner cannot transfer to self", owner, owner, "transfer", ErrInvalidInput},
+		{"admin cannot promote", admin, member, "promote", ErrPermissionDenied},
+		{"admin cannot demote admin", admin, admin, "demote", ErrPermissionDenied},
+		{"admin cannot transfer", admin, member, "transfer", ErrPermissionDenied},
+		{"admin removes member", admin, member, "remove", nil},
+		{"admin cannot remove owner", admin, owner, "remove", ErrPermissionDenied},
+		{"member cannot promote", member, other, "promote", ErrPermissionDenied},
+		{"member cannot transfer", member, other, "transfer", ErrPermissionDenied},
+		{"member cannot remove member", member, other, "remove", ErrPermissionDenied},
+		{"member cannot remove owner", member, owner, "remove", ErrPermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newGroupFixture()
+			ids := map[string]uuid.UUID{owner: f.ownerID, admin: f.adminID, member: f.memberID, other: f.otherID}
+			actor, target := ids[tt.actor], ids[tt.target]
+			var err error
+			switch tt.action {
+			case "promote":
+				err = f.svc.SetMemberRole(f.convID, actor, target, model.ParticipantRoleAdmin)
+			case "demote":
+				err = f.svc.SetMemberRole(f.convID, actor, target, model.ParticipantRoleMember)
+			case "transfer":
+				err = f.svc.TransferOwnership(f.convID, actor, target)
+			case "remove":
+				err = f.svc.RemoveMember(f.convID, actor, target)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(f.events.events) == 0 || len(f.msgRepo.created) == 0 {
+					t.Errorf("expected an event and a system message, got %v / %d", f.events.events, len(f.msgRepo.created))
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			if len(f.events.events) != 0 || len(f.msgRepo.created) != 0 {
+				t.Errorf("refused action must not notify, got %v / %d", f.events.events, len(f.msgRepo.created))
+			}
+		})
+	}
+}
+
+func TestConversationService_SetMemberRole(t *testing.T) {
+	f := newGroupFixture()
+	member := f.memberID
+	if err := f.svc.SetMemberRole(f.convID, f.ownerID, member, model.ParticipantRoleOwner); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("role owner: expected ErrInvalidInput, got %v", err)
+	}
+	if err := f.svc.SetMemberRole(f.convID, f.ownerID, uuid.New(), model.ParticipantRoleAdmin); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("outsider: expected ErrUserNotFound, got %v", err)
+	}
+	if err := f.svc.SetMemberRole(f.convID, f.ownerID, member, model.ParticipantRoleAdmin); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := f.convRepo.participants[member].Role; got != model.ParticipantRoleAdmin {
+		t.Errorf("role = %s, want admin", got)
+	}
+	if len(f.events.events) != 1 || f.events.events[0] != model.EventRoleChanged || f.events.data[0]["role"] != model.ParticipantRoleAdmin {
+		t.Errorf("expected role_changed to admin, got %v %v", f.events.events, f.events.data)
+	}
+	// Unchanged role: nothing happens.
+	if err := f.svc.SetMemberRole(f.convID, f.ownerID, member, model.ParticipantRoleAdmin); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.events.events) != 1 || len(f.msgRepo.created) != 1 {
+		t.Errorf("unchanged role should not notify, got %v / %d", f.events.events, len(f.msgRepo.created))
+	}
+}
+
+func TestConversationService_TransferOwnership(t *testing.T) {
+	f := newGroupFixture()
+	member := f.memberID
+	if err := f.svc.TransferOwnership(f.convID, f.ownerID, uuid.New()); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("outsider: expected ErrUserNotFound, got %v", err)
+	}
+	if err := f.svc.TransferOwnership(f.convID, f.ownerID, member); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.convRepo.participants[member].Role != model.ParticipantRoleOwner || f.convRepo.participants[f.ownerID].Role != model.ParticipantRoleAdmin {
+		t.Errorf("expected member owner and previous owner admin, got %s / %s", f.convRepo.participants[member].Role, f.convRepo.participants[f.ownerID].Role)
+	}
+	if len(f.events.data) != 1 || f.events.data[0]["previous_owner"] != f.ownerID || f.events.data[0]["user_id"] != member {
+		t.Errorf("unexpected role_changed data %v", f.events.data)
+	}
+	ev, _ := parseSystemEvent(f.msgRepo.created[0])
+	if ev == nil || ev.Action != SystemActionOwnerChanged || ev.Via != "transfer" {
+		t.Errorf("unexpected system message %+v", ev)
+	}
+	// The previous owner is an admin now and can no longer manage roles.
+	if err := f.svc.SetMemberRole(f.convID, f.ownerID, member, model.ParticipantRoleMember); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("previous owner: expected ErrPermissionDenied, got %v", err)
+	}
+}
+
+func TestConversationService_LeaveGroup_OwnerSuccession(t *testing.T) {
+	t.Run("longest-tenured admin", func(t *testing.T) {
+		f := newGroupFixture()
+		member := f.memberID
+		// The member joined before the admin; admins still take precedence.
+		f.convRepo.participants[member].JoinedAt = f.convRepo.participants[f.ownerID].JoinedAt.Add(time.Second)
+		second := uuid.MustParse("b0000000-0000-0000-0000-000000000005")
+		f.convRepo.participants[second] = &model.ConversationParticipant{ConversationID: f.convID, UserID: second, Role: model.ParticipantRoleAdmin,
+			JoinedAt: f.convRepo.participants[f.adminID].JoinedAt.Add(time.Hour)}
+		if err := f.svc.LeaveGroup(f.convID, f.ownerID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := f.convRepo.participants[f.ownerID]; ok {
+			t.Error("owner should have left")
+		}
+		if got := f.convRepo.participants[f.adminID].Role; got != model.ParticipantRoleOwner {
+			t.Errorf("longest-tenured admin role = %s, want owner", got)
+		}
+		if f.convRepo.participants[second].Role != model.ParticipantRoleAdmin || f.convRepo.participants[member].Role != model.ParticipantRoleMember {
+			t.Error("other participants must keep their roles")
+		}
+		if len(f.events.events) != 2 || f.events.events[0] != model.EventRoleChanged || f.events.events[1] != model.EventMemberLeft {
+			t.Errorf("expected role_changed then member_left, got %v", f.events.events)
+		}
+		if ev, _ := parseSystemEvent(f.msgRepo.created[0]); ev == nil || ev.Via != "auto" {
+			t.Errorf("expected automatic owner change message, got %+v", ev)
+		}
+	})
+	t.Run("longest-tenured member", func(t *testing.T) {
+		f := newGroupFixture()
+		member, other := f.memberID, f.otherID
+		delete(f.convRepo.participants, f.adminID)
+		f.convRepo.participants[other].JoinedAt = f.convRepo.participants[member].JoinedAt.Add(-time.Second)
+		if err := f.svc.LeaveGroup(f.convID, f.ownerID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := f.convRepo.participants[other].Role; got != model.ParticipantRoleOwner {
+			t.Errorf("longest-tenured member role = %s, want owner", got)
+		}
+	})
+	t.Run("bots are skipped", func(t *testing.T) {
+		f := newGroupFixture()
+		member, other := f.memberID, f.otherID
+		delete(f.convRepo.participants, f.adminID)
+		f.svc.(*conversationService).userRepo = &mockUserRepo{getByIDsUsers: []*model.User{{UserID: member, IsBot: true}, {UserID: other}}}
+		if err := f.svc.LeaveGroup(f.convID, f.ownerID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := f.convRepo.participants[other].Role; got != model.ParticipantRoleOwner {
+			t.Errorf("person role = %s, want owner over the earlier bot", got)
+		}
+	})
+	t.Run("last participant deletes the group", func(t *testing.T) {
+		f := newGroupFixture()
+		for _, uid := range []uuid.UUID{f.adminID, f.memberID, f.otherID} {
+			delete(f.convRepo.participants, uid)
+		}
+		if err := f.svc.LeaveGroup(f.convID, f.ownerID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !f.convRepo.deleted {
+			t.Error("expected the group to be deleted")
+		}
+	})
+}
+
+func TestConversationService_LeaveGroup(t *testing.T) {
+	f := newGroupFixture()
+	member := f.memberID
+	if err := f.svc.LeaveGroup(f.convID, uuid.New()); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("outsider: expected ErrNotParticipant, got %v", err)
+	}
+	// Removing oneself is leaving, even for plain members.
+	if err := f.svc.RemoveMember(f.convID, member, member); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := f.convRepo.participants[member]; ok {
+		t.Error("member should have left")
+	}
+	if len(f.events.data) != 1 || f.events.data[0]["via"] != "left" {
+		t.Errorf("unexpected member_left data %v", f.events.data)
+	}
+	f.convRepo.getByIDConv.Type = model.ConversationTypeOneOnOne
+	if err := f.svc.LeaveGroup(f.convID, f.adminID); !errors.Is(err, ErrGroupOnly) {
+		t.Errorf("1:1: expected ErrGroupOnly, got %v", err)
+	}
+}
+
+func TestConversationService_OnAccountDeleted(t *testing.T) {
+	f := newGroupFixture()
+	f.convRepo.groupIDs = []uuid.UUID{f.convID}
+	f.svc.OnAccountDeleted(f.ownerID)
+	if _, ok := f.convRepo.participants[f.ownerID]; ok {
+		t.Error("deleted user should have left the group")
+	}
+	if got := f.convRepo.participants[f.adminID].Role; got != model.ParticipantRoleOwner {
+		t.Errorf("admin role = %s, want owner", got)
+	}
+	if n := len(f.events.data); n == 0 || f.events.data[n-1]["via"] != "account_deleted" {
+		t.Errorf("unexpected events %v", f.events.data)
+	}
+}
+
+func TestConversationService_HistoryStart_Visibility(t *testing.T) {
+	f := newGroupFixture()
+	member := f.memberID
+	joined := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	f.convRepo.participants[member].JoinedAt = joined
+	f.msgRepo.byID = map[int64]*model.Message{
+		1: {MessageID: 1, CreatedAt: joined.AddDate(0, 0, -30)},
+		2: {MessageID: 2, CreatedAt: joined.AddDate(0, 0, -2)},
+		3: {MessageID: 3, CreatedAt: joined.Add(-time.Minute)},
+		4: {MessageID: 4, CreatedAt: joined.Add(time.Minute)},
+	}
+	zero, four := int64(0), int64(4)
+	tests := []struct {
+		visibility string
+		days       int
+		cleared    *int64
+		want       int64
+	}{
+		{model.HistoryVisibilityAll, 0, nil, 0},
+		{"", 0, nil, 0},
+		{model.HistoryVisibilityJoined, 0, nil, 3},
+		{model.HistoryVisibilityDays, 7, nil, 1},
+		{model.HistoryVisibilityDays, 60, nil, 0},
+		// Cleared history wins when it reaches further.
+		{model.HistoryVisibilityJoined, 0, &four, 4},
+		{model.HistoryVisibilityDays, 7, &zero, 1},
+	}
+	for _, tt := range tests {
+		f.convRepo.getByIDConv.HistoryVisibility, f.convRepo.getByIDConv.HistoryDays = tt.visibility, tt.days
+		f.convRepo.participants[member].ClearedUpToMessageID = tt.cleared
+		got, err := f.svc.HistoryStart(f.convID, member)
+		if err != nil {
+			t.Fatalf("%s/%d: unexpected error: %v", tt.visibility, tt.days, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s/%d cleared=%v: HistoryStart = %d, want %d", tt.visibility, tt.days, tt.cleared, got, tt.want)
+		}
+	}
+
+	// 1:1 conversations always show the whole history.
+	f.convRepo.getByIDConv.Type = model.ConversationTypeOneOnOne
+	f.convRepo.getByIDConv.HistoryVisibility = model.HistoryVisibilityJoined
+	f.convRepo.participants[member].ClearedUpToMessageID = nil
+	if got, _ := f.svc.HistoryStart(f.convID, member); got != 0 {
+		t.Errorf("1:1: HistoryStart = %d, want 0", got)
+	}
+}
+
+func TestConversationService_SetHistoryVisibility(t *testing.T) {
+	f := newGroupFixture()
+	member := f.memberID
+	if _, err := f.svc.SetHistoryVisibility(f.convID, member, model.HistoryVisibilityJoined, 0); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("member: expected ErrPermissionDenied, got %v", err)
+	}
+	for _, tt := range []struct {
+		visibility string
+		days       int
+	}{{"everything", 0}, {model.HistoryVisibilityDays, 0}, {model.HistoryVisibilityDays, maxHistoryDays + 1}} {
+		if _, err := f.svc.SetHistoryVisibility(f.convID, f.adminID, tt.visibility, tt.days); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s/%d: expected ErrInvalidInput, got %v", tt.visibility, tt.days, err)
+		}
+	}
+	conv, err := f.svc.SetHistoryVisibility(f.convID, f.adminID, model.HistoryVisibilityDays, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conv.HistoryVisibility != model.HistoryVisibilityDays || conv.HistoryDays != 7 {
+		t.Errorf("unexpected settings %s/%d", conv.HistoryVisibility, conv.HistoryDays)
+	}
+	if len(f.events.events) != 1 || f.events.events[0] != model.EventConversationUpdated || f.events.data[0]["history_days"] != 7 {
+		t.Errorf("expected conversation_updated, got %v %v", f.events.events, f.events.data)
+	}
+	// Days only apply to the days mode.
+	if conv, _ := f.svc.SetHistoryVisibility(f.convID, f.ownerID, model.HistoryVisibilityJoined, 30); conv == nil || conv.HistoryDays != 0 {
+		t.Errorf("joined: expected days reset to 0, got %+v", conv)
+	}
+}
+
+func TestConversationService_CreateChannel(t *testing.T) {
+	creator := uuid.New()
+	convRepo := &mockConversationRepo{participants: map[uuid.UUID]*model.ConversationParticipant{}}
+	svc := NewConversationService(convRepo, &mockUserRepo{}, &mockMessageRepoForConv{}, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+	if _, err := svc.CreateChannel(creator, "   "); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("blank name: expected ErrInvalidInput, got %v", err)
+	}
+	conv, err := svc.CreateChannel(creator, " News ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conv.Type != model.ConversationTypeChannel || conv.Name != "News" {
+		t.Errorf("unexpected channel %+v", conv)
+	}
+	if p := convRepo.participants[creator]; p == nil || p.Role != model.ParticipantRoleOwner {
+		t.Errorf("creator should be the owner, got %+v", p)
+	}
+}
+
+func TestConversationService_CheckSend_Channel(t *testing.T) {
+	f := newGroupFixture()
+	f.convRepo.getByIDConv.Type = model.ConversationTypeChannel
+	subscriber := f.memberID
+	err := f.svc.CheckSend(f.convID, subscriber)
+	if !errors.Is(err, ErrAdminOnlySend) {
+		t.Errorf("subscriber: expected ErrAdminOnlySend, got %v", err)
+	}
+	for _, uid := range []uuid.UUID{f.ownerID, f.adminID} {
+		if err := f.svc.CheckSend(f.convID, uid); err != nil {
+			t.Errorf("publisher %s: unexpected error %v", uid, err)
+		}
+	}
+}
+
+func TestConversationService_Channel_Membership(t *testing.T) {
+	f := newGroupFixture()
+	f.convRepo.getByIDConv.Type = model.ConversationTypeChannel
+	subscriber := f.memberID
+	// Subscribers joining and leaving are not recorded in the timeline, but still notified.
+	inv, err := f.svc.CreateInvite(f.convID, f.adminID, InviteOptions{})
+	if err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	joiner := uuid.New()
+	if _, err := f.svc.JoinByInvite(inv.Code, joiner); err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	if err := f.svc.LeaveGroup(f.convID, subscriber); err != nil {
+		t.Fatalf("leave: %v", err)
+	}
+	if err := f.svc.RemoveMember(f.convID, f.adminID, joiner); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if len(f.msgRepo.created) != 0 {
+		t.Errorf("expected no system messages in a channel, got %d", len(f.msgRepo.created))
+	}
+	want := []string{model.EventMemberJoined, model.EventMemberLeft, model.EventMemberLeft}
+	if fmt.Sprint(f.events.events) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", f.events.events, want)
+	}
+
+	// Channels are not bound by the group size limit.
+	for i := 0; i < MaxGroupMembers; i++ {
+		uid := uuid.New()
+		f.convRepo.participants[uid] = &model.ConversationParticipant{ConversationID: f.convID, UserID: uid, Role: model.ParticipantRoleMember}
+	}
+	if _, err := f.svc.JoinByInvite(inv.Code, uuid.New()); err != nil {
+		t.Errorf("join beyond the group limit: unexpected error %v", err)
+	}
+	f.convRepo.getByIDConv.Type = model.ConversationTypeGroup
+	if _, err := f.svc.JoinByInvite(inv.Code, uuid.New()); !errors.Is(err, ErrInvalidGroupSize) {
+		t.Errorf("full group: expected ErrInvalidGroupSize, got %v", err)
+	}
+}
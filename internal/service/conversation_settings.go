@@ -0,0 +1,123 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_settings.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Per-user conversation settings: mute, pin to top, archive and hide
+
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/repository"
+)
+
+// ConversationListFilter selects archived and hidden conversations in the conversation list.
+type ConversationListFilter = repository.ConversationListFilter
+
+// ConversationSettingsUpdate changes the user's settings for a conversation; nil fields are left
+// unchanged.
+type ConversationSettingsUpdate struct {
+	// MutedUntil mutes the conversation until the given time, which must be in the future.
+	MutedUntil *time.Time
+	// Unmute clears the mute; it wins over MutedUntil.
+	Unmute bool
+	// Pinned pins the conversation below the user's other pinned ones, or unpins it.
+	Pinned *bool
+	// PinOrder pins the conversation at the given position (1 is the top); 0 unpins it.
+	PinOrder *int
+	Archived *bool
+	// Hidden hides the conversation from the list until a new message arrives.
+	Hidden *bool
+}
+
+// UpdateSettings applies the user's settings for the conversation and returns the updated
+// participant row.
+func (s *conversationService) UpdateSettings(conversationID, userID uuid.UUID, u ConversationSettingsUpdate) (*model.ConversationParticipant, error) {
+	now := time.Now()
+	if !u.Unmute && u.MutedUntil != nil {
+		if !u.MutedUntil.After(now) {
+			return nil, fmt.Errorf("%w: muted_until must be in the future", ErrInvalidInput)
+		}
+		if u.MutedUntil.Sub(now) > maxMuteDuration {
+			return nil, fmt.Errorf("%w: muted_until must be within a year", ErrInvalidInput)
+		}
+	}
+	if u.PinOrder != nil && *u.PinOrder < 0 {
+		return nil, fmt.Errorf("%w: pin_order must not be negative", ErrInvalidInput)
+	}
+	p, err := s.convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrNotParticipant
+	}
+
+	updates := make(map[string]interface{})
+	if u.Unmute {
+		updates["muted_until"] = nil
+		p.MutedUntil = nil
+	} else if u.MutedUntil != nil {
+		until := u.MutedUntil.UTC()
+		updates["muted_until"] = until
+		p.MutedUntil = &until
+	}
+	switch {
+	case u.PinOrder != nil:
+		updates["pin_order"] = *u.PinOrder
+		p.PinOrder = *u.PinOrder
+	case u.Pinned != nil && !*u.Pinned:
+		updates["pin_order"] = 0
+		p.PinOrder = 0
+	case u.Pinned != nil && p.PinOrder == 0:
+		max, err := s.convRepo.MaxPinOrder(userID)
+		if err != nil {
+			return nil, fmt.Errorf("read pin order: %w", err)
+		}
+		updates["pin_order"] = max + 1
+		p.PinOrder = max + 1
+	}
+	if u.Archived != nil {
+		updates["archived"] = *u.Archived
+		p.Archived = *u.Archived
+	}
+	if u.Hidden != nil {
+		if *u.Hidden {
+			// Hidden until a message newer than the current last one arrives.
+			var lastID int64
+			lastMsgs, err := s.msgRepo.GetLastMessagesByConversationIDs([]uuid.UUID{conversationID})
+			if err != nil {
+				return nil, fmt.Errorf("read last message: %w", err)
+			}
+			if m := lastMsgs[conversationID]; m != nil {
+				lastID = m.MessageID
+			}
+			updates["hidden_until_message_id"] = lastID
+			p.HiddenUntilMessageID = &lastID
+		} else {
+			updates["hidden_until_message_id"] = nil
+			p.HiddenUntilMessageID = nil
+		}
+	}
+	if err := s.convRepo.UpdateParticipantSettings(conversationID, userID, updates); err != nil {
+		return nil, fmt.Errorf("update settings: %w", err)
+	}
+	return p, nil
+}
+
+// GetUnreadTotal returns the user's unread badge: unread messages over all conversations the
+// user has not muted.
+func (s *conversationService) GetUnreadTotal(userID uuid.UUID) (int, error) {
+	return s.convRepo.GetUnreadTotal(userID, time.Now())
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: conversation_system.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: System messages recording conversation changes in the timeline
+
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// System message actions.
+const (
+	SystemActionMemberJoined        = "member_joined"
+	SystemActionGroupRenamed        = "group_renamed"
+	SystemActionTopicChanged        = "topic_changed"
+	SystemActionMessagePinned       = "message_pinned"
+	SystemActionMessageUnpinned     = "message_unpinned"
+	SystemActionAnnouncementChanged = "announcement_changed"
+	SystemActionAnnouncementCleared = "announcement_cleared"
+	SystemActionMemberLeft          = "member_left"
+	SystemActionMemberRemoved       = "member_removed"
+	SystemActionRoleChanged         = "role_changed"
+	SystemActionOwnerChanged        = "owner_changed"
+)
+
+// SystemEvent is the metadata of a system message: who did what to whom. The optional fields
+// depend on the action.
+type SystemEvent struct {
+	Actor   uuid.UUID   `json:"actor"`
+	Action  string      `json:"action"`
+	Targets []uuid.UUID `json:"targets"`
+	// Via is how members joined ("added", "invite", "approved") or how the owner changed
+	// ("transfer", "auto").
+	Via string `json:"via,omitempty"`
+	// Name is the new group name.
+	Name string `json:"name,omitempty"`
+	// Topic is the new topic; empty when cleared.
+	Topic     string `json:"topic,omitempty"`
+	MessageID int64  `json:"message_id,omitempty"`
+	// Role is the targets' new role.
+	Role string `json:"role,omitempty"`
+}
+
+// userIDs returns the actor and the targets.
+func (e *SystemEvent) userIDs() []uuid.UUID {
+	return append([]uuid.UUID{e.Actor}, e.Targets...)
+}
+
+// Render returns the text shown for the event, with user names from names.
+func (e *SystemEvent) Render(names map[uuid.UUID]string) string {
+	name := func(id uuid.UUID) string {
+		if n, ok := names[id]; ok {
+			return n
+		}
+		return "someone"
+	}
+	targets := make([]string, len(e.Targets))
+	for i, id := range e.Targets {
+		targets[i] = name(id)
+	}
+	actor := name(e.Actor)
+	switch e.Action {
+	case SystemActionMemberJoined:
+		switch {
+		case e.Via == "approved":
+			return actor + " approved " + strings.Join(targets, ", ") + " to join the group"
+		case e.Via == "invite" || (len(e.Targets) == 1 && e.Targets[0] == e.Actor):
+			return strings.Join(targets, ", ") + " joined the group"
+		default:
+			return actor + " added " + strings.Join(targets, ", ")
+		}
+	case SystemActionGroupRenamed:
+		return actor + " renamed the group to \"" + e.Name + "\""
+	case SystemActionTopicChanged:
+		if e.Topic == "" {
+			return actor + " cleared the topic"
+		}
+		return actor + " changed the topic to \"" + e.Topic + "\""
+	case SystemActionMessagePinned:
+		return actor + " pinned a message"
+	case SystemActionMessageUnpinned:
+		return actor + " unpinned a message"
+	case SystemActionAnnouncementChanged:
+		return actor + " updated the announcement"
+	case SystemActionAnnouncementCleared:
+		return actor + " cleared the announcement"
+	case SystemActionMemberLeft:
+		return actor + " left the group"
+	case SystemActionMemberRemoved:
+		return actor + " removed " + strings.Join(targets, ", ")
+	case SystemActionRoleChanged:
+		if e.Role == model.ParticipantRoleAdmin {
+			return actor + " made " + strings.Join(targets, ", ") + " an admin"
+		}
+		return actor + " removed " + strings.Join(targets, ", ") + " as admin"
+	case SystemActionOwnerChanged:
+		if e.Via == "auto" {
+			return strings.Join(targets, ", ") + " is now the owner"
+		}
+		return actor + " made " + strings.Join(targets, ", ") + " the owner"
+	default:
+		return actor + " changed the conversation"
+	}
+}
+
+// parseSystemEvent reads the event of a system message; false if the message has none.
+func parseSystemEvent(msg *model.Message) (*SystemEvent, bool) {
+	if msg.MessageType != model.MessageTypeSystem || msg.Metadata == nil {
+		return nil, false
+	}
+	var e SystemEvent
+	if err := json.Unmarshal([]byte(*msg.Metadata), &e); err != nil || e.Action == "" {
+		return nil, false
+	}
+	return &e, true
+}
+
+// postSystemMessage stores a system message for the event, sent as its actor, and fans it out
+// like any other message. Failures are logged only: the change it records has already been made.
+func (s *conversationService) postSystemMessage(conversationID uuid.UUID, e *SystemEvent) {
+	if e.Targets == nil {
+		e.Targets = []uuid.UUID{}
+	}
+	raw, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("[CONV] system message encode failed conversation_id=%s action=%s err=%v", conversationID, e.Action, err)
+		return
+	}
+	metadata := string(raw)
+	msg := &model.Message{
+		ConversationID: conversationID,
+		SenderID:       e.Actor,
+		Content:        e.Render(s.displayNames(e.userIDs())),
+		MessageType:    model.MessageTypeSystem,
+		Metadata:       &metadata,
+	}
+	if err := s.msgRepo.Create(msg); err != nil {
+		log.Printf("[CONV] system message store failed conversation_id=%s action=%s err=%v", conversationID, e.Action, err)
+		return
+	}
+	if s.notifier != nil {
+		s.notifier.NotifyNewMessage(conversationID, msg)
+	}
+}
+
+// RenderSystemMessages sets the content of the system messages among msgs from their metadata,
+// with the users' current names.
+func (s *conversationService) RenderSystemMessages(msgs []*model.Message) {
+	events := make(map[int]*SystemEvent)
+	var userIDs []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for i, msg := range msgs {
+		e, ok := parseSystemEvent(msg)
+		if !ok {
+			continue
+		}
+		events[i] = e
+		for _, uid := range e.userIDs() {
+			if !seen[uid] {
+				seen[uid] = true
+				userIDs = append(userIDs, uid)
+			}
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+	names := s.displayNames(userIDs)
+	for i, e := range events {
+		msgs[i].Content = e.Render(names)
+	}
+}
+
+// announceJoin records that userIDs joined the group, with a system message (not in channels)
+// and a model.EventMemberJoined event. via says how: "added", "invite" or "approved".
+func (s *conversationService) announceJoin(conv *model.Conversation, actorID uuid.UUID, userIDs []uuid.UUID, via string) {
+	if len(userIDs) == 0 {
+		return
+	}
+	if conv.Type != model.ConversationTypeChannel {
+		s.postSystemMessage(conv.ConversationID, &SystemEvent{
+			Actor:   actorID,
+			Action:  SystemActionMemberJoined,
+			Targets: userIDs,
+			Via:     via,
+		})
+	}
+	s.notify(conv.ConversationID, actorID, model.EventMemberJoined, map[string]interface{}{
+		"user_ids": userIDs,
+		"via":      via,
+	})
+}
+
+// displayNames maps user IDs to display names, falling back to the username. Users that
+// cannot be loaded are left out.
+func (s *conversationService) displayNames(userIDs []uuid.UUID) map[uuid.UUID]string {
+	out := make(map[uuid.UUID]string, len(userIDs))
+	users, err := s.userRepo.GetByIDs(userIDs)
+	if err != nil {
+		return out
+	}
+	for _, u := range users {
+		if u.DisplayName != "" {
+			out[u.UserID] = u.DisplayName
+		} else {
+			out[u.UserID] = u.Username
+		}
+	}
+	return out
+}
@@ -0,0 +1,240 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: incoming_webhook_service.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Incoming webhook tokens and posting messages through them
+
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/repository"
+)
+
+var (
+	ErrIncomingWebhookNotFound = errors.New("incoming webhook not found")
+	ErrInvalidHookToken        = errors.New("invalid or revoked webhook token")
+)
+
+const (
+	// HookTokenPrefix starts every incoming webhook token.
+	HookTokenPrefix = "uimh_"
+
+	maxIncomingWebhooksPerConversation = 10
+	maxIncomingWebhookNameLength       = 100
+	hookTokenBytes                     = 32
+	hookTokenPrefixLength              = len(HookTokenPrefix) + 8
+	hookTouchInterval                  = time.Minute
+
+	maxIncomingAttachments       = 10
+	maxAttachmentTitleLength     = 256
+	maxAttachmentTextLength      = 4000
+	maxAttachmentColorLength     = 20
+	maxAttachmentURLLength       = 2000
+	metadataKeyIncomingWebhookID = "incoming_webhook_id"
+	metadataKeyAttachments       = "attachments"
+)
+
+// IncomingMessage is the payload of POST /hooks/:token.
+type IncomingMessage struct {
+	Text        string               `json:"text"`
+	Attachments []IncomingAttachment `json:"attachments,omitempty"`
+}
+
+// IncomingAttachment is descriptive metadata shown with the message (e.g. a link to an alert).
+// Nothing is fetched or uploaded; clients render the fields as given.
+type IncomingAttachment struct {
+	Title    string `json:"title,omitempty"`
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Color    string `json:"color,omitempty"`
+}
+
+// IncomingWebhookService manages incoming webhook tokens and posts their messages. Tokens are
+// managed by conversation owners (see WebhookService) and post as one of the owner's bots, which
+// must be a participant of the conversation.
+type IncomingWebhookService interface {
+	// CreateIncomingWebhook issues a token. The plaintext token is returned only here; just its
+	// hash is stored.
+	CreateIncomingWebhook(userID, conversationID, botID uuid.UUID, name string) (*model.IncomingWebhook, string, error)
+	// ListIncomingWebhooks returns the conversation's tokens, revoked ones included.
+	ListIncomingWebhooks(userID, conversationID uuid.UUID) ([]*model.IncomingWebhook, error)
+	RevokeIncomingWebhook(userID, conversationID, hookID uuid.UUID) error
+	// Post creates a message as the token's bot through MessageService.Create, so it is fanned
+	// out like any other message. Unknown and revoked tokens and deleted bots yield
+	// ErrInvalidHookToken.
+	Post(token string, in *IncomingMessage) (*model.Message, error)
+}
+
+type incomingWebhookService struct {
+	hookRepo repository.IncomingWebhookRepository
+	convRepo repository.ConversationRepository
+	userRepo repository.UserRepository
+	msgSvc   MessageService
+	now      func() time.Time
+}
+
+// NewIncomingWebhookService creates a new incoming webhook service.
+func NewIncomingWebhookService(hookRepo repository.IncomingWebhookRepository, convRepo repository.ConversationRepository, userRepo repository.UserRepository, msgSvc MessageService) IncomingWebhookService {
+	return &incomingWebhookService{hookRepo: hookRepo, convRepo: convRepo, userRepo: userRepo, msgSvc: msgSvc, now: time.Now}
+}
+
+// CreateIncomingWebhook checks the caller owns the conversation and the bot, and that the bot is
+// a participant, then stores a new token.
+func (s *incomingWebhookService) CreateIncomingWebhook(userID, conversationID, botID uuid.UUID, name string) (*model.IncomingWebhook, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxIncomingWebhookNameLength {
+		return nil, "", fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidInput, maxIncomingWebhookNameLength)
+	}
+	if err := requireConversationOwner(s.convRepo, conversationID, userID); err != nil {
+		return nil, "", err
+	}
+	bot, err := s.userRepo.GetByID(botID)
+	if err != nil || !bot.IsBot || bot.BotOwnerID == nil || *bot.BotOwnerID != userID {
+		return nil, "", ErrBotNotFound
+	}
+	p, err := s.convRepo.GetParticipant(conversationID, botID)
+	if err != nil {
+		return nil, "", err
+	}
+	if p == nil {
+		return nil, "", fmt.Errorf("%w: the bot is not a participant of the conversation", ErrInvalidInput)
+	}
+	hooks, err := s.hookRepo.ListByConversation(conversationID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list incoming webhooks: %w", err)
+	}
+	active := 0
+	for _, h := range hooks {
+		if h.RevokedAt == nil {
+			active++
+		}
+	}
+	if active >= maxIncomingWebhooksPerConversation {
+		return nil, "", ErrWebhookLimit
+	}
+
+	secret := make([]byte, hookTokenBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := HookTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	hook := &model.IncomingWebhook{
+		ConversationID: conversationID,
+		BotID:          botID,
+		CreatedBy:      userID,
+		Name:           name,
+		Prefix:         token[:hookTokenPrefixLength],
+		TokenHash:      hashAPIKey(token),
+		CreatedAt:      s.now(),
+	}
+	if err := s.hookRepo.Create(hook); err != nil {
+		return nil, "", fmt.Errorf("failed to store incoming webhook: %w", err)
+	}
+	log.Printf("[WEBHOOK] incoming webhook created hook_id=%s conversation_id=%s bot_id=%s prefix=%s", hook.HookID, conversationID, botID, hook.Prefix)
+	return hook, token, nil
+}
+
+// ListIncomingWebhooks returns the conversation's tokens.
+func (s *incomingWebhookService) ListIncomingWebhooks(userID, conversationID uuid.UUID) ([]*model.IncomingWebhook, error) {
+	if err := requireConversationOwner(s.convRepo, conversationID, userID); err != nil {
+		return nil, err
+	}
+	return s.hookRepo.ListByConversation(conversationID)
+}
+
+// RevokeIncomingWebhook revokes a token; posts with it fail from then on.
+func (s *incomingWebhookService) RevokeIncomingWebhook(userID, conversationID, hookID uuid.UUID) error {
+	if err := requireConversationOwner(s.convRepo, conversationID, userID); err != nil {
+		return err
+	}
+	ok, err := s.hookRepo.Revoke(conversationID, hookID, s.now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke incoming webhook: %w", err)
+	}
+	if !ok {
+		return ErrIncomingWebhookNotFound
+	}
+	log.Printf("[WEBHOOK] incoming webhook revoked hook_id=%s conversation_id=%s user_id=%s", hookID, conversationID, userID)
+	return nil
+}
+
+// Post resolves the token and sends the message as its bot.
+func (s *incomingWebhookService) Post(token string, in *IncomingMessage) (*model.Message, error) {
+	if !strings.HasPrefix(token, HookTokenPrefix) {
+		return nil, ErrInvalidHookToken
+	}
+	hook, err := s.hookRepo.GetByTokenHash(hashAPIKey(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load incoming webhook: %w", err)
+	}
+	if hook == nil || hook.RevokedAt != nil {
+		return nil, ErrInvalidHookToken
+	}
+	bot, err := s.userRepo.GetByID(hook.BotID)
+	if err != nil || !bot.IsBot {
+		return nil, ErrInvalidHookToken
+	}
+	if err := validateAttachments(in.Attachments); err != nil {
+		return nil, err
+	}
+	metadata := map[string]interface{}{metadataKeyIncomingWebhookID: hook.HookID.String()}
+	if len(in.Attachments) > 0 {
+		metadata[metadataKeyAttachments] = in.Attachments
+	}
+	msg, err := s.msgSvc.Create(hook.ConversationID, hook.BotID, in.Text, model.MessageTypeText, metadata)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	// last_used_at is informational; refresh it at most once per interval to avoid a write per post.
+	if hook.LastUsedAt == nil || now.Sub(*hook.LastUsedAt) >= hookTouchInterval {
+		if err := s.hookRepo.Touch(hook.HookID, now); err != nil {
+			log.Printf("[WEBHOOK] incoming webhook touch failed hook_id=%s err=%v", hook.HookID, err)
+		}
+	}
+	return msg, nil
+}
+
+func validateAttachments(attachments []IncomingAttachment) error {
+	if len(attachments) > maxIncomingAttachments {
+		return fmt.Errorf("%w: at most %d attachments", ErrInvalidInput, maxIncomingAttachments)
+	}
+	for i, a := range attachments {
+		if utf8.RuneCountInString(a.Title) > maxAttachmentTitleLength || utf8.RuneCountInString(a.Text) > maxAttachmentTextLength {
+			return fmt.Errorf("%w: attachment %d: title or text too long", ErrInvalidInput, i)
+		}
+		if len(a.Color) > maxAttachmentColorLength {
+			return fmt.Errorf("%w: attachment %d: invalid color", ErrInvalidInput, i)
+		}
+		for _, raw := range []string{a.URL, a.ImageURL} {
+			if raw == "" {
+				continue
+			}
+			u, err := url.Parse(raw)
+			if err != nil || len(raw) > maxAttachmentURLLength || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+				return fmt.Errorf("%w: attachment %d: urls must be absolute http(s) URLs", ErrInvalidInput, i)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: incoming_webhook_service_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for incoming webhook tokens and posting
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+type mockIncomingWebhookRepository struct {
+	hooks   map[uuid.UUID]*model.IncomingWebhook
+	touched int
+}
+
+func newMockIncomingWebhookRepository() *mockIncomingWebhookRepository {
+	return &mockIncomingWebhookRepository{hooks: make(map[uuid.UUID]*model.IncomingWebhook)}
+}
+
+func (m *mockIncomingWebhookRepository) Create(h *model.IncomingWebhook) error {
+	h.HookID = uuid.New()
+	m.hooks[h.HookID] = h
+	return nil
+}
+
+func (m *mockIncomingWebhookRepository) GetByTokenHash(tokenHash string) (*model.IncomingWebhook, error) {
+	for _, h := range m.hooks {
+		if h.TokenHash == tokenHash {
+			return h, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockIncomingWebhookRepository) ListByConversation(conversationID uuid.UUID) ([]*model.IncomingWebhook, error) {
+	var out []*model.IncomingWebhook
+	for _, h := range m.hooks {
+		if h.ConversationID == conversationID {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockIncomingWebhookRepository) Revoke(conversationID, hookID uuid.UUID, at time.Time) (bool, error) {
+	h, ok := m.hooks[hookID]
+	if !ok || h.ConversationID != conversationID || h.RevokedAt != nil {
+		return false, nil
+	}
+	h.RevokedAt = &at
+	return true, nil
+}
+
+func (m *mockIncomingWebhookRepository) Touch(hookID uuid.UUID, at time.Time) error {
+	m.touched++
+	m.hooks[hookID].LastUsedAt = &at
+	return nil
+}
+
+type incomingFixture struct {
+	svc      IncomingWebhookService
+	repo     *mockIncomingWebhookRepository
+	notifier *mockNotifier
+	owner    uuid.UUID
+	bot      uuid.UUID
+	conv     uuid.UUID
+}
+
+func newIncomingFixture() *incomingFixture {
+	f := &incomingFixture{
+		repo:     newMockIncomingWebhookRepository(),
+		notifier: &mockNotifier{},
+		owner:    uuid.New(),
+		bot:      uuid.New(),
+		conv:     uuid.New(),
+	}
+	convRepo := &mockConversationRepo{
+		getByIDConv: &model.Conversation{ConversationID: f.conv, Type: model.ConversationTypeOneOnOne},
+		participant: &model.ConversationParticipant{ConversationID: f.conv, Role: model.ParticipantRoleMember},
+	}
+	userRepo := &mockUserRepo{getByIDUser: &model.User{UserID: f.bot, IsBot: true, BotOwnerID: &f.owner}}
+	msgSvc := NewMessageService(&mockMessageRepo{}, &mockConvServiceForMessage{}, f.notifier)
+	f.svc = NewIncomingWebhookService(f.repo, convRepo, userRepo, msgSvc)
+	return f
+}
+
+func TestIncomingWebhook_CreateAndPost(t *testing.T) {
+	f := newIncomingFixture()
+	hook, token, err := f.svc.CreateIncomingWebhook(f.owner, f.conv, f.bot, "alerts")
+	if err != nil {
+		t.Fatalf("CreateIncomingWebhook: %v", err)
+	}
+	if !strings.HasPrefix(token, HookTokenPrefix) || !strings.HasPrefix(token, hook.Prefix) {
+		t.Errorf("token %q does not start with prefix %q", token, hook.Prefix)
+	}
+	if hook.TokenHash == token || strings.Contains(hook.TokenHash, token) {
+		t.Error("plaintext token must not be stored")
+	}
+
+	msg, err := f.svc.Post(token, &IncomingMessage{
+		Text:        "disk almost full",
+		Attachments: []IncomingAttachment{{Title: "db-1", URL: "https://grafana.example.com/d/1"}},
+	})
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if msg.SenderID != f.bot || msg.ConversationID != f.conv {
+		t.Errorf("message = %+v, want sender bot in conversation", msg)
+	}
+	if !f.notifier.called {
+		t.Error("message was not fanned out")
+	}
+	var meta struct {
+		HookID      string               `json:"incoming_webhook_id"`
+		Attachments []IncomingAttachment `json:"attachments"`
+	}
+	if msg.Metadata == nil || json.Unmarshal([]byte(*msg.Metadata), &meta) != nil {
+		t.Fatalf("metadata = %v", msg.Metadata)
+	}
+	if meta.HookID != hook.HookID.String() || len(meta.Attachments) != 1 || meta.Attachments[0].Title != "db-1" {
+		t.Errorf("metadata = %+v", meta)
+	}
+	if f.repo.touched != 1 {
+		t.Errorf("touched = %d, want 1", f.repo.touched)
+	}
+	if _, err := f.svc.Post(token, &IncomingMessage{Text: "again"}); err != nil {
+		t.Fatalf("second Post: %v", err)
+	}
+	if f.repo.touched != 1 {
+		t.Errorf("touched = %d, want last use refreshed at most once a minute", f.repo.touched)
+	}
+}
+
+func TestIncomingWebhook_Revoked(t *testing.T) {
+	f := newIncomingFixture()
+	hook, token, err := f.svc.CreateIncomingWebhook(f.owner, f.conv, f.bot, "alerts")
+	if err != nil {
+		t.Fatalf("CreateIncomingWebhook: %v", err)
+	}
+	if err := f.svc.RevokeIncomingWebhook(f.owner, f.conv, hook.HookID); err != nil {
+		t.Fatalf("RevokeIncomingWebhook: %v", err)
+	}
+	if _, err := f.svc.Post(token, &IncomingMessage{Text: "hi"}); !errors.Is(err, ErrInvalidHookToken) {
+		t.Errorf("Post with revoked token: want ErrInvalidHookToken, got %v", err)
+	}
+	if err := f.svc.RevokeIncomingWebhook(f.owner, f.conv, hook.HookID); !errors.Is(err, ErrIncomingWebhookNotFound) {
+		t.Errorf("second revoke: want ErrIncomingWebhookNotFound, got %v", err)
+	}
+	if _, err := f.svc.Post(HookTokenPrefix+"unknown", &IncomingMessage{Text: "hi"}); !errors.Is(err, ErrInvalidHookToken) {
+		t.Errorf("Post with unknown token: want ErrInvalidHookToken, got %v", err)
+	}
+}
+
+func TestIncomingWebhook_BotMustBelongToCaller(t *testing.T) {
+	f := newIncomingFixture()
+	if _, _, err := f.svc.CreateIncomingWebhook(uuid.New(), f.conv, f.bot, "alerts"); !errors.Is(err, ErrBotNotFound) {
+		t.Errorf("want ErrBotNotFound for another user's bot, got %v", err)
+	}
+}
+
+func TestIncomingWebhook_InvalidPayload(t *testing.T) {
+	f := newIncomingFixture()
+	_, token, err := f.svc.CreateIncomingWebhook(f.owner, f.conv, f.bot, "alerts")
+	if err != nil {
+		t.Fatalf("CreateIncomingWebhook: %v", err)
+	}
+	for name, in := range map[string]*IncomingMessage{
+		"empty text":     {Text: "  "},
+		"bad url":        {Text: "x", Attachments: []IncomingAttachment{{URL: "javascript:alert(1)"}}},
+		"too many items": {Text: "x", Attachments: make([]IncomingAttachment, maxIncomingAttachments+1)},
+	} {
+		if _, err := f.svc.Post(token, in); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: want ErrInvalidInput, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: login_guard.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Login brute-force protection (failure counters, exponential lockout, audit events)
+
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/store"
+)
+
+// ErrTooManyAttempts is matched (errors.Is) by *TooManyAttemptsError.
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// TooManyAttemptsError is returned by Login while the username or client IP is locked out.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string { return ErrTooManyAttempts.Error() }
+
+// Is makes errors.Is(err, ErrTooManyAttempts) match.
+func (e *TooManyAttemptsError) Is(target error) bool { return target == ErrTooManyAttempts }
+
+// Security audit event types.
+const (
+	AuditLoginLockout   = "login_lockout"    // a username or IP crossed the failure threshold
+	AuditLoginBlocked   = "login_blocked"    // a login was refused because of an active lockout
+	AuditLoginSucceeded = "login_after_fail" // successful login after recorded failures
+)
+
+// SecurityEvent is a security-relevant occurrence for audit logging. Never contains passwords.
+type SecurityEvent struct {
+	Type      string
+	Username  string
+	UserID    uuid.UUID // uuid.Nil when unknown
+	IP        string
+	Failures  int64
+	LockedFor time.Duration
+	Time      time.Time
+}
+
+// SecurityAuditor receives security events (e.g. to forward to a SIEM).
+// Implementations can be nil-safe; the guard logs events itself when no auditor is set.
+type SecurityAuditor interface {
+	AuditSecurityEvent(event SecurityEvent)
+}
+
+// LoginPolicy configures brute-force protection. After MaxUserFailures failures for a username
+// (MaxIPFailures for a client IP) within Window, the key is locked for BaseLockout, doubling with
+// every further failure up to MaxLockout.
+type LoginPolicy struct {
+	MaxUserFailures int
+	MaxIPFailures   int
+	Window          time.Duration
+	BaseLockout     time.Duration
+	MaxLockout      time.Duration
+}
+
+// DefaultLoginPolicy is used for zero fields of the policy passed to NewLoginGuard.
+var DefaultLoginPolicy = LoginPolicy{
+	MaxUserFailures: 5,
+	MaxIPFailures:   20,
+	Window:          15 * time.Minute,
+	BaseLockout:     30 * time.Second,
+	MaxLockout:      time.Hour,
+}
+
+// LoginGuard tracks failed logins per username and per client IP and refuses logins while
+// either is locked out. Counters live in a store.LoginAttemptStore, so with Redis they are
+// shared by all instances.
+type LoginGuard struct {
+	attempts store.LoginAttemptStore
+	policy   LoginPolicy
+	auditor  SecurityAuditor
+}
+
+// NewLoginGuard creates a login guard. auditor can be nil (events are logged with [AUDIT]).
+func NewLoginGuard(attempts store.LoginAttemptStore, policy LoginPolicy, auditor SecurityAuditor) *LoginGuard {
+	if policy.MaxUserFailures <= 0 {
+		policy.MaxUserFailures = DefaultLoginPolicy.MaxUserFailures
+	}
+	if policy.MaxIPFailures <= 0 {
+		policy.MaxIPFailures = DefaultLoginPolicy.MaxIPFailures
+	}
+	if policy.Window <= 0 {
+		policy.Window = DefaultLoginPolicy.Window
+	}
+	if policy.BaseLockout <= 0 {
+		policy.BaseLockout = DefaultLoginPolicy.BaseLockout
+	}
+	if policy.MaxLockout <= 0 {
+		policy.MaxLockout = DefaultLoginPolicy.MaxLockout
+	}
+	return &LoginGuard{attempts: attempts, policy: policy, auditor: auditor}
+}
+
+// Check returns a *TooManyAttemptsError if the username or IP is locked out. Store errors
+// do not block logins.
+func (g *LoginGuard) Check(ctx context.Context, username, ip string) error {
+	var retryAfter time.Duration
+	for _, key := range g.keys(username, ip) {
+		d, err := g.attempts.LockRemaining(ctx, key)
+		if err != nil {
+			log.Printf("[AUTH] login guard check failed key=%s err=%v", key, err)
+			continue
+		}
+		if d > retryAfter {
+			retryAfter = d
+		}
+	}
+	if retryAfter <= 0 {
+		return nil
+	}
+	g.audit(SecurityEvent{Type: AuditLoginBlocked, Username: username, IP: ip, LockedFor: retryAfter})
+	return &TooManyAttemptsError{RetryAfter: retryAfter}
+}
+
+// Failure records a failed login for the username and IP, locking keys that crossed their
+// threshold. Unknown usernames are counted the same way so lockouts do not reveal which exist.
+func (g *LoginGuard) Failure(ctx context.Context, username, ip string, userID uuid.UUID) {
+	keys := g.keys(username, ip)
+	for i, key := range keys {
+		limit := g.policy.MaxUserFailures
+		if i == 1 {
+			limit = g.policy.MaxIPFailures
+		}
+		n, err := g.attempts.RecordFailure(ctx, key, g.policy.Window)
+		if err != nil {
+			log.Printf("[AUTH] login guard record failed key=%s err=%v", key, err)
+			continue
+		}
+		if n < int64(limit) {
+			continue
+		}
+		d := g.lockoutFor(n - int64(limit))
+		if err := g.attempts.Lock(ctx, key, d); err != nil {
+			log.Printf("[AUTH] login guard lock failed key=%s err=%v", key, err)
+			continue
+		}
+		g.audit(SecurityEvent{Type: AuditLoginLockout, Username: username, UserID: userID, IP: ip, Failures: n, LockedFor: d})
+	}
+}
+
+// Success clears the username's failure counter, auditing the login if failures had been
+// recorded. The IP counter is kept so that one valid account cannot be used to reset an IP
+// that is guessing passwords for others.
+func (g *LoginGuard) Success(ctx context.Context, username, ip string, userID uuid.UUID) {
+	key := userKey(username)
+	n, err := g.attempts.Failures(ctx, key)
+	if err != nil {
+		log.Printf("[AUTH] login guard read failed key=%s err=%v", key, err)
+	}
+	if n > 0 {
+		g.audit(SecurityEvent{Type: AuditLoginSucceeded, Username: username, UserID: userID, IP: ip, Failures: n})
+	}
+	if err := g.attempts.Reset(ctx, key); err != nil {
+		log.Printf("[AUTH] login guard reset failed err=%v", err)
+	}
+}
+
+// lockoutFor returns BaseLockout * 2^excess, capped at MaxLockout.
+func (g *LoginGuard) lockoutFor(excess int64) time.Duration {
+	d := g.policy.BaseLockout
+	for i := int64(0); i < excess && d < g.policy.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > g.policy.MaxLockout {
+		d = g.policy.MaxLockout
+	}
+	return d
+}
+
+// keys returns the username key and, if known, the IP key.
+func (g *LoginGuard) keys(username, ip string) []string {
+	keys := []string{userKey(username)}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func (g *LoginGuard) audit(event SecurityEvent) {
+	event.Time = time.Now()
+	if g.auditor != nil {
+		g.auditor.AuditSecurityEvent(event)
+		return
+	}
+	log.Printf("[AUDIT] event=%s username=%s user_id=%s ip=%s failures=%d locked_for=%s",
+		event.Type, event.Username, event.UserID, event.IP, event.Failures, event.LockedFor)
+}
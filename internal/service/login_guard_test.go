@@ -0,0 +1,154 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: login_guard_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for login brute-force protection
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/pkg/jwt"
+	"github.com/convexwf/uim-go/internal/store"
+)
+
+// recordingAuditor collects security events for assertions.
+type recordingAuditor struct {
+	events []SecurityEvent
+}
+
+func (a *recordingAuditor) AuditSecurityEvent(event SecurityEvent) {
+	a.events = append(a.events, event)
+}
+
+func (a *recordingAuditor) count(eventType string) int {
+	n := 0
+	for _, e := range a.events {
+		if e.Type == eventType {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLoginGuard_LockoutAndBackoff(t *testing.T) {
+	ctx := context.Background()
+	auditor := &recordingAuditor{}
+	guard := NewLoginGuard(store.NewMemoryLoginAttemptStore(), LoginPolicy{
+		MaxUserFailures: 3,
+		MaxIPFailures:   100,
+		BaseLockout:     time.Minute,
+		MaxLockout:      5 * time.Minute,
+	}, auditor)
+
+	for i := 0; i < 2; i++ {
+		guard.Failure(ctx, "alice", "10.0.0.1", uuid.Nil)
+	}
+	if err := guard.Check(ctx, "alice", "10.0.0.1"); err != nil {
+		t.Fatalf("Check() below threshold error = %v", err)
+	}
+
+	guard.Failure(ctx, "alice", "10.0.0.1", uuid.Nil)
+	err := guard.Check(ctx, "ALICE", "10.0.0.2")
+	var lockErr *TooManyAttemptsError
+	if !errors.As(err, &lockErr) || !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("Check() error = %v, want TooManyAttemptsError (username is case-insensitive)", err)
+	}
+	if lockErr.RetryAfter <= 0 || lockErr.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %v, want (0, 1m]", lockErr.RetryAfter)
+	}
+	if got := auditor.count(AuditLoginLockout); got != 1 {
+		t.Errorf("lockout events = %d, want 1", got)
+	}
+
+	// Each further failure doubles the lockout, capped at MaxLockout.
+	want := []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for _, w := range want {
+		guard.Failure(ctx, "alice", "10.0.0.1", uuid.Nil)
+		last := auditor.events[len(auditor.events)-1]
+		if last.LockedFor != w {
+			t.Errorf("LockedFor = %v, want %v", last.LockedFor, w)
+		}
+	}
+
+	// Other usernames are unaffected.
+	if err := guard.Check(ctx, "bob", "10.0.0.3"); err != nil {
+		t.Errorf("Check(bob) error = %v", err)
+	}
+}
+
+func TestLoginGuard_IPLimit(t *testing.T) {
+	ctx := context.Background()
+	guard := NewLoginGuard(store.NewMemoryLoginAttemptStore(), LoginPolicy{MaxUserFailures: 100, MaxIPFailures: 3}, &recordingAuditor{})
+
+	// Spraying different usernames from one IP trips the IP limit.
+	for _, name := range []string{"a", "b", "c"} {
+		guard.Failure(ctx, name, "10.0.0.9", uuid.Nil)
+	}
+	if err := guard.Check(ctx, "d", "10.0.0.9"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Check() from locked IP error = %v, want ErrTooManyAttempts", err)
+	}
+	if err := guard.Check(ctx, "d", "10.0.0.10"); err != nil {
+		t.Errorf("Check() from other IP error = %v", err)
+	}
+}
+
+func TestAuthService_Login_LockedOut(t *testing.T) {
+	auditor := &recordingAuditor{}
+	guard := NewLoginGuard(store.NewMemoryLoginAttemptStore(), LoginPolicy{MaxUserFailures: 3, MaxIPFailures: 100}, auditor)
+	jwtManager := jwt.NewJWTManager("test-secret", 15*time.Minute, 168*time.Hour)
+	authService := NewAuthService(newMockUserRepository(), newMockSessionRepository(), newMockUserTokenRepository(), newMockMFARepository(), jwtManager, AuthOptions{LoginGuard: guard})
+	client := ClientInfo{IP: "10.0.0.1"}
+
+	if _, _, _, err := authService.Register("testuser", "test@example.com", "password123", ClientInfo{}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	// A success resets the username's counter.
+	for i := 0; i < 2; i++ {
+		if _, _, _, err := authService.Login("testuser", "wrong", client); err != ErrInvalidCredentials {
+			t.Fatalf("Login() error = %v, want ErrInvalidCredentials", err)
+		}
+	}
+	if _, _, _, err := authService.Login("testuser", "password123", client); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if got := auditor.count(AuditLoginSucceeded); got != 1 {
+		t.Errorf("login after failures events = %d, want 1", got)
+	}
+	for i := 0; i < 2; i++ {
+		_, _, _, _ = authService.Login("testuser", "wrong", client)
+	}
+	if _, _, _, err := authService.Login("testuser", "password123", client); err != nil {
+		t.Fatalf("Login() after reset error = %v", err)
+	}
+
+	// Three failures lock the account, even for the correct password.
+	for i := 0; i < 3; i++ {
+		_, _, _, _ = authService.Login("testuser", "wrong", client)
+	}
+	_, _, _, err := authService.Login("testuser", "password123", client)
+	if !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("Login() while locked error = %v, want ErrTooManyAttempts", err)
+	}
+
+	// Unknown usernames are counted the same way.
+	for i := 0; i < 3; i++ {
+		_, _, _, _ = authService.Login("ghost", "x", client)
+	}
+	if _, _, _, err := authService.Login("ghost", "x", client); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Login(unknown) error = %v, want ErrTooManyAttempts", err)
+	}
+}
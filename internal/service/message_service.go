@@ -14,6 +14,7 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"unicode/utf8"
@@ -30,6 +31,7 @@ var (
 
 const (
 	MaxMessageContentLength = 64 * 1024 // 64KB
+	MaxMessageMetadataBytes = 16 * 1024 // 16KB of JSON
 )
 
 // MessageNotifier is called after a message is persisted (e.g. to broadcast via WebSocket).
@@ -40,8 +42,16 @@ type MessageNotifier interface {
 
 // MessageService defines message operations.
 type MessageService interface {
-	Create(conversationID, senderID uuid.UUID, content string, msgType model.MessageType) (*model.Message, error)
+	// Create persists a message. metadata may be nil; otherwise it is stored as the message's
+	// JSON metadata (e.g. attachments of an incoming webhook). Mentions in text and emote
+	// messages are added to the metadata and counted for the mentioned users. Refused messages
+	// error with ErrNotParticipant, ErrInvalidInput, a *SendRestrictionError (group moderation),
+	// ErrMentionAllNotAllowed or ErrMentionRateLimited.
+	Create(conversationID, senderID uuid.UUID, content string, msgType model.MessageType, metadata map[string]interface{}) (*model.Message, error)
 	ListByConversationID(conversationID, userID uuid.UUID, limit, offset int, beforeID *int64) ([]*model.Message, error)
+	// ListSince returns up to limit messages after afterID, oldest first, for clients catching
+	// up after reconnecting (channel messages are not queued for offline subscribers).
+	ListSince(conversationID, userID uuid.UUID, afterID int64, limit int) ([]*model.Message, error)
 }
 
 type messageService struct {
@@ -60,8 +70,8 @@ func NewMessageService(msgRepo repository.MessageRepository, convSvc Conversatio
 }
 
 // Create validates, persists a message, and optionally notifies (e.g. WebSocket broadcast).
-func (s *messageService) Create(conversationID, senderID uuid.UUID, content string, msgType model.MessageType) (*model.Message, error) {
-	if err := s.convSvc.EnsureUserInConversation(conversationID, senderID); err != nil {
+func (s *messageService) Create(conversationID, senderID uuid.UUID, content string, msgType model.MessageType, metadata map[string]interface{}) (*model.Message, error) {
+	if err := s.convSvc.CheckSend(conversationID, senderID); err != nil {
 		return nil, err
 	}
 	content = trimContent(content)
@@ -74,12 +84,38 @@ func (s *messageService) Create(conversationID, senderID uuid.UUID, content stri
 	if msgType == "" {
 		msgType = model.MessageTypeText
 	}
+	if msgType == model.MessageTypeSystem {
+		return nil, fmt.Errorf("%w: system messages are created by the server", ErrInvalidInput)
+	}
 	msg := &model.Message{
 		ConversationID: conversationID,
 		SenderID:       senderID,
 		Content:        content,
 		MessageType:    msgType,
 	}
+	if msgType == model.MessageTypeText || msgType == model.MessageTypeEmote {
+		mentions, err := s.convSvc.ResolveMentions(conversationID, senderID, content)
+		if err != nil {
+			return nil, err
+		}
+		if mentions != nil {
+			metadata = mergeMetadata(metadata, mentions.Metadata())
+			for _, uid := range mentions.Notified {
+				msg.Mentions = append(msg.Mentions, model.MessageMention{UserID: uid, ConversationID: conversationID})
+			}
+		}
+	}
+	if len(metadata) > 0 {
+		raw, err := json.Marshal(metadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid metadata", ErrInvalidInput)
+		}
+		if len(raw) > MaxMessageMetadataBytes {
+			return nil, fmt.Errorf("%w: metadata too large", ErrInvalidInput)
+		}
+		encoded := string(raw)
+		msg.Metadata = &encoded
+	}
 	if err := s.msgRepo.Create(msg); err != nil {
 		return nil, fmt.Errorf("create message: %w", err)
 	}
@@ -89,12 +125,52 @@ func (s *messageService) Create(conversationID, senderID uuid.UUID, content stri
 	return msg, nil
 }
 
-// ListByConversationID returns messages for a conversation if the user is a participant.
+// ListByConversationID returns messages for a conversation if the user is a participant,
+// without the history the user cleared. System messages are rendered with current names.
 func (s *messageService) ListByConversationID(conversationID, userID uuid.UUID, limit, offset int, beforeID *int64) ([]*model.Message, error) {
-	if err := s.convSvc.EnsureUserInConversation(conversationID, userID); err != nil {
+	start, err := s.convSvc.HistoryStart(conversationID, userID)
+	if err != nil {
 		return nil, err
 	}
-	return s.msgRepo.ListByConversationID(conversationID, limit, offset, beforeID)
+	var afterID *int64
+	if start > 0 {
+		afterID = &start
+	}
+	msgs, err := s.msgRepo.ListByConversationID(conversationID, limit, offset, beforeID, afterID)
+	if err != nil {
+		return nil, err
+	}
+	s.convSvc.RenderSystemMessages(msgs)
+	return msgs, nil
+}
+
+// ListSince returns the messages following afterID the user may see, oldest first.
+func (s *messageService) ListSince(conversationID, userID uuid.UUID, afterID int64, limit int) ([]*model.Message, error) {
+	start, err := s.convSvc.HistoryStart(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if start > afterID {
+		afterID = start
+	}
+	msgs, err := s.msgRepo.ListAfter(conversationID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	s.convSvc.RenderSystemMessages(msgs)
+	return msgs, nil
+}
+
+// mergeMetadata returns the entries of both maps without modifying them; extra wins on conflicts.
+func mergeMetadata(metadata, extra map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(metadata)+len(extra))
+	for k, v := range metadata {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
 }
 
 func trimContent(s string) string {
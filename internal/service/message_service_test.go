@@ -8,18 +8,23 @@ package service
 
 import (
 	"errors"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/convexwf/uim-go/internal/model"
 	"github.com/convexwf/uim-go/internal/repository"
+	"github.com/convexwf/uim-go/internal/store"
 )
 
 type mockMessageRepo struct {
 	createErr error
 	listMsgs  []*model.Message
 	listErr   error
+	afterID   *int64
 }
 
 func (m *mockMessageRepo) Create(msg *model.Message) error {
@@ -31,7 +36,12 @@ func (m *mockMessageRepo) Create(msg *model.Message) error {
 	}
 	return nil
 }
-func (m *mockMessageRepo) ListByConversationID(convID uuid.UUID, limit, offset int, beforeID *int64) ([]*model.Message, error) {
+func (m *mockMessageRepo) ListByConversationID(convID uuid.UUID, limit, offset int, beforeID, afterID *int64) ([]*model.Message, error) {
+	m.afterID = afterID
+	return m.listMsgs, m.listErr
+}
+func (m *mockMessageRepo) ListAfter(conversationID uuid.UUID, afterID int64, limit int) ([]*model.Message, error) {
+	m.afterID = &afterID
 	return m.listMsgs, m.listErr
 }
 func (m *mockMessageRepo) GetByID(messageID int64) (*model.Message, error) {
@@ -40,10 +50,22 @@ func (m *mockMessageRepo) GetByID(messageID int64) (*model.Message, error) {
 func (m *mockMessageRepo) GetLastMessagesByConversationIDs(conversationIDs []uuid.UUID) (map[uuid.UUID]*model.Message, error) {
 	return nil, nil
 }
+func (m *mockMessageRepo) LastSentAt(conversationID, senderID uuid.UUID) (*time.Time, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) LastIDBefore(conversationID uuid.UUID, t time.Time) (int64, error) {
+	return 0, nil
+}
 
-// mockConvServiceForMessage only implements EnsureUserInConversation behavior for message tests.
+// mockConvServiceForMessage only implements EnsureUserInConversation and CheckSend behavior for
+// message tests.
 type mockConvServiceForMessage struct {
-	ensureErr error
+	ensureErr    error
+	sendErr      error
+	mentions     *Mentions
+	mentionsErr  error
+	historyStart int64
+	rendered     int
 }
 
 func (m *mockConvServiceForMessage) CreateOneOnOne(creatorID, otherUserID uuid.UUID) (*model.Conversation, error) {
@@ -55,7 +77,7 @@ func (m *mockConvServiceForMessage) GetByID(conversationID, userID uuid.UUID) (*
 func (m *mockConvServiceForMessage) ListByUserID(userID uuid.UUID, limit, offset int) ([]*model.Conversation, error) {
 	return nil, nil
 }
-func (m *mockConvServiceForMessage) ListByUserIDWithMeta(userID uuid.UUID, limit, offset int) ([]*ConversationWithMeta, error) {
+func (m *mockConvServiceForMessage) ListByUserIDWithMeta(userID uuid.UUID, limit, offset int, filter ConversationListFilter) ([]*ConversationWithMeta, error) {
 	return nil, nil
 }
 func (m *mockConvServiceForMessage) MarkRead(conversationID, userID uuid.UUID, lastReadMessageID int64) error {
@@ -64,9 +86,112 @@ func (m *mockConvServiceForMessage) MarkRead(conversationID, userID uuid.UUID, l
 func (m *mockConvServiceForMessage) DeleteConversation(conversationID, userID uuid.UUID) error {
 	return nil
 }
+func (m *mockConvServiceForMessage) ClearHistory(conversationID, userID uuid.UUID, upToMessageID int64) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) PurgeConversation(conversationID, operatorID uuid.UUID) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) HistoryStart(conversationID, userID uuid.UUID) (int64, error) {
+	return m.historyStart, m.ensureErr
+}
+func (m *mockConvServiceForMessage) SetHistoryVisibility(conversationID, operatorID uuid.UUID, visibility string, days int) (*model.Conversation, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) CreateChannel(creatorID uuid.UUID, name string) (*model.Conversation, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) CreateInvite(conversationID, operatorID uuid.UUID, opts InviteOptions) (*model.ConversationInvite, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) ListInvites(conversationID, operatorID uuid.UUID) ([]*model.ConversationInvite, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) RevokeInvite(conversationID, operatorID uuid.UUID, code string) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) JoinByInvite(code string, userID uuid.UUID) (*JoinResult, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) SetJoinApproval(conversationID, operatorID uuid.UUID, enabled bool) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) ListJoinRequests(conversationID, operatorID uuid.UUID) ([]*model.ConversationJoinRequest, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) DecideJoinRequest(conversationID, operatorID uuid.UUID, requestID int64, approve bool) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) RenameGroup(conversationID, operatorID uuid.UUID, name string) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) RenderSystemMessages(msgs []*model.Message) {
+	m.rendered += len(msgs)
+}
 func (m *mockConvServiceForMessage) EnsureUserInConversation(conversationID, userID uuid.UUID) error {
 	return m.ensureErr
 }
+func (m *mockConvServiceForMessage) CheckSend(conversationID, senderID uuid.UUID) error {
+	if m.ensureErr != nil {
+		return m.ensureErr
+	}
+	return m.sendErr
+}
+func (m *mockConvServiceForMessage) UpdateModeration(conversationID, operatorID uuid.UUID, u ModerationUpdate) (*model.Conversation, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) SilenceMember(conversationID, operatorID, userID uuid.UUID, d time.Duration) (*time.Time, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) UnsilenceMember(conversationID, operatorID, userID uuid.UUID) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) SetMemberRole(conversationID, operatorID, userID uuid.UUID, role string) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) TransferOwnership(conversationID, ownerID, newOwnerID uuid.UUID) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) LeaveGroup(conversationID, userID uuid.UUID) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) RemoveMember(conversationID, operatorID, userID uuid.UUID) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) OnAccountDeleted(userID uuid.UUID) {}
+func (m *mockConvServiceForMessage) CreateGroup(creatorID uuid.UUID, name string, memberIDs []uuid.UUID) (*model.Conversation, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) AddMembers(conversationID, operatorID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) SetTopic(conversationID, operatorID uuid.UUID, topic string) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) SetMutedUntil(conversationID, userID uuid.UUID, until *time.Time) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) UpdateSettings(conversationID, userID uuid.UUID, u ConversationSettingsUpdate) (*model.ConversationParticipant, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) GetUnreadTotal(userID uuid.UUID) (int, error) {
+	return 0, nil
+}
+func (m *mockConvServiceForMessage) ResolveMentions(conversationID, senderID uuid.UUID, content string) (*Mentions, error) {
+	return m.mentions, m.mentionsErr
+}
+func (m *mockConvServiceForMessage) GetDetail(conversationID, userID uuid.UUID) (*ConversationDetail, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) PinMessage(conversationID, operatorID uuid.UUID, messageID int64) (*model.PinnedMessage, error) {
+	return nil, nil
+}
+func (m *mockConvServiceForMessage) UnpinMessage(conversationID, operatorID uuid.UUID, messageID int64) error {
+	return nil
+}
+func (m *mockConvServiceForMessage) SetAnnouncement(conversationID, operatorID uuid.UUID, text string) (*model.Announcement, error) {
+	return nil, nil
+}
 
 type mockNotifier struct {
 	called   bool
@@ -86,7 +211,7 @@ func TestMessageService_Create_NotParticipant(t *testing.T) {
 	msgRepo := &mockMessageRepo{}
 	convSvc := &mockConvServiceForMessage{ensureErr: ErrNotParticipant}
 	svc := NewMessageService(msgRepo, convSvc, nil)
-	_, err := svc.Create(convID, senderID, "hello", model.MessageTypeText)
+	_, err := svc.Create(convID, senderID, "hello", model.MessageTypeText, nil)
 	if err == nil {
 		t.Fatal("expected error when not participant")
 	}
@@ -101,7 +226,7 @@ func TestMessageService_Create_EmptyContent(t *testing.T) {
 	msgRepo := &mockMessageRepo{}
 	convSvc := &mockConvServiceForMessage{}
 	svc := NewMessageService(msgRepo, convSvc, nil)
-	_, err := svc.Create(convID, senderID, "   ", model.MessageTypeText)
+	_, err := svc.Create(convID, senderID, "   ", model.MessageTypeText, nil)
 	if err == nil {
 		t.Fatal("expected error for empty content")
 	}
@@ -117,7 +242,7 @@ func TestMessageService_Create_Success_NotifierCalled(t *testing.T) {
 	convSvc := &mockConvServiceForMessage{}
 	notifier := &mockNotifier{}
 	svc := NewMessageService(msgRepo, convSvc, notifier)
-	msg, err := svc.Create(convID, senderID, "hello", model.MessageTypeText)
+	msg, err := svc.Create(convID, senderID, "hello", model.MessageTypeText, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -135,6 +260,67 @@ func TestMessageService_Create_Success_NotifierCalled(t *testing.T) {
 	}
 }
 
+func TestMessageService_Create_Mentions(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	senderID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	bobID := uuid.MustParse("b0000000-0000-0000-0000-000000000002")
+	convSvc := &mockConvServiceForMessage{mentions: &Mentions{UserIDs: []uuid.UUID{bobID}, Notified: []uuid.UUID{bobID}}}
+	svc := NewMessageService(&mockMessageRepo{}, convSvc, nil)
+	metadata := map[string]interface{}{"source": "test"}
+	msg, err := svc.Create(convID, senderID, "hi @bob", model.MessageTypeText, metadata)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(msg.Mentions) != 1 || msg.Mentions[0].UserID != bobID || msg.Mentions[0].ConversationID != convID {
+		t.Errorf("mentions = %+v, want bob", msg.Mentions)
+	}
+	if msg.Metadata == nil || !strings.Contains(*msg.Metadata, bobID.String()) || !strings.Contains(*msg.Metadata, `"source":"test"`) {
+		t.Errorf("metadata = %v, want mentions merged with the caller's metadata", msg.Metadata)
+	}
+	if _, ok := metadata[metadataKeyMentions]; ok {
+		t.Error("the caller's metadata map was modified")
+	}
+}
+
+func TestMessageService_Create_MentionAllRefused(t *testing.T) {
+	convSvc := &mockConvServiceForMessage{mentionsErr: ErrMentionAllNotAllowed}
+	notifier := &mockNotifier{}
+	svc := NewMessageService(&mockMessageRepo{}, convSvc, notifier)
+	_, err := svc.Create(uuid.New(), uuid.New(), "@all lunch?", model.MessageTypeText, nil)
+	if !errors.Is(err, ErrMentionAllNotAllowed) {
+		t.Fatalf("expected ErrMentionAllNotAllowed, got %v", err)
+	}
+	if notifier.called {
+		t.Error("refused message must not be sent")
+	}
+}
+
+func TestMessageService_Create_SendRestricted(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	notifier := &mockNotifier{}
+	restriction := &SendRestrictionError{Reason: ErrSlowMode, RetryAfter: 5 * time.Second}
+	svc := NewMessageService(&mockMessageRepo{}, &mockConvServiceForMessage{sendErr: restriction}, notifier)
+	_, err := svc.Create(convID, userID, "hi", model.MessageTypeText, nil)
+	var restricted *SendRestrictionError
+	if !errors.As(err, &restricted) || !errors.Is(err, ErrSlowMode) || restricted.RetryAfter != 5*time.Second {
+		t.Errorf("expected the slow mode restriction, got %v", err)
+	}
+	if notifier.called {
+		t.Error("refused message must not be broadcast")
+	}
+}
+
+func TestMessageService_Create_SystemTypeRefused(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	msgRepo := &mockMessageRepo{}
+	svc := NewMessageService(msgRepo, &mockConvServiceForMessage{}, nil)
+	if _, err := svc.Create(convID, userID, "fake join", model.MessageTypeSystem, nil); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+}
+
 func TestMessageService_ListByConversationID_NotParticipant(t *testing.T) {
 	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
 	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
@@ -166,8 +352,167 @@ func TestMessageService_ListByConversationID_Success(t *testing.T) {
 	if len(msgs) != 1 || msgs[0].Content != "hi" {
 		t.Errorf("expected list with one message hi, got %v", msgs)
 	}
+	if convSvc.rendered != 1 {
+		t.Errorf("expected the page to be rendered, got %d messages", convSvc.rendered)
+	}
+	if msgRepo.afterID != nil {
+		t.Errorf("full history should not set afterID, got %d", *msgRepo.afterID)
+	}
+}
+
+func TestMessageService_ListByConversationID_ClearedHistory(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	msgRepo := &mockMessageRepo{}
+	svc := NewMessageService(msgRepo, &mockConvServiceForMessage{historyStart: 7}, nil)
+	if _, err := svc.ListByConversationID(convID, userID, 50, 0, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msgRepo.afterID == nil || *msgRepo.afterID != 7 {
+		t.Errorf("afterID = %v, want 7", msgRepo.afterID)
+	}
 }
 
 var _ repository.MessageRepository = (*mockMessageRepo)(nil)
 var _ ConversationService = (*mockConvServiceForMessage)(nil)
 var _ MessageNotifier = (*mockNotifier)(nil)
+
+func TestMessageService_ListSince(t *testing.T) {
+	convID := uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	userID := uuid.MustParse("b0000000-0000-0000-0000-000000000001")
+	msgRepo := &mockMessageRepo{listMsgs: []*model.Message{{MessageID: 11, ConversationID: convID, MessageType: model.MessageTypeText}}}
+	convSvc := &mockConvServiceForMessage{historyStart: 5}
+	svc := NewMessageService(msgRepo, convSvc, nil)
+
+	msgs, err := svc.ListSince(convID, userID, 10, 50)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(msgs) != 1 || msgRepo.afterID == nil || *msgRepo.afterID != 10 {
+		t.Errorf("expected messages after 10, got %v (after %v)", msgs, msgRepo.afterID)
+	}
+	// The hidden history still bounds a client that asks for older messages.
+	if _, err := svc.ListSince(convID, userID, 2, 50); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *msgRepo.afterID != 5 {
+		t.Errorf("expected the history start 5 as bound, got %d", *msgRepo.afterID)
+	}
+
+	convSvc.ensureErr = ErrNotParticipant
+	if _, err := svc.ListSince(convID, userID, 0, 50); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("expected ErrNotParticipant, got %v", err)
+	}
+}
+
+// queryCountingConvRepo counts the conversation reads of a message send that reach the database.
+type queryCountingConvRepo struct {
+	repository.ConversationRepository
+	queries *atomic.Int64
+}
+
+func (r queryCountingConvRepo) GetByID(conversationID uuid.UUID) (*model.Conversation, error) {
+	r.queries.Add(1)
+	return r.ConversationRepository.GetByID(conversationID)
+}
+func (r queryCountingConvRepo) GetParticipant(conversationID, userID uuid.UUID) (*model.ConversationParticipant, error) {
+	r.queries.Add(1)
+	return r.ConversationRepository.GetParticipant(conversationID, userID)
+}
+func (r queryCountingConvRepo) GetSendPolicy(conversationID uuid.UUID) (*model.SendPolicy, error) {
+	r.queries.Add(1)
+	return r.ConversationRepository.GetSendPolicy(conversationID)
+}
+func (r queryCountingConvRepo) IsParticipant(conversationID, userID uuid.UUID) (bool, error) {
+	r.queries.Add(1)
+	return r.ConversationRepository.IsParticipant(conversationID, userID)
+}
+func (r queryCountingConvRepo) GetParticipantUserIDs(conversationID uuid.UUID) ([]uuid.UUID, error) {
+	r.queries.Add(1)
+	return r.ConversationRepository.GetParticipantUserIDs(conversationID)
+}
+
+// queryCountingMessageRepo counts message inserts without keeping the messages.
+type queryCountingMessageRepo struct {
+	mockMessageRepoForConv
+	queries *atomic.Int64
+}
+
+func (r *queryCountingMessageRepo) Create(msg *model.Message) error {
+	r.queries.Add(1)
+	return nil
+}
+
+// queryCountingUserRepo counts the user lookups of mention resolution.
+type queryCountingUserRepo struct {
+	mockUserRepo
+	queries *atomic.Int64
+}
+
+func (r *queryCountingUserRepo) GetByIDs(userIDs []uuid.UUID) ([]*model.User, error) {
+	r.queries.Add(1)
+	return r.mockUserRepo.GetByIDs(userIDs)
+}
+
+// hubLookup stands in for the WebSocket hub, which loads the participants of every new message.
+type hubLookup struct {
+	convRepo repository.ConversationRepository
+}
+
+func (h hubLookup) NotifyNewMessage(conversationID uuid.UUID, msg *model.Message) {
+	_, _ = h.convRepo.GetParticipantUserIDs(conversationID)
+}
+
+// BenchmarkMessageCreate runs MessageService.Create, then the hub's participant lookup, for a
+// member of a 50-member group, with one membership change per 1000 sends. db_queries/op counts
+// every repository call that reaches the database and membership_queries/op the conversation
+// reads among them (CheckSend's membership and send policy, mentions and the hub's participant
+// list). With the cache only the message insert, and mention name lookups, remain.
+func BenchmarkMessageCreate(b *testing.B) {
+	for _, bc := range []struct {
+		name    string
+		cached  bool
+		content string
+	}{
+		{"uncached", false, "hello"},
+		{"cached", true, "hello"},
+		{"uncached_mention", false, "@bob hello"},
+		{"cached_mention", true, "@bob hello"},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			convID := uuid.New()
+			senderID, bobID := uuid.New(), uuid.New()
+			mock := &mockConversationRepo{
+				getByIDConv:  &model.Conversation{ConversationID: convID, Type: model.ConversationTypeGroup},
+				participants: make(map[uuid.UUID]*model.ConversationParticipant),
+			}
+			for _, uid := range append([]uuid.UUID{senderID, bobID}, make([]uuid.UUID, 48)...) {
+				if uid == uuid.Nil {
+					uid = uuid.New()
+				}
+				mock.participants[uid] = &model.ConversationParticipant{ConversationID: convID, UserID: uid, Role: model.ParticipantRoleMember}
+			}
+			queries, membershipQueries := &atomic.Int64{}, &atomic.Int64{}
+			var convRepo repository.ConversationRepository = queryCountingConvRepo{ConversationRepository: mock, queries: membershipQueries}
+			if bc.cached {
+				convRepo = repository.NewCachedConversationRepository(convRepo, store.NewMemoryMembershipCache(100, 1000, time.Minute))
+			}
+			msgRepo := &queryCountingMessageRepo{queries: queries}
+			userRepo := &queryCountingUserRepo{mockUserRepo: mockUserRepo{getByIDsUsers: []*model.User{{UserID: bobID, Username: "bob"}}}, queries: queries}
+			convSvc := NewConversationService(convRepo, userRepo, msgRepo, &mockPinRepo{}, &mockInviteRepo{}, nil, nil, nil, ConversationOptions{})
+			svc := NewMessageService(msgRepo, convSvc, hubLookup{convRepo: convRepo})
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if i%1000 == 999 {
+					_ = convRepo.AddParticipant(&model.ConversationParticipant{ConversationID: convID, UserID: uuid.New()})
+				}
+				if _, err := svc.Create(convID, senderID, bc.content, model.MessageTypeText, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(queries.Load()+membershipQueries.Load())/float64(b.N), "db_queries/op")
+			b.ReportMetric(float64(membershipQueries.Load())/float64(b.N), "membership_queries/op")
+		})
+	}
+}
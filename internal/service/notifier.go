@@ -0,0 +1,51 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: notifier.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Hook points for conversation events (WebSocket hub, outgoing webhooks)
+
+package service
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// ConversationEventNotifier is told about conversation events other than new messages, e.g.
+// model.EventMemberJoined. actorID is the user who caused the event (uuid.Nil if none).
+// Implementations must not block.
+type ConversationEventNotifier interface {
+	NotifyConversationEvent(conversationID, actorID uuid.UUID, event string, data map[string]interface{})
+}
+
+// MessageNotifiers fans new messages out to several notifiers (e.g. the WebSocket hub and the
+// webhook dispatcher). Nil entries are skipped.
+type MessageNotifiers []MessageNotifier
+
+// NotifyNewMessage implements MessageNotifier.
+func (n MessageNotifiers) NotifyNewMessage(conversationID uuid.UUID, msg *model.Message) {
+	for _, notifier := range n {
+		if notifier != nil {
+			notifier.NotifyNewMessage(conversationID, msg)
+		}
+	}
+}
+
+// ConversationEventNotifiers fans conversation events out to several notifiers. Nil entries are skipped.
+type ConversationEventNotifiers []ConversationEventNotifier
+
+// NotifyConversationEvent implements ConversationEventNotifier.
+func (n ConversationEventNotifiers) NotifyConversationEvent(conversationID, actorID uuid.UUID, event string, data map[string]interface{}) {
+	for _, notifier := range n {
+		if notifier != nil {
+			notifier.NotifyConversationEvent(conversationID, actorID, event, data)
+		}
+	}
+}
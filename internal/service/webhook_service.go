@@ -0,0 +1,241 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: webhook_service.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Management of outgoing conversation webhooks and their delivery log
+
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/repository"
+	"github.com/convexwf/uim-go/internal/webhook"
+)
+
+var (
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrWebhookLimit         = errors.New("webhook limit reached")
+	ErrNotConversationOwner = errors.New("only the conversation owner may do this")
+)
+
+const (
+	maxWebhooksPerConversation = 10
+	maxWebhookURLLength        = 2000
+	webhookSecretBytes         = 32
+	maxDeliveryListLimit       = 100
+)
+
+// WebhookOptions configures WebhookService.
+type WebhookOptions struct {
+	// RequireHTTPS refuses plain http:// webhook URLs (production).
+	RequireHTTPS bool
+	// AllowPrivateNetworks accepts loopback, private and link-local hosts (local development).
+	// The dispatcher re-checks every resolved address when it connects.
+	AllowPrivateNetworks bool
+}
+
+// WebhookService manages the outgoing webhooks of conversations. Only owners manage webhooks:
+// the owner of a group, or either participant of a 1:1 conversation.
+type WebhookService interface {
+	// CreateWebhook registers a URL for the given events (all events if empty). The returned
+	// webhook carries the signing secret; it is not shown again.
+	CreateWebhook(userID, conversationID uuid.UUID, rawURL string, events []string) (*model.Webhook, error)
+	ListWebhooks(userID, conversationID uuid.UUID) ([]*model.Webhook, error)
+	DeleteWebhook(userID, conversationID, webhookID uuid.UUID) error
+	// ListDeliveries returns the webhook's delivery log, newest first. status "" means any
+	// status; model.WebhookDeliveryDead lists the dead letters.
+	ListDeliveries(userID, conversationID, webhookID uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	convRepo    repository.ConversationRepository
+	opts        WebhookOptions
+}
+
+// NewWebhookService creates a new webhook service.
+func NewWebhookService(webhookRepo repository.WebhookRepository, convRepo repository.ConversationRepository, opts WebhookOptions) WebhookService {
+	return &webhookService{webhookRepo: webhookRepo, convRepo: convRepo, opts: opts}
+}
+
+// CreateWebhook validates the URL and events and stores a webhook with a fresh secret.
+func (s *webhookService) CreateWebhook(userID, conversationID uuid.UUID, rawURL string, events []string) (*model.Webhook, error) {
+	if err := s.requireOwner(conversationID, userID); err != nil {
+		return nil, err
+	}
+	rawURL = strings.TrimSpace(rawURL)
+	if err := s.validateURL(rawURL); err != nil {
+		return nil, err
+	}
+	normalized, err := normalizeEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.webhookRepo.ListByConversation(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	if len(existing) >= maxWebhooksPerConversation {
+		return nil, ErrWebhookLimit
+	}
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	webhook := &model.Webhook{
+		ConversationID: conversationID,
+		CreatedBy:      userID,
+		URL:            rawURL,
+		Secret:         "whsec_" + base64.RawURLEncoding.EncodeToString(secret),
+		Events:         strings.Join(normalized, ","),
+		CreatedAt:      time.Now(),
+	}
+	if err := s.webhookRepo.Create(webhook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	log.Printf("[WEBHOOK] created webhook_id=%s conversation_id=%s user_id=%s events=%s", webhook.WebhookID, conversationID, userID, webhook.Events)
+	return webhook, nil
+}
+
+// ListWebhooks returns the conversation's webhooks.
+func (s *webhookService) ListWebhooks(userID, conversationID uuid.UUID) ([]*model.Webhook, error) {
+	if err := s.requireOwner(conversationID, userID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.ListByConversation(conversationID)
+}
+
+// DeleteWebhook removes the webhook and its delivery log; pending retries are dropped.
+func (s *webhookService) DeleteWebhook(userID, conversationID, webhookID uuid.UUID) error {
+	if _, err := s.ownedWebhook(userID, conversationID, webhookID); err != nil {
+		return err
+	}
+	if err := s.webhookRepo.Delete(webhookID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	log.Printf("[WEBHOOK] deleted webhook_id=%s conversation_id=%s user_id=%s", webhookID, conversationID, userID)
+	return nil
+}
+
+// ListDeliveries returns the most recent deliveries (at most 100).
+func (s *webhookService) ListDeliveries(userID, conversationID, webhookID uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error) {
+	switch status {
+	case "", model.WebhookDeliveryPending, model.WebhookDeliverySucceeded, model.WebhookDeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: unknown delivery status %q", ErrInvalidInput, status)
+	}
+	if _, err := s.ownedWebhook(userID, conversationID, webhookID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxDeliveryListLimit {
+		limit = maxDeliveryListLimit
+	}
+	return s.webhookRepo.ListDeliveries(webhookID, status, limit)
+}
+
+// ownedWebhook checks ownership of the conversation and that the webhook belongs to it.
+func (s *webhookService) ownedWebhook(userID, conversationID, webhookID uuid.UUID) (*model.Webhook, error) {
+	if err := s.requireOwner(conversationID, userID); err != nil {
+		return nil, err
+	}
+	webhook, err := s.webhookRepo.GetByID(webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhook: %w", err)
+	}
+	if webhook == nil || webhook.ConversationID != conversationID {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) requireOwner(conversationID, userID uuid.UUID) error {
+	return requireConversationOwner(s.convRepo, conversationID, userID)
+}
+
+// requireConversationOwner allows group owners and both participants of a 1:1 conversation.
+func requireConversationOwner(convRepo repository.ConversationRepository, conversationID, userID uuid.UUID) error {
+	p, err := convRepo.GetParticipant(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrNotParticipant
+	}
+	conv, err := convRepo.GetByID(conversationID)
+	if err != nil {
+		return ErrConversationNotFound
+	}
+	if conv.Type != model.ConversationTypeOneOnOne && p.Role != model.ParticipantRoleOwner {
+		return ErrNotConversationOwner
+	}
+	return nil
+}
+
+func (s *webhookService) validateURL(rawURL string) error {
+	if rawURL == "" || len(rawURL) > maxWebhookURLLength {
+		return fmt.Errorf("%w: url must be 1-%d characters", ErrInvalidInput, maxWebhookURLLength)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidInput)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: url must not contain credentials", ErrInvalidInput)
+	}
+	if s.opts.RequireHTTPS && u.Scheme != "https" {
+		return fmt.Errorf("%w: url must use https", ErrInvalidInput)
+	}
+	if !s.opts.AllowPrivateNetworks {
+		host := u.Hostname()
+		if ip := net.ParseIP(host); (ip != nil && webhook.BlockedIP(ip)) || strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+			return fmt.Errorf("%w: url must point to a public host", ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// normalizeEvents validates events against model.WebhookEvents and drops duplicates. No events
+// means all events.
+func normalizeEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return model.WebhookEvents, nil
+	}
+	seen := make(map[string]bool, len(events))
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		e = strings.TrimSpace(e)
+		known := false
+		for _, k := range model.WebhookEvents {
+			if k == e {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidInput, e)
+		}
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
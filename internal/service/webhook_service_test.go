@@ -0,0 +1,199 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: webhook_service_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for outgoing webhook management
+
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+type mockWebhookRepository struct {
+	webhooks   map[uuid.UUID]*model.Webhook
+	deliveries []*model.WebhookDelivery
+}
+
+func newMockWebhookRepository() *mockWebhookRepository {
+	return &mockWebhookRepository{webhooks: make(map[uuid.UUID]*model.Webhook)}
+}
+
+func (m *mockWebhookRepository) Create(w *model.Webhook) error {
+	w.WebhookID = uuid.New()
+	m.webhooks[w.WebhookID] = w
+	return nil
+}
+
+func (m *mockWebhookRepository) GetByID(id uuid.UUID) (*model.Webhook, error) {
+	return m.webhooks[id], nil
+}
+
+func (m *mockWebhookRepository) ListByConversation(conversationID uuid.UUID) ([]*model.Webhook, error) {
+	var out []*model.Webhook
+	for _, w := range m.webhooks {
+		if w.ConversationID == conversationID {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockWebhookRepository) Delete(id uuid.UUID) error {
+	delete(m.webhooks, id)
+	return nil
+}
+
+func (m *mockWebhookRepository) CreateDelivery(d *model.WebhookDelivery) error {
+	m.deliveries = append(m.deliveries, d)
+	return nil
+}
+
+func (m *mockWebhookRepository) GetDelivery(id uuid.UUID) (*model.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *mockWebhookRepository) UpdateDelivery(d *model.WebhookDelivery) error { return nil }
+
+func (m *mockWebhookRepository) ListDeliveries(webhookID uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error) {
+	var out []*model.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockWebhookRepository) ListPendingDeliveries(limit int) ([]*model.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func newWebhookFixture(convType model.ConversationType, role string) (*webhookService, *mockWebhookRepository, uuid.UUID, uuid.UUID) {
+	userID := uuid.New()
+	convID := uuid.New()
+	convRepo := &mockConversationRepo{
+		getByIDConv: &model.Conversation{ConversationID: convID, Type: convType},
+		participant: &model.ConversationParticipant{ConversationID: convID, UserID: userID, Role: role},
+	}
+	repo := newMockWebhookRepository()
+	svc := NewWebhookService(repo, convRepo, WebhookOptions{RequireHTTPS: true}).(*webhookService)
+	return svc, repo, userID, convID
+}
+
+func TestWebhookService_CreateWebhook(t *testing.T) {
+	svc, _, userID, convID := newWebhookFixture(model.ConversationTypeGroup, model.ParticipantRoleOwner)
+	w, err := svc.CreateWebhook(userID, convID, "https://ci.example.com/hook", []string{model.EventNewMessage, model.EventNewMessage})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	if !strings.HasPrefix(w.Secret, "whsec_") || w.Events != model.EventNewMessage {
+		t.Fatalf("unexpected webhook: secret=%q events=%q", w.Secret, w.Events)
+	}
+
+	all, err := svc.CreateWebhook(userID, convID, "https://ci.example.com/all", nil)
+	if err != nil {
+		t.Fatalf("CreateWebhook (all events): %v", err)
+	}
+	if len(all.EventList()) != len(model.WebhookEvents) {
+		t.Fatalf("empty events should subscribe to all, got %q", all.Events)
+	}
+}
+
+func TestWebhookService_CreateWebhook_Validation(t *testing.T) {
+	svc, _, userID, convID := newWebhookFixture(model.ConversationTypeGroup, model.ParticipantRoleOwner)
+	cases := []struct {
+		url    string
+		events []string
+	}{
+		{"http://ci.example.com/hook", nil},          // https required
+		{"ftp://ci.example.com/hook", nil},           // scheme
+		{"/relative", nil},                           // not absolute
+		{"https://user:pw@ci.example.com/hook", nil}, // credentials
+		{"https://169.254.169.254/latest", nil},      // link-local
+		{"https://10.0.0.5/hook", nil},               // private
+		{"https://[::1]:8080/hook", nil},             // loopback
+		{"https://localhost/hook", nil},              // loopback name
+		{"https://ci.example.com/hook", []string{"typing"}},
+	}
+	for _, c := range cases {
+		if _, err := svc.CreateWebhook(userID, convID, c.url, c.events); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("CreateWebhook(%q, %v): want ErrInvalidInput, got %v", c.url, c.events, err)
+		}
+	}
+
+	for i := 0; i < maxWebhooksPerConversation; i++ {
+		if _, err := svc.CreateWebhook(userID, convID, "https://ci.example.com/hook", nil); err != nil {
+			t.Fatalf("CreateWebhook %d: %v", i, err)
+		}
+	}
+	if _, err := svc.CreateWebhook(userID, convID, "https://ci.example.com/hook", nil); !errors.Is(err, ErrWebhookLimit) {
+		t.Fatalf("want ErrWebhookLimit, got %v", err)
+	}
+}
+
+func TestWebhookService_OwnerOnly(t *testing.T) {
+	svc, _, userID, convID := newWebhookFixture(model.ConversationTypeGroup, model.ParticipantRoleMember)
+	if _, err := svc.CreateWebhook(userID, convID, "https://ci.example.com/hook", nil); !errors.Is(err, ErrNotConversationOwner) {
+		t.Fatalf("group member: want ErrNotConversationOwner, got %v", err)
+	}
+
+	// Both participants of a 1:1 conversation own it.
+	svc, _, userID, convID = newWebhookFixture(model.ConversationTypeOneOnOne, model.ParticipantRoleMember)
+	if _, err := svc.CreateWebhook(userID, convID, "https://ci.example.com/hook", nil); err != nil {
+		t.Fatalf("1:1 participant: %v", err)
+	}
+
+	svc.convRepo.(*mockConversationRepo).participant = nil
+	if _, err := svc.ListWebhooks(userID, convID); !errors.Is(err, ErrNotParticipant) {
+		t.Fatalf("non-participant: want ErrNotParticipant, got %v", err)
+	}
+}
+
+func TestWebhookService_DeleteAndDeliveries(t *testing.T) {
+	svc, repo, userID, convID := newWebhookFixture(model.ConversationTypeGroup, model.ParticipantRoleOwner)
+	w, _ := svc.CreateWebhook(userID, convID, "https://ci.example.com/hook", nil)
+	_ = repo.CreateDelivery(&model.WebhookDelivery{DeliveryID: uuid.New(), WebhookID: w.WebhookID, Status: model.WebhookDeliveryDead})
+	_ = repo.CreateDelivery(&model.WebhookDelivery{DeliveryID: uuid.New(), WebhookID: w.WebhookID, Status: model.WebhookDeliverySucceeded})
+
+	dead, err := svc.ListDeliveries(userID, convID, w.WebhookID, model.WebhookDeliveryDead, 0)
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("dead letters: got %d, err %v", len(dead), err)
+	}
+	if _, err := svc.ListDeliveries(userID, convID, w.WebhookID, "bogus", 0); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("bad status: want ErrInvalidInput, got %v", err)
+	}
+	// A webhook of another conversation is not found through this one.
+	if _, err := svc.ListDeliveries(userID, convID, uuid.New(), "", 0); !errors.Is(err, ErrWebhookNotFound) {
+		t.Fatalf("unknown webhook: want ErrWebhookNotFound, got %v", err)
+	}
+
+	if err := svc.DeleteWebhook(userID, convID, w.WebhookID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if err := svc.DeleteWebhook(userID, convID, w.WebhookID); !errors.Is(err, ErrWebhookNotFound) {
+		t.Fatalf("delete twice: want ErrWebhookNotFound, got %v", err)
+	}
+}
+
+func TestMessageNotifiers_FanOut(t *testing.T) {
+	a, b := &mockNotifier{}, &mockNotifier{}
+	convID := uuid.New()
+	n := MessageNotifiers{a, nil, b}
+	n.NotifyNewMessage(convID, &model.Message{Content: "x"})
+	if !a.called || !b.called || a.lastConv != convID || b.lastConv != convID {
+		t.Fatal("every notifier should be called")
+	}
+}
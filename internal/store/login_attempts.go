@@ -0,0 +1,212 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: login_attempts.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Failed login attempt counters and lockouts with Redis and in-memory implementations
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	loginFailKeyPrefix = "login:fail:"
+	loginLockKeyPrefix = "login:lock:"
+)
+
+// LoginAttemptStore counts failed logins per key (e.g. "user:alice", "ip:203.0.113.7") and
+// holds temporary lockouts.
+type LoginAttemptStore interface {
+	// RecordFailure increments the key's failure counter and returns the new count. The counter
+	// expires window after the last failure.
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	// Failures returns the key's current failure count (0 if none were recorded in the window).
+	Failures(ctx context.Context, key string) (int64, error)
+	// Lock blocks the key for d.
+	Lock(ctx context.Context, key string, d time.Duration) error
+	// LockRemaining returns how long the key stays locked (0 if not locked).
+	LockRemaining(ctx context.Context, key string) (time.Duration, error)
+	// Reset clears the failure counter and lock of the key.
+	Reset(ctx context.Context, key string) error
+}
+
+// RedisLoginAttemptStore implements LoginAttemptStore in Redis so that limits hold across
+// instances. When Redis fails, operations fall back to a local MemoryLoginAttemptStore
+// (limits then apply per instance only).
+type RedisLoginAttemptStore struct {
+	client   redis.Cmdable
+	fallback *MemoryLoginAttemptStore
+}
+
+// NewRedisLoginAttemptStore creates a login attempt store backed by Redis with an in-memory fallback.
+func NewRedisLoginAttemptStore(client redis.Cmdable) *RedisLoginAttemptStore {
+	return &RedisLoginAttemptStore{client: client, fallback: NewMemoryLoginAttemptStore()}
+}
+
+// RecordFailure increments the counter and refreshes its TTL in one transaction.
+func (s *RedisLoginAttemptStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, loginFailKeyPrefix+key)
+	pipe.Expire(ctx, loginFailKeyPrefix+key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[AUTH] login attempts: redis unavailable, using local fallback: %v", err)
+		return s.fallback.RecordFailure(ctx, key, window)
+	}
+	return incr.Val(), nil
+}
+
+// Failures reads the counter. Failures recorded in the fallback while Redis was down count too.
+func (s *RedisLoginAttemptStore) Failures(ctx context.Context, key string) (int64, error) {
+	local, _ := s.fallback.Failures(ctx, key)
+	n, err := s.client.Get(ctx, loginFailKeyPrefix+key).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Printf("[AUTH] login attempts: redis unavailable, using local fallback: %v", err)
+		return local, nil
+	}
+	return n + local, nil
+}
+
+// Lock sets the lock key with TTL d.
+func (s *RedisLoginAttemptStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	if err := s.client.Set(ctx, loginLockKeyPrefix+key, 1, d).Err(); err != nil {
+		log.Printf("[AUTH] login attempts: redis unavailable, using local fallback: %v", err)
+		return s.fallback.Lock(ctx, key, d)
+	}
+	return nil
+}
+
+// LockRemaining returns the lock key's TTL. Locks recorded in the fallback while Redis was down
+// are honored too.
+func (s *RedisLoginAttemptStore) LockRemaining(ctx context.Context, key string) (time.Duration, error) {
+	local, _ := s.fallback.LockRemaining(ctx, key)
+	ttl, err := s.client.PTTL(ctx, loginLockKeyPrefix+key).Result()
+	if err != nil {
+		log.Printf("[AUTH] login attempts: redis unavailable, using local fallback: %v", err)
+		return local, nil
+	}
+	if ttl < 0 { // -2: no key, -1: no TTL (never set by Lock)
+		ttl = 0
+	}
+	if local > ttl {
+		return local, nil
+	}
+	return ttl, nil
+}
+
+// Reset deletes the counter and lock.
+func (s *RedisLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_ = s.fallback.Reset(ctx, key)
+	if err := s.client.Del(ctx, loginFailKeyPrefix+key, loginLockKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("login attempts reset: %w", err)
+	}
+	return nil
+}
+
+// MemoryLoginAttemptStore implements LoginAttemptStore in process memory (single instance, or
+// fallback of RedisLoginAttemptStore).
+type MemoryLoginAttemptStore struct {
+	mu        sync.Mutex
+	failures  map[string]memoryCounter
+	locks     map[string]time.Time
+	lastSweep time.Time
+}
+
+type memoryCounter struct {
+	count   int64
+	expires time.Time
+}
+
+// NewMemoryLoginAttemptStore creates an in-memory login attempt store.
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		failures:  make(map[string]memoryCounter),
+		locks:     make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// RecordFailure increments the counter, restarting it if it expired.
+func (s *MemoryLoginAttemptStore) RecordFailure(_ context.Context, key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	c := s.failures[key]
+	if !now.Before(c.expires) {
+		c.count = 0
+	}
+	c.count++
+	c.expires = now.Add(window)
+	s.failures[key] = c
+	return c.count, nil
+}
+
+// Failures returns the counter, or 0 once it expired.
+func (s *MemoryLoginAttemptStore) Failures(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.failures[key]
+	if !time.Now().Before(c.expires) {
+		return 0, nil
+	}
+	return c.count, nil
+}
+
+// Lock blocks the key for d.
+func (s *MemoryLoginAttemptStore) Lock(_ context.Context, key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks[key] = time.Now().Add(d)
+	return nil
+}
+
+// LockRemaining returns how long the key stays locked.
+func (s *MemoryLoginAttemptStore) LockRemaining(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if remaining := time.Until(s.locks[key]); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+// Reset clears the counter and lock.
+func (s *MemoryLoginAttemptStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+	delete(s.locks, key)
+	return nil
+}
+
+// sweep drops expired entries at most once per memorySweepInterval. Caller must hold s.mu.
+func (s *MemoryLoginAttemptStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	for k, c := range s.failures {
+		if !now.Before(c.expires) {
+			delete(s.failures, k)
+		}
+	}
+	for k, until := range s.locks {
+		if !now.Before(until) {
+			delete(s.locks, k)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: login_attempts_test.go
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for login attempt stores
+
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testLoginAttemptStore(t *testing.T, s LoginAttemptStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := s.RecordFailure(ctx, "user:alice", time.Minute)
+		if err != nil || n != want {
+			t.Fatalf("RecordFailure() = %d, %v; want %d", n, err, want)
+		}
+	}
+	if n, _ := s.RecordFailure(ctx, "user:bob", time.Minute); n != 1 {
+		t.Errorf("RecordFailure(bob) = %d, want independent counter 1", n)
+	}
+	if n, err := s.Failures(ctx, "user:alice"); err != nil || n != 3 {
+		t.Errorf("Failures() = %d, %v; want 3", n, err)
+	}
+
+	if d, _ := s.LockRemaining(ctx, "user:alice"); d != 0 {
+		t.Errorf("LockRemaining() before Lock = %v, want 0", d)
+	}
+	if err := s.Lock(ctx, "user:alice", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := s.LockRemaining(ctx, "user:alice"); d <= 0 || d > time.Minute {
+		t.Errorf("LockRemaining() = %v, want (0, 1m]", d)
+	}
+
+	if err := s.Reset(ctx, "user:alice"); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := s.LockRemaining(ctx, "user:alice"); d != 0 {
+		t.Errorf("LockRemaining() after Reset = %v, want 0", d)
+	}
+	if n, _ := s.Failures(ctx, "user:alice"); n != 0 {
+		t.Errorf("Failures() after Reset = %d, want 0", n)
+	}
+	if n, _ := s.RecordFailure(ctx, "user:alice", time.Minute); n != 1 {
+		t.Errorf("RecordFailure() after Reset = %d, want 1", n)
+	}
+}
+
+func TestMemoryLoginAttemptStore(t *testing.T) {
+	testLoginAttemptStore(t, NewMemoryLoginAttemptStore())
+}
+
+func TestMemoryLoginAttemptStore_WindowExpiry(t *testing.T) {
+	s := NewMemoryLoginAttemptStore()
+	ctx := context.Background()
+	_, _ = s.RecordFailure(ctx, "ip:1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if n, _ := s.RecordFailure(ctx, "ip:1", time.Minute); n != 1 {
+		t.Errorf("RecordFailure() after window = %d, want 1", n)
+	}
+}
+
+func TestRedisLoginAttemptStore(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { mr.Close(); _ = client.Close() })
+
+	s := NewRedisLoginAttemptStore(client)
+	testLoginAttemptStore(t, s)
+
+	if ttl := mr.TTL(loginFailKeyPrefix + "user:bob"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("failure counter TTL = %v, want window", ttl)
+	}
+}
+
+func TestRedisLoginAttemptStore_Fallback(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { _ = client.Close() })
+	s := NewRedisLoginAttemptStore(client)
+	mr.Close()
+
+	ctx := context.Background()
+	if n, err := s.RecordFailure(ctx, "user:alice", time.Minute); err != nil || n != 1 {
+		t.Fatalf("RecordFailure() with redis down = %d, %v; want 1, nil", n, err)
+	}
+	if err := s.Lock(ctx, "user:alice", time.Minute); err != nil {
+		t.Fatalf("Lock() with redis down error = %v", err)
+	}
+	if d, err := s.LockRemaining(ctx, "user:alice"); err != nil || d <= 0 {
+		t.Errorf("LockRemaining() with redis down = %v, %v; want locked", d, err)
+	}
+}
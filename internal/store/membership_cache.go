@@ -0,0 +1,440 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: membership_cache.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Conversation membership cache with in-process LRU and Redis implementations
+
+package store
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	membershipKeyPrefix = "membership:"
+	// membershipPolicyKeyPrefix holds the encoded send policies (see MembershipCache.SetSendPolicy).
+	membershipPolicyKeyPrefix = "membership:policy:"
+	// membershipGenKeyPrefix counts the invalidations of a conversation (see MembershipVersion).
+	membershipGenKeyPrefix = "membership:gen:"
+	// membershipInvalidateChannel carries the IDs of conversations whose membership changed.
+	membershipInvalidateChannel = "membership:invalidate"
+	membershipResubscribeDelay  = time.Second
+	// membershipGenStripes is the number of invalidation counters of a MemoryMembershipCache;
+	// conversations share them by hash, which only costs an occasional skipped fill.
+	membershipGenStripes = 256
+)
+
+// MembershipCache caches the participant user IDs of conversations and, next to them, what send
+// checks need about the participants (the caller's encoded send policy). Entries expire after a
+// TTL, which bounds how long a missed invalidation can leave stale data.
+//
+// A read-through fill takes the Version before loading the participants and passes it to Set:
+// if the conversation was invalidated in between, the loaded list may predate the change and
+// Set drops it.
+type MembershipCache interface {
+	// Get returns the participants sorted by ID (see ContainsUser); false on a miss. The slice
+	// is shared and must not be modified.
+	Get(ctx context.Context, conversationID uuid.UUID) ([]uuid.UUID, bool)
+	// Version returns the conversation's invalidation count, to be passed to Set.
+	Version(ctx context.Context, conversationID uuid.UUID) MembershipVersion
+	// Set caches the participants unless the conversation was invalidated since version was
+	// taken. Lists longer than the cache's member limit are not cached.
+	Set(ctx context.Context, conversationID uuid.UUID, version MembershipVersion, userIDs []uuid.UUID)
+	// GetSendPolicy returns the conversation's send policy as passed to SetSendPolicy; false on a
+	// miss. The slice is shared and must not be modified.
+	GetSendPolicy(ctx context.Context, conversationID uuid.UUID) ([]byte, bool)
+	// SetSendPolicy caches an encoded send policy unless the conversation was invalidated since
+	// version was taken.
+	SetSendPolicy(ctx context.Context, conversationID uuid.UUID, version MembershipVersion, policy []byte)
+	// Invalidate drops the participants and the send policy, on every instance for shared caches.
+	Invalidate(ctx context.Context, conversationID uuid.UUID) error
+}
+
+// MembershipVersion identifies the invalidations a fill has seen; see MembershipCache.
+type MembershipVersion struct {
+	local  uint64
+	shared string // Redis counter; "" if it could not be read
+}
+
+// ContainsUser reports whether userID is in userIDs as returned by MembershipCache.Get.
+func ContainsUser(userIDs []uuid.UUID, userID uuid.UUID) bool {
+	i := sort.Search(len(userIDs), func(i int) bool { return bytes.Compare(userIDs[i][:], userID[:]) >= 0 })
+	return i < len(userIDs) && userIDs[i] == userID
+}
+
+// sortedUserIDs returns a sorted copy of userIDs.
+func sortedUserIDs(userIDs []uuid.UUID) []uuid.UUID {
+	out := append([]uuid.UUID(nil), userIDs...)
+	sort.Slice(out, func(i, j int) bool { return bytes.Compare(out[i][:], out[j][:]) < 0 })
+	return out
+}
+
+// MemoryMembershipCache implements MembershipCache as an in-process LRU of at most capacity
+// conversations. It is used on its own when Redis is not configured (single instance) and in
+// front of RedisMembershipCache.
+type MemoryMembershipCache struct {
+	mu         sync.Mutex
+	capacity   int
+	maxMembers int
+	ttl        time.Duration
+	order      *list.List // front is the most recently used
+	entries    map[uuid.UUID]*list.Element
+	gens       [membershipGenStripes]uint64 // invalidation counters by conversation hash
+	now        func() time.Time
+}
+
+// membershipEntry holds either or both parts of a conversation; a zero expiry means the part is
+// not cached.
+type membershipEntry struct {
+	conversationID uuid.UUID
+	userIDs        []uuid.UUID // sorted
+	expires        time.Time
+	policy         []byte
+	policyExpires  time.Time
+}
+
+// NewMemoryMembershipCache creates an LRU cache of capacity conversations with up to maxMembers
+// participants each; entries expire after ttl.
+func NewMemoryMembershipCache(capacity, maxMembers int, ttl time.Duration) *MemoryMembershipCache {
+	return &MemoryMembershipCache{
+		capacity:   capacity,
+		maxMembers: maxMembers,
+		ttl:        ttl,
+		order:      list.New(),
+		entries:    make(map[uuid.UUID]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Get returns the cached participants and marks the entry recently used.
+func (c *MemoryMembershipCache) Get(_ context.Context, conversationID uuid.UUID) ([]uuid.UUID, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[conversationID]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*membershipEntry)
+	if !c.now().Before(e.expires) {
+		e.userIDs, e.expires = nil, time.Time{}
+		c.dropIfEmpty(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.userIDs, true
+}
+
+// GetSendPolicy returns the cached send policy and marks the entry recently used.
+func (c *MemoryMembershipCache) GetSendPolicy(_ context.Context, conversationID uuid.UUID) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[conversationID]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*membershipEntry)
+	if !c.now().Before(e.policyExpires) {
+		e.policy, e.policyExpires = nil, time.Time{}
+		c.dropIfEmpty(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.policy, true
+}
+
+// Version returns the invalidation counter of the conversation's stripe.
+func (c *MemoryMembershipCache) Version(_ context.Context, conversationID uuid.UUID) MembershipVersion {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return MembershipVersion{local: c.gens[genStripe(conversationID)]}
+}
+
+// Set caches the participants, evicting the least recently used entry when full.
+func (c *MemoryMembershipCache) Set(_ context.Context, conversationID uuid.UUID, version MembershipVersion, userIDs []uuid.UUID) {
+	if len(userIDs) > c.maxMembers || c.capacity <= 0 {
+		return
+	}
+	c.set(conversationID, version.local, sortedUserIDs(userIDs))
+}
+
+// SetSendPolicy caches the policy, evicting the least recently used entry when full.
+func (c *MemoryMembershipCache) SetSendPolicy(_ context.Context, conversationID uuid.UUID, version MembershipVersion, policy []byte) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.setPolicy(conversationID, version.local, policy)
+}
+
+// set stores already sorted user IDs unless the conversation's stripe counter moved past gen.
+func (c *MemoryMembershipCache) set(conversationID uuid.UUID, gen uint64, sorted []uuid.UUID) {
+	c.update(conversationID, gen, func(e *membershipEntry, expires time.Time) {
+		e.userIDs, e.expires = sorted, expires
+	})
+}
+
+// setPolicy stores an encoded policy unless the conversation's stripe counter moved past gen.
+func (c *MemoryMembershipCache) setPolicy(conversationID uuid.UUID, gen uint64, policy []byte) {
+	c.update(conversationID, gen, func(e *membershipEntry, expires time.Time) {
+		e.policy, e.policyExpires = policy, expires
+	})
+}
+
+// update applies fill to the conversation's entry, creating it if needed, unless the stripe
+// counter moved past gen.
+func (c *MemoryMembershipCache) update(conversationID uuid.UUID, gen uint64, fill func(e *membershipEntry, expires time.Time)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gens[genStripe(conversationID)] != gen {
+		return
+	}
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.entries[conversationID]; ok {
+		fill(el.Value.(*membershipEntry), expires)
+		c.order.MoveToFront(el)
+		return
+	}
+	e := &membershipEntry{conversationID: conversationID}
+	fill(e, expires)
+	c.entries[conversationID] = c.order.PushFront(e)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate drops the entry and fails the fills in progress.
+func (c *MemoryMembershipCache) Invalidate(_ context.Context, conversationID uuid.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gens[genStripe(conversationID)]++
+	if el, ok := c.entries[conversationID]; ok {
+		c.remove(el)
+	}
+	return nil
+}
+
+// Clear drops every entry and fails the fills in progress.
+func (c *MemoryMembershipCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.gens {
+		c.gens[i]++
+	}
+	c.order.Init()
+	c.entries = make(map[uuid.UUID]*list.Element)
+}
+
+// Len returns the number of cached conversations.
+func (c *MemoryMembershipCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// genStripe maps a conversation to its invalidation counter.
+func genStripe(conversationID uuid.UUID) int {
+	return int(conversationID[15]) % membershipGenStripes
+}
+
+// dropIfEmpty deletes the entry once neither part is cached. Caller must hold c.mu.
+func (c *MemoryMembershipCache) dropIfEmpty(el *list.Element) {
+	if e := el.Value.(*membershipEntry); e.expires.IsZero() && e.policyExpires.IsZero() {
+		c.remove(el)
+	}
+}
+
+// remove deletes the entry. Caller must hold c.mu.
+func (c *MemoryMembershipCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*membershipEntry).conversationID)
+}
+
+// RedisMembershipCache implements MembershipCache with a local MemoryMembershipCache in front of
+// Redis keys shared by all instances. Invalidations delete the key and are published on a
+// channel; Listen drops the local entries named there, so every instance forgets a changed
+// membership. Invalidations also increment a per-conversation counter in Redis, which Set
+// compares atomically with the Version taken before the load, so a fill racing with an
+// invalidation on another instance is dropped too. Redis errors are logged and treated as misses.
+type RedisMembershipCache struct {
+	client redis.UniversalClient
+	local  *MemoryMembershipCache
+	ttl    time.Duration
+}
+
+// NewRedisMembershipCache creates a membership cache backed by Redis with local in front. Run
+// Listen to receive the invalidations of other instances.
+func NewRedisMembershipCache(client redis.UniversalClient, local *MemoryMembershipCache, ttl time.Duration) *RedisMembershipCache {
+	return &RedisMembershipCache{client: client, local: local, ttl: ttl}
+}
+
+// Get answers from the local cache, then from Redis (filling the local cache).
+func (c *RedisMembershipCache) Get(ctx context.Context, conversationID uuid.UUID) ([]uuid.UUID, bool) {
+	if ids, ok := c.local.Get(ctx, conversationID); ok {
+		return ids, true
+	}
+	gen := c.local.Version(ctx, conversationID).local
+	raw, err := c.client.Get(ctx, membershipKeyPrefix+conversationID.String()).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("[CACHE] membership get conversation_id=%s err=%v", conversationID, err)
+		}
+		return nil, false
+	}
+	if len(raw)%16 != 0 {
+		return nil, false
+	}
+	ids := make([]uuid.UUID, len(raw)/16)
+	for i := range ids {
+		copy(ids[i][:], raw[i*16:])
+	}
+	c.local.set(conversationID, gen, ids)
+	return ids, true
+}
+
+// GetSendPolicy answers from the local cache, then from Redis (filling the local cache).
+func (c *RedisMembershipCache) GetSendPolicy(ctx context.Context, conversationID uuid.UUID) ([]byte, bool) {
+	if policy, ok := c.local.GetSendPolicy(ctx, conversationID); ok {
+		return policy, true
+	}
+	gen := c.local.Version(ctx, conversationID).local
+	policy, err := c.client.Get(ctx, membershipPolicyKeyPrefix+conversationID.String()).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("[CACHE] send policy get conversation_id=%s err=%v", conversationID, err)
+		}
+		return nil, false
+	}
+	c.local.setPolicy(conversationID, gen, policy)
+	return policy, true
+}
+
+// Version combines the local stripe counter with the conversation's counter in Redis. The
+// local counter is read first: an invalidation published by another instance after the Redis
+// read then still fails the local fill.
+func (c *RedisMembershipCache) Version(ctx context.Context, conversationID uuid.UUID) MembershipVersion {
+	v := c.local.Version(ctx, conversationID)
+	gen, err := c.client.Get(ctx, membershipGenKeyPrefix+conversationID.String()).Result()
+	switch {
+	case err == redis.Nil:
+		v.shared = "0"
+	case err != nil:
+		log.Printf("[CACHE] membership version conversation_id=%s err=%v", conversationID, err)
+	default:
+		v.shared = gen
+	}
+	return v
+}
+
+// membershipSetScript stores the list or policy (KEYS[2]) only if the counter (KEYS[1]) still equals the
+// version the fill started with.
+var membershipSetScript = redis.NewScript(`
+if (redis.call("GET", KEYS[1]) or "0") ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[2], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
+// Set caches the participants in Redis, as their concatenated 16-byte IDs, and locally, unless
+// the conversation was invalidated since version was taken.
+func (c *RedisMembershipCache) Set(ctx context.Context, conversationID uuid.UUID, version MembershipVersion, userIDs []uuid.UUID) {
+	if len(userIDs) > c.local.maxMembers || version.shared == "" {
+		return
+	}
+	sorted := sortedUserIDs(userIDs)
+	raw := make([]byte, 0, len(sorted)*16)
+	for _, id := range sorted {
+		raw = append(raw, id[:]...)
+	}
+	keys := []string{membershipGenKeyPrefix + conversationID.String(), membershipKeyPrefix + conversationID.String()}
+	stored, err := membershipSetScript.Run(ctx, c.client, keys, version.shared, raw, c.ttl.Milliseconds()).Int()
+	if err != nil {
+		log.Printf("[CACHE] membership set conversation_id=%s err=%v", conversationID, err)
+		return
+	}
+	if stored == 1 {
+		c.local.set(conversationID, version.local, sorted)
+	}
+}
+
+// SetSendPolicy caches the policy in Redis and locally, unless the conversation was invalidated
+// since version was taken.
+func (c *RedisMembershipCache) SetSendPolicy(ctx context.Context, conversationID uuid.UUID, version MembershipVersion, policy []byte) {
+	if version.shared == "" {
+		return
+	}
+	keys := []string{membershipGenKeyPrefix + conversationID.String(), membershipPolicyKeyPrefix + conversationID.String()}
+	stored, err := membershipSetScript.Run(ctx, c.client, keys, version.shared, policy, c.ttl.Milliseconds()).Int()
+	if err != nil {
+		log.Printf("[CACHE] send policy set conversation_id=%s err=%v", conversationID, err)
+		return
+	}
+	if stored == 1 {
+		c.local.setPolicy(conversationID, version.local, policy)
+	}
+}
+
+// Invalidate drops the entry here and in Redis, increments the conversation's counter and tells
+// the other instances. The counter lives twice as long as entries; a fill outlasting that could
+// see it reset and cache a stale list until the entry expires.
+func (c *RedisMembershipCache) Invalidate(ctx context.Context, conversationID uuid.UUID) error {
+	_ = c.local.Invalidate(ctx, conversationID)
+	pipe := c.client.TxPipeline()
+	pipe.Incr(ctx, membershipGenKeyPrefix+conversationID.String())
+	pipe.PExpire(ctx, membershipGenKeyPrefix+conversationID.String(), 2*c.ttl)
+	pipe.Del(ctx, membershipKeyPrefix+conversationID.String(), membershipPolicyKeyPrefix+conversationID.String())
+	pipe.Publish(ctx, membershipInvalidateChannel, conversationID.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("membership invalidate: %w", err)
+	}
+	return nil
+}
+
+// Listen drops local entries as invalidations arrive until ctx is done. After the subscription
+// is re-established the whole local cache is dropped: invalidations may have been missed.
+func (c *RedisMembershipCache) Listen(ctx context.Context) {
+	sub := c.client.Subscribe(ctx, membershipInvalidateChannel)
+	defer sub.Close()
+	subscribed := false
+	for {
+		msg, err := sub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[CACHE] membership invalidation subscription: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(membershipResubscribeDelay):
+			}
+			continue
+		}
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if subscribed {
+				c.local.Clear()
+			}
+			subscribed = true
+		case *redis.Message:
+			if id, err := uuid.Parse(m.Payload); err == nil {
+				_ = c.local.Invalidate(ctx, id)
+			}
+		}
+	}
+}
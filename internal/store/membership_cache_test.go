@@ -0,0 +1,252 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: membership_cache_test.go
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for membership caches
+
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryMembershipCache_GetSetInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryMembershipCache(10, 100, time.Minute)
+	conv := uuid.New()
+	a, b, stranger := uuid.New(), uuid.New(), uuid.New()
+
+	if _, ok := c.Get(ctx, conv); ok {
+		t.Fatal("empty cache should miss")
+	}
+	c.Set(ctx, conv, c.Version(ctx, conv), []uuid.UUID{a, b})
+	ids, ok := c.Get(ctx, conv)
+	if !ok || len(ids) != 2 {
+		t.Fatalf("Get = %v, %v; want the two participants", ids, ok)
+	}
+	if !ContainsUser(ids, a) || !ContainsUser(ids, b) || ContainsUser(ids, stranger) {
+		t.Errorf("ContainsUser wrong for %v", ids)
+	}
+	if err := c.Invalidate(ctx, conv); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(ctx, conv); ok {
+		t.Error("invalidated entry should miss")
+	}
+}
+
+func TestMemoryMembershipCache_FillRacingInvalidationIsDropped(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryMembershipCache(10, 100, time.Minute)
+	conv := uuid.New()
+
+	version := c.Version(ctx, conv)
+	// The membership changes while the list is being loaded.
+	if err := c.Invalidate(ctx, conv); err != nil {
+		t.Fatal(err)
+	}
+	c.Set(ctx, conv, version, []uuid.UUID{uuid.New()})
+	if _, ok := c.Get(ctx, conv); ok {
+		t.Error("a list loaded before the invalidation should not be cached")
+	}
+	c.Set(ctx, conv, c.Version(ctx, conv), []uuid.UUID{uuid.New()})
+	if _, ok := c.Get(ctx, conv); !ok {
+		t.Error("a fill after the invalidation should be cached")
+	}
+}
+
+func TestMemoryMembershipCache_SendPolicy(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryMembershipCache(10, 100, time.Minute)
+	conv := uuid.New()
+
+	c.SetSendPolicy(ctx, conv, c.Version(ctx, conv), []byte(`{"type":"group"}`))
+	if _, ok := c.Get(ctx, conv); ok {
+		t.Error("caching a policy should not cache a participant list")
+	}
+	c.Set(ctx, conv, c.Version(ctx, conv), []uuid.UUID{uuid.New()})
+	if policy, ok := c.GetSendPolicy(ctx, conv); !ok || string(policy) != `{"type":"group"}` {
+		t.Fatalf("GetSendPolicy = %q, %v", policy, ok)
+	}
+
+	version := c.Version(ctx, conv)
+	if err := c.Invalidate(ctx, conv); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.GetSendPolicy(ctx, conv); ok {
+		t.Error("invalidation should drop the policy")
+	}
+	c.SetSendPolicy(ctx, conv, version, []byte(`{}`))
+	if _, ok := c.GetSendPolicy(ctx, conv); ok {
+		t.Error("a policy loaded before the invalidation should not be cached")
+	}
+}
+
+func TestMemoryMembershipCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryMembershipCache(2, 100, time.Minute)
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	c.Set(ctx, first, c.Version(ctx, first), []uuid.UUID{uuid.New()})
+	c.Set(ctx, second, c.Version(ctx, second), []uuid.UUID{uuid.New()})
+	c.Get(ctx, first) // second is now the least recently used
+	c.Set(ctx, third, c.Version(ctx, third), []uuid.UUID{uuid.New()})
+
+	if _, ok := c.Get(ctx, second); ok {
+		t.Error("least recently used entry should be evicted")
+	}
+	for _, conv := range []uuid.UUID{first, third} {
+		if _, ok := c.Get(ctx, conv); !ok {
+			t.Errorf("entry %s should be kept", conv)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+}
+
+func TestMemoryMembershipCache_ExpiryAndMemberLimit(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryMembershipCache(10, 2, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	big := uuid.New()
+	c.Set(ctx, big, c.Version(ctx, big), []uuid.UUID{uuid.New(), uuid.New(), uuid.New()})
+	if _, ok := c.Get(ctx, big); ok {
+		t.Error("lists over the member limit should not be cached")
+	}
+
+	conv := uuid.New()
+	c.Set(ctx, conv, c.Version(ctx, conv), []uuid.UUID{uuid.New()})
+	now = now.Add(time.Minute)
+	if _, ok := c.Get(ctx, conv); ok {
+		t.Error("expired entry should miss")
+	}
+}
+
+func setupRedisMembershipCaches(t *testing.T) (*RedisMembershipCache, *RedisMembershipCache) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	newInstance := func() *RedisMembershipCache {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { _ = client.Close() })
+		return NewRedisMembershipCache(client, NewMemoryMembershipCache(10, 100, time.Minute), time.Minute)
+	}
+	t.Cleanup(mr.Close)
+	return newInstance(), newInstance()
+}
+
+func TestRedisMembershipCache_SharedAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	one, two := setupRedisMembershipCaches(t)
+	conv := uuid.New()
+	a, b := uuid.New(), uuid.New()
+
+	one.Set(ctx, conv, one.Version(ctx, conv), []uuid.UUID{a, b})
+	ids, ok := two.Get(ctx, conv)
+	if !ok || len(ids) != 2 || !ContainsUser(ids, a) || !ContainsUser(ids, b) {
+		t.Fatalf("other instance Get = %v, %v; want both participants from Redis", ids, ok)
+	}
+	if _, ok := two.local.Get(ctx, conv); !ok {
+		t.Error("a Redis hit should fill the local cache")
+	}
+}
+
+func TestRedisMembershipCache_FillRacingInvalidationIsDropped(t *testing.T) {
+	ctx := context.Background()
+	one, two := setupRedisMembershipCaches(t)
+	conv := uuid.New()
+
+	// Instance two starts a fill; instance one changes the membership before it is stored.
+	version := two.Version(ctx, conv)
+	if err := one.Invalidate(ctx, conv); err != nil {
+		t.Fatal(err)
+	}
+	two.Set(ctx, conv, version, []uuid.UUID{uuid.New()})
+	if _, ok := two.local.Get(ctx, conv); ok {
+		t.Error("stale fill should not reach the local cache")
+	}
+	if _, ok := one.Get(ctx, conv); ok {
+		t.Error("stale fill should not reach Redis")
+	}
+
+	two.Set(ctx, conv, two.Version(ctx, conv), []uuid.UUID{uuid.New()})
+	if _, ok := one.Get(ctx, conv); !ok {
+		t.Error("a fill after the invalidation should be shared")
+	}
+}
+
+func TestRedisMembershipCache_SendPolicy(t *testing.T) {
+	ctx := context.Background()
+	one, two := setupRedisMembershipCaches(t)
+	conv := uuid.New()
+
+	one.SetSendPolicy(ctx, conv, one.Version(ctx, conv), []byte(`{"type":"group"}`))
+	if policy, ok := two.GetSendPolicy(ctx, conv); !ok || string(policy) != `{"type":"group"}` {
+		t.Fatalf("other instance GetSendPolicy = %q, %v; want the policy from Redis", policy, ok)
+	}
+
+	version := two.Version(ctx, conv)
+	if err := one.Invalidate(ctx, conv); err != nil {
+		t.Fatal(err)
+	}
+	two.SetSendPolicy(ctx, conv, version, []byte(`{}`))
+	if _, ok := one.GetSendPolicy(ctx, conv); ok {
+		t.Error("a policy loaded before the invalidation should not be shared")
+	}
+}
+
+func TestRedisMembershipCache_InvalidationReachesOtherInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	one, two := setupRedisMembershipCaches(t)
+	go two.Listen(ctx)
+
+	conv := uuid.New()
+	one.Set(ctx, conv, one.Version(ctx, conv), []uuid.UUID{uuid.New()})
+	if _, ok := two.Get(ctx, conv); !ok {
+		t.Fatal("second instance should load the entry")
+	}
+	// Wait for the subscription before publishing.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n, err := one.client.PubSubNumSub(ctx, membershipInvalidateChannel).Result()
+		if err == nil && n[membershipInvalidateChannel] > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("second instance did not subscribe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := one.Invalidate(ctx, conv); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, ok := two.local.Get(ctx, conv); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("second instance kept its local entry after the invalidation")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok := two.Get(ctx, conv); ok {
+		t.Error("invalidated entry should be gone from Redis too")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: oidc_state.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Pending OpenID Connect login state with Redis and in-memory implementations
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const oidcStateKeyPrefix = "oidc:state:"
+
+// OIDCState is what the server remembers between redirecting to the provider and the callback.
+// The code verifier never leaves the server.
+type OIDCState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// OIDCStateStore keeps pending logins keyed by the OAuth state parameter.
+type OIDCStateStore interface {
+	Save(ctx context.Context, state string, data OIDCState, ttl time.Duration) error
+	// Take returns and deletes the state (single use). Returns nil, nil if it is unknown or expired.
+	Take(ctx context.Context, state string) (*OIDCState, error)
+}
+
+// RedisOIDCStateStore implements OIDCStateStore in Redis so that the callback may reach any
+// instance. When Redis fails, it falls back to a local MemoryOIDCStateStore.
+type RedisOIDCStateStore struct {
+	client   redis.Cmdable
+	fallback *MemoryOIDCStateStore
+}
+
+// NewRedisOIDCStateStore creates a state store backed by Redis with an in-memory fallback.
+func NewRedisOIDCStateStore(client redis.Cmdable) *RedisOIDCStateStore {
+	return &RedisOIDCStateStore{client: client, fallback: NewMemoryOIDCStateStore()}
+}
+
+// Save stores the state as JSON with TTL.
+func (s *RedisOIDCStateStore) Save(ctx context.Context, state string, data OIDCState, ttl time.Duration) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("oidc state marshal: %w", err)
+	}
+	if err := s.client.Set(ctx, oidcStateKeyPrefix+state, b, ttl).Err(); err != nil {
+		log.Printf("[AUTH] oidc state: redis unavailable, using local fallback: %v", err)
+		return s.fallback.Save(ctx, state, data, ttl)
+	}
+	return nil
+}
+
+// Take reads and deletes the state atomically (GETDEL). States saved to the fallback while
+// Redis was down are found too.
+func (s *RedisOIDCStateStore) Take(ctx context.Context, state string) (*OIDCState, error) {
+	if local, _ := s.fallback.Take(ctx, state); local != nil {
+		return local, nil
+	}
+	b, err := s.client.GetDel(ctx, oidcStateKeyPrefix+state).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("oidc state take: %w", err)
+	}
+	var data OIDCState
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("oidc state unmarshal: %w", err)
+	}
+	return &data, nil
+}
+
+// MemoryOIDCStateStore implements OIDCStateStore in process memory (single instance, or
+// fallback of RedisOIDCStateStore).
+type MemoryOIDCStateStore struct {
+	mu        sync.Mutex
+	states    map[string]memoryOIDCState
+	lastSweep time.Time
+}
+
+type memoryOIDCState struct {
+	data    OIDCState
+	expires time.Time
+}
+
+// NewMemoryOIDCStateStore creates an in-memory state store.
+func NewMemoryOIDCStateStore() *MemoryOIDCStateStore {
+	return &MemoryOIDCStateStore{states: make(map[string]memoryOIDCState), lastSweep: time.Now()}
+}
+
+// Save stores the state until ttl passes.
+func (s *MemoryOIDCStateStore) Save(_ context.Context, state string, data OIDCState, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	s.states[state] = memoryOIDCState{data: data, expires: now.Add(ttl)}
+	return nil
+}
+
+// Take returns and deletes the state.
+func (s *MemoryOIDCStateStore) Take(_ context.Context, state string) (*OIDCState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[state]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.states, state)
+	if !time.Now().Before(st.expires) {
+		return nil, nil
+	}
+	return &st.data, nil
+}
+
+// sweep drops expired states at most once per memorySweepInterval. Caller must hold s.mu.
+func (s *MemoryOIDCStateStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	for k, st := range s.states {
+		if !now.Before(st.expires) {
+			delete(s.states, k)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: oidc_state_test.go
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for pending OpenID Connect login state stores
+
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testOIDCStateStore(t *testing.T, s OIDCStateStore) {
+	t.Helper()
+	ctx := context.Background()
+	want := OIDCState{Provider: "google", Nonce: "n", CodeVerifier: "v"}
+	if err := s.Save(ctx, "state-1", want, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Take(ctx, "state-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || *got != want {
+		t.Fatalf("Take() = %+v, want %+v", got, want)
+	}
+	if got, _ := s.Take(ctx, "state-1"); got != nil {
+		t.Error("state should be single-use")
+	}
+	if got, _ := s.Take(ctx, "unknown"); got != nil {
+		t.Error("unknown state should return nil")
+	}
+}
+
+func TestMemoryOIDCStateStore(t *testing.T) {
+	testOIDCStateStore(t, NewMemoryOIDCStateStore())
+}
+
+func TestRedisOIDCStateStore(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { mr.Close(); _ = client.Close() })
+	testOIDCStateStore(t, NewRedisOIDCStateStore(client))
+}
+
+func TestMemoryOIDCStateStore_Expiry(t *testing.T) {
+	s := NewMemoryOIDCStateStore()
+	ctx := context.Background()
+	_ = s.Save(ctx, "state-1", OIDCState{Provider: "p"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if got, _ := s.Take(ctx, "state-1"); got != nil {
+		t.Error("expired state should not be returned")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: rate_limit.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Token-bucket rate limiter with Redis and in-memory implementations
+
+package store
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const rateLimitKeyPrefix = "ratelimit:"
+
+// RateLimit is a token bucket: it holds up to Burst tokens and refills PerMinute tokens per
+// minute (or PerHour tokens per hour when PerMinute is 0, for rare actions). Each request takes
+// one token.
+type RateLimit struct {
+	PerMinute int
+	PerHour   int
+	Burst     int // 0 means PerMinute (or PerHour)
+}
+
+func (l RateLimit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	if l.PerMinute > 0 {
+		return float64(l.PerMinute)
+	}
+	return float64(l.PerHour)
+}
+
+// ratePerMs is the refill rate in tokens per millisecond.
+func (l RateLimit) ratePerMs() float64 {
+	if l.PerMinute > 0 {
+		return float64(l.PerMinute) / float64(time.Minute/time.Millisecond)
+	}
+	return float64(l.PerHour) / float64(time.Hour/time.Millisecond)
+}
+
+// ttl is how long an idle bucket is kept: after that it would be full anyway.
+func (l RateLimit) ttl() time.Duration {
+	return time.Duration(l.capacity()/l.ratePerMs())*time.Millisecond + time.Second
+}
+
+// RateLimitResult is the outcome of RateLimiter.Allow.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // whole tokens left after this request
+	RetryAfter time.Duration // when the next token is available (0 if Allowed)
+}
+
+// RateLimiter takes tokens from named buckets (e.g. "api:user:<id>", "auth:ip:203.0.113.7").
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// rateLimitResult builds the result from the tokens left in the bucket.
+func rateLimitResult(allowed bool, tokens float64, limit RateLimit) RateLimitResult {
+	res := RateLimitResult{Allowed: allowed, Remaining: int(math.Floor(tokens))}
+	if !allowed {
+		res.RetryAfter = time.Duration(math.Ceil((1-tokens)/limit.ratePerMs())) * time.Millisecond
+	}
+	return res
+}
+
+// tokenBucketScript refills and takes one token atomically. Time is passed in by the caller
+// (milliseconds) so the script stays deterministic. Returns {allowed, tokens}.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+if now > ts then
+  tokens = math.min(capacity, tokens + (now - ts) * rate)
+  ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateLimiter implements RateLimiter in Redis so that limits hold across instances.
+// When Redis fails, it falls back to a local MemoryRateLimiter (limits then apply per instance).
+type RedisRateLimiter struct {
+	client   redis.Scripter
+	fallback *MemoryRateLimiter
+}
+
+// NewRedisRateLimiter creates a rate limiter backed by Redis with an in-memory fallback.
+func NewRedisRateLimiter(client redis.Scripter) *RedisRateLimiter {
+	return &RedisRateLimiter{client: client, fallback: NewMemoryRateLimiter()}
+}
+
+// Allow takes a token from the bucket for key.
+func (s *RedisRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	now := time.Now().UnixMilli()
+	vals, err := tokenBucketScript.Run(ctx, s.client, []string{rateLimitKeyPrefix + key},
+		limit.capacity(), limit.ratePerMs(), now, limit.ttl().Milliseconds()).Slice()
+	if err != nil || len(vals) != 2 {
+		log.Printf("[RATE] redis unavailable, using local fallback: %v", err)
+		return s.fallback.Allow(ctx, key, limit)
+	}
+	allowed, _ := vals[0].(int64)
+	tokensStr, _ := vals[1].(string)
+	tokens, _ := strconv.ParseFloat(tokensStr, 64)
+	return rateLimitResult(allowed == 1, tokens, limit), nil
+}
+
+// MemoryRateLimiter implements RateLimiter in process memory (single instance, or fallback of
+// RedisRateLimiter).
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens  float64
+	last    time.Time
+	expires time.Time
+}
+
+// NewMemoryRateLimiter creates an in-memory rate limiter.
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: make(map[string]*memoryBucket), lastSweep: time.Now()}
+}
+
+// Allow takes a token from the bucket for key.
+func (s *MemoryRateLimiter) Allow(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: limit.capacity(), last: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(limit.capacity(), b.tokens+float64(elapsed)/float64(time.Millisecond)*limit.ratePerMs())
+		b.last = now
+	}
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.expires = now.Add(limit.ttl())
+	return rateLimitResult(allowed, b.tokens, limit), nil
+}
+
+// sweep drops idle buckets at most once per memorySweepInterval. Caller must hold s.mu.
+func (s *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	for k, b := range s.buckets {
+		if !now.Before(b.expires) {
+			delete(s.buckets, k)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: rate_limit_test.go
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for token-bucket rate limiters
+
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testRateLimiter(t *testing.T, s RateLimiter) {
+	t.Helper()
+	ctx := context.Background()
+	limit := RateLimit{PerMinute: 60, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		res, err := s.Allow(ctx, "api:user:1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed {
+			t.Fatalf("request %d denied within burst", i+1)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("request %d: Remaining = %d, want %d", i+1, res.Remaining, 2-i)
+		}
+	}
+	res, err := s.Allow(ctx, "api:user:1", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed {
+		t.Fatal("request beyond burst allowed")
+	}
+	// 60/min refills one token per second.
+	if res.RetryAfter <= 0 || res.RetryAfter > time.Second {
+		t.Errorf("RetryAfter = %v, want (0, 1s]", res.RetryAfter)
+	}
+
+	if res, _ := s.Allow(ctx, "api:user:2", limit); !res.Allowed {
+		t.Error("separate key should have its own bucket")
+	}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	testRateLimiter(t, NewMemoryRateLimiter())
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { mr.Close(); _ = client.Close() })
+	s := NewRedisRateLimiter(client)
+	testRateLimiter(t, s)
+
+	// Buckets are shared by every limiter on the same Redis (i.e. across instances).
+	other := NewRedisRateLimiter(client)
+	if res, _ := other.Allow(context.Background(), "api:user:1", RateLimit{PerMinute: 60, Burst: 3}); res.Allowed {
+		t.Error("second instance should see the exhausted bucket")
+	}
+	if ttl := mr.TTL(rateLimitKeyPrefix + "api:user:1"); ttl <= 0 {
+		t.Errorf("bucket TTL = %v, want > 0", ttl)
+	}
+}
+
+func TestMemoryRateLimiter_Refill(t *testing.T) {
+	s := NewMemoryRateLimiter()
+	ctx := context.Background()
+	limit := RateLimit{PerMinute: 6000, Burst: 1} // one token per 10ms
+	if res, _ := s.Allow(ctx, "k", limit); !res.Allowed {
+		t.Fatal("first request denied")
+	}
+	if res, _ := s.Allow(ctx, "k", limit); res.Allowed {
+		t.Fatal("second request allowed before refill")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if res, _ := s.Allow(ctx, "k", limit); !res.Allowed {
+		t.Error("request denied after refill")
+	}
+}
+
+func TestMemoryRateLimiter_PerHour(t *testing.T) {
+	s := NewMemoryRateLimiter()
+	ctx := context.Background()
+	limit := RateLimit{PerHour: 2}
+	for i := 0; i < 2; i++ {
+		if res, _ := s.Allow(ctx, "k", limit); !res.Allowed {
+			t.Fatalf("request %d denied", i+1)
+		}
+	}
+	res, _ := s.Allow(ctx, "k", limit)
+	if res.Allowed {
+		t.Fatal("third request allowed")
+	}
+	if res.RetryAfter < 29*time.Minute || res.RetryAfter > 30*time.Minute {
+		t.Errorf("RetryAfter = %s, want about 30m", res.RetryAfter)
+	}
+}
+
+func TestRedisRateLimiter_FallbackWhenRedisDown(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { _ = client.Close() })
+	s := NewRedisRateLimiter(client)
+	mr.Close()
+
+	limit := RateLimit{PerMinute: 60, Burst: 1}
+	res, err := s.Allow(context.Background(), "k", limit)
+	if err != nil || !res.Allowed {
+		t.Fatalf("Allow with redis down: %+v err=%v", res, err)
+	}
+	if res, _ := s.Allow(context.Background(), "k", limit); res.Allowed {
+		t.Error("local fallback should still enforce the limit")
+	}
+}
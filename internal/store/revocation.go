@@ -0,0 +1,220 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: revocation.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Token revocation store (user / session / jti) with Redis and in-memory implementations
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	revokedUserKeyPrefix    = "revoked:user:"
+	revokedSessionKeyPrefix = "revoked:session:"
+	revokedTokenKeyPrefix   = "revoked:token:"
+	memorySweepInterval     = time.Minute
+)
+
+// RevocationStore records revoked credentials so signature-valid JWTs can be rejected before expiry.
+//
+//   - RevokeUser invalidates every token of the user issued before the given time that is not bound to
+//     a session (deletion, password change): tokens from before sessions existed and MFA pending
+//     tokens. Session-bound tokens are revoked with their sessions. Issue times have second
+//     precision, so the cutoff is rounded up to the next second.
+//   - RevokeSession invalidates every token bound to a login session.
+//   - RevokeToken invalidates a single token by its jti.
+//
+// ttl should be at least the remaining lifetime of the affected tokens; entries expire afterwards.
+type RevocationStore interface {
+	RevokeUser(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error
+	RevokeSession(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error
+	RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, userID, sessionID uuid.UUID, tokenID string, issuedAt time.Time) (bool, error)
+}
+
+// RedisRevocationStore implements RevocationStore using Redis keys with TTL, so revocations are
+// shared across instances. Every write is mirrored to a local MemoryRevocationStore, which answers
+// reads when Redis is unreachable.
+type RedisRevocationStore struct {
+	client   redis.Cmdable
+	fallback *MemoryRevocationStore
+}
+
+// NewRedisRevocationStore creates a revocation store backed by Redis with an in-memory fallback.
+func NewRedisRevocationStore(client redis.Cmdable) *RedisRevocationStore {
+	return &RedisRevocationStore{client: client, fallback: NewMemoryRevocationStore()}
+}
+
+// RevokeUser stores the cutoff (unix seconds, see cutoffSeconds) for the user's tokens.
+func (s *RedisRevocationStore) RevokeUser(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	_ = s.fallback.RevokeUser(ctx, userID, before, ttl)
+	key := revokedUserKeyPrefix + userID.String()
+	if err := s.client.Set(ctx, key, cutoffSeconds(before), ttl).Err(); err != nil {
+		return fmt.Errorf("revocation set user: %w", err)
+	}
+	return nil
+}
+
+// RevokeSession marks the session revoked.
+func (s *RedisRevocationStore) RevokeSession(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	_ = s.fallback.RevokeSession(ctx, sessionID, ttl)
+	if err := s.client.Set(ctx, revokedSessionKeyPrefix+sessionID.String(), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("revocation set session: %w", err)
+	}
+	return nil
+}
+
+// RevokeToken marks a single token (jti) revoked.
+func (s *RedisRevocationStore) RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error {
+	_ = s.fallback.RevokeToken(ctx, tokenID, ttl)
+	if err := s.client.Set(ctx, revokedTokenKeyPrefix+tokenID, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("revocation set token: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked checks user cutoff, session and jti in one round trip. On Redis errors it
+// answers from the local fallback (revocations made by this instance only).
+func (s *RedisRevocationStore) IsRevoked(ctx context.Context, userID, sessionID uuid.UUID, tokenID string, issuedAt time.Time) (bool, error) {
+	pipe := s.client.Pipeline()
+	var userCmd *redis.StringCmd
+	var sessionCmd, tokenCmd *redis.IntCmd
+	if sessionID != uuid.Nil {
+		sessionCmd = pipe.Exists(ctx, revokedSessionKeyPrefix+sessionID.String())
+	} else {
+		userCmd = pipe.Get(ctx, revokedUserKeyPrefix+userID.String())
+	}
+	if tokenID != "" {
+		tokenCmd = pipe.Exists(ctx, revokedTokenKeyPrefix+tokenID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		log.Printf("[REVOKE] redis unavailable, using local fallback: %v", err)
+		return s.fallback.IsRevoked(ctx, userID, sessionID, tokenID, issuedAt)
+	}
+	if sessionCmd != nil && sessionCmd.Val() > 0 {
+		return true, nil
+	}
+	if tokenCmd != nil && tokenCmd.Val() > 0 {
+		return true, nil
+	}
+	if userCmd != nil {
+		if v, err := userCmd.Result(); err == nil {
+			cutoff, err := strconv.ParseInt(v, 10, 64)
+			if err == nil && issuedAt.Unix() < cutoff {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// cutoffSeconds rounds a user cutoff up to whole seconds. A token's "iat" is truncated to the
+// second, so a token issued earlier in the cutoff's second would otherwise pass; one issued
+// later in that second is rejected too.
+func cutoffSeconds(before time.Time) int64 {
+	sec := before.Unix()
+	if before.Nanosecond() > 0 {
+		sec++
+	}
+	return sec
+}
+
+// MemoryRevocationStore implements RevocationStore in process memory. It is used when Redis is
+// not configured (single instance) and as the fallback of RedisRevocationStore.
+type MemoryRevocationStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryRevocation
+	lastSweep time.Time
+}
+
+type memoryRevocation struct {
+	cutoff  int64 // unix seconds; only used for user entries
+	expires time.Time
+}
+
+// NewMemoryRevocationStore creates an in-memory revocation store.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{entries: make(map[string]memoryRevocation), lastSweep: time.Now()}
+}
+
+// RevokeUser stores the cutoff for the user's tokens.
+func (s *MemoryRevocationStore) RevokeUser(_ context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	s.put(revokedUserKeyPrefix+userID.String(), cutoffSeconds(before), ttl)
+	return nil
+}
+
+// RevokeSession marks the session revoked.
+func (s *MemoryRevocationStore) RevokeSession(_ context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	s.put(revokedSessionKeyPrefix+sessionID.String(), 0, ttl)
+	return nil
+}
+
+// RevokeToken marks a single token (jti) revoked.
+func (s *MemoryRevocationStore) RevokeToken(_ context.Context, tokenID string, ttl time.Duration) error {
+	s.put(revokedTokenKeyPrefix+tokenID, 0, ttl)
+	return nil
+}
+
+// IsRevoked reports whether any of the session, jti or (for tokens without a session) user
+// cutoff entries match.
+func (s *MemoryRevocationStore) IsRevoked(_ context.Context, userID, sessionID uuid.UUID, tokenID string, issuedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if sessionID != uuid.Nil {
+		if _, ok := s.get(revokedSessionKeyPrefix+sessionID.String(), now); ok {
+			return true, nil
+		}
+	}
+	if tokenID != "" {
+		if _, ok := s.get(revokedTokenKeyPrefix+tokenID, now); ok {
+			return true, nil
+		}
+	}
+	if sessionID == uuid.Nil {
+		if e, ok := s.get(revokedUserKeyPrefix+userID.String(), now); ok && issuedAt.Unix() < e.cutoff {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryRevocationStore) put(key string, cutoff int64, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.entries[key] = memoryRevocation{cutoff: cutoff, expires: now.Add(ttl)}
+	if now.Sub(s.lastSweep) > memorySweepInterval {
+		for k, e := range s.entries {
+			if !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+}
+
+// get returns the entry if present and unexpired. Caller must hold s.mu.
+func (s *MemoryRevocationStore) get(key string, now time.Time) (memoryRevocation, bool) {
+	e, ok := s.entries[key]
+	if !ok || !now.Before(e.expires) {
+		return memoryRevocation{}, false
+	}
+	return e, true
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: revocation_test.go
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for token revocation stores
+
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func setupRedisRevocationStore(t *testing.T) (*RedisRevocationStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s := NewRedisRevocationStore(client)
+	t.Cleanup(func() { mr.Close(); _ = client.Close() })
+	return s, mr
+}
+
+func testRevocationStore(t *testing.T, s RevocationStore) {
+	t.Helper()
+	ctx := context.Background()
+	userID := uuid.New()
+	sessionID := uuid.New()
+	issued := time.Now().Add(-time.Minute)
+
+	revoked, err := s.IsRevoked(ctx, userID, sessionID, "jti-1", issued)
+	if err != nil || revoked {
+		t.Fatalf("initial: revoked=%v err=%v", revoked, err)
+	}
+
+	if err := s.RevokeToken(ctx, "jti-1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := s.IsRevoked(ctx, userID, sessionID, "jti-1", issued); !revoked {
+		t.Error("token jti-1 should be revoked")
+	}
+	if revoked, _ := s.IsRevoked(ctx, userID, sessionID, "jti-2", issued); revoked {
+		t.Error("token jti-2 should not be revoked")
+	}
+
+	if err := s.RevokeSession(ctx, sessionID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := s.IsRevoked(ctx, userID, sessionID, "jti-2", issued); !revoked {
+		t.Error("tokens of revoked session should be revoked")
+	}
+
+	otherUser := uuid.New()
+	if err := s.RevokeUser(ctx, otherUser, time.Now(), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := s.IsRevoked(ctx, otherUser, uuid.Nil, "", issued); !revoked {
+		t.Error("token issued before user cutoff should be revoked")
+	}
+	if revoked, _ := s.IsRevoked(ctx, otherUser, uuid.Nil, "", time.Now().Add(time.Minute)); revoked {
+		t.Error("token issued after user cutoff should not be revoked")
+	}
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	testRevocationStore(t, NewMemoryRevocationStore())
+}
+
+func TestRedisRevocationStore(t *testing.T) {
+	s, _ := setupRedisRevocationStore(t)
+	testRevocationStore(t, s)
+}
+
+func TestRevocationStore_UserCutoffRoundsUp(t *testing.T) {
+	redisStore, _ := setupRedisRevocationStore(t)
+	for name, s := range map[string]RevocationStore{"memory": NewMemoryRevocationStore(), "redis": redisStore} {
+		ctx := context.Background()
+		userID := uuid.New()
+		second := time.Now().Truncate(time.Second)
+		cutoff := second.Add(500 * time.Millisecond)
+		if err := s.RevokeUser(ctx, userID, cutoff, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		// "iat" is whole seconds: a token from earlier in the cutoff's second must not pass.
+		if revoked, _ := s.IsRevoked(ctx, userID, uuid.Nil, "", second); !revoked {
+			t.Errorf("%s: token issued in the cutoff's second should be revoked", name)
+		}
+		if revoked, _ := s.IsRevoked(ctx, userID, uuid.Nil, "", second.Add(time.Second)); revoked {
+			t.Errorf("%s: token issued in the next second should not be revoked", name)
+		}
+		// Session-bound tokens are revoked through their session only.
+		if revoked, _ := s.IsRevoked(ctx, userID, uuid.New(), "", second); revoked {
+			t.Errorf("%s: token of a live session should not be revoked by the user cutoff", name)
+		}
+	}
+}
+
+func TestMemoryRevocationStore_Expiry(t *testing.T) {
+	s := NewMemoryRevocationStore()
+	ctx := context.Background()
+	if err := s.RevokeToken(ctx, "jti-1", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if revoked, _ := s.IsRevoked(ctx, uuid.New(), uuid.Nil, "jti-1", time.Now()); revoked {
+		t.Error("expired revocation should not apply")
+	}
+}
+
+func TestRedisRevocationStore_FallbackWhenRedisDown(t *testing.T) {
+	s, mr := setupRedisRevocationStore(t)
+	ctx := context.Background()
+	sessionID := uuid.New()
+	if err := s.RevokeSession(ctx, sessionID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+	revoked, err := s.IsRevoked(ctx, uuid.New(), sessionID, "", time.Now())
+	if err != nil {
+		t.Fatalf("IsRevoked with redis down: %v", err)
+	}
+	if !revoked {
+		t.Error("local fallback should still report the session revoked")
+	}
+}
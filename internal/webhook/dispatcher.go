@@ -0,0 +1,471 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: dispatcher.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Asynchronous, signed delivery of conversation events to outgoing webhooks
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/pkg/retry"
+	"github.com/convexwf/uim-go/internal/repository"
+)
+
+// Request headers sent with every delivery.
+const (
+	HeaderEvent     = "X-UIM-Event"
+	HeaderDelivery  = "X-UIM-Delivery"
+	HeaderTimestamp = "X-UIM-Timestamp"
+	// HeaderSignature is "sha256=" + hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret.
+	HeaderSignature = "X-UIM-Signature"
+)
+
+const (
+	resumeBatchSize  = 1000
+	maxResponseBytes = 4 * 1024
+	maxErrorLength   = 500
+)
+
+// Options tunes the dispatcher. Zero fields take the defaults below.
+type Options struct {
+	Workers        int           // concurrent deliveries (default 4)
+	QueueSize      int           // events waiting for a worker; more are dropped (default 1000)
+	MaxAttempts    int           // attempts per delivery before it is dead-lettered (default 6)
+	RetryBaseDelay time.Duration // delay before the first retry, doubling after each (default 10s)
+	RetryMaxDelay  time.Duration // cap of the retry delay (default 1h)
+	Timeout        time.Duration // per-attempt HTTP timeout (default 10s)
+	// AllowPrivateNetworks lets the default client connect to loopback, private and link-local
+	// addresses. Only for local development and tests.
+	AllowPrivateNetworks bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 6
+	}
+	if o.RetryBaseDelay <= 0 {
+		o.RetryBaseDelay = 10 * time.Second
+	}
+	if o.RetryMaxDelay <= 0 {
+		o.RetryMaxDelay = time.Hour
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	return o
+}
+
+// ErrBlockedAddress is returned when a webhook host resolves to an address that is not
+// publicly routable. Such deliveries are dead-lettered without a retry.
+var ErrBlockedAddress = errors.New("webhook address is not publicly routable")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), not covered by net.IP.IsPrivate.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// BlockedIP reports whether a webhook must not connect to ip: loopback, private, link-local
+// (including the cloud metadata address 169.254.169.254), unspecified, multicast and CGNAT
+// addresses, in IPv4, IPv6 and IPv4-mapped IPv6 form.
+func BlockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// dialControl runs after DNS resolution, so it also catches public names that resolve (or
+// rebind) to internal addresses.
+func dialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || BlockedIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// newClient returns the default delivery client: it does not follow redirects and, unless
+// allowPrivate, refuses to connect to addresses rejected by BlockedIP.
+func newClient(allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialControl}
+		transport.DialContext = dialer.DialContext
+		// Through a proxy the dialer would only see the proxy's address.
+		transport.Proxy = nil
+	}
+	return &http.Client{
+		Transport: transport,
+		// A redirect could point the signed request anywhere; treat it as a failed delivery.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// Payload is the JSON body POSTed to a webhook.
+type Payload struct {
+	DeliveryID     uuid.UUID   `json:"delivery_id"`
+	Event          string      `json:"event"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	ActorID        *uuid.UUID  `json:"actor_id,omitempty"`
+	OccurredAt     time.Time   `json:"occurred_at"`
+	Data           interface{} `json:"data,omitempty"`
+}
+
+// event is a conversation event waiting to be fanned out to the conversation's webhooks.
+type event struct {
+	conversationID uuid.UUID
+	actorID        uuid.UUID
+	name           string
+	data           interface{}
+	occurredAt     time.Time
+}
+
+// job is either a new event or a retry of a stored delivery.
+type job struct {
+	event      *event
+	deliveryID uuid.UUID
+}
+
+// Dispatcher delivers conversation events to outgoing webhooks. Notify calls only enqueue;
+// workers look up the webhooks, record a delivery per webhook and POST it. Failed deliveries
+// are retried with exponential backoff (see retry.Backoff) and end as dead letters after
+// MaxAttempts. Pending deliveries are resumed by Start after a restart.
+//
+// Dispatcher implements service.MessageNotifier and service.ConversationEventNotifier.
+type Dispatcher struct {
+	repo   repository.WebhookRepository
+	client *http.Client
+	opts   Options
+	now    func() time.Time
+
+	jobs     chan job
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	timers map[uuid.UUID]*time.Timer
+}
+
+// NewDispatcher creates a dispatcher. client may be nil (a client that does not follow
+// redirects and only connects to public addresses is used). Call Start before events are
+// delivered.
+func NewDispatcher(repo repository.WebhookRepository, client *http.Client, opts Options) *Dispatcher {
+	opts = opts.withDefaults()
+	if client == nil {
+		client = newClient(opts.AllowPrivateNetworks)
+	}
+	return &Dispatcher{
+		repo:   repo,
+		client: client,
+		opts:   opts,
+		now:    time.Now,
+		jobs:   make(chan job, opts.QueueSize),
+		stop:   make(chan struct{}),
+		timers: make(map[uuid.UUID]*time.Timer),
+	}
+}
+
+// Start launches the workers and reschedules deliveries left pending by a previous run.
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.opts.Workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	pending, err := d.repo.ListPendingDeliveries(resumeBatchSize)
+	if err != nil {
+		log.Printf("[WEBHOOK] resume pending deliveries failed: %v", err)
+		return
+	}
+	now := d.now()
+	for _, p := range pending {
+		delay := time.Duration(0)
+		if p.NextAttemptAt != nil && p.NextAttemptAt.After(now) {
+			delay = p.NextAttemptAt.Sub(now)
+		}
+		d.schedule(p.DeliveryID, delay)
+	}
+	if len(pending) > 0 {
+		log.Printf("[WEBHOOK] resumed %d pending deliveries", len(pending))
+	}
+}
+
+// Stop cancels scheduled retries and waits for in-flight deliveries. Retries not yet attempted
+// stay pending in the database and are resumed by the next Start.
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		d.mu.Lock()
+		for id, t := range d.timers {
+			t.Stop()
+			delete(d.timers, id)
+		}
+		d.mu.Unlock()
+	})
+	d.wg.Wait()
+}
+
+// NotifyNewMessage implements service.MessageNotifier.
+func (d *Dispatcher) NotifyNewMessage(conversationID uuid.UUID, msg *model.Message) {
+	d.enqueueEvent(&event{
+		conversationID: conversationID,
+		actorID:        msg.SenderID,
+		name:           model.EventNewMessage,
+		data:           map[string]interface{}{"message": msg},
+		occurredAt:     d.now(),
+	})
+}
+
+// NotifyConversationEvent implements service.ConversationEventNotifier.
+func (d *Dispatcher) NotifyConversationEvent(conversationID, actorID uuid.UUID, eventName string, data map[string]interface{}) {
+	d.enqueueEvent(&event{
+		conversationID: conversationID,
+		actorID:        actorID,
+		name:           eventName,
+		data:           data,
+		occurredAt:     d.now(),
+	})
+}
+
+// enqueueEvent never blocks the caller: when the queue is full the event is dropped.
+func (d *Dispatcher) enqueueEvent(ev *event) {
+	select {
+	case <-d.stop:
+		return
+	default:
+	}
+	select {
+	case d.jobs <- job{event: ev}:
+	default:
+		log.Printf("[WEBHOOK] queue full, dropping event=%s conversation_id=%s", ev.name, ev.conversationID)
+	}
+}
+
+// schedule queues a retry of the delivery after delay.
+func (d *Dispatcher) schedule(deliveryID uuid.UUID, delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	select {
+	case <-d.stop:
+		return
+	default:
+	}
+	if old, ok := d.timers[deliveryID]; ok {
+		old.Stop()
+	}
+	d.timers[deliveryID] = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		delete(d.timers, deliveryID)
+		d.mu.Unlock()
+		select {
+		case d.jobs <- job{deliveryID: deliveryID}:
+		case <-d.stop:
+		}
+	})
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case j := <-d.jobs:
+			if j.event != nil {
+				d.fanOut(j.event)
+			} else {
+				d.retry(j.deliveryID)
+			}
+		}
+	}
+}
+
+// fanOut records and attempts one delivery per webhook subscribed to the event.
+func (d *Dispatcher) fanOut(ev *event) {
+	webhooks, err := d.repo.ListByConversation(ev.conversationID)
+	if err != nil {
+		log.Printf("[WEBHOOK] list webhooks failed conversation_id=%s err=%v", ev.conversationID, err)
+		return
+	}
+	for _, w := range webhooks {
+		if !w.Subscribes(ev.name) {
+			continue
+		}
+		payload := Payload{
+			DeliveryID:     uuid.New(),
+			Event:          ev.name,
+			ConversationID: ev.conversationID,
+			OccurredAt:     ev.occurredAt,
+			Data:           ev.data,
+		}
+		if ev.actorID != uuid.Nil {
+			actor := ev.actorID
+			payload.ActorID = &actor
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("[WEBHOOK] encode payload failed event=%s err=%v", ev.name, err)
+			continue
+		}
+		delivery := &model.WebhookDelivery{
+			DeliveryID: payload.DeliveryID,
+			WebhookID:  w.WebhookID,
+			Event:      ev.name,
+			Payload:    string(body),
+			Status:     model.WebhookDeliveryPending,
+			CreatedAt:  d.now(),
+		}
+		if err := d.repo.CreateDelivery(delivery); err != nil {
+			log.Printf("[WEBHOOK] record delivery failed webhook_id=%s err=%v", w.WebhookID, err)
+			continue
+		}
+		d.attempt(w, delivery)
+	}
+}
+
+// retry attempts a stored delivery again.
+func (d *Dispatcher) retry(deliveryID uuid.UUID) {
+	delivery, err := d.repo.GetDelivery(deliveryID)
+	if err != nil {
+		log.Printf("[WEBHOOK] load delivery failed delivery_id=%s err=%v", deliveryID, err)
+		return
+	}
+	if delivery == nil || delivery.Status != model.WebhookDeliveryPending {
+		return
+	}
+	w, err := d.repo.GetByID(delivery.WebhookID)
+	if err != nil {
+		log.Printf("[WEBHOOK] load webhook failed webhook_id=%s err=%v", delivery.WebhookID, err)
+		d.schedule(deliveryID, d.opts.RetryBaseDelay)
+		return
+	}
+	if w == nil {
+		d.finish(delivery, model.WebhookDeliveryDead, "webhook deleted")
+		return
+	}
+	d.attempt(w, delivery)
+}
+
+// attempt POSTs the delivery once and records the outcome: success, a scheduled retry, or a dead letter.
+func (d *Dispatcher) attempt(w *model.Webhook, delivery *model.WebhookDelivery) {
+	delivery.Attempts++
+	statusCode, err := d.post(w, delivery)
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		d.finish(delivery, model.WebhookDeliverySucceeded, "")
+		return
+	}
+	if !retryable(statusCode) || errors.Is(err, ErrBlockedAddress) || delivery.Attempts >= d.opts.MaxAttempts {
+		log.Printf("[WEBHOOK] delivery dead webhook_id=%s delivery_id=%s attempts=%d err=%v", w.WebhookID, delivery.DeliveryID, delivery.Attempts, err)
+		d.finish(delivery, model.WebhookDeliveryDead, err.Error())
+		return
+	}
+	delay := retry.Backoff(d.opts.RetryBaseDelay, delivery.Attempts-1, d.opts.RetryMaxDelay)
+	next := d.now().Add(delay)
+	delivery.NextAttemptAt = &next
+	delivery.LastError = truncate(err.Error(), maxErrorLength)
+	if err := d.repo.UpdateDelivery(delivery); err != nil {
+		log.Printf("[WEBHOOK] update delivery failed delivery_id=%s err=%v", delivery.DeliveryID, err)
+	}
+	d.schedule(delivery.DeliveryID, delay)
+}
+
+// finish records a final status.
+func (d *Dispatcher) finish(delivery *model.WebhookDelivery, status model.WebhookDeliveryStatus, lastError string) {
+	now := d.now()
+	delivery.Status = status
+	delivery.LastError = truncate(lastError, maxErrorLength)
+	delivery.NextAttemptAt = nil
+	delivery.CompletedAt = &now
+	if err := d.repo.UpdateDelivery(delivery); err != nil {
+		log.Printf("[WEBHOOK] update delivery failed delivery_id=%s err=%v", delivery.DeliveryID, err)
+	}
+}
+
+// post sends the signed request. It returns the response status (0 without a response) and an
+// error unless the receiver answered 2xx.
+func (d *Dispatcher) post(w *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "uim-webhook/1")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.DeliveryID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(w.Secret, timestamp, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the X-UIM-Signature value for a body sent at timestamp (Unix seconds).
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature matches the body and timestamp. Receivers should also
+// reject timestamps too far from their clock to limit replays.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// retryable reports whether a failed attempt may succeed later: network errors (no status),
+// server errors, 408 and 429. Other responses mean the receiver rejected the request.
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode >= 500 || statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
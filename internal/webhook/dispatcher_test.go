@@ -0,0 +1,373 @@
+// Copyright 2025 convexwf
+//
+// Project: uim-go
+// File: dispatcher_test.go
+// Email: convexwf@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// See the License for the full terms.
+//
+// Description: Unit tests for webhook delivery against httptest receivers
+
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/convexwf/uim-go/internal/model"
+)
+
+// memoryRepo is an in-memory WebhookRepository.
+type memoryRepo struct {
+	mu         sync.Mutex
+	webhooks   map[uuid.UUID]*model.Webhook
+	deliveries map[uuid.UUID]*model.WebhookDelivery
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{webhooks: make(map[uuid.UUID]*model.Webhook), deliveries: make(map[uuid.UUID]*model.WebhookDelivery)}
+}
+
+func (r *memoryRepo) Create(w *model.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if w.WebhookID == uuid.Nil {
+		w.WebhookID = uuid.New()
+	}
+	r.webhooks[w.WebhookID] = w
+	return nil
+}
+
+func (r *memoryRepo) GetByID(id uuid.UUID) (*model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.webhooks[id], nil
+}
+
+func (r *memoryRepo) ListByConversation(conversationID uuid.UUID) ([]*model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*model.Webhook
+	for _, w := range r.webhooks {
+		if w.ConversationID == conversationID {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepo) Delete(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.webhooks, id)
+	return nil
+}
+
+func (r *memoryRepo) CreateDelivery(d *model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *d
+	r.deliveries[d.DeliveryID] = &cp
+	return nil
+}
+
+func (r *memoryRepo) GetDelivery(id uuid.UUID) (*model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.deliveries[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (r *memoryRepo) UpdateDelivery(d *model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *d
+	r.deliveries[d.DeliveryID] = &cp
+	return nil
+}
+
+func (r *memoryRepo) ListDeliveries(webhookID uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*model.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			cp := *d
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepo) ListPendingDeliveries(limit int) ([]*model.WebhookDelivery, error) {
+	return nil, nil
+}
+
+// waitDelivery polls until the webhook has a delivery in a final state.
+func waitDelivery(t *testing.T, repo *memoryRepo, webhookID uuid.UUID) *model.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		list, _ := repo.ListDeliveries(webhookID, "", 10)
+		for _, d := range list {
+			if d.Status != model.WebhookDeliveryPending {
+				return d
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("delivery did not complete")
+	return nil
+}
+
+func newTestDispatcher(repo *memoryRepo) *Dispatcher {
+	d := NewDispatcher(repo, nil, Options{Workers: 2, MaxAttempts: 3, RetryBaseDelay: 10 * time.Millisecond, RetryMaxDelay: 50 * time.Millisecond, Timeout: time.Second, AllowPrivateNetworks: true})
+	d.Start()
+	return d
+}
+
+func TestDispatcher_DeliversSignedMessage(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header.Clone(), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	repo := newMemoryRepo()
+	convID := uuid.New()
+	hook := &model.Webhook{ConversationID: convID, URL: srv.URL, Secret: "s3cret", Events: model.EventNewMessage}
+	_ = repo.Create(hook)
+	d := newTestDispatcher(repo)
+	defer d.Stop()
+
+	sender := uuid.New()
+	d.NotifyNewMessage(convID, &model.Message{MessageID: 7, ConversationID: convID, SenderID: sender, Content: "build passed"})
+
+	var r received
+	select {
+	case r = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("receiver got nothing")
+	}
+	ts, err := strconv.ParseInt(r.header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header: %v", err)
+	}
+	if !Verify("s3cret", ts, r.body, r.header.Get(HeaderSignature)) {
+		t.Fatal("signature does not verify")
+	}
+	if Verify("other", ts, r.body, r.header.Get(HeaderSignature)) {
+		t.Fatal("signature verifies with the wrong secret")
+	}
+	if r.header.Get(HeaderEvent) != model.EventNewMessage {
+		t.Fatalf("event header = %q", r.header.Get(HeaderEvent))
+	}
+	var payload struct {
+		DeliveryID string `json:"delivery_id"`
+		Event      string `json:"event"`
+		ActorID    string `json:"actor_id"`
+		Data       struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(r.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != model.EventNewMessage || payload.ActorID != sender.String() || payload.Data.Message.Content != "build passed" {
+		t.Fatalf("unexpected payload: %s", r.body)
+	}
+	if payload.DeliveryID != r.header.Get(HeaderDelivery) {
+		t.Fatalf("delivery id mismatch: body %s header %s", payload.DeliveryID, r.header.Get(HeaderDelivery))
+	}
+
+	delivery := waitDelivery(t, repo, hook.WebhookID)
+	if delivery.Status != model.WebhookDeliverySucceeded || delivery.Attempts != 1 || delivery.LastStatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected delivery record: %+v", delivery)
+	}
+}
+
+func TestDispatcher_RetriesThenSucceeds(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	repo := newMemoryRepo()
+	convID := uuid.New()
+	hook := &model.Webhook{ConversationID: convID, URL: srv.URL, Secret: "s", Events: model.EventNewMessage}
+	_ = repo.Create(hook)
+	d := newTestDispatcher(repo)
+	defer d.Stop()
+
+	d.NotifyNewMessage(convID, &model.Message{ConversationID: convID, SenderID: uuid.New(), Content: "hi"})
+	delivery := waitDelivery(t, repo, hook.WebhookID)
+	if delivery.Status != model.WebhookDeliverySucceeded || delivery.Attempts != 3 {
+		t.Fatalf("want success after 3 attempts, got %+v", delivery)
+	}
+}
+
+func TestDispatcher_DeadLetterAfterMaxAttempts(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	repo := newMemoryRepo()
+	convID := uuid.New()
+	hook := &model.Webhook{ConversationID: convID, URL: srv.URL, Secret: "s", Events: model.EventNewMessage}
+	_ = repo.Create(hook)
+	d := newTestDispatcher(repo)
+	defer d.Stop()
+
+	d.NotifyNewMessage(convID, &model.Message{ConversationID: convID, SenderID: uuid.New(), Content: "hi"})
+	delivery := waitDelivery(t, repo, hook.WebhookID)
+	if delivery.Status != model.WebhookDeliveryDead || delivery.Attempts != 3 || delivery.LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("want dead letter after 3 attempts, got %+v", delivery)
+	}
+	if delivery.LastError == "" || delivery.CompletedAt == nil {
+		t.Fatalf("dead letter should keep the last error: %+v", delivery)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("receiver called %d times, want 3", n)
+	}
+}
+
+func TestDispatcher_ClientErrorIsNotRetried(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	repo := newMemoryRepo()
+	convID := uuid.New()
+	hook := &model.Webhook{ConversationID: convID, URL: srv.URL, Secret: "s", Events: model.EventNewMessage}
+	_ = repo.Create(hook)
+	d := newTestDispatcher(repo)
+	defer d.Stop()
+
+	d.NotifyNewMessage(convID, &model.Message{ConversationID: convID, SenderID: uuid.New(), Content: "hi"})
+	delivery := waitDelivery(t, repo, hook.WebhookID)
+	if delivery.Status != model.WebhookDeliveryDead || delivery.Attempts != 1 {
+		t.Fatalf("want dead letter after 1 attempt, got %+v", delivery)
+	}
+}
+
+func TestDispatcher_RefusesPrivateAddress(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	repo := newMemoryRepo()
+	convID := uuid.New()
+	hook := &model.Webhook{ConversationID: convID, URL: srv.URL, Secret: "s", Events: model.EventNewMessage}
+	_ = repo.Create(hook)
+	d := NewDispatcher(repo, nil, Options{Workers: 1, MaxAttempts: 3, RetryBaseDelay: 10 * time.Millisecond, Timeout: time.Second})
+	d.Start()
+	defer d.Stop()
+
+	d.NotifyNewMessage(convID, &model.Message{ConversationID: convID, SenderID: uuid.New(), Content: "hi"})
+	delivery := waitDelivery(t, repo, hook.WebhookID)
+	if delivery.Status != model.WebhookDeliveryDead || delivery.Attempts != 1 {
+		t.Fatalf("want dead letter after 1 attempt, got %+v", delivery)
+	}
+	if !strings.Contains(delivery.LastError, ErrBlockedAddress.Error()) {
+		t.Fatalf("last error %q should name the blocked address", delivery.LastError)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("loopback receiver called %d times", n)
+	}
+}
+
+func TestBlockedIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"224.0.0.1":        true,
+		"::1":              true,
+		"fe80::1":          true,
+		"fd00::1":          true,
+		"::ffff:127.0.0.1": true,
+		"93.184.216.34":    false,
+		"2606:4700::1111":  false,
+	} {
+		if got := BlockedIP(net.ParseIP(addr)); got != want {
+			t.Errorf("BlockedIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestDispatcher_OnlySubscribedEvents(t *testing.T) {
+	got := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get(HeaderEvent)
+	}))
+	defer srv.Close()
+
+	repo := newMemoryRepo()
+	convID := uuid.New()
+	hook := &model.Webhook{ConversationID: convID, URL: srv.URL, Secret: "s", Events: model.EventMemberJoined}
+	_ = repo.Create(hook)
+	other := &model.Webhook{ConversationID: uuid.New(), URL: srv.URL, Secret: "s", Events: model.EventMemberJoined}
+	_ = repo.Create(other)
+	d := newTestDispatcher(repo)
+	defer d.Stop()
+
+	d.NotifyNewMessage(convID, &model.Message{ConversationID: convID, SenderID: uuid.New(), Content: "ignored"})
+	d.NotifyConversationEvent(convID, uuid.New(), model.EventMemberJoined, map[string]interface{}{"user_id": uuid.New()})
+
+	select {
+	case ev := <-got:
+		if ev != model.EventMemberJoined {
+			t.Fatalf("got event %q, want member_joined", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("receiver got nothing")
+	}
+	select {
+	case ev := <-got:
+		t.Fatalf("unexpected extra delivery %q", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
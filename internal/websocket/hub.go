@@ -18,31 +18,57 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	gorillawebsocket "github.com/gorilla/websocket"
 
 	"github.com/convexwf/uim-go/internal/model"
+	"github.com/convexwf/uim-go/internal/pkg/retry"
 	"github.com/convexwf/uim-go/internal/repository"
 	"github.com/convexwf/uim-go/internal/store"
 )
 
 // Hub maintains active WebSocket connections and broadcasts messages to conversation participants.
 // If OfflineQueue is set, messages for offline users are pushed to the queue for delivery on reconnect.
+// Channels are the exception: their messages only go to connected subscribers, found through an
+// index of the channels of each connected user, and offline subscribers pull them on reconnect.
 type Hub struct {
 	convRepo     repository.ConversationRepository
 	offlineQueue store.OfflineQueue
 	// userID -> set of clients (one user can have multiple connections)
 	clients map[uuid.UUID]map[*Client]struct{}
-	mu      sync.RWMutex
+	// channelID -> connected subscribers, and connected userID -> their channels
+	channelSubs  map[uuid.UUID]map[uuid.UUID]struct{}
+	userChannels map[uuid.UUID]map[uuid.UUID]struct{}
+	// registering holds the channel joins and leaves seen while Register loads a user's channels.
+	registering map[uuid.UUID]*pendingChannels
+	mu          sync.RWMutex
+
+	// isChannel caches whether a conversation is a channel (the type never changes).
+	kindsMu sync.Mutex
+	kinds   map[uuid.UUID]bool
+}
+
+// pendingChannels records, per channel, whether the user's last membership event was a join.
+type pendingChannels struct {
+	joined map[uuid.UUID]bool
 }
 
+// maxCachedKinds bounds the conversation type cache; it is emptied when full.
+const maxCachedKinds = 50000
+
+// CloseSessionRevoked is the WebSocket close code sent when the connection's login session is revoked.
+const CloseSessionRevoked = 4001
+
 // Client represents a single WebSocket connection with its user ID.
+// SessionID is the login session of the token used to connect (uuid.Nil for tokens without one).
 type Client struct {
-	UserID uuid.UUID
-	Conn   *gorillawebsocket.Conn
-	Send   chan []byte
-	Hub    *Hub
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	Conn      *gorillawebsocket.Conn
+	Send      chan []byte
+	Hub       *Hub
 }
 
 // NewHub creates a new WebSocket hub. offlineQueue may be nil (offline messages are dropped).
@@ -51,16 +77,18 @@ func NewHub(convRepo repository.ConversationRepository, offlineQueue store.Offli
 		convRepo:     convRepo,
 		offlineQueue: offlineQueue,
 		clients:      make(map[uuid.UUID]map[*Client]struct{}),
+		channelSubs:  make(map[uuid.UUID]map[uuid.UUID]struct{}),
+		userChannels: make(map[uuid.UUID]map[uuid.UUID]struct{}),
+		registering:  make(map[uuid.UUID]*pendingChannels),
+		kinds:        make(map[uuid.UUID]bool),
 	}
 }
 
 // NotifyNewMessage implements service.MessageNotifier. It broadcasts the message to all participants of the conversation.
 // Participants not connected are skipped for real-time delivery; if OfflineQueue is set, the message is pushed there.
+// Channel messages only go to connected subscribers (fan-out on read): the others fetch them by
+// message ID when they reconnect.
 func (h *Hub) NotifyNewMessage(conversationID uuid.UUID, msg *model.Message) {
-	userIDs, err := h.convRepo.GetParticipantUserIDs(conversationID)
-	if err != nil {
-		return
-	}
 	payload, err := json.Marshal(WSMessage{
 		Type:    "new_message",
 		Message: msg,
@@ -68,6 +96,22 @@ func (h *Hub) NotifyNewMessage(conversationID uuid.UUID, msg *model.Message) {
 	if err != nil {
 		return
 	}
+	isChannel, err := h.isChannel(conversationID)
+	if err != nil {
+		// Guessing "group" would push a channel message to every offline subscriber. Clients
+		// fetch the message when they next sync.
+		log.Printf("[WS] conversation type lookup failed, message not pushed: conversation_id=%s message_id=%d err=%v", conversationID, msg.MessageID, err)
+		return
+	}
+	if isChannel {
+		h.sendToChannel(conversationID, payload)
+		return
+	}
+	userIDs, err := h.convRepo.GetParticipantUserIDs(conversationID)
+	if err != nil {
+		return
+	}
+	var offline []uuid.UUID
 	h.mu.RLock()
 	for _, uid := range userIDs {
 		if conns, ok := h.clients[uid]; ok {
@@ -78,23 +122,259 @@ func (h *Hub) NotifyNewMessage(conversationID uuid.UUID, msg *model.Message) {
 					// skip if send buffer full
 				}
 			}
-		} else if h.offlineQueue != nil {
-			if err := h.offlineQueue.Push(context.Background(), uid, payload); err != nil {
-				log.Printf("[Hub] offline queue push: user_id=%s err=%v", uid, err)
+		} else {
+			offline = append(offline, uid)
+		}
+	}
+	h.mu.RUnlock()
+	// Push outside the lock: a slow Redis must not hold up Register and Unregister.
+	if h.offlineQueue == nil {
+		return
+	}
+	for _, uid := range offline {
+		if err := h.offlineQueue.Push(context.Background(), uid, payload); err != nil {
+			log.Printf("[Hub] offline queue push: user_id=%s err=%v", uid, err)
+		}
+	}
+}
+
+// NotifyConversationEvent implements service.ConversationEventNotifier. Events (e.g.
+// pin_changed) go to the participants' open connections only; clients reload the conversation
+// after reconnecting, so nothing is queued for offline users.
+func (h *Hub) NotifyConversationEvent(conversationID, actorID uuid.UUID, event string, data map[string]interface{}) {
+	msg := WSMessage{Type: event, ConversationID: conversationID.String(), Data: data}
+	if actorID != uuid.Nil {
+		msg.ActorID = actorID.String()
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	isChannel, err := h.isChannel(conversationID)
+	if err != nil {
+		log.Printf("[WS] conversation type lookup failed, event not sent: event=%s conversation_id=%s err=%v", event, conversationID, err)
+		return
+	}
+	if isChannel {
+		h.notifyChannelEvent(conversationID, event, data, payload)
+		return
+	}
+	userIDs, err := h.convRepo.GetParticipantUserIDs(conversationID)
+	if err != nil {
+		log.Printf("[WS] event participants lookup failed event=%s conversation_id=%s err=%v", event, conversationID, err)
+		return
+	}
+	for _, uid := range userIDs {
+		h.sendToUser(uid, payload)
+	}
+	// The participant who left or was removed is no longer listed; tell them too.
+	if left, ok := data["user_id"].(uuid.UUID); ok && event == model.EventMemberLeft {
+		h.sendToUser(left, payload)
+	}
+}
+
+// notifyChannelEvent keeps the subscription index up to date on joins and leaves, which only
+// the subscribers concerned are told about; other events go to every connected subscriber.
+func (h *Hub) notifyChannelEvent(channelID uuid.UUID, event string, data map[string]interface{}, payload []byte) {
+	switch event {
+	case model.EventMemberJoined, model.EventMemberLeft:
+		var userIDs []uuid.UUID
+		if ids, ok := data["user_ids"].([]uuid.UUID); ok {
+			userIDs = ids
+		} else if id, ok := data["user_id"].(uuid.UUID); ok {
+			userIDs = []uuid.UUID{id}
+		}
+		h.mu.Lock()
+		for _, uid := range userIDs {
+			if _, online := h.clients[uid]; !online {
+				continue
+			}
+			if p := h.registering[uid]; p != nil {
+				p.joined[channelID] = event == model.EventMemberJoined
+			}
+			if event == model.EventMemberJoined {
+				h.subscribe(channelID, uid)
+			} else {
+				h.unsubscribe(channelID, uid)
+			}
+		}
+		h.mu.Unlock()
+		for _, uid := range userIDs {
+			h.sendToUser(uid, payload)
+		}
+	default:
+		h.sendToChannel(channelID, payload)
+	}
+}
+
+// sendToChannel queues payload on every connection of the channel's connected subscribers.
+func (h *Hub) sendToChannel(channelID uuid.UUID, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for uid := range h.channelSubs[channelID] {
+		for c := range h.clients[uid] {
+			select {
+			case c.Send <- payload:
+			default:
+				// skip if send buffer full
 			}
 		}
 	}
+}
+
+// subscribe and unsubscribe update the channel index; h.mu must be held for writing.
+func (h *Hub) subscribe(channelID, userID uuid.UUID) {
+	if h.channelSubs[channelID] == nil {
+		h.channelSubs[channelID] = make(map[uuid.UUID]struct{})
+	}
+	h.channelSubs[channelID][userID] = struct{}{}
+	if h.userChannels[userID] == nil {
+		h.userChannels[userID] = make(map[uuid.UUID]struct{})
+	}
+	h.userChannels[userID][channelID] = struct{}{}
+}
+
+func (h *Hub) unsubscribe(channelID, userID uuid.UUID) {
+	if subs, ok := h.channelSubs[channelID]; ok {
+		delete(subs, userID)
+		if len(subs) == 0 {
+			delete(h.channelSubs, channelID)
+		}
+	}
+	if chs, ok := h.userChannels[userID]; ok {
+		delete(chs, channelID)
+		if len(chs) == 0 {
+			delete(h.userChannels, userID)
+		}
+	}
+}
+
+// isChannel reports whether the conversation is a channel. Transient lookup errors are retried;
+// callers must not deliver when an error is returned.
+func (h *Hub) isChannel(conversationID uuid.UUID) (bool, error) {
+	h.kindsMu.Lock()
+	isChannel, ok := h.kinds[conversationID]
+	h.kindsMu.Unlock()
+	if ok {
+		return isChannel, nil
+	}
+	var conv *model.Conversation
+	err := retry.Do(3, 50*time.Millisecond, func() error {
+		var err error
+		conv, err = h.convRepo.GetByID(conversationID)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	isChannel = conv.Type == model.ConversationTypeChannel
+	h.rememberKind(conversationID, isChannel)
+	return isChannel, nil
+}
+
+func (h *Hub) rememberKind(conversationID uuid.UUID, isChannel bool) {
+	h.kindsMu.Lock()
+	defer h.kindsMu.Unlock()
+	if len(h.kinds) >= maxCachedKinds {
+		h.kinds = make(map[uuid.UUID]bool)
+	}
+	h.kinds[conversationID] = isChannel
+}
+
+// NotifyEphemeral implements service.CommandNotifier. The message goes to the user's open
+// connections only; it is neither stored nor queued for offline delivery.
+func (h *Hub) NotifyEphemeral(userID uuid.UUID, msg *model.EphemeralMessage) {
+	payload, err := json.Marshal(WSMessage{Type: "ephemeral", Ephemeral: msg})
+	if err != nil {
+		return
+	}
+	h.sendToUser(userID, payload)
+}
+
+// DeliverCommand implements service.CommandNotifier. It sends the invocation to the bot's open
+// connections and reports whether at least one accepted it.
+func (h *Hub) DeliverCommand(botID uuid.UUID, inv *model.CommandInvocation) bool {
+	payload, err := json.Marshal(WSMessage{Type: "command", Command: inv})
+	if err != nil {
+		return false
+	}
+	return h.sendToUser(botID, payload) > 0
+}
+
+// sendToUser queues payload on every connection of the user and returns how many accepted it.
+func (h *Hub) sendToUser(userID uuid.UUID, payload []byte) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	sent := 0
+	for c := range h.clients[userID] {
+		select {
+		case c.Send <- payload:
+			sent++
+		default:
+			// skip if send buffer full
+		}
+	}
+	return sent
+}
+
+// NotifySessionRevoked implements service.SessionNotifier. It force-closes every connection
+// opened with the revoked session; the read loop then unregisters the client as usual.
+func (h *Hub) NotifySessionRevoked(userID, sessionID uuid.UUID) {
+	var targets []*Client
+	h.mu.RLock()
+	for c := range h.clients[userID] {
+		if c.SessionID == sessionID {
+			targets = append(targets, c)
+		}
+	}
 	h.mu.RUnlock()
+	for _, c := range targets {
+		msg := gorillawebsocket.FormatCloseMessage(CloseSessionRevoked, "session revoked")
+		_ = c.Conn.WriteControl(gorillawebsocket.CloseMessage, msg, time.Now().Add(time.Second))
+		_ = c.Conn.Close()
+	}
+	if len(targets) > 0 {
+		log.Printf("[Hub] session revoked: user_id=%s session_id=%s closed=%d", userID, sessionID, len(targets))
+	}
 }
 
-// Register adds a client to the hub.
+// Register adds a client to the hub. The user's first connection adds them to the index of
+// their channels. The user is online before the channel list is loaded, so joins and leaves
+// committed meanwhile are applied by notifyChannelEvent and recorded; they win over the list.
 func (h *Hub) Register(client *Client) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	if h.clients[client.UserID] == nil {
+	first := h.clients[client.UserID] == nil
+	var pending *pendingChannels
+	if first {
 		h.clients[client.UserID] = make(map[*Client]struct{})
+		pending = &pendingChannels{joined: make(map[uuid.UUID]bool)}
+		h.registering[client.UserID] = pending
 	}
 	h.clients[client.UserID][client] = struct{}{}
+	h.mu.Unlock()
+	if !first {
+		return
+	}
+
+	channelIDs, err := h.convRepo.ListIDsForUser(client.UserID, model.ConversationTypeChannel)
+	if err != nil {
+		log.Printf("[Hub] channel subscriptions lookup failed: user_id=%s err=%v", client.UserID, err)
+	}
+	for _, id := range channelIDs {
+		h.rememberKind(id, true)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.registering[client.UserID] != pending {
+		// The user disconnected (and maybe reconnected) meanwhile.
+		return
+	}
+	delete(h.registering, client.UserID)
+	for _, id := range channelIDs {
+		if joined, seen := pending.joined[id]; !seen || joined {
+			h.subscribe(id, client.UserID)
+		}
+	}
 }
 
 // Unregister removes a client from the hub.
@@ -105,6 +385,10 @@ func (h *Hub) Unregister(client *Client) {
 		delete(conns, client)
 		if len(conns) == 0 {
 			delete(h.clients, client.UserID)
+			delete(h.registering, client.UserID)
+			for id := range h.userChannels[client.UserID] {
+				h.unsubscribe(id, client.UserID)
+			}
 		}
 	}
 	close(client.Send)
@@ -112,8 +396,24 @@ func (h *Hub) Unregister(client *Client) {
 
 // WSMessage is the JSON envelope for WebSocket messages.
 type WSMessage struct {
-	Type    string        `json:"type"`
-	Message *model.Message `json:"message,omitempty"`
+	Type      string                   `json:"type"`
+	Message   *model.Message           `json:"message,omitempty"`
+	Ephemeral *model.EphemeralMessage  `json:"ephemeral,omitempty"`
+	Command   *model.CommandInvocation `json:"command,omitempty"`
+	// Conversation events (see NotifyConversationEvent).
+	ConversationID string                 `json:"conversation_id,omitempty"`
+	ActorID        string                 `json:"actor_id,omitempty"`
+	Data           map[string]interface{} `json:"data,omitempty"`
+	// Error is set on "error" frames, sent when a client's send_message is refused.
+	Error *WSError `json:"error,omitempty"`
+}
+
+// WSError describes why a client message was refused.
+type WSError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// RetryAfter is the number of seconds before sending may succeed (rate limits, slow mode, mutes).
+	RetryAfter int `json:"retry_after,omitempty"`
 }
 
 // WSClientMessage is the JSON format for client-to-server messages.
@@ -0,0 +1,19 @@
+-- Migration: 000003_user_sessions
+-- Description: Add per-device login sessions bound to access/refresh tokens
+-- Created: 2026-10-19
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    session_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    device_name VARCHAR(100),
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_used_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user
+    ON user_sessions(user_id, last_used_at DESC)
+    WHERE revoked_at IS NULL;
@@ -0,0 +1,20 @@
+-- Migration: 000004_user_tokens
+-- Description: Email verification state and single-use tokens for email verification / password reset
+-- Created: 2026-10-19
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+-- Only the SHA-256 of a token is stored. The raw token exists only in the email sent to the user.
+CREATE TABLE IF NOT EXISTS user_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user
+    ON user_tokens(user_id, purpose)
+    WHERE used_at IS NULL;
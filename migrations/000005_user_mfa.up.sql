@@ -0,0 +1,25 @@
+-- Migration: 000005_user_mfa
+-- Description: TOTP two-factor authentication secrets and hashed recovery codes
+-- Created: 2026-10-19
+
+-- confirmed_at stays NULL until the user proved the authenticator works (2FA is off until then).
+-- last_used_step is the last accepted RFC 6238 time step, so a code cannot be replayed.
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id UUID PRIMARY KEY,
+    totp_secret VARCHAR(64) NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    code_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    used_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_recovery_codes_user_hash
+    ON user_recovery_codes(user_id, code_hash);
@@ -0,0 +1,19 @@
+-- Migration: 000006_user_identities
+-- Description: External OpenID Connect identities linked to local users
+-- Created: 2026-10-19
+
+-- (provider, subject) identifies an account at the identity provider. Provider is the configured
+-- provider name, subject the ID token "sub" claim (stable per provider and user).
+CREATE TABLE IF NOT EXISTS user_identities (
+    identity_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_login_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_provider_subject
+    ON user_identities(provider, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_bot_api_keys_bot_id;
+DROP INDEX IF EXISTS idx_bot_api_keys_key_hash;
+DROP TABLE IF EXISTS bot_api_keys;
+DROP INDEX IF EXISTS idx_users_bot_owner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS bot_owner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS is_bot;
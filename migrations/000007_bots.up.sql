@@ -0,0 +1,26 @@
+-- Migration: 000007_bots
+-- Description: Bot users owned by human users and their scoped API keys
+-- Created: 2026-10-19
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bot_owner_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_users_bot_owner_id ON users(bot_owner_id) WHERE bot_owner_id IS NOT NULL;
+
+-- Only the SHA-256 of a key is stored. Prefix is the public part of the key shown in listings.
+-- Scopes is a comma-separated list (e.g. messages:write,ws).
+CREATE TABLE IF NOT EXISTS bot_api_keys (
+    key_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bot_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bot_api_keys_key_hash ON bot_api_keys(key_hash);
+CREATE INDEX IF NOT EXISTS idx_bot_api_keys_bot_id ON bot_api_keys(bot_id);
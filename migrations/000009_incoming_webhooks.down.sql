@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_incoming_webhooks_conversation_id;
+DROP INDEX IF EXISTS idx_incoming_webhooks_token_hash;
+DROP TABLE IF EXISTS incoming_webhooks;
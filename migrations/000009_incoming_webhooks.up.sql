@@ -0,0 +1,21 @@
+-- Migration: 000009_incoming_webhooks
+-- Description: Incoming webhook tokens that post messages into a conversation as a bot
+-- Created: 2026-10-19
+
+-- Only the SHA-256 of a token is stored. Prefix is the public start of the token shown in
+-- listings. Revoked rows are kept for the audit trail.
+CREATE TABLE IF NOT EXISTS incoming_webhooks (
+    hook_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL,
+    bot_id UUID NOT NULL,
+    created_by UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_incoming_webhooks_token_hash ON incoming_webhooks(token_hash);
+CREATE INDEX IF NOT EXISTS idx_incoming_webhooks_conversation_id ON incoming_webhooks(conversation_id);
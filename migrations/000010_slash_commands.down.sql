@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_bot_commands_bot_id;
+DROP TABLE IF EXISTS bot_commands;
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS muted_until;
+ALTER TABLE conversations DROP COLUMN IF EXISTS topic;
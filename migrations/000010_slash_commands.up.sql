@@ -0,0 +1,18 @@
+-- Migration: 000010_slash_commands
+-- Description: Group topics, per-participant mute and slash commands registered by bots
+-- Created: 2026-10-19
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS topic TEXT;
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS muted_until TIMESTAMP;
+
+-- Command names are unique per conversation. Built-in names are reserved by the service.
+CREATE TABLE IF NOT EXISTS bot_commands (
+    conversation_id UUID NOT NULL,
+    name VARCHAR(32) NOT NULL,
+    bot_id UUID NOT NULL,
+    description VARCHAR(200) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (conversation_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bot_commands_bot_id ON bot_commands(bot_id);
@@ -0,0 +1,13 @@
+-- Migration: 000011_message_mentions
+-- Description: Users mentioned by each message, for mention-aware unread counts
+-- Created: 2026-10-19
+
+-- One row per mentioned user. @all is expanded to the group's participants at send time.
+CREATE TABLE IF NOT EXISTS message_mentions (
+    message_id BIGINT NOT NULL,
+    user_id UUID NOT NULL,
+    conversation_id UUID NOT NULL,
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_mentions_user_conv ON message_mentions(user_id, conversation_id, message_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS pinned_messages;
+ALTER TABLE conversations DROP COLUMN IF EXISTS announcement_at;
+ALTER TABLE conversations DROP COLUMN IF EXISTS announcement_by;
+ALTER TABLE conversations DROP COLUMN IF EXISTS announcement;
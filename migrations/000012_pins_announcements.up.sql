@@ -0,0 +1,15 @@
+-- Migration: 000012_pins_announcements
+-- Description: Pinned messages and group announcements
+-- Created: 2026-10-19
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS announcement TEXT;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS announcement_by UUID;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS announcement_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS pinned_messages (
+    conversation_id UUID NOT NULL,
+    message_id BIGINT NOT NULL,
+    pinned_by UUID NOT NULL,
+    pinned_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (conversation_id, message_id)
+);
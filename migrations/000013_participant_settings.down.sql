@@ -0,0 +1,3 @@
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS hidden_until_message_id;
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS archived;
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS pin_order;
@@ -0,0 +1,7 @@
+-- Migration: 000013_participant_settings
+-- Description: Per-user conversation settings (pin to top, archive, hide until a new message)
+-- Created: 2026-10-19
+
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS pin_order INT NOT NULL DEFAULT 0;
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS hidden_until_message_id BIGINT;
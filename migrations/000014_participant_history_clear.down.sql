@@ -0,0 +1 @@
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS cleared_up_to_message_id;
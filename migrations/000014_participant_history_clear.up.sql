@@ -0,0 +1,5 @@
+-- Migration: 000014_participant_history_clear
+-- Description: Per-participant history clearing (delete a conversation for one user only)
+-- Created: 2026-10-19
+
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS cleared_up_to_message_id BIGINT;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS conversation_join_requests;
+DROP TABLE IF EXISTS conversation_invites;
+ALTER TABLE conversations DROP COLUMN IF EXISTS join_approval;
@@ -0,0 +1,34 @@
+-- Migration: 000015_invites
+-- Description: Group invite links, join approval mode and join requests
+-- Created: 2026-10-19
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS join_approval BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS conversation_invites (
+    code VARCHAR(32) PRIMARY KEY,
+    conversation_id UUID NOT NULL,
+    created_by UUID NOT NULL,
+    expires_at TIMESTAMP,
+    max_uses INT NOT NULL DEFAULT 0,
+    uses INT NOT NULL DEFAULT 0,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_invites_conversation_id ON conversation_invites(conversation_id);
+
+CREATE TABLE IF NOT EXISTS conversation_join_requests (
+    request_id BIGSERIAL PRIMARY KEY,
+    conversation_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    invite_code VARCHAR(32) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    decided_by UUID,
+    decided_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_join_requests_conversation_status ON conversation_join_requests(conversation_id, status);
+
+-- At most one pending request per user and group
+CREATE UNIQUE INDEX IF NOT EXISTS idx_join_requests_pending_user ON conversation_join_requests(conversation_id, user_id) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS silenced_until;
+ALTER TABLE conversations DROP COLUMN IF EXISTS slow_mode_seconds;
+ALTER TABLE conversations DROP COLUMN IF EXISTS admin_only_send;
@@ -0,0 +1,7 @@
+-- Migration: 000016_group_moderation
+-- Description: Group moderation (admin-only send, slow mode, per-member mute)
+-- Created: 2026-10-19
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS admin_only_send BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS slow_mode_seconds INT NOT NULL DEFAULT 0;
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS silenced_until TIMESTAMP;
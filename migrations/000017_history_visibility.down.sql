@@ -0,0 +1,2 @@
+ALTER TABLE conversations DROP COLUMN IF EXISTS history_days;
+ALTER TABLE conversations DROP COLUMN IF EXISTS history_visibility;
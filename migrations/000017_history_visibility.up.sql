@@ -0,0 +1,6 @@
+-- Migration: 000017_history_visibility
+-- Description: Per-group visibility of history from before a member joined
+-- Created: 2026-10-19
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS history_visibility VARCHAR(16) NOT NULL DEFAULT 'all';
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS history_days INT NOT NULL DEFAULT 0;
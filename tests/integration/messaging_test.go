@@ -46,12 +46,13 @@ func setupMessagingRouter(t *testing.T) (http.Handler, string) {
 	convRepo := repository.NewConversationRepository(db)
 	contactRepo := repository.NewContactRepository(db)
 	msgRepo := repository.NewMessageRepository(db)
-	authSvc := service.NewAuthService(userRepo, jwtMgr)
-	convSvc := service.NewConversationService(convRepo, userRepo, msgRepo)
+	sessionRepo := repository.NewSessionRepository(db)
+	convSvc := service.NewConversationService(convRepo, userRepo, msgRepo, repository.NewPinnedMessageRepository(db), repository.NewInviteRepository(db), nil, nil, nil, service.ConversationOptions{})
 	contactSvc := service.NewContactService(contactRepo, userRepo, nil)
 	hub := websocket.NewHub(convRepo, nil)
+	authSvc := service.NewAuthService(userRepo, sessionRepo, repository.NewUserTokenRepository(db), repository.NewMFARepository(db), jwtMgr, service.AuthOptions{SessionNotifier: hub})
 	msgSvc := service.NewMessageService(msgRepo, convSvc, hub)
-	router := api.SetupRouter(cfg, db, authSvc, jwtMgr, convSvc, contactSvc, msgSvc, hub, nil, nil, nil)
+	router := api.SetupRouter(cfg, db, authSvc, jwtMgr, convSvc, contactSvc, msgSvc, hub, nil, nil, nil, api.RouterDeps{})
 	router.Use(middleware.CORSMiddleware(cfg))
 	router.Use(middleware.LoggerMiddlewareSimple())
 	router.Use(middleware.ErrorHandlerMiddleware())
@@ -109,12 +110,13 @@ func setupMessagingRouterWithRedis(t *testing.T) (http.Handler, string, *minired
 	convRepo := repository.NewConversationRepository(db)
 	contactRepo := repository.NewContactRepository(db)
 	msgRepo := repository.NewMessageRepository(db)
-	authSvc := service.NewAuthService(userRepo, jwtMgr)
-	convSvc := service.NewConversationService(convRepo, userRepo, msgRepo)
+	sessionRepo := repository.NewSessionRepository(db)
+	convSvc := service.NewConversationService(convRepo, userRepo, msgRepo, repository.NewPinnedMessageRepository(db), repository.NewInviteRepository(db), nil, nil, nil, service.ConversationOptions{})
 	contactSvc := service.NewContactService(contactRepo, userRepo, presenceStore)
 	hub := websocket.NewHub(convRepo, offlineQueue)
+	authSvc := service.NewAuthService(userRepo, sessionRepo, repository.NewUserTokenRepository(db), repository.NewMFARepository(db), jwtMgr, service.AuthOptions{SessionNotifier: hub})
 	msgSvc := service.NewMessageService(msgRepo, convSvc, hub)
-	router := api.SetupRouter(cfg, db, authSvc, jwtMgr, convSvc, contactSvc, msgSvc, hub, rdb, offlineQueue, presenceStore)
+	router := api.SetupRouter(cfg, db, authSvc, jwtMgr, convSvc, contactSvc, msgSvc, hub, rdb, offlineQueue, presenceStore, api.RouterDeps{})
 	router.Use(middleware.CORSMiddleware(cfg))
 	router.Use(middleware.LoggerMiddlewareSimple())
 	router.Use(middleware.ErrorHandlerMiddleware())